@@ -8,6 +8,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first character of the node
+	End() token.Position // position right after the last character of the node
 }
 
 type Statement interface {
@@ -25,6 +27,18 @@ type Program struct {
 }
 
 func (p *Program) TokenLiteral() string { return "" }
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[0].Pos()
+}
+func (p *Program) End() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[len(p.Statements)-1].End()
+}
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -45,19 +59,36 @@ func (p *Program) String() string {
 type LetStmt struct {
 	Token token.Token
 	Name  *IdentifierExpr
+	Type  *TypeAnnotation // nil when the variable is not annotated
 	Value Expression
 }
 
 func (ls *LetStmt) statementNode()       {}
 func (ls *LetStmt) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStmt) Pos() token.Position  { return ls.Token.Start }
+func (ls *LetStmt) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	if ls.Type != nil {
+		return ls.Type.End()
+	}
+	return ls.Name.End()
+}
 func (ls *LetStmt) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral())
 	out.WriteString(" ")
 	out.WriteString(ls.Name.String())
-	out.WriteString(" = ")
-	out.WriteString(ls.Value.String())
+	if ls.Type != nil {
+		out.WriteString(": ")
+		out.WriteString(ls.Type.String())
+	}
+	if ls.Value != nil {
+		out.WriteString(" = ")
+		out.WriteString(ls.Value.String())
+	}
 	out.WriteString(";")
 
 	return out.String()
@@ -70,6 +101,13 @@ type ReturnStmt struct {
 
 func (r *ReturnStmt) statementNode()       {}
 func (r *ReturnStmt) TokenLiteral() string { return r.Token.Literal }
+func (r *ReturnStmt) Pos() token.Position  { return r.Token.Start }
+func (r *ReturnStmt) End() token.Position {
+	if r.Value != nil {
+		return r.Value.End()
+	}
+	return r.Token.End
+}
 func (r *ReturnStmt) String() string {
 	var out bytes.Buffer
 
@@ -88,6 +126,13 @@ type ExpressionStmt struct {
 
 func (e *ExpressionStmt) statementNode()       {}
 func (e *ExpressionStmt) TokenLiteral() string { return e.Token.Literal }
+func (e *ExpressionStmt) Pos() token.Position  { return e.Token.Start }
+func (e *ExpressionStmt) End() token.Position {
+	if e.Expression != nil {
+		return e.Expression.End()
+	}
+	return e.Token.End
+}
 func (e *ExpressionStmt) String() string {
 	var out bytes.Buffer
 
@@ -104,10 +149,13 @@ func (e *ExpressionStmt) String() string {
 type BlockStmt struct {
 	Token      token.Token // '{'
 	Statements []Statement
+	Rbrace     token.Position
 }
 
 func (b *BlockStmt) statementNode()       {}
 func (b *BlockStmt) TokenLiteral() string { return b.Token.Literal }
+func (b *BlockStmt) Pos() token.Position  { return b.Token.Start }
+func (b *BlockStmt) End() token.Position  { return closingEnd(b.Rbrace, 1, b.Token.End) }
 func (b *BlockStmt) String() string {
 	var out bytes.Buffer
 	out.WriteString("{ ")
@@ -124,10 +172,13 @@ type ClassStmt struct {
 	Name       *IdentifierExpr
 	Superclass *IdentifierExpr
 	Methods    []*LetStmt
+	Rbrace     token.Position
 }
 
 func (c *ClassStmt) statementNode()       {}
 func (c *ClassStmt) TokenLiteral() string { return c.Token.Literal }
+func (c *ClassStmt) Pos() token.Position  { return c.Token.Start }
+func (c *ClassStmt) End() token.Position  { return closingEnd(c.Rbrace, 1, c.Token.End) }
 func (c *ClassStmt) String() string {
 	var out bytes.Buffer
 
@@ -155,6 +206,187 @@ func (c *ClassStmt) String() string {
 	return out.String()
 }
 
+type WhileStmt struct {
+	Token     token.Token // while
+	Condition Expression
+	Body      Statement
+}
+
+func (w *WhileStmt) statementNode()       {}
+func (w *WhileStmt) TokenLiteral() string { return w.Token.Literal }
+func (w *WhileStmt) Pos() token.Position  { return w.Token.Start }
+func (w *WhileStmt) End() token.Position  { return endOf(w.Body, w.Token.End) }
+func (w *WhileStmt) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while (")
+	out.WriteString(w.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(w.Body.String())
+
+	return out.String()
+}
+
+// ImportStmt loads module from Path and binds it to Name.
+type ImportStmt struct {
+	Token token.Token // import
+	Path  *StringLiteralExpr
+	Name  *IdentifierExpr
+}
+
+func (i *ImportStmt) statementNode()       {}
+func (i *ImportStmt) TokenLiteral() string { return i.Token.Literal }
+func (i *ImportStmt) Pos() token.Position  { return i.Token.Start }
+func (i *ImportStmt) End() token.Position  { return i.Name.End() }
+func (i *ImportStmt) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("import ")
+	out.WriteString(i.Path.String())
+	out.WriteString(" as ")
+	out.WriteString(i.Name.String())
+	out.WriteString(";")
+
+	return out.String()
+}
+
+type ThrowStmt struct {
+	Token token.Token // throw
+	Value Expression
+}
+
+func (t *ThrowStmt) statementNode()       {}
+func (t *ThrowStmt) TokenLiteral() string { return t.Token.Literal }
+func (t *ThrowStmt) Pos() token.Position  { return t.Token.Start }
+func (t *ThrowStmt) End() token.Position  { return t.Value.End() }
+func (t *ThrowStmt) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(t.TokenLiteral())
+	out.WriteString(" ")
+	out.WriteString(t.Value.String())
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// TryStmt has at least one of Catch and Finally, Param is set together with Catch.
+type TryStmt struct {
+	Token   token.Token // try
+	Body    *BlockStmt
+	Param   *IdentifierExpr
+	Catch   *BlockStmt
+	Finally *BlockStmt
+}
+
+func (t *TryStmt) statementNode()       {}
+func (t *TryStmt) TokenLiteral() string { return t.Token.Literal }
+func (t *TryStmt) Pos() token.Position  { return t.Token.Start }
+func (t *TryStmt) End() token.Position {
+	if t.Finally != nil {
+		return t.Finally.End()
+	}
+	return t.Catch.End()
+}
+func (t *TryStmt) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(t.Body.String())
+	if t.Catch != nil {
+		out.WriteString(" catch (")
+		out.WriteString(t.Param.String())
+		out.WriteString(") ")
+		out.WriteString(t.Catch.String())
+	}
+	if t.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(t.Finally.String())
+	}
+
+	return out.String()
+}
+
+// ForStmt is a C-style loop, any of Init, Condition and Update may be nil.
+type ForStmt struct {
+	Token     token.Token // for
+	Init      Statement
+	Condition Expression
+	Update    Expression
+	Body      Statement
+}
+
+func (f *ForStmt) statementNode()       {}
+func (f *ForStmt) TokenLiteral() string { return f.Token.Literal }
+func (f *ForStmt) Pos() token.Position  { return f.Token.Start }
+func (f *ForStmt) End() token.Position  { return endOf(f.Body, f.Token.End) }
+func (f *ForStmt) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if f.Init != nil {
+		out.WriteString(f.Init.String())
+	} else {
+		out.WriteString(";")
+	}
+	out.WriteString(" ")
+	if f.Condition != nil {
+		out.WriteString(f.Condition.String())
+	}
+	out.WriteString("; ")
+	if f.Update != nil {
+		out.WriteString(f.Update.String())
+	}
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+type ForInStmt struct {
+	Token    token.Token // for
+	Variable *IdentifierExpr
+	Iterable Expression
+	Body     Statement
+}
+
+func (f *ForInStmt) statementNode()       {}
+func (f *ForInStmt) TokenLiteral() string { return f.Token.Literal }
+func (f *ForInStmt) Pos() token.Position  { return f.Token.Start }
+func (f *ForInStmt) End() token.Position  { return endOf(f.Body, f.Token.End) }
+func (f *ForInStmt) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(f.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(f.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+type BreakStmt struct {
+	Token token.Token
+}
+
+func (b *BreakStmt) statementNode()       {}
+func (b *BreakStmt) TokenLiteral() string { return b.Token.Literal }
+func (b *BreakStmt) Pos() token.Position  { return b.Token.Start }
+func (b *BreakStmt) End() token.Position  { return b.Token.End }
+func (b *BreakStmt) String() string       { return b.TokenLiteral() + ";" }
+
+type ContinueStmt struct {
+	Token token.Token
+}
+
+func (c *ContinueStmt) statementNode()       {}
+func (c *ContinueStmt) TokenLiteral() string { return c.Token.Literal }
+func (c *ContinueStmt) Pos() token.Position  { return c.Token.Start }
+func (c *ContinueStmt) End() token.Position  { return c.Token.End }
+func (c *ContinueStmt) String() string       { return c.TokenLiteral() + ";" }
+
 // Expressions
 
 type IdentifierExpr struct {
@@ -164,14 +396,30 @@ type IdentifierExpr struct {
 
 func (i *IdentifierExpr) expressionNode()      {}
 func (i *IdentifierExpr) TokenLiteral() string { return i.Token.Literal }
+func (i *IdentifierExpr) Pos() token.Position  { return i.Token.Start }
+func (i *IdentifierExpr) End() token.Position  { return i.Token.End }
 func (i *IdentifierExpr) String() string       { return i.Value }
 
+// TypeAnnotation is a type name following ':' after variable, parameter
+// or parameters list of function.
+type TypeAnnotation struct {
+	Token token.Token
+	Name  string
+}
+
+func (t *TypeAnnotation) TokenLiteral() string { return t.Token.Literal }
+func (t *TypeAnnotation) Pos() token.Position  { return t.Token.Start }
+func (t *TypeAnnotation) End() token.Position  { return t.Token.End }
+func (t *TypeAnnotation) String() string       { return t.Name }
+
 type NullExpr struct {
 	Token token.Token
 }
 
 func (n *NullExpr) expressionNode()      {}
 func (n *NullExpr) TokenLiteral() string { return n.Token.Literal }
+func (n *NullExpr) Pos() token.Position  { return n.Token.Start }
+func (n *NullExpr) End() token.Position  { return n.Token.End }
 func (n *NullExpr) String() string       { return n.TokenLiteral() }
 
 type IntLiteralExpr struct {
@@ -181,8 +429,21 @@ type IntLiteralExpr struct {
 
 func (i *IntLiteralExpr) expressionNode()      {}
 func (i *IntLiteralExpr) TokenLiteral() string { return i.Token.Literal }
+func (i *IntLiteralExpr) Pos() token.Position  { return i.Token.Start }
+func (i *IntLiteralExpr) End() token.Position  { return i.Token.End }
 func (i *IntLiteralExpr) String() string       { return i.TokenLiteral() }
 
+type FloatLiteralExpr struct {
+	Token token.Token
+	Value float64
+}
+
+func (f *FloatLiteralExpr) expressionNode()      {}
+func (f *FloatLiteralExpr) TokenLiteral() string { return f.Token.Literal }
+func (f *FloatLiteralExpr) Pos() token.Position  { return f.Token.Start }
+func (f *FloatLiteralExpr) End() token.Position  { return f.Token.End }
+func (f *FloatLiteralExpr) String() string       { return f.TokenLiteral() }
+
 type BoolLiteralExpr struct {
 	Token token.Token
 	Value bool
@@ -190,6 +451,8 @@ type BoolLiteralExpr struct {
 
 func (b *BoolLiteralExpr) expressionNode()      {}
 func (b *BoolLiteralExpr) TokenLiteral() string { return b.Token.Literal }
+func (b *BoolLiteralExpr) Pos() token.Position  { return b.Token.Start }
+func (b *BoolLiteralExpr) End() token.Position  { return b.Token.End }
 func (b *BoolLiteralExpr) String() string       { return b.TokenLiteral() }
 
 type StringLiteralExpr struct {
@@ -199,15 +462,58 @@ type StringLiteralExpr struct {
 
 func (s *StringLiteralExpr) expressionNode()      {}
 func (s *StringLiteralExpr) TokenLiteral() string { return s.Token.Literal }
-func (s *StringLiteralExpr) String() string       { return `"` + s.TokenLiteral() + `"` }
+func (s *StringLiteralExpr) Pos() token.Position  { return s.Token.Start }
+func (s *StringLiteralExpr) End() token.Position  { return s.Token.End }
+func (s *StringLiteralExpr) String() string {
+	if s.Token.Type == token.RAW_STRING {
+		return "`" + s.TokenLiteral() + "`"
+	}
+	return `"` + s.TokenLiteral() + `"`
+}
+
+// TemplateExpr is a string with embedded expressions,
+// Texts surround Exprs so there is always one text more.
+type TemplateExpr struct {
+	Token token.Token // head of the template
+	Texts []*StringLiteralExpr
+	Exprs []Expression
+}
+
+func (t *TemplateExpr) expressionNode()      {}
+func (t *TemplateExpr) TokenLiteral() string { return t.Token.Literal }
+func (t *TemplateExpr) Pos() token.Position  { return t.Token.Start }
+func (t *TemplateExpr) End() token.Position  { return t.Texts[len(t.Texts)-1].End() }
+func (t *TemplateExpr) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`"`)
+	for i, text := range t.Texts {
+		if i > 0 {
+			out.WriteString("}")
+		}
+		out.WriteString(text.TokenLiteral())
+		if i < len(t.Exprs) {
+			out.WriteString("${")
+			out.WriteString(t.Exprs[i].String())
+		}
+	}
+	out.WriteString(`"`)
+
+	return out.String()
+}
 
 type ArrayLiteralExpr struct {
 	Token    token.Token
 	Elements []Expression
+	Rbracket token.Position
 }
 
 func (a *ArrayLiteralExpr) expressionNode()      {}
 func (a *ArrayLiteralExpr) TokenLiteral() string { return a.Token.Literal }
+func (a *ArrayLiteralExpr) Pos() token.Position  { return a.Token.Start }
+func (a *ArrayLiteralExpr) End() token.Position {
+	return closingEnd(a.Rbracket, 1, a.Token.End)
+}
 func (a *ArrayLiteralExpr) String() string {
 	var out bytes.Buffer
 
@@ -227,18 +533,29 @@ func (a *ArrayLiteralExpr) String() string {
 }
 
 type HashLiteralExpr struct {
-	Token token.Token
-	Pairs map[Expression]Expression
+	Token  token.Token
+	Pairs  []HashPair
+	Rbrace token.Position // '|}'
+}
+
+// HashPair is a key-value pair of hash literal.
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (h *HashLiteralExpr) expressionNode()      {}
 func (h *HashLiteralExpr) TokenLiteral() string { return h.Token.Literal }
+func (h *HashLiteralExpr) Pos() token.Position  { return h.Token.Start }
+func (h *HashLiteralExpr) End() token.Position {
+	return closingEnd(h.Rbrace, 2, h.Token.End)
+}
 func (h *HashLiteralExpr) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for k, v := range h.Pairs {
-		pairs = append(pairs, k.String()+": "+v.String())
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
 	}
 
 	out.WriteString("{| ")
@@ -264,6 +581,8 @@ type PrefixExpr struct {
 
 func (p *PrefixExpr) expressionNode()      {}
 func (p *PrefixExpr) TokenLiteral() string { return p.Token.Literal }
+func (p *PrefixExpr) Pos() token.Position  { return p.Token.Start }
+func (p *PrefixExpr) End() token.Position  { return endOf(p.Right, p.Token.End) }
 func (p *PrefixExpr) String() string {
 	var out bytes.Buffer
 
@@ -284,6 +603,8 @@ type InfixExpr struct {
 
 func (p *InfixExpr) expressionNode()      {}
 func (p *InfixExpr) TokenLiteral() string { return p.Token.Literal }
+func (p *InfixExpr) Pos() token.Position  { return posOf(p.Left, p.Token.Start) }
+func (p *InfixExpr) End() token.Position  { return endOf(p.Right, p.Token.End) }
 func (p *InfixExpr) String() string {
 	var out bytes.Buffer
 
@@ -297,13 +618,15 @@ func (p *InfixExpr) String() string {
 }
 
 type AssignExpr struct {
-	Token      token.Token // =
+	Token      token.Token // '=' or compound assignment like '+='
 	Identifier *IdentifierExpr
 	Expression Expression
 }
 
 func (a *AssignExpr) expressionNode()      {}
 func (a *AssignExpr) TokenLiteral() string { return a.Token.Literal }
+func (a *AssignExpr) Pos() token.Position  { return a.Identifier.Pos() }
+func (a *AssignExpr) End() token.Position  { return endOf(a.Expression, a.Token.End) }
 func (a *AssignExpr) String() string {
 	var out bytes.Buffer
 	out.WriteString(a.Identifier.String())
@@ -323,6 +646,13 @@ type IfExpr struct {
 
 func (i *IfExpr) expressionNode()      {}
 func (i *IfExpr) TokenLiteral() string { return i.Token.Literal }
+func (i *IfExpr) Pos() token.Position  { return i.Token.Start }
+func (i *IfExpr) End() token.Position {
+	if i.Else != nil {
+		return i.Else.End()
+	}
+	return endOf(i.Then, i.Token.End)
+}
 func (i *IfExpr) String() string {
 	var out bytes.Buffer
 	out.WriteString("if ")
@@ -340,11 +670,30 @@ func (i *IfExpr) String() string {
 type FunctionExpr struct {
 	Token      token.Token
 	Parameters []*IdentifierExpr
-	Body       *BlockStmt
+	// ParameterTypes parallels Parameters with nil for parameters
+	// without annotation, it is nil when none of them is annotated.
+	ParameterTypes []*TypeAnnotation
+	ReturnType     *TypeAnnotation
+	Body           *BlockStmt
+}
+
+// ParameterType returns annotation of i-th parameter or nil.
+func (f *FunctionExpr) ParameterType(i int) *TypeAnnotation {
+	if i < len(f.ParameterTypes) {
+		return f.ParameterTypes[i]
+	}
+	return nil
 }
 
 func (f *FunctionExpr) expressionNode()      {}
 func (f *FunctionExpr) TokenLiteral() string { return f.Token.Literal }
+func (f *FunctionExpr) Pos() token.Position  { return f.Token.Start }
+func (f *FunctionExpr) End() token.Position {
+	if f.Body != nil {
+		return f.Body.End()
+	}
+	return f.Token.End
+}
 func (f *FunctionExpr) String() string {
 	var out bytes.Buffer
 
@@ -352,11 +701,20 @@ func (f *FunctionExpr) String() string {
 	last := len(f.Parameters) - 1
 	for i, ident := range f.Parameters {
 		out.WriteString(ident.Value)
+		if t := f.ParameterType(i); t != nil {
+			out.WriteString(": ")
+			out.WriteString(t.String())
+		}
 		if i != last {
 			out.WriteString(", ")
 		}
 	}
-	out.WriteString(") ")
+	out.WriteString(")")
+	if f.ReturnType != nil {
+		out.WriteString(": ")
+		out.WriteString(f.ReturnType.String())
+	}
+	out.WriteString(" ")
 
 	out.WriteString(f.Body.String())
 
@@ -367,10 +725,13 @@ type CallExpr struct {
 	Token     token.Token // '('
 	Function  Expression  // IdentifierExpr || FunctionExpr
 	Arguments []Expression
+	Rparen    token.Position
 }
 
 func (c *CallExpr) expressionNode()      {}
 func (c *CallExpr) TokenLiteral() string { return c.Token.Literal }
+func (c *CallExpr) Pos() token.Position  { return posOf(c.Function, c.Token.Start) }
+func (c *CallExpr) End() token.Position  { return closingEnd(c.Rparen, 1, c.Token.End) }
 func (c *CallExpr) String() string {
 	var out bytes.Buffer
 
@@ -390,17 +751,67 @@ func (c *CallExpr) String() string {
 }
 
 type IndexExpr struct {
-	Token token.Token // '['
-	Left  Expression
-	Index Expression
+	Token    token.Token // '['
+	Left     Expression
+	Index    Expression
+	Rbracket token.Position
 }
 
 func (i *IndexExpr) expressionNode()      {}
 func (i *IndexExpr) TokenLiteral() string { return i.Token.Literal }
+func (i *IndexExpr) Pos() token.Position  { return posOf(i.Left, i.Token.Start) }
+func (i *IndexExpr) End() token.Position  { return closingEnd(i.Rbracket, 1, i.Token.End) }
 func (i *IndexExpr) String() string {
 	return "(" + i.Left.String() + "[" + i.Index.String() + "])"
 }
 
+// IndexSetExpr is assignment to an element, left[Index] = Value.
+type IndexSetExpr struct {
+	Token token.Token // '=' or compound assignment like '+='
+	Left  Expression
+	Index Expression
+	Value Expression
+}
+
+func (i *IndexSetExpr) expressionNode()      {}
+func (i *IndexSetExpr) TokenLiteral() string { return i.Token.Literal }
+func (i *IndexSetExpr) Pos() token.Position  { return posOf(i.Left, i.Token.Start) }
+func (i *IndexSetExpr) End() token.Position  { return endOf(i.Value, i.Token.End) }
+func (i *IndexSetExpr) String() string {
+	return "(" + i.Left.String() + "[" + i.Index.String() + "] " + i.TokenLiteral() + " " + i.Value.String() + ")"
+}
+
+// SliceExpr is left[Low:High], missing bounds are nil.
+type SliceExpr struct {
+	Token    token.Token // '['
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Rbracket token.Position
+}
+
+func (s *SliceExpr) expressionNode()      {}
+func (s *SliceExpr) TokenLiteral() string { return s.Token.Literal }
+func (s *SliceExpr) Pos() token.Position  { return posOf(s.Left, s.Token.Start) }
+func (s *SliceExpr) End() token.Position  { return closingEnd(s.Rbracket, 1, s.Token.End) }
+func (s *SliceExpr) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(s.Left.String())
+	out.WriteString("[")
+	if s.Low != nil {
+		out.WriteString(s.Low.String())
+	}
+	out.WriteString(":")
+	if s.High != nil {
+		out.WriteString(s.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 type GetExpr struct {
 	Token      token.Token // '.'
 	Expression Expression
@@ -409,6 +820,13 @@ type GetExpr struct {
 
 func (g *GetExpr) expressionNode()      {}
 func (g *GetExpr) TokenLiteral() string { return g.Token.Literal }
+func (g *GetExpr) Pos() token.Position  { return posOf(g.Expression, g.Token.Start) }
+func (g *GetExpr) End() token.Position {
+	if g.Field != nil {
+		return g.Field.End()
+	}
+	return g.Token.End
+}
 func (g *GetExpr) String() string {
 	var out bytes.Buffer
 
@@ -422,7 +840,7 @@ func (g *GetExpr) String() string {
 }
 
 type SetExpr struct {
-	Token      token.Token // '.'
+	Token      token.Token // '=' or compound assignment like '+='
 	Expression Expression
 	Field      *IdentifierExpr
 	Value      Expression
@@ -430,6 +848,8 @@ type SetExpr struct {
 
 func (g *SetExpr) expressionNode()      {}
 func (g *SetExpr) TokenLiteral() string { return g.Token.Literal }
+func (g *SetExpr) Pos() token.Position  { return posOf(g.Expression, g.Token.Start) }
+func (g *SetExpr) End() token.Position  { return endOf(g.Value, g.Token.End) }
 func (g *SetExpr) String() string {
 	var out bytes.Buffer
 
@@ -437,7 +857,7 @@ func (g *SetExpr) String() string {
 	out.WriteString(g.Expression.String())
 	out.WriteString(".")
 	out.WriteString(g.Field.String())
-	out.WriteString(" = ")
+	out.WriteString(" " + g.TokenLiteral() + " ")
 	out.WriteString(g.Value.String())
 	out.WriteString(")")
 
@@ -450,6 +870,8 @@ type ThisExpr struct {
 
 func (t *ThisExpr) expressionNode()      {}
 func (t *ThisExpr) TokenLiteral() string { return t.Token.Literal }
+func (t *ThisExpr) Pos() token.Position  { return t.Token.Start }
+func (t *ThisExpr) End() token.Position  { return t.Token.End }
 func (t *ThisExpr) String() string       { return t.TokenLiteral() }
 
 type SuperExpr struct {
@@ -459,6 +881,43 @@ type SuperExpr struct {
 
 func (s *SuperExpr) expressionNode()      {}
 func (s *SuperExpr) TokenLiteral() string { return s.Token.Literal }
+func (s *SuperExpr) Pos() token.Position  { return s.Token.Start }
+func (s *SuperExpr) End() token.Position {
+	if s.Method != nil {
+		return s.Method.End()
+	}
+	return s.Token.End
+}
 func (s *SuperExpr) String() string {
 	return s.TokenLiteral() + "." + s.Method.String()
 }
+
+// Span returns source range covered by node.
+func Span(n Node) token.Span {
+	return token.Span{Start: n.Pos(), End: n.End()}
+}
+
+func posOf(n Node, fallback token.Position) token.Position {
+	if n == nil {
+		return fallback
+	}
+	return n.Pos()
+}
+
+func endOf(n Node, fallback token.Position) token.Position {
+	if n == nil {
+		return fallback
+	}
+	return n.End()
+}
+
+// closingEnd returns position right after closing delimiter of width bytes
+// which starts at pos, or fallback if delimiter position is unknown.
+func closingEnd(pos token.Position, width int, fallback token.Position) token.Position {
+	if !pos.IsValid() {
+		return fallback
+	}
+	pos.Offset += width
+	pos.Column += width
+	return pos
+}
@@ -510,32 +510,38 @@ func TestAstString(t *testing.T) {
 						Type:    token.LHASHBRACE,
 						Literal: "{|",
 					},
-					Pairs: map[ast.Expression]ast.Expression{
-						&ast.StringLiteralExpr{
-							Token: token.Token{
-								Type:    token.STRING,
-								Literal: "x",
+					Pairs: []ast.HashPair{
+						{
+							Key: &ast.StringLiteralExpr{
+								Token: token.Token{
+									Type:    token.STRING,
+									Literal: "x",
+								},
+								Value: "x",
 							},
-							Value: "x",
-						}: &ast.IntLiteralExpr{
-							Token: token.Token{
-								Type:    token.INT,
-								Literal: "10",
+							Value: &ast.IntLiteralExpr{
+								Token: token.Token{
+									Type:    token.INT,
+									Literal: "10",
+								},
+								Value: 10,
 							},
-							Value: 10,
 						},
-						&ast.StringLiteralExpr{
-							Token: token.Token{
-								Type:    token.STRING,
-								Literal: "y",
+						{
+							Key: &ast.StringLiteralExpr{
+								Token: token.Token{
+									Type:    token.STRING,
+									Literal: "y",
+								},
+								Value: "y",
 							},
-							Value: "y",
-						}: &ast.BoolLiteralExpr{
-							Token: token.Token{
-								Type:    token.TRUE,
-								Literal: "true",
+							Value: &ast.BoolLiteralExpr{
+								Token: token.Token{
+									Type:    token.TRUE,
+									Literal: "true",
+								},
+								Value: true,
 							},
-							Value: true,
 						},
 					},
 				},
@@ -584,3 +590,55 @@ class Hello < World {
 	}
 
 }
+
+func TestInspect(t *testing.T) {
+	// class A < B { fn m(x) { return x + 1; } }
+	ident := func(name string) *ast.IdentifierExpr {
+		return &ast.IdentifierExpr{Token: token.Token{Type: token.IDENTIFIER, Literal: name}, Value: name}
+	}
+	program := &ast.Program{
+		Statements: []ast.Statement{
+			&ast.ClassStmt{
+				Name:       ident("A"),
+				Superclass: ident("B"),
+				Methods: []*ast.LetStmt{{
+					Name: ident("m"),
+					Value: &ast.FunctionExpr{
+						Parameters: []*ast.IdentifierExpr{ident("x")},
+						Body: &ast.BlockStmt{
+							Statements: []ast.Statement{
+								&ast.ReturnStmt{Value: &ast.InfixExpr{
+									Left:     ident("x"),
+									Operator: "+",
+									Right:    &ast.IntLiteralExpr{Value: 1},
+								}},
+							},
+						},
+					},
+				}},
+			},
+			// incomplete node left by parser error
+			&ast.LetStmt{Name: ident("y")},
+		},
+	}
+
+	visited := []string{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.IdentifierExpr:
+			visited = append(visited, node.Value)
+		case *ast.FunctionExpr:
+			visited = append(visited, "fn")
+		case *ast.InfixExpr:
+			// skip operands
+			visited = append(visited, node.Operator)
+			return false
+		}
+		return true
+	})
+
+	want := "A B m fn x + y"
+	if got := strings.Join(visited, " "); got != want {
+		t.Errorf("Wrong visit order, got %q, want %q.", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+package ast
+
+import "reflect"
+
+// Inspect traverses AST in depth-first order, it calls f for node and,
+// when f returns true, for each of its children. Missing children
+// of incomplete nodes produced by parser on errors are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || reflect.ValueOf(node).IsNil() || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		inspectStatements(n.Statements, f)
+	case *LetStmt:
+		Inspect(n.Name, f)
+		Inspect(n.Type, f)
+		Inspect(n.Value, f)
+	case *ReturnStmt:
+		Inspect(n.Value, f)
+	case *ExpressionStmt:
+		Inspect(n.Expression, f)
+	case *BlockStmt:
+		inspectStatements(n.Statements, f)
+	case *ClassStmt:
+		Inspect(n.Name, f)
+		Inspect(n.Superclass, f)
+		for _, method := range n.Methods {
+			Inspect(method, f)
+		}
+	case *WhileStmt:
+		Inspect(n.Condition, f)
+		Inspect(n.Body, f)
+	case *ImportStmt:
+		Inspect(n.Path, f)
+		Inspect(n.Name, f)
+	case *ThrowStmt:
+		Inspect(n.Value, f)
+	case *TryStmt:
+		Inspect(n.Body, f)
+		Inspect(n.Param, f)
+		Inspect(n.Catch, f)
+		Inspect(n.Finally, f)
+	case *ForStmt:
+		Inspect(n.Init, f)
+		Inspect(n.Condition, f)
+		Inspect(n.Update, f)
+		Inspect(n.Body, f)
+	case *ForInStmt:
+		Inspect(n.Variable, f)
+		Inspect(n.Iterable, f)
+		Inspect(n.Body, f)
+	case *TemplateExpr:
+		for i, text := range n.Texts {
+			Inspect(text, f)
+			if i < len(n.Exprs) {
+				Inspect(n.Exprs[i], f)
+			}
+		}
+	case *ArrayLiteralExpr:
+		inspectExpressions(n.Elements, f)
+	case *HashLiteralExpr:
+		for _, pair := range n.Pairs {
+			Inspect(pair.Key, f)
+			Inspect(pair.Value, f)
+		}
+	case *PrefixExpr:
+		Inspect(n.Right, f)
+	case *InfixExpr:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *AssignExpr:
+		Inspect(n.Identifier, f)
+		Inspect(n.Expression, f)
+	case *IfExpr:
+		Inspect(n.Condition, f)
+		Inspect(n.Then, f)
+		Inspect(n.Else, f)
+	case *FunctionExpr:
+		for i, param := range n.Parameters {
+			Inspect(param, f)
+			Inspect(n.ParameterType(i), f)
+		}
+		Inspect(n.ReturnType, f)
+		Inspect(n.Body, f)
+	case *CallExpr:
+		Inspect(n.Function, f)
+		inspectExpressions(n.Arguments, f)
+	case *IndexExpr:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *IndexSetExpr:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+		Inspect(n.Value, f)
+	case *SliceExpr:
+		Inspect(n.Left, f)
+		Inspect(n.Low, f)
+		Inspect(n.High, f)
+	case *GetExpr:
+		Inspect(n.Expression, f)
+		Inspect(n.Field, f)
+	case *SetExpr:
+		Inspect(n.Expression, f)
+		Inspect(n.Field, f)
+		Inspect(n.Value, f)
+	case *SuperExpr:
+		Inspect(n.Method, f)
+	}
+}
+
+func inspectStatements(statements []Statement, f func(Node) bool) {
+	for _, stmt := range statements {
+		Inspect(stmt, f)
+	}
+}
+
+func inspectExpressions(expressions []Expression, f func(Node) bool) {
+	for _, expr := range expressions {
+		Inspect(expr, f)
+	}
+}
@@ -0,0 +1,443 @@
+// Package checker finds type errors before execution. Variables, parameters
+// and return values may be annotated with types
+//
+//	fn add(a: int, b: int): int { a + b }
+//
+// types of other values are inferred from literals, functions and classes,
+// values of unknown type have type 'any' which is compatible with every type.
+// Checking is gradual, misuse of values with only inferred types is reported
+// as warning, since such code may be correct, e.g. it may catch the error.
+package checker
+
+import (
+	"monkey/ast"
+	"monkey/diagnostics"
+	"monkey/token"
+)
+
+const (
+	ERR_UNKNOWN_TYPE            = "Unknown type '%s'."
+	ERR_TYPE_MISMATCH           = "Type mismatch: %s %s %s."
+	ERR_UNKNOWN_OPERATOR        = "Unknown operator: %s %s %s."
+	ERR_UNKNOWN_PREFIX_OPERATOR = "Unknown operator: %s%s."
+	ERR_WRONG_ASSIGNMENT        = "Can not assign %s to '%s' of type %s."
+	ERR_WRONG_ARGUMENT          = "Argument %d of '%s' should be %s, got %s."
+	ERR_WRONG_RETURN            = "Function should return %s, got %s."
+	ERR_WRONG_INDEX             = "Can not index %s with %s."
+	ERR_NOT_CALLABLE            = "Can not call %s."
+	ERR_NO_FIELDS               = "Can not access field '%s' of %s."
+	ERR_NOT_ITERABLE            = "Can not iterate over %s."
+	ERR_MISSING_RETURN          = "Function should return %s, but it may end without a value."
+)
+
+// errorCodes maps error message formats to stable diagnostic codes.
+var errorCodes = map[string]string{
+	ERR_UNKNOWN_TYPE:            "T001",
+	ERR_TYPE_MISMATCH:           "T002",
+	ERR_UNKNOWN_OPERATOR:        "T003",
+	ERR_UNKNOWN_PREFIX_OPERATOR: "T004",
+	ERR_WRONG_ASSIGNMENT:        "T005",
+	ERR_WRONG_ARGUMENT:          "T006",
+	ERR_WRONG_RETURN:            "T007",
+	ERR_WRONG_INDEX:             "T008",
+	ERR_NOT_CALLABLE:            "T009",
+	ERR_NO_FIELDS:               "T010",
+	ERR_NOT_ITERABLE:            "T011",
+	ERR_MISSING_RETURN:          "T012",
+}
+
+var errorHints = map[string]string{
+	ERR_UNKNOWN_TYPE: "types are int, float, string, bool, null, array, hash, fn, any or class names",
+}
+
+type checker struct {
+	definitions map[ast.Expression]*ast.IdentifierExpr
+	errors      []diagnostics.Diagnostic
+
+	// classes by name for annotations and by declaration for superclasses
+	classes         map[string]*ast.ClassStmt
+	declaredClasses map[*ast.IdentifierExpr]*ast.ClassStmt
+
+	annotations map[*ast.TypeAnnotation]*Type
+	// declarations assigned after initialization
+	reassigned map[*ast.IdentifierExpr]bool
+	// types of declarations, missing ones have type any
+	vars map[*ast.IdentifierExpr]*Type
+
+	currFn    *ast.FunctionExpr
+	currClass *ast.ClassStmt
+}
+
+// New creates checker of program with variables resolved to definitions
+// by the resolver.
+func New(definitions map[ast.Expression]*ast.IdentifierExpr) *checker {
+	return &checker{
+		definitions:     definitions,
+		errors:          []diagnostics.Diagnostic{},
+		classes:         map[string]*ast.ClassStmt{},
+		declaredClasses: map[*ast.IdentifierExpr]*ast.ClassStmt{},
+		annotations:     map[*ast.TypeAnnotation]*Type{},
+		reassigned:      map[*ast.IdentifierExpr]bool{},
+		vars:            map[*ast.IdentifierExpr]*Type{},
+	}
+}
+
+// Errors returns problems found by Check, including warnings.
+func (c *checker) Errors() []diagnostics.Diagnostic {
+	return c.errors
+}
+
+func (c *checker) Check(program *ast.Program) {
+	c.collect(program)
+	c.statements(program.Statements)
+}
+
+// collect finds classes and types of annotated declarations, functions and
+// classes before checking, so they are known where used before declaration.
+func (c *checker) collect(program *ast.Program) {
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.ClassStmt:
+			if _, ok := c.classes[node.Name.Value]; !ok {
+				c.classes[node.Name.Value] = node
+			}
+			c.declaredClasses[node.Name] = node
+		case *ast.AssignExpr:
+			if decl, ok := c.definitions[node.Identifier]; ok {
+				c.reassigned[decl] = true
+			}
+		}
+		return true
+	})
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.TypeAnnotation:
+			c.annotated(node)
+		case *ast.LetStmt:
+			if node.Type != nil {
+				c.vars[node.Name] = c.annotated(node.Type)
+			} else if fn, ok := node.Value.(*ast.FunctionExpr); ok && !c.reassigned[node.Name] {
+				c.vars[node.Name] = &Type{Kind: FUNCTION, Fn: fn}
+			}
+		case *ast.ClassStmt:
+			if !c.reassigned[node.Name] {
+				c.vars[node.Name] = &Type{Kind: CLASS, Class: node}
+			}
+		case *ast.FunctionExpr:
+			for i, param := range node.Parameters {
+				if t := node.ParameterType(i); t != nil {
+					c.vars[param] = c.annotated(t)
+				}
+			}
+		}
+		return true
+	})
+}
+
+func (c *checker) resolveType(annotation *ast.TypeAnnotation) *Type {
+	if t, ok := builtinTypes[annotation.Name]; ok {
+		return t
+	}
+	if class, ok := c.classes[annotation.Name]; ok {
+		return &Type{Kind: INSTANCE, Class: class}
+	}
+	c.error(annotation, ERR_UNKNOWN_TYPE, annotation.Name)
+	return Any
+}
+
+// annotated returns type named by annotation, missing annotation means any.
+func (c *checker) annotated(annotation *ast.TypeAnnotation) *Type {
+	if annotation == nil {
+		return Any
+	}
+	if t, ok := c.annotations[annotation]; ok {
+		return t
+	}
+	t := *c.resolveType(annotation)
+	t.Declared = true
+	c.annotations[annotation] = &t
+	return &t
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		c.statement(stmt)
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.LetStmt:
+		if s.Value == nil {
+			return
+		}
+		value := c.expr(s.Value)
+		if s.Type != nil {
+			c.checkAssignment(s.Value, value, s.Name.Value, c.annotated(s.Type))
+		} else if !c.reassigned[s.Name] {
+			c.vars[s.Name] = value
+		}
+	case *ast.ReturnStmt:
+		value := Null
+		if s.Value != nil {
+			value = c.expr(s.Value)
+		}
+		if c.currFn != nil && c.currFn.ReturnType != nil {
+			c.checkReturn(s, value)
+		}
+	case *ast.ExpressionStmt:
+		c.expr(s.Expression)
+	case *ast.BlockStmt:
+		c.statements(s.Statements)
+	case *ast.ClassStmt:
+		enclosingClass := c.currClass
+		c.currClass = s
+		for _, method := range s.Methods {
+			if fn, ok := method.Value.(*ast.FunctionExpr); ok {
+				c.function(fn, method.Name.Value == token.INITIALIZER_KEYWORD)
+			}
+		}
+		c.currClass = enclosingClass
+	case *ast.WhileStmt:
+		c.expr(s.Condition)
+		c.statement(s.Body)
+	case *ast.ForStmt:
+		if s.Init != nil {
+			c.statement(s.Init)
+		}
+		c.expr(s.Condition)
+		c.expr(s.Update)
+		c.statement(s.Body)
+	case *ast.ForInStmt:
+		c.forIn(s)
+	case *ast.ThrowStmt:
+		c.expr(s.Value)
+	case *ast.TryStmt:
+		c.statement(s.Body)
+		if s.Catch != nil {
+			c.statement(s.Catch)
+		}
+		if s.Finally != nil {
+			c.statement(s.Finally)
+		}
+	}
+}
+
+func (c *checker) forIn(s *ast.ForInStmt) {
+	iterable := c.expr(s.Iterable)
+
+	item := Any
+	switch iterable.Kind {
+	case ARRAY:
+		item = iterable.elem()
+	case STRING:
+		item = String
+	case ANY, HASH:
+	default:
+		c.misuse(s.Iterable, iterable.Declared, ERR_NOT_ITERABLE, iterable)
+	}
+	if !c.reassigned[s.Variable] {
+		c.vars[s.Variable] = item
+	}
+
+	c.statement(s.Body)
+}
+
+func (c *checker) function(fn *ast.FunctionExpr, isInit bool) {
+	enclosingFn := c.currFn
+	c.currFn = fn
+
+	stmts := fn.Body.Statements
+	for i, stmt := range stmts {
+		// value of the last expression is returned implicitly
+		last, ok := stmt.(*ast.ExpressionStmt)
+		if !ok || i != len(stmts)-1 || isInit || fn.ReturnType == nil {
+			c.statement(stmt)
+			continue
+		}
+		c.checkReturn(last.Expression, c.expr(last.Expression))
+	}
+
+	if !isInit && fn.ReturnType != nil {
+		want := c.annotated(fn.ReturnType)
+		if want.Kind != ANY && want.Kind != NULL && !returns(fn.Body) {
+			c.error(fn.ReturnType, ERR_MISSING_RETURN, want)
+		}
+	}
+
+	c.currFn = enclosingFn
+}
+
+// returns reports whether statement in tail position of function body
+// always ends with return, throw or expression giving the returned value.
+func returns(stmt ast.Statement) bool {
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		if len(s.Statements) == 0 {
+			return false
+		}
+		for _, stmt := range s.Statements[:len(s.Statements)-1] {
+			if exits(stmt) {
+				return true
+			}
+		}
+		return returns(s.Statements[len(s.Statements)-1])
+	case *ast.ExpressionStmt:
+		if e, ok := s.Expression.(*ast.IfExpr); ok {
+			return e.Else != nil && returns(e.Then) && returns(e.Else)
+		}
+		return true
+	case *ast.TryStmt:
+		if s.Finally != nil && exits(s.Finally) {
+			return true
+		}
+		return returns(s.Body) && (s.Catch == nil || returns(s.Catch))
+	default:
+		return exits(stmt)
+	}
+}
+
+// exits reports whether statement always ends with return or throw.
+func exits(stmt ast.Statement) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt, *ast.ThrowStmt:
+		return true
+	case *ast.BlockStmt:
+		for _, stmt := range s.Statements {
+			if exits(stmt) {
+				return true
+			}
+		}
+		return false
+	case *ast.ExpressionStmt:
+		e, ok := s.Expression.(*ast.IfExpr)
+		return ok && e.Else != nil && exits(e.Then) && exits(e.Else)
+	case *ast.TryStmt:
+		if s.Finally != nil && exits(s.Finally) {
+			return true
+		}
+		return exits(s.Body) && (s.Catch == nil || exits(s.Catch))
+	case *ast.WhileStmt:
+		literal, ok := s.Condition.(*ast.BoolLiteralExpr)
+		return ok && literal.Value && !breaks(s.Body)
+	case *ast.ForStmt:
+		return s.Condition == nil && !breaks(s.Body)
+	default:
+		return false
+	}
+}
+
+// breaks reports whether loop body has break statement leaving the loop.
+func breaks(body ast.Statement) bool {
+	found := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.BreakStmt:
+			found = true
+		case *ast.WhileStmt, *ast.ForStmt, *ast.ForInStmt, *ast.FunctionExpr:
+			// break of nested loop leaves only that loop
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+func (c *checker) checkReturn(node ast.Node, value *Type) {
+	want := c.annotated(c.currFn.ReturnType)
+	if !c.assignable(value, want) {
+		c.error(node, ERR_WRONG_RETURN, want, value)
+	}
+}
+
+func (c *checker) checkAssignment(node ast.Node, value *Type, name string, want *Type) {
+	if !c.assignable(value, want) {
+		c.error(node, ERR_WRONG_ASSIGNMENT, value, name, want)
+	}
+}
+
+// assignable reports whether value of type t may be stored in variable
+// of type want.
+func (c *checker) assignable(t, want *Type) bool {
+	switch {
+	case t.Kind == ANY || want.Kind == ANY:
+		return true
+	case t.Kind == INT && want.Kind == FLOAT:
+		return true
+	case t.Kind == CLASS && want.Kind == FUNCTION:
+		return true
+	case t.Kind != want.Kind:
+		return false
+	case t.Kind == INSTANCE:
+		return c.inherits(t.Class, want.Class)
+	default:
+		return true
+	}
+}
+
+// inherits reports whether class is the same as ancestor or its subclass.
+func (c *checker) inherits(class, ancestor *ast.ClassStmt) bool {
+	for i := 0; class != nil && i <= len(c.declaredClasses); i++ {
+		if class == ancestor {
+			return true
+		}
+		if class.Superclass == nil {
+			return false
+		}
+		decl, ok := c.definitions[class.Superclass]
+		if !ok {
+			// superclass declared elsewhere may be the ancestor
+			return true
+		}
+		class = c.declaredClasses[decl]
+	}
+	return class == nil
+}
+
+func (c *checker) superclass(class *ast.ClassStmt) *ast.ClassStmt {
+	if class.Superclass == nil {
+		return nil
+	}
+	if decl, ok := c.definitions[class.Superclass]; ok {
+		return c.declaredClasses[decl]
+	}
+	return nil
+}
+
+// method finds method of class or its superclasses.
+func (c *checker) method(class *ast.ClassStmt, name string) *ast.FunctionExpr {
+	for i := 0; class != nil && i <= len(c.declaredClasses); i++ {
+		for _, method := range class.Methods {
+			if method.Name.Value == name {
+				fn, _ := method.Value.(*ast.FunctionExpr)
+				return fn
+			}
+		}
+		class = c.superclass(class)
+	}
+	return nil
+}
+
+func (c *checker) error(node ast.Node, format string, args ...interface{}) {
+	c.report(diagnostics.Error, node, format, args...)
+}
+
+// misuse reports misuse of value, it is an error when the value has
+// declared type, otherwise the inferred type may be wrong.
+func (c *checker) misuse(node ast.Node, declared bool, format string, args ...interface{}) {
+	if declared {
+		c.report(diagnostics.Error, node, format, args...)
+	} else {
+		c.report(diagnostics.Warning, node, format, args...)
+	}
+}
+
+func (c *checker) report(severity diagnostics.Severity, node ast.Node, format string, args ...interface{}) {
+	d := diagnostics.New(severity, errorCodes[format], ast.Span(node), format, args...)
+	if hint, ok := errorHints[format]; ok {
+		d = d.WithHint(hint)
+	}
+
+	c.errors = append(c.errors, d)
+}
@@ -0,0 +1,211 @@
+package checker_test
+
+import (
+	"fmt"
+	"monkey/checker"
+	"monkey/diagnostics"
+	"monkey/eval/evaltest"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/resolver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func check(t *testing.T, source string) []string {
+	t.Helper()
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Can not parse %q: %s", source, p.Errors()[0])
+	}
+
+	r := resolver.New()
+	r.Resolve(program)
+	if len(r.Errors()) != 0 {
+		t.Fatalf("Can not resolve %q: %s", source, r.Errors()[0])
+	}
+
+	c := checker.New(r.Definitions())
+	c.Check(program)
+
+	result := []string{}
+	for _, d := range c.Errors() {
+		code := d.Code
+		if d.Severity == diagnostics.Warning {
+			code += " warning"
+		}
+		result = append(result, fmt.Sprintf("%s %s: %s", d.Span.Start, code, d.Message))
+	}
+	return result
+}
+
+func TestChecker(t *testing.T) {
+	tt := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			"infix",
+			`1 + "a"; "a" - "b"; 1 + 2.5 < 3; 1.5 & 1; [1] + [2]; true == 1; x + 1; 2 ** -1 & 1;`,
+			[]string{
+				"1:1 T002 warning: Type mismatch: int + string.",
+				"1:10 T003 warning: Unknown operator: string - string.",
+				"1:34 T003 warning: Unknown operator: float & int.",
+				"1:43 T003 warning: Unknown operator: array + array.",
+				"1:72 T003 warning: Unknown operator: float & int.",
+			},
+		},
+		{
+			"prefix",
+			`-"a"; ~1.5; !"a"; -1.5; ~x;`,
+			[]string{
+				"1:1 T004 warning: Unknown operator: -string.",
+				"1:7 T004 warning: Unknown operator: ~float.",
+			},
+		},
+		{
+			"inferred variables",
+			`let a = 1; let s = "s"; a + s; let b = 1; b = "b"; b + s; let f = fn() { a }; f + 1;`,
+			[]string{
+				"1:25 T002 warning: Type mismatch: int + string.",
+				"1:79 T002 warning: Type mismatch: fn + int.",
+			},
+		},
+		{
+			"annotated variables",
+			`let a: int = "a"; let b: float = 1; let c: string; c = 2; c += "s"; c += 1; let d: any = 1; d = "d";`,
+			[]string{
+				"1:14 T005: Can not assign string to 'a' of type int.",
+				"1:56 T005: Can not assign int to 'c' of type string.",
+				"1:69 T002: Type mismatch: string + int.",
+			},
+		},
+		{
+			"unknown types",
+			`let a: integer = 1; fn f(x: Point): number {} class Point {} let p: Point;`,
+			[]string{
+				"1:8 T001: Unknown type 'integer'.",
+				"1:37 T001: Unknown type 'number'.",
+			},
+		},
+		{
+			"arguments",
+			`fn add(a: int, b: float): float { a + b } add(1, 2); add(1.5, 2); add("a"); let r = add(1, 2) + "s"; let g = add; g(1, "b");`,
+			[]string{
+				"1:58 T006: Argument 1 of 'add' should be int, got float.",
+				"1:71 T006: Argument 1 of 'add' should be int, got string.",
+				"1:85 T002: Type mismatch: float + string.",
+				"1:120 T006: Argument 2 of 'g' should be float, got string.",
+			},
+		},
+		{
+			"returns",
+			`fn a(): int { return "a"; } fn b(x): string { if (x) { return; } x } fn c(): int { 1.5 } fn d(): float { 1 } fn e(): null { puts(1) }`,
+			[]string{
+				"1:15 T007: Function should return int, got string.",
+				"1:56 T007: Function should return string, got null.",
+				"1:84 T007: Function should return int, got float.",
+			},
+		},
+		{
+			"recursion",
+			`fn fact(n: int): int { if (n < 2) { return 1; } n * fact(n - 1) } fact("3");`,
+			[]string{"1:72 T006: Argument 1 of 'fact' should be int, got string."},
+		},
+		{
+			"arrays and hashes",
+			`let a = [1, 2]; a[0] + "s"; a["k"]; let h = {| "k": "v" |}; h["k"] + 1; "s"[0] + 1; 1[0]; "s"[0] = "t"; a[0] += "s"; let m = [1, "a"]; m[0] + "s";`,
+			[]string{
+				"1:17 T002 warning: Type mismatch: int + string.",
+				"1:29 T008 warning: Can not index array with string.",
+				"1:61 T002 warning: Type mismatch: string + int.",
+				"1:73 T002 warning: Type mismatch: string + int.",
+				"1:85 T008 warning: Can not index int with int.",
+				"1:91 T008 warning: Can not index string with int.",
+				"1:105 T002 warning: Type mismatch: int + string.",
+			},
+		},
+		{
+			"loops",
+			`for (x in [1, 2]) { x + "s"; } for (ch in "abc") { ch - 1; } for (k in {| 1: 2 |}) { k + "s"; } for (i in 10) {}`,
+			[]string{
+				"1:21 T002 warning: Type mismatch: int + string.",
+				"1:52 T002 warning: Type mismatch: string - int.",
+				"1:107 T011 warning: Can not iterate over int.",
+			},
+		},
+		{
+			"classes",
+			"class A { fn init(x: int) { this.x = x; } fn get(): int { this.x } }" +
+				" class B < A { fn init() { super.init(\"s\"); } }" +
+				" let a: A = A(\"s\"); let b: A = B(); let c: B = A(1); a.get() + \"s\"; A + 1; A.x; 1.x; let f: fn = A;",
+			[]string{
+				"1:107 T006: Argument 1 of 'init' should be int, got string.",
+				"1:130 T006: Argument 1 of 'A' should be int, got string.",
+				"1:163 T005: Can not assign A to 'c' of type B.",
+				"1:169 T002: Type mismatch: int + string.",
+				"1:184 T002 warning: Type mismatch: class A + int.",
+				"1:191 T010 warning: Can not access field 'x' of class A.",
+				"1:196 T010 warning: Can not access field 'x' of int.",
+			},
+		},
+		{
+			"not callable",
+			`let a = 1; a(); "s"(); let f: fn; f(); let h = fn(g: fn) { g(); }; h(1);`,
+			[]string{
+				"1:12 T009 warning: Can not call int.",
+				"1:17 T009 warning: Can not call string.",
+				"1:70 T006: Argument 1 of 'h' should be fn, got int.",
+			},
+		},
+		{
+			"declared operands",
+			`let a: int = 1; let s: string = "s"; a + "s"; -s; s[true]; a(); a.x; for (x in a) {} let b = a; b + s;`,
+			[]string{
+				"1:38 T002: Type mismatch: int + string.",
+				"1:47 T004: Unknown operator: -string.",
+				"1:51 T008: Can not index string with bool.",
+				"1:60 T009: Can not call int.",
+				"1:65 T010: Can not access field 'x' of int.",
+				"1:80 T011: Can not iterate over int.",
+				"1:97 T002: Type mismatch: int + string.",
+			},
+		},
+		{
+			"missing returns",
+			"fn a(x): int { if (x) { return 1; } } fn b(x): int { if (x) { 1 } else { 2 } } fn c(): int {}" +
+				" fn d(x): string { while (true) { if (x) { return \"d\"; } } } fn e(): int { for (;;) { break; } }" +
+				" fn f(): int { try { 1 } catch (e) { throw e; } } fn g(): null {} fn h(): any { let x = 1; }" +
+				" fn i(x): int { if (x) { return 1; } else { throw 2; } let y = 1; }",
+			[]string{
+				"1:10 T012: Function should return int, but it may end without a value.",
+				"1:88 T012: Function should return int, but it may end without a value.",
+				"1:163 T012: Function should return int, but it may end without a value.",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := check(t, tc.source); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Wrong errors of %q\ngot  %q\nwant %q", tc.source, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestNoFalsePositives checks that shared test cases, which evaluate
+// without errors, pass the checker, they may have warnings only.
+func TestNoFalsePositives(t *testing.T) {
+	for _, tc := range evaltest.Cases {
+		for _, err := range check(t, tc.Source) {
+			if !strings.Contains(err, " warning: ") {
+				t.Errorf("Unexpected error of %q: %q", tc.Source, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package checker
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// expr checks expression and returns type of its value.
+func (c *checker) expr(e ast.Expression) *Type {
+	switch e := e.(type) {
+	case nil:
+		return Any
+	case *ast.IntLiteralExpr:
+		return Int
+	case *ast.FloatLiteralExpr:
+		return Float
+	case *ast.BoolLiteralExpr:
+		return Bool
+	case *ast.StringLiteralExpr:
+		return String
+	case *ast.NullExpr:
+		return Null
+	case *ast.TemplateExpr:
+		for _, expr := range e.Exprs {
+			c.expr(expr)
+		}
+		return String
+	case *ast.IdentifierExpr:
+		return c.variable(e)
+	case *ast.ArrayLiteralExpr:
+		elements := []*Type{}
+		for _, el := range e.Elements {
+			elements = append(elements, c.expr(el))
+		}
+		return &Type{Kind: ARRAY, Elem: common(elements)}
+	case *ast.HashLiteralExpr:
+		values := []*Type{}
+		for _, pair := range e.Pairs {
+			c.expr(pair.Key)
+			values = append(values, c.expr(pair.Value))
+		}
+		return &Type{Kind: HASH, Elem: common(values)}
+	case *ast.PrefixExpr:
+		return c.prefix(e)
+	case *ast.InfixExpr:
+		return c.infix(e, c.expr(e.Left), e.Operator, c.expr(e.Right))
+	case *ast.AssignExpr:
+		return c.assign(e)
+	case *ast.IfExpr:
+		c.expr(e.Condition)
+		c.statement(e.Then)
+		if e.Else != nil {
+			c.statement(e.Else)
+		}
+		return Any
+	case *ast.FunctionExpr:
+		c.function(e, false)
+		return &Type{Kind: FUNCTION, Fn: e}
+	case *ast.CallExpr:
+		return c.call(e)
+	case *ast.IndexExpr:
+		return c.index(e, c.expr(e.Left), c.expr(e.Index))
+	case *ast.IndexSetExpr:
+		return c.indexSet(e)
+	case *ast.SliceExpr:
+		left := c.expr(e.Left)
+		c.expr(e.Low)
+		c.expr(e.High)
+		if left.Kind == STRING || left.Kind == ARRAY {
+			return left
+		}
+		return Any
+	case *ast.GetExpr:
+		return c.get(e, c.expr(e.Expression))
+	case *ast.SetExpr:
+		c.fields(e, c.expr(e.Expression), e.Field.Value)
+		value := c.expr(e.Value)
+		if _, ok := token.CompoundOperator(e.Token.Type); ok {
+			return Any
+		}
+		return value
+	case *ast.ThisExpr:
+		if c.currClass != nil {
+			return &Type{Kind: INSTANCE, Class: c.currClass}
+		}
+		return Any
+	case *ast.SuperExpr:
+		if c.currClass != nil {
+			if super := c.superclass(c.currClass); super != nil {
+				if fn := c.method(super, e.Method.Value); fn != nil {
+					return &Type{Kind: FUNCTION, Fn: fn}
+				}
+			}
+		}
+		return Any
+	default:
+		return Any
+	}
+}
+
+func (c *checker) variable(ident *ast.IdentifierExpr) *Type {
+	if decl, ok := c.definitions[ident]; ok {
+		if t, ok := c.vars[decl]; ok {
+			return t
+		}
+	}
+	return Any
+}
+
+func (c *checker) prefix(e *ast.PrefixExpr) *Type {
+	right := c.expr(e.Right)
+
+	switch {
+	case e.Operator == token.BANG:
+		return Bool
+	case right.Kind == ANY:
+		return Any
+	case e.Operator == token.MINUS && right.isNumber():
+		return right
+	case e.Operator == token.BIT_NOT && right.Kind == INT:
+		return Int
+	default:
+		c.misuse(e, right.Declared, ERR_UNKNOWN_PREFIX_OPERATOR, e.Operator, right)
+		return Any
+	}
+}
+
+// infix mirrors evaluation of infix operators, node is reported on errors.
+func (c *checker) infix(node ast.Node, left *Type, operator string, right *Type) *Type {
+	switch operator {
+	case token.AND, token.OR:
+		// logical operators return one of the operands
+		return common([]*Type{left, right})
+	case token.EQUAL_EQUAL, token.NOT_EQUAL:
+		return Bool
+	}
+
+	comparison := operator == token.LESS || operator == token.LESS_EQUAL ||
+		operator == token.GREATER || operator == token.GREATER_EQUAL
+	arithmetic := operator == token.PLUS || operator == token.MINUS || operator == token.STAR ||
+		operator == token.SLASH || operator == token.PERCENT || operator == token.POWER
+
+	switch {
+	case left.Kind == ANY || right.Kind == ANY:
+		if comparison {
+			return Bool
+		}
+		return Any
+	case left.Kind == INT && right.Kind == INT:
+		if comparison {
+			return Bool
+		}
+		if operator == token.POWER && isNegative(node) {
+			return Float
+		}
+		return Int
+	case left.isNumber() && right.isNumber() && (comparison || arithmetic):
+		if comparison {
+			return Bool
+		}
+		return Float
+	case left.Kind == STRING && right.Kind == STRING && operator == token.PLUS:
+		return String
+	case left.Kind != right.Kind && !(left.isNumber() && right.isNumber()):
+		c.misuse(node, left.Declared || right.Declared, ERR_TYPE_MISMATCH, left, operator, right)
+		return Any
+	default:
+		c.misuse(node, left.Declared || right.Declared, ERR_UNKNOWN_OPERATOR, left, operator, right)
+		return Any
+	}
+}
+
+// isNegative reports whether right operand of infix expression is
+// a negated literal, like exponent of 2 ** -1.
+func isNegative(node ast.Node) bool {
+	infix, ok := node.(*ast.InfixExpr)
+	if !ok {
+		return false
+	}
+	prefix, ok := infix.Right.(*ast.PrefixExpr)
+	if !ok || prefix.Operator != token.MINUS {
+		return false
+	}
+	_, ok = prefix.Right.(*ast.IntLiteralExpr)
+	return ok
+}
+
+func (c *checker) assign(e *ast.AssignExpr) *Type {
+	value := c.expr(e.Expression)
+
+	decl, ok := c.definitions[e.Identifier]
+	if !ok {
+		return value
+	}
+	t, ok := c.vars[decl]
+	if !ok {
+		return value
+	}
+
+	if operator, ok := token.CompoundOperator(e.Token.Type); ok {
+		value = c.infix(e, t, operator, value)
+	}
+	c.checkAssignment(e.Expression, value, e.Identifier.Value, t)
+
+	return value
+}
+
+func (c *checker) call(e *ast.CallExpr) *Type {
+	callee := c.expr(e.Function)
+	args := []*Type{}
+	for _, arg := range e.Arguments {
+		args = append(args, c.expr(arg))
+	}
+
+	var fn *ast.FunctionExpr
+	result := Any
+
+	switch callee.Kind {
+	case ANY:
+	case FUNCTION:
+		fn = callee.Fn
+		if fn != nil {
+			result = c.annotated(fn.ReturnType)
+		}
+	case CLASS:
+		fn = c.method(callee.Class, token.INITIALIZER_KEYWORD)
+		result = &Type{Kind: INSTANCE, Class: callee.Class}
+	default:
+		c.misuse(e.Function, callee.Declared, ERR_NOT_CALLABLE, callee)
+		return Any
+	}
+
+	if fn == nil {
+		return result
+	}
+	for i, arg := range args {
+		if i >= len(fn.Parameters) {
+			break
+		}
+		want := c.annotated(fn.ParameterType(i))
+		if !c.assignable(arg, want) {
+			c.error(e.Arguments[i], ERR_WRONG_ARGUMENT, i+1, calleeName(e.Function), want, arg)
+		}
+	}
+
+	return result
+}
+
+func calleeName(callee ast.Expression) string {
+	switch callee := callee.(type) {
+	case *ast.IdentifierExpr:
+		return callee.Value
+	case *ast.GetExpr:
+		return callee.Field.Value
+	case *ast.SuperExpr:
+		return callee.Method.Value
+	default:
+		return "fn"
+	}
+}
+
+func (c *checker) index(node ast.Node, left *Type, index *Type) *Type {
+	indexable := index.Kind == INT || index.Kind == ANY
+
+	switch {
+	case left.Kind == ANY:
+		return Any
+	case left.Kind == ARRAY && indexable:
+		return left.elem()
+	case left.Kind == STRING && indexable:
+		return String
+	case left.Kind == HASH:
+		return left.elem()
+	default:
+		c.misuse(node, left.Declared || index.Declared, ERR_WRONG_INDEX, left, index)
+		return Any
+	}
+}
+
+func (c *checker) indexSet(e *ast.IndexSetExpr) *Type {
+	left := c.expr(e.Left)
+	index := c.expr(e.Index)
+	value := c.expr(e.Value)
+
+	if left.Kind == STRING {
+		// strings are immutable
+		c.misuse(e, left.Declared, ERR_WRONG_INDEX, left, index)
+		return value
+	}
+
+	elem := c.index(e, left, index)
+	if operator, ok := token.CompoundOperator(e.Token.Type); ok {
+		return c.infix(e, elem, operator, value)
+	}
+	return value
+}
+
+func (c *checker) get(e *ast.GetExpr, object *Type) *Type {
+	if !c.fields(e, object, e.Field.Value) || object.Kind != INSTANCE {
+		return Any
+	}
+	if fn := c.method(object.Class, e.Field.Value); fn != nil {
+		return &Type{Kind: FUNCTION, Fn: fn}
+	}
+	return Any
+}
+
+// fields reports whether object of given type may have fields.
+func (c *checker) fields(node ast.Node, object *Type, name string) bool {
+	if object.Kind == ANY || object.Kind == INSTANCE {
+		return true
+	}
+	c.misuse(node, object.Declared, ERR_NO_FIELDS, name, object)
+	return false
+}
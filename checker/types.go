@@ -0,0 +1,114 @@
+package checker
+
+import "monkey/ast"
+
+// Kind is a kind of values known before execution.
+type Kind int
+
+const (
+	ANY Kind = iota
+	NULL
+	INT
+	FLOAT
+	BOOL
+	STRING
+	ARRAY
+	HASH
+	FUNCTION
+	CLASS // class itself, calling it creates instances
+	INSTANCE
+)
+
+var kindNames = map[Kind]string{
+	ANY:      "any",
+	NULL:     "null",
+	INT:      "int",
+	FLOAT:    "float",
+	BOOL:     "bool",
+	STRING:   "string",
+	ARRAY:    "array",
+	HASH:     "hash",
+	FUNCTION: "fn",
+}
+
+// Type describes values, only types of arrays, hashes, functions and
+// classes have details.
+type Type struct {
+	Kind  Kind
+	Elem  *Type             // elements of array or values of hash, nil when unknown
+	Fn    *ast.FunctionExpr // signature of function, nil when unknown
+	Class *ast.ClassStmt    // class of CLASS and INSTANCE types
+
+	// Declared types come from annotations, other ones are inferred.
+	Declared bool
+}
+
+var (
+	Any    = &Type{Kind: ANY}
+	Null   = &Type{Kind: NULL}
+	Int    = &Type{Kind: INT}
+	Float  = &Type{Kind: FLOAT}
+	Bool   = &Type{Kind: BOOL}
+	String = &Type{Kind: STRING}
+)
+
+// builtinTypes are types named in annotations, other names refer to classes.
+var builtinTypes = map[string]*Type{
+	"any":    Any,
+	"null":   Null,
+	"int":    Int,
+	"float":  Float,
+	"bool":   Bool,
+	"string": String,
+	"array":  {Kind: ARRAY},
+	"hash":   {Kind: HASH},
+	"fn":     {Kind: FUNCTION},
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case CLASS:
+		return "class " + t.Class.Name.Value
+	case INSTANCE:
+		return t.Class.Name.Value
+	default:
+		return kindNames[t.Kind]
+	}
+}
+
+func (t *Type) isNumber() bool {
+	return t.Kind == INT || t.Kind == FLOAT
+}
+
+// elem returns type of elements of array or values of hash.
+func (t *Type) elem() *Type {
+	if t.Elem == nil {
+		return Any
+	}
+	return t.Elem
+}
+
+// same reports whether values of both types are indistinguishable
+// for the checker.
+func same(a, b *Type) bool {
+	if a.Kind != b.Kind || a.Fn != b.Fn || a.Class != b.Class {
+		return false
+	}
+	if a.Elem == nil || b.Elem == nil {
+		return a.Elem == b.Elem
+	}
+	return same(a.Elem, b.Elem)
+}
+
+// common returns type of all given types, or any when they differ.
+func common(types []*Type) *Type {
+	if len(types) == 0 {
+		return nil
+	}
+	for _, t := range types[1:] {
+		if !same(t, types[0]) {
+			return Any
+		}
+	}
+	return types[0]
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/diagnostics"
+	"monkey/eval"
+	"monkey/lint"
+	"monkey/repl"
+	"monkey/runner"
+	"os"
+)
+
+const usageInfo = `
+Usage:
+monkey [options] [script]  to run script
+monkey debug script        to run script in step debugger
+monkey dap                 to serve Debug Adapter Protocol on stdio
+monkey lsp                 to serve Language Server Protocol on stdio
+monkey fmt [-check] files  to format files in place, with -check list
+                           files which are not formatted and fail
+monkey lint [-enable=rules] [-disable=rules] files
+                           to report suspicious code, rules are comma
+                           separated names: unused-variable,
+                           unused-parameter, shadow, unreachable,
+                           undeclared-assignment, argument-count,
+                           uninitialized-field (default all)
+monkey                     to run REPL
+
+Options:
+-diagnostics=text|json     format of reported errors (default text)
+-engine=eval|vm            run scripts and REPL with tree-walking evaluator
+                           or bytecode virtual machine (default eval),
+                           debug and dap support only eval
+-max-depth=n               limit nested calls, calls in return statements
+                           do not count (default 10000)
+-allow=capabilities        grant scripts builtins of comma separated
+                           capabilities: filesystem (also needed by
+                           imports), env, network
+                           (default only pure, stdout, clock)`
+
+func main() {
+	flag.Usage = func() { fmt.Println(usageInfo) }
+	diagnosticsFormat := flag.String("diagnostics", "text", "")
+	engineName := flag.String("engine", "eval", "")
+	maxDepth := flag.Int("max-depth", eval.DEFAULT_MAX_CALL_DEPTH, "")
+	allow := flag.String("allow", "", "")
+	flag.Parse()
+
+	format, err := diagnostics.ParseFormat(*diagnosticsFormat)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(usageInfo)
+		os.Exit(64)
+	}
+
+	engine, err := runner.ParseEngine(*engineName)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(usageInfo)
+		os.Exit(64)
+	}
+
+	if *maxDepth < 1 {
+		fmt.Println("max-depth should be positive")
+		fmt.Println(usageInfo)
+		os.Exit(64)
+	}
+
+	capabilities, err := eval.ParseCapabilities(*allow)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(usageInfo)
+		os.Exit(64)
+	}
+
+	args := flag.Args()
+	opts := runner.Options{
+		Diagnostics:  format,
+		Engine:       engine,
+		MaxCallDepth: *maxDepth,
+		Capabilities: capabilities,
+	}
+
+	switch {
+	case len(args) == 0:
+		r := repl.New(os.Stdin, os.Stdout)
+		r.SetOptions(repl.Options{
+			VM:           engine == runner.VM,
+			MaxCallDepth: *maxDepth,
+			Capabilities: capabilities,
+		})
+		r.Start()
+	case len(args) == 2 && args[0] == "debug":
+		runner.DebugFile(args[1], opts)
+	case len(args) == 1 && args[0] == "dap":
+		runner.ServeDAP(opts)
+	case len(args) == 1 && args[0] == "lsp":
+		runner.ServeLSP()
+	case len(args) >= 2 && args[0] == "fmt":
+		flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+		flags.Usage = flag.Usage
+		check := flags.Bool("check", false, "")
+		flags.Parse(args[1:])
+		if flags.NArg() == 0 {
+			fmt.Println(usageInfo)
+			os.Exit(64)
+		}
+		runner.FormatFiles(flags.Args(), *check, opts)
+	case len(args) >= 2 && args[0] == "lint":
+		flags := flag.NewFlagSet("lint", flag.ExitOnError)
+		flags.Usage = flag.Usage
+		enable := flags.String("enable", "", "")
+		disable := flags.String("disable", "", "")
+		flags.Parse(args[1:])
+
+		config, err := lint.ParseConfig(*enable, *disable)
+		if err != nil || flags.NArg() == 0 {
+			if err != nil {
+				fmt.Println(err)
+			}
+			fmt.Println(usageInfo)
+			os.Exit(64)
+		}
+		runner.LintFiles(flags.Args(), config, opts)
+	case len(args) == 1:
+		runner.RunFile(args[0], opts)
+	default:
+		fmt.Println(usageInfo)
+		os.Exit(64)
+	}
+}
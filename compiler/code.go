@@ -0,0 +1,301 @@
+package compiler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpNull
+	OpTrue
+	OpFalse
+
+	// OpPop discards the top of the stack, OpResult pops it as the value of
+	// the statement just executed, OpLastResult pushes that value back.
+	OpPop
+	OpResult
+	OpLastResult
+	// OpDup pushes copies of the given number of values on top of the stack.
+	OpDup
+
+	OpDefineGlobal
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetUpvalue
+	OpSetUpvalue
+	OpCloseUpvalue
+
+	OpBang
+	OpNegate
+	OpBitNot
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+
+	OpJump
+	OpJumpIfFalse
+	OpJumpIfFalseKeep
+	OpJumpIfTrueKeep
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSetIndex
+	OpSlice
+	OpTemplate
+
+	OpCall
+	// OpTailCall is a call whose result is returned right away, called
+	// function replaces the caller in its frame. Other callees are called
+	// like by OpCall and OpReturn following it returns the result.
+	OpTailCall
+	OpReturn
+	OpClosure
+
+	OpClass
+	OpInherit
+	OpMethod
+	OpGetProperty
+	OpSetProperty
+	OpGetSuper
+
+	OpIter
+	OpIterNext
+
+	// OpTry installs exception handler jumping to its operand with the
+	// caught exception pushed, OpEndTry removes the innermost handler.
+	OpTry
+	OpEndTry
+	OpThrow
+
+	// OpImport pushes module loaded from path in constant of its operand.
+	OpImport
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:        {"OpConstant", []int{2}},
+	OpNull:            {"OpNull", []int{}},
+	OpTrue:            {"OpTrue", []int{}},
+	OpFalse:           {"OpFalse", []int{}},
+	OpPop:             {"OpPop", []int{}},
+	OpResult:          {"OpResult", []int{}},
+	OpLastResult:      {"OpLastResult", []int{}},
+	OpDup:             {"OpDup", []int{1}},
+	OpDefineGlobal:    {"OpDefineGlobal", []int{2}},
+	OpGetGlobal:       {"OpGetGlobal", []int{2}},
+	OpSetGlobal:       {"OpSetGlobal", []int{2}},
+	OpGetLocal:        {"OpGetLocal", []int{2}},
+	OpSetLocal:        {"OpSetLocal", []int{2}},
+	OpGetUpvalue:      {"OpGetUpvalue", []int{2}},
+	OpSetUpvalue:      {"OpSetUpvalue", []int{2}},
+	OpCloseUpvalue:    {"OpCloseUpvalue", []int{}},
+	OpBang:            {"OpBang", []int{}},
+	OpNegate:          {"OpNegate", []int{}},
+	OpBitNot:          {"OpBitNot", []int{}},
+	OpAdd:             {"OpAdd", []int{}},
+	OpSub:             {"OpSub", []int{}},
+	OpMul:             {"OpMul", []int{}},
+	OpDiv:             {"OpDiv", []int{}},
+	OpMod:             {"OpMod", []int{}},
+	OpPow:             {"OpPow", []int{}},
+	OpBitAnd:          {"OpBitAnd", []int{}},
+	OpBitOr:           {"OpBitOr", []int{}},
+	OpBitXor:          {"OpBitXor", []int{}},
+	OpShiftLeft:       {"OpShiftLeft", []int{}},
+	OpShiftRight:      {"OpShiftRight", []int{}},
+	OpEqual:           {"OpEqual", []int{}},
+	OpNotEqual:        {"OpNotEqual", []int{}},
+	OpGreater:         {"OpGreater", []int{}},
+	OpGreaterEqual:    {"OpGreaterEqual", []int{}},
+	OpLess:            {"OpLess", []int{}},
+	OpLessEqual:       {"OpLessEqual", []int{}},
+	OpJump:            {"OpJump", []int{2}},
+	OpJumpIfFalse:     {"OpJumpIfFalse", []int{2}},
+	OpJumpIfFalseKeep: {"OpJumpIfFalseKeep", []int{2}},
+	OpJumpIfTrueKeep:  {"OpJumpIfTrueKeep", []int{2}},
+	OpArray:           {"OpArray", []int{2}},
+	OpHash:            {"OpHash", []int{2}},
+	OpIndex:           {"OpIndex", []int{}},
+	OpSetIndex:        {"OpSetIndex", []int{}},
+	OpSlice:           {"OpSlice", []int{}},
+	OpTemplate:        {"OpTemplate", []int{2}},
+	OpCall:            {"OpCall", []int{1}},
+	OpTailCall:        {"OpTailCall", []int{1}},
+	OpReturn:          {"OpReturn", []int{}},
+	OpClosure:         {"OpClosure", []int{2}},
+	OpClass:           {"OpClass", []int{2}},
+	OpInherit:         {"OpInherit", []int{}},
+	OpMethod:          {"OpMethod", []int{2}},
+	OpGetProperty:     {"OpGetProperty", []int{2}},
+	OpSetProperty:     {"OpSetProperty", []int{2}},
+	OpGetSuper:        {"OpGetSuper", []int{2}},
+	OpIter:            {"OpIter", []int{}},
+	OpIterNext:        {"OpIterNext", []int{2}},
+	OpTry:             {"OpTry", []int{2}},
+	OpEndTry:          {"OpEndTry", []int{}},
+	OpThrow:           {"OpThrow", []int{}},
+	OpImport:          {"OpImport", []int{2}},
+}
+
+// Operators maps opcodes of prefix and infix operations to the operator
+// they implement.
+var Operators = map[Opcode]string{
+	OpBang:         token.BANG,
+	OpNegate:       token.MINUS,
+	OpBitNot:       token.BIT_NOT,
+	OpAdd:          token.PLUS,
+	OpSub:          token.MINUS,
+	OpMul:          token.STAR,
+	OpDiv:          token.SLASH,
+	OpMod:          token.PERCENT,
+	OpPow:          token.POWER,
+	OpBitAnd:       token.BIT_AND,
+	OpBitOr:        token.BIT_OR,
+	OpBitXor:       token.BIT_XOR,
+	OpShiftLeft:    token.SHIFT_LEFT,
+	OpShiftRight:   token.SHIFT_RIGHT,
+	OpEqual:        token.EQUAL_EQUAL,
+	OpNotEqual:     token.NOT_EQUAL,
+	OpGreater:      token.GREATER,
+	OpGreaterEqual: token.GREATER_EQUAL,
+	OpLess:         token.LESS,
+	OpLessEqual:    token.LESS_EQUAL,
+}
+
+var prefixOpcodes = map[string]Opcode{
+	token.BANG:    OpBang,
+	token.MINUS:   OpNegate,
+	token.BIT_NOT: OpBitNot,
+}
+
+var infixOpcodes = map[string]Opcode{
+	token.PLUS:          OpAdd,
+	token.MINUS:         OpSub,
+	token.STAR:          OpMul,
+	token.SLASH:         OpDiv,
+	token.PERCENT:       OpMod,
+	token.POWER:         OpPow,
+	token.BIT_AND:       OpBitAnd,
+	token.BIT_OR:        OpBitOr,
+	token.BIT_XOR:       OpBitXor,
+	token.SHIFT_LEFT:    OpShiftLeft,
+	token.SHIFT_RIGHT:   OpShiftRight,
+	token.EQUAL_EQUAL:   OpEqual,
+	token.NOT_EQUAL:     OpNotEqual,
+	token.GREATER:       OpGreater,
+	token.GREATER_EQUAL: OpGreaterEqual,
+	token.LESS:          OpLess,
+	token.LESS_EQUAL:    OpLessEqual,
+}
+
+func Lookup(op Opcode) (*Definition, error) {
+	def, ok := definitions[op]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes instruction, operands are stored in big-endian order.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 1:
+			instruction[offset] = byte(o)
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		}
+		offset += def.OperandWidths[i]
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes operands of instruction described by def,
+// it returns operands and number of bytes read.
+func ReadOperands(def *Definition, ins []byte) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, w := range def.OperandWidths {
+		switch w {
+		case 1:
+			operands[i] = int(ins[offset])
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		}
+		offset += w
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins []byte) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// Disassemble returns human readable listing of instructions, one per line.
+func Disassemble(ins []byte) string {
+	var out strings.Builder
+
+	for i := 0; i < len(ins); {
+		def, err := Lookup(Opcode(ins[i]))
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s", i, def.Name)
+		for _, o := range operands {
+			fmt.Fprintf(&out, " %d", o)
+		}
+		out.WriteString("\n")
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
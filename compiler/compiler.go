@@ -0,0 +1,1061 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+const (
+	ERR_UNSUPPORTED_NODE   = "compiler: unsupported node %T"
+	ERR_TOO_MANY_CONSTANTS = "compiler: too many constants"
+	ERR_TOO_MANY_LOCALS    = "compiler: too many local variables in function"
+	ERR_TOO_MANY_ARGUMENTS = "compiler: too many arguments in call"
+	ERR_JUMP_TOO_FAR       = "compiler: function body is too large"
+)
+
+// Bytecode is the compiled program: the top-level script function,
+// constant pool shared by all functions and names of global variables.
+type Bytecode struct {
+	Main      *object.CompiledFunction
+	Constants []object.Object
+	Globals   []string
+}
+
+type fnKind int
+
+const (
+	scriptFn fnKind = iota
+	plainFn
+	methodFn
+	initFn
+)
+
+type local struct {
+	name     string
+	depth    int
+	captured bool
+}
+
+type loop struct {
+	depth     int // scope depth of locals which outlive break and continue
+	tries     int // number of try statements enclosing the loop
+	breaks    []int
+	continues []int
+}
+
+// tryBlock is a try statement whose body or catch block is being compiled.
+type tryBlock struct {
+	handlers int            // number of exception handlers it installed
+	finally  *ast.BlockStmt // nil when there is no finally block
+}
+
+// scope is a compilation state of a single function.
+type scope struct {
+	enclosing *scope
+	kind      fnKind
+	fn        *object.CompiledFunction
+	locals    []local
+	depth     int
+	loops     []*loop
+	tries     []*tryBlock
+}
+
+type Compiler struct {
+	constants   []object.Object
+	globals     map[string]int
+	globalNames []string
+
+	scope *scope
+	span  token.Span // span of the node instructions are emitted for
+}
+
+func New() *Compiler {
+	return &Compiler{globals: make(map[string]int)}
+}
+
+// NewModule creates compiler of module imported by program compiled to
+// bytecode, constants and globals of the module are appended to those of
+// the program, global variables of the module get slots of their own.
+func NewModule(bytecode *Bytecode) *Compiler {
+	return &Compiler{
+		constants:   bytecode.Constants,
+		globals:     make(map[string]int),
+		globalNames: bytecode.Globals,
+	}
+}
+
+func (c *Compiler) Compile(program *ast.Program) error {
+	c.scope = &scope{kind: scriptFn, fn: &object.CompiledFunction{}}
+	// slot 0 holds the function being executed
+	c.addLocal("")
+
+	for _, stmt := range program.Statements {
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+	}
+
+	c.emit(OpLastResult)
+	c.emit(OpReturn)
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Main:      c.scope.fn,
+		Constants: c.constants,
+		Globals:   c.globalNames,
+	}
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	defer c.at(node)()
+
+	switch node := node.(type) {
+	case *ast.BlockStmt:
+		c.beginScope()
+		if len(node.Statements) == 0 {
+			c.emit(OpNull)
+			c.emit(OpResult)
+		}
+		for _, stmt := range node.Statements {
+			if err := c.compile(stmt); err != nil {
+				return err
+			}
+		}
+		c.endScope()
+	case *ast.ExpressionStmt:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(OpResult)
+	case *ast.ReturnStmt:
+		if call, ok := node.Value.(*ast.CallExpr); ok && c.tailCalls() {
+			if err := c.compileCall(call, OpTailCall); err != nil {
+				return err
+			}
+			c.emit(OpReturn)
+			return nil
+		}
+		if c.scope.kind == initFn {
+			c.emit(OpGetLocal, 0)
+		} else if node.Value != nil {
+			if err := c.compile(node.Value); err != nil {
+				return err
+			}
+		} else {
+			c.emit(OpNull)
+		}
+		if c.hasFinally(0) {
+			return c.compileReturnFromTry()
+		}
+		c.emit(OpReturn)
+	case *ast.LetStmt:
+		return c.compileLetStmt(node)
+	case *ast.ClassStmt:
+		return c.compileClassStmt(node)
+	case *ast.WhileStmt:
+		return c.compileWhileStmt(node)
+	case *ast.ForStmt:
+		return c.compileForStmt(node)
+	case *ast.ForInStmt:
+		return c.compileForInStmt(node)
+	case *ast.ImportStmt:
+		return c.compileImportStmt(node)
+	case *ast.TryStmt:
+		return c.compileTryStmt(node)
+	case *ast.ThrowStmt:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(OpThrow)
+	case *ast.BreakStmt:
+		lp := c.scope.loops[len(c.scope.loops)-1]
+		if err := c.leaveTries(lp.tries); err != nil {
+			return err
+		}
+		c.discardLocals(lp.depth)
+		lp.breaks = append(lp.breaks, c.emit(OpJump, 0))
+	case *ast.ContinueStmt:
+		lp := c.scope.loops[len(c.scope.loops)-1]
+		if err := c.leaveTries(lp.tries); err != nil {
+			return err
+		}
+		c.discardLocals(lp.depth)
+		lp.continues = append(lp.continues, c.emit(OpJump, 0))
+	case *ast.IntLiteralExpr:
+		return c.emitConstant(&object.Integer{Value: node.Value})
+	case *ast.FloatLiteralExpr:
+		return c.emitConstant(&object.Float{Value: node.Value})
+	case *ast.StringLiteralExpr:
+		return c.emitConstant(&object.String{Value: node.Value})
+	case *ast.BoolLiteralExpr:
+		if node.Value {
+			c.emit(OpTrue)
+		} else {
+			c.emit(OpFalse)
+		}
+	case *ast.NullExpr:
+		c.emit(OpNull)
+	case *ast.TemplateExpr:
+		parts := 0
+		for i, text := range node.Texts {
+			if text.Value != "" {
+				if err := c.emitConstant(&object.String{Value: text.Value}); err != nil {
+					return err
+				}
+				parts++
+			}
+			if i < len(node.Exprs) {
+				if err := c.compile(node.Exprs[i]); err != nil {
+					return err
+				}
+				parts++
+			}
+		}
+		c.emit(OpTemplate, parts)
+	case *ast.ArrayLiteralExpr:
+		for _, el := range node.Elements {
+			if err := c.compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(OpArray, len(node.Elements))
+	case *ast.HashLiteralExpr:
+		for _, pair := range node.Pairs {
+			if err := c.compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(OpHash, len(node.Pairs))
+	case *ast.PrefixExpr:
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := prefixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf(ERR_UNSUPPORTED_NODE, node)
+		}
+		c.emit(op)
+	case *ast.InfixExpr:
+		if node.Token.Type == token.OR || node.Token.Type == token.AND {
+			return c.compileLogicalExpr(node)
+		}
+
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf(ERR_UNSUPPORTED_NODE, node)
+		}
+		c.emit(op)
+	case *ast.IndexExpr:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(OpIndex)
+	case *ast.SliceExpr:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(OpNull)
+			} else if err := c.compile(bound); err != nil {
+				return err
+			}
+		}
+		c.emit(OpSlice)
+	case *ast.GetExpr:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		return c.emitNamed(OpGetProperty, node.Field.Value)
+	case *ast.SetExpr:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		err := c.compileAssignedValue(node.Token, node.Value, func() error {
+			c.emit(OpDup, 1)
+			return c.emitNamed(OpGetProperty, node.Field.Value)
+		})
+		if err != nil {
+			return err
+		}
+		return c.emitNamed(OpSetProperty, node.Field.Value)
+	case *ast.IndexSetExpr:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Index); err != nil {
+			return err
+		}
+		err := c.compileAssignedValue(node.Token, node.Value, func() error {
+			c.emit(OpDup, 2)
+			c.emit(OpIndex)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		c.emit(OpSetIndex)
+	case *ast.AssignExpr:
+		err := c.compileAssignedValue(node.Token, node.Expression, func() error {
+			c.getVariable(node.Identifier.Value)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		c.setVariable(node.Identifier.Value)
+	case *ast.IfExpr:
+		return c.compileIfExpr(node)
+	case *ast.IdentifierExpr:
+		c.getVariable(node.Value)
+	case *ast.ThisExpr:
+		c.getVariable(token.THIS_KEYWORD)
+	case *ast.SuperExpr:
+		c.getVariable(token.THIS_KEYWORD)
+		c.getVariable(token.SUPER_KEYWORD)
+		return c.emitNamed(OpGetSuper, node.Method.Value)
+	case *ast.FunctionExpr:
+		return c.compileFunction(node, "", plainFn)
+	case *ast.CallExpr:
+		return c.compileCall(node, OpCall)
+	default:
+		return fmt.Errorf(ERR_UNSUPPORTED_NODE, node)
+	}
+
+	return nil
+}
+
+// compileCall compiles callee and arguments of call made by op.
+func (c *Compiler) compileCall(node *ast.CallExpr, op Opcode) error {
+	if err := c.compile(node.Function); err != nil {
+		return err
+	}
+	for _, arg := range node.Arguments {
+		if err := c.compile(arg); err != nil {
+			return err
+		}
+	}
+	if len(node.Arguments) > math.MaxUint8 {
+		return fmt.Errorf(ERR_TOO_MANY_ARGUMENTS)
+	}
+	c.emit(op, len(node.Arguments))
+	return nil
+}
+
+// tailCalls reports whether calls returned by the function being compiled
+// may replace it, calls returned from try statements must complete before
+// them and initializers return the instance instead.
+func (c *Compiler) tailCalls() bool {
+	return (c.scope.kind == plainFn || c.scope.kind == methodFn) && len(c.scope.tries) == 0
+}
+
+// compileAssignedValue compiles value of assignment, for compound
+// assignment current emits the current value of the target first.
+func (c *Compiler) compileAssignedValue(assignment token.Token, value ast.Expression, current func() error) error {
+	operator, ok := token.CompoundOperator(assignment.Type)
+	if !ok {
+		return c.compile(value)
+	}
+
+	if err := current(); err != nil {
+		return err
+	}
+	if err := c.compile(value); err != nil {
+		return err
+	}
+
+	op, ok := infixOpcodes[operator]
+	if !ok {
+		return fmt.Errorf(ERR_UNSUPPORTED_NODE, value)
+	}
+	c.emit(op)
+
+	return nil
+}
+
+func (c *Compiler) compileLetStmt(node *ast.LetStmt) error {
+	name := node.Name.Value
+
+	if c.isGlobalScope() {
+		if err := c.compileValue(node.Value, name); err != nil {
+			return err
+		}
+		c.emit(OpDefineGlobal, c.global(name))
+		c.emit(OpResult)
+		return nil
+	}
+
+	// functions can refer to themselves, other values can not
+	if _, ok := node.Value.(*ast.FunctionExpr); ok {
+		if err := c.addLocal(name); err != nil {
+			return err
+		}
+		if err := c.compileValue(node.Value, name); err != nil {
+			return err
+		}
+	} else {
+		if err := c.compileValue(node.Value, name); err != nil {
+			return err
+		}
+		if err := c.addLocal(name); err != nil {
+			return err
+		}
+	}
+
+	c.emit(OpGetLocal, len(c.scope.locals)-1)
+	c.emit(OpResult)
+	return nil
+}
+
+// compileValue compiles initializer of variable, anonymous functions
+// get the name of variable.
+func (c *Compiler) compileValue(value ast.Expression, name string) error {
+	switch value := value.(type) {
+	case nil:
+		c.emit(OpNull)
+		return nil
+	case *ast.FunctionExpr:
+		return c.compileFunction(value, name, plainFn)
+	default:
+		return c.compile(value)
+	}
+}
+
+func (c *Compiler) compileImportStmt(node *ast.ImportStmt) error {
+	// path is relative to the file of the import statement, which the vm
+	// finds in its span
+	if err := c.emitWithConstant(OpImport, &object.String{Value: node.Path.Value}); err != nil {
+		return err
+	}
+
+	name := node.Name.Value
+	if c.isGlobalScope() {
+		c.emit(OpDefineGlobal, c.global(name))
+		c.emit(OpResult)
+		return nil
+	}
+
+	if err := c.addLocal(name); err != nil {
+		return err
+	}
+	c.emit(OpGetLocal, len(c.scope.locals)-1)
+	c.emit(OpResult)
+	return nil
+}
+
+func (c *Compiler) compileClassStmt(node *ast.ClassStmt) error {
+	name := node.Name.Value
+
+	template := &object.Class{Name: node.Name}
+	if err := c.emitWithConstant(OpClass, template); err != nil {
+		return err
+	}
+
+	if c.isGlobalScope() {
+		c.emit(OpDefineGlobal, c.global(name))
+		c.emit(OpPop)
+	} else if err := c.addLocal(name); err != nil {
+		return err
+	}
+
+	if node.Superclass != nil {
+		if err := c.compile(node.Superclass); err != nil {
+			return err
+		}
+
+		// superclass lives in a local captured by methods using 'super'
+		c.beginScope()
+		if err := c.addLocal(token.SUPER_KEYWORD); err != nil {
+			return err
+		}
+
+		c.getVariable(name)
+		c.emit(OpInherit)
+	}
+
+	c.getVariable(name)
+	for _, field := range node.Methods {
+		// parser only allows let expressions with functionExpr values for now
+		method, _ := field.Value.(*ast.FunctionExpr)
+
+		kind := methodFn
+		if field.Name.Value == token.INITIALIZER_KEYWORD {
+			kind = initFn
+		}
+
+		if err := c.compileFunction(method, field.Name.Value, kind); err != nil {
+			return err
+		}
+		if err := c.emitNamed(OpMethod, field.Name.Value); err != nil {
+			return err
+		}
+	}
+	c.emit(OpPop)
+
+	if node.Superclass != nil {
+		c.endScope()
+	}
+
+	c.getVariable(name)
+	c.emit(OpResult)
+	return nil
+}
+
+func (c *Compiler) compileWhileStmt(node *ast.WhileStmt) error {
+	start := c.offset()
+
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(OpJumpIfFalse, 0)
+
+	lp := c.beginLoop()
+	if err := c.compileLoopBody(node.Body); err != nil {
+		return err
+	}
+	if err := c.patchJumps(lp.continues, start); err != nil {
+		return err
+	}
+	c.emit(OpJump, start)
+
+	if err := c.patchJumps(append(lp.breaks, exit), c.offset()); err != nil {
+		return err
+	}
+	c.endLoop()
+
+	c.emit(OpNull)
+	c.emit(OpResult)
+	return nil
+}
+
+func (c *Compiler) compileForStmt(node *ast.ForStmt) error {
+	c.beginScope()
+
+	if node.Init != nil {
+		if err := c.compile(node.Init); err != nil {
+			return err
+		}
+	}
+
+	start := c.offset()
+
+	exits := []int{}
+	if node.Condition != nil {
+		if err := c.compile(node.Condition); err != nil {
+			return err
+		}
+		exits = append(exits, c.emit(OpJumpIfFalse, 0))
+	}
+
+	lp := c.beginLoop()
+	if err := c.compileLoopBody(node.Body); err != nil {
+		return err
+	}
+
+	if err := c.patchJumps(lp.continues, c.offset()); err != nil {
+		return err
+	}
+	if node.Update != nil {
+		if err := c.compile(node.Update); err != nil {
+			return err
+		}
+		c.emit(OpPop)
+	}
+	c.emit(OpJump, start)
+
+	if err := c.patchJumps(append(lp.breaks, exits...), c.offset()); err != nil {
+		return err
+	}
+	c.endLoop()
+	c.endScope()
+
+	c.emit(OpNull)
+	c.emit(OpResult)
+	return nil
+}
+
+func (c *Compiler) compileForInStmt(node *ast.ForInStmt) error {
+	if err := c.compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(OpIter)
+
+	c.beginScope()
+	// hidden local holding the iterator
+	if err := c.addLocal(""); err != nil {
+		return err
+	}
+
+	lp := c.beginLoop()
+	start := c.offset()
+	exit := c.emit(OpIterNext, 0)
+
+	// every iteration gets own variable so closures capture current item
+	c.beginScope()
+	if err := c.addLocal(node.Variable.Value); err != nil {
+		return err
+	}
+	if err := c.compile(node.Body); err != nil {
+		return err
+	}
+	c.endScope()
+
+	if err := c.patchJumps(lp.continues, start); err != nil {
+		return err
+	}
+	c.emit(OpJump, start)
+
+	if err := c.patchJumps(append(lp.breaks, exit), c.offset()); err != nil {
+		return err
+	}
+	c.endLoop()
+	c.endScope()
+
+	c.emit(OpNull)
+	c.emit(OpResult)
+	return nil
+}
+
+// compileTryStmt protects body with handler of catch block, and both of
+// them with handler running finally block and rethrowing the exception.
+// Finally block is also copied to every way out of the body and catch block.
+func (c *Compiler) compileTryStmt(node *ast.TryStmt) error {
+	try := &tryBlock{finally: node.Finally}
+	c.scope.tries = append(c.scope.tries, try)
+
+	rethrow := -1
+	if node.Finally != nil {
+		rethrow = c.emit(OpTry, 0)
+		try.handlers++
+	}
+	catch := -1
+	if node.Catch != nil {
+		catch = c.emit(OpTry, 0)
+		try.handlers++
+	}
+
+	if err := c.compile(node.Body); err != nil {
+		return err
+	}
+
+	if node.Catch != nil {
+		c.emit(OpEndTry)
+		try.handlers--
+		end := c.emit(OpJump, 0)
+
+		if err := c.patchJumps([]int{catch}, c.offset()); err != nil {
+			return err
+		}
+		// caught exception is pushed by the handler
+		c.beginScope()
+		if err := c.addLocal(node.Param.Value); err != nil {
+			return err
+		}
+		if err := c.compile(node.Catch); err != nil {
+			return err
+		}
+		c.endScope()
+
+		if err := c.patchJumps([]int{end}, c.offset()); err != nil {
+			return err
+		}
+	}
+
+	c.scope.tries = c.scope.tries[:len(c.scope.tries)-1]
+	if node.Finally == nil {
+		return nil
+	}
+
+	c.emit(OpEndTry)
+	// value of the statement is the value of body or catch block
+	c.beginScope()
+	c.emit(OpLastResult)
+	if err := c.addLocal(""); err != nil {
+		return err
+	}
+	if err := c.compile(node.Finally); err != nil {
+		return err
+	}
+	c.emit(OpGetLocal, len(c.scope.locals)-1)
+	c.emit(OpResult)
+	c.endScope()
+	end := c.emit(OpJump, 0)
+
+	if err := c.patchJumps([]int{rethrow}, c.offset()); err != nil {
+		return err
+	}
+	c.beginScope()
+	if err := c.addLocal(""); err != nil {
+		return err
+	}
+	if err := c.compile(node.Finally); err != nil {
+		return err
+	}
+	c.emit(OpGetLocal, len(c.scope.locals)-1)
+	c.emit(OpThrow)
+	c.forgetScope()
+
+	return c.patchJumps([]int{end}, c.offset())
+}
+
+// compileReturnFromTry runs finally blocks of try statements the return
+// leaves, returned value waits for them in a hidden local.
+func (c *Compiler) compileReturnFromTry() error {
+	c.beginScope()
+	if err := c.addLocal(""); err != nil {
+		return err
+	}
+	if err := c.leaveTries(0); err != nil {
+		return err
+	}
+	c.emit(OpGetLocal, len(c.scope.locals)-1)
+	c.emit(OpReturn)
+	c.forgetScope()
+	return nil
+}
+
+// hasFinally reports whether any of try statements entered after the first
+// outer ones has finally block.
+func (c *Compiler) hasFinally(outer int) bool {
+	for _, try := range c.scope.tries[outer:] {
+		if try.finally != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// leaveTries emits instructions jumping out of try statements entered
+// after the first outer ones: their handlers are removed and finally
+// blocks run, innermost first.
+func (c *Compiler) leaveTries(outer int) error {
+	tries := c.scope.tries
+	defer func() { c.scope.tries = tries }()
+
+	for i := len(tries) - 1; i >= outer; i-- {
+		for j := 0; j < tries[i].handlers; j++ {
+			c.emit(OpEndTry)
+		}
+		if tries[i].finally != nil {
+			// finally block is not protected by its own statement
+			c.scope.tries = tries[:i]
+			if err := c.compile(tries[i].finally); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compileLoopBody compiles body in its own scope, so variables declared
+// by single statement bodies do not pile up on the stack.
+func (c *Compiler) compileLoopBody(body ast.Statement) error {
+	c.beginScope()
+	if err := c.compile(body); err != nil {
+		return err
+	}
+	c.endScope()
+	return nil
+}
+
+func (c *Compiler) compileIfExpr(node *ast.IfExpr) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	elseJump := c.emit(OpJumpIfFalse, 0)
+
+	if err := c.compileBranch(node.Then); err != nil {
+		return err
+	}
+	endJump := c.emit(OpJump, 0)
+
+	if err := c.patchJumps([]int{elseJump}, c.offset()); err != nil {
+		return err
+	}
+	if node.Else != nil {
+		if err := c.compileBranch(node.Else); err != nil {
+			return err
+		}
+	} else {
+		c.emit(OpNull)
+	}
+
+	return c.patchJumps([]int{endJump}, c.offset())
+}
+
+// compileBranch pushes the value of the last statement executed in branch.
+func (c *Compiler) compileBranch(branch ast.Statement) error {
+	c.beginScope()
+	if err := c.compile(branch); err != nil {
+		return err
+	}
+	c.endScope()
+	c.emit(OpLastResult)
+	return nil
+}
+
+func (c *Compiler) compileLogicalExpr(node *ast.InfixExpr) error {
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+
+	op := OpJumpIfTrueKeep
+	if node.Token.Type == token.AND {
+		op = OpJumpIfFalseKeep
+	}
+	jump := c.emit(op, 0)
+
+	c.emit(OpPop)
+	if err := c.compile(node.Right); err != nil {
+		return err
+	}
+
+	return c.patchJumps([]int{jump}, c.offset())
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionExpr, name string, kind fnKind) error {
+	fn := &object.CompiledFunction{
+		Name:       name,
+		NumParams:  len(node.Parameters),
+		IsInit:     kind == initFn,
+		Parameters: node.Parameters,
+		Body:       node.Body,
+	}
+
+	c.scope = &scope{enclosing: c.scope, kind: kind, fn: fn, depth: 1}
+
+	// slot 0 holds the receiver of methods and the function itself otherwise
+	receiver := ""
+	if kind == methodFn || kind == initFn {
+		receiver = token.THIS_KEYWORD
+	}
+	c.addLocal(receiver)
+
+	for _, p := range node.Parameters {
+		if err := c.addLocal(p.Value); err != nil {
+			return err
+		}
+	}
+
+	if len(node.Body.Statements) == 0 {
+		c.emit(OpNull)
+		c.emit(OpResult)
+	}
+	for _, stmt := range node.Body.Statements {
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+	}
+
+	if kind == initFn {
+		c.emit(OpGetLocal, 0)
+	} else {
+		c.emit(OpLastResult)
+	}
+	c.emit(OpReturn)
+
+	c.scope = c.scope.enclosing
+
+	return c.emitWithConstant(OpClosure, fn)
+}
+
+func (c *Compiler) getVariable(name string) {
+	if slot := resolveLocal(c.scope, name); slot != -1 {
+		c.emit(OpGetLocal, slot)
+	} else if idx := resolveUpvalue(c.scope, name); idx != -1 {
+		c.emit(OpGetUpvalue, idx)
+	} else {
+		c.emit(OpGetGlobal, c.global(name))
+	}
+}
+
+func (c *Compiler) setVariable(name string) {
+	if slot := resolveLocal(c.scope, name); slot != -1 {
+		c.emit(OpSetLocal, slot)
+	} else if idx := resolveUpvalue(c.scope, name); idx != -1 {
+		c.emit(OpSetUpvalue, idx)
+	} else {
+		c.emit(OpSetGlobal, c.global(name))
+	}
+}
+
+func resolveLocal(s *scope, name string) int {
+	for i := len(s.locals) - 1; i >= 0; i-- {
+		if s.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func resolveUpvalue(s *scope, name string) int {
+	if s.enclosing == nil {
+		return -1
+	}
+
+	if slot := resolveLocal(s.enclosing, name); slot != -1 {
+		s.enclosing.locals[slot].captured = true
+		return addCapture(s, object.Capture{Local: true, Index: slot})
+	}
+
+	if idx := resolveUpvalue(s.enclosing, name); idx != -1 {
+		return addCapture(s, object.Capture{Local: false, Index: idx})
+	}
+
+	return -1
+}
+
+func addCapture(s *scope, capture object.Capture) int {
+	for i, c := range s.fn.Captures {
+		if c == capture {
+			return i
+		}
+	}
+	s.fn.Captures = append(s.fn.Captures, capture)
+	return len(s.fn.Captures) - 1
+}
+
+func (c *Compiler) isGlobalScope() bool {
+	return c.scope.kind == scriptFn && c.scope.depth == 0
+}
+
+// Global returns slot of global variable referred to by compiled code.
+func (c *Compiler) Global(name string) (int, bool) {
+	idx, ok := c.globals[name]
+	return idx, ok
+}
+
+func (c *Compiler) global(name string) int {
+	if idx, ok := c.globals[name]; ok {
+		return idx
+	}
+	c.globalNames = append(c.globalNames, name)
+	c.globals[name] = len(c.globalNames) - 1
+	return c.globals[name]
+}
+
+// addLocal declares variable living in the stack slot of the value
+// which was pushed last.
+func (c *Compiler) addLocal(name string) error {
+	if len(c.scope.locals) > math.MaxUint16 {
+		return fmt.Errorf(ERR_TOO_MANY_LOCALS)
+	}
+	c.scope.locals = append(c.scope.locals, local{name: name, depth: c.scope.depth})
+	return nil
+}
+
+func (c *Compiler) beginScope() {
+	c.scope.depth++
+}
+
+// forgetScope ends scope left by jump, its locals are discarded by the
+// jump target.
+func (c *Compiler) forgetScope() {
+	c.scope.depth--
+
+	locals := c.scope.locals
+	for len(locals) > 0 && locals[len(locals)-1].depth > c.scope.depth {
+		locals = locals[:len(locals)-1]
+	}
+	c.scope.locals = locals
+}
+
+func (c *Compiler) endScope() {
+	c.discardLocals(c.scope.depth - 1)
+	c.forgetScope()
+}
+
+// discardLocals emits instructions removing from the stack locals deeper
+// than depth, it does not forget them as jumps leave the scope only at runtime.
+func (c *Compiler) discardLocals(depth int) {
+	locals := c.scope.locals
+	for i := len(locals) - 1; i >= 0 && locals[i].depth > depth; i-- {
+		if locals[i].captured {
+			c.emit(OpCloseUpvalue)
+		} else {
+			c.emit(OpPop)
+		}
+	}
+}
+
+func (c *Compiler) beginLoop() *loop {
+	lp := &loop{depth: c.scope.depth, tries: len(c.scope.tries)}
+	c.scope.loops = append(c.scope.loops, lp)
+	return lp
+}
+
+func (c *Compiler) endLoop() {
+	c.scope.loops = c.scope.loops[:len(c.scope.loops)-1]
+}
+
+func (c *Compiler) at(node ast.Node) func() {
+	prev := c.span
+	c.span = ast.Span(node)
+	return func() { c.span = prev }
+}
+
+func (c *Compiler) offset() int {
+	return len(c.scope.fn.Instructions)
+}
+
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	fn := c.scope.fn
+	pos := len(fn.Instructions)
+
+	ins := Make(op, operands...)
+	fn.Instructions = append(fn.Instructions, ins...)
+	for range ins {
+		fn.Spans = append(fn.Spans, c.span)
+	}
+
+	return pos
+}
+
+func (c *Compiler) emitConstant(obj object.Object) error {
+	return c.emitWithConstant(OpConstant, obj)
+}
+
+func (c *Compiler) emitNamed(op Opcode, name string) error {
+	return c.emitWithConstant(op, &object.String{Value: name})
+}
+
+// emitWithConstant emits instruction with the index of obj in constant pool.
+func (c *Compiler) emitWithConstant(op Opcode, obj object.Object) error {
+	if len(c.constants) > math.MaxUint16 {
+		return fmt.Errorf(ERR_TOO_MANY_CONSTANTS)
+	}
+	c.constants = append(c.constants, obj)
+	c.emit(op, len(c.constants)-1)
+	return nil
+}
+
+// patchJumps points jump instructions at given positions to target.
+func (c *Compiler) patchJumps(jumps []int, target int) error {
+	if target > math.MaxUint16 {
+		return fmt.Errorf(ERR_JUMP_TOO_FAR)
+	}
+
+	ins := c.scope.fn.Instructions
+	for _, pos := range jumps {
+		copy(ins[pos:], Make(Opcode(ins[pos]), target))
+	}
+	return nil
+}
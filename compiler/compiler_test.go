@@ -0,0 +1,212 @@
+package compiler_test
+
+import (
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tt := []struct {
+		op       compiler.Opcode
+		operands []int
+		want     []byte
+	}{
+		{compiler.OpConstant, []int{65534}, []byte{byte(compiler.OpConstant), 255, 254}},
+		{compiler.OpAdd, []int{}, []byte{byte(compiler.OpAdd)}},
+		{compiler.OpCall, []int{3}, []byte{byte(compiler.OpCall), 3}},
+	}
+
+	for _, tc := range tt {
+		got := compiler.Make(tc.op, tc.operands...)
+
+		if string(got) != string(tc.want) {
+			t.Errorf("Wrong instruction, got %v, want %v.", got, tc.want)
+		}
+
+		def, err := compiler.Lookup(tc.op)
+		if err != nil {
+			t.Fatalf("Definition not found: %s.", err)
+		}
+
+		operands, read := compiler.ReadOperands(def, got[1:])
+		if read != len(got)-1 {
+			t.Errorf("Wrong number of bytes read, got %d, want %d.", read, len(got)-1)
+		}
+		for i, o := range operands {
+			if o != tc.operands[i] {
+				t.Errorf("Wrong operand, got %d, want %d.", o, tc.operands[i])
+			}
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tt := []struct {
+		source string
+		want   string
+	}{
+		{
+			source: "1 + 2;",
+			want: `0000 OpConstant 0
+0003 OpConstant 1
+0006 OpAdd
+0007 OpResult
+0008 OpLastResult
+0009 OpReturn
+`,
+		},
+		{
+			source: "let x = 1; x;",
+			want: `0000 OpConstant 0
+0003 OpDefineGlobal 0
+0006 OpResult
+0007 OpGetGlobal 0
+0010 OpResult
+0011 OpLastResult
+0012 OpReturn
+`,
+		},
+		{
+			source: "{ let x = 1; }",
+			want: `0000 OpConstant 0
+0003 OpGetLocal 1
+0006 OpResult
+0007 OpPop
+0008 OpLastResult
+0009 OpReturn
+`,
+		},
+		{
+			source: `"a${1}";`,
+			want: `0000 OpConstant 0
+0003 OpConstant 1
+0006 OpTemplate 2
+0009 OpResult
+0010 OpLastResult
+0011 OpReturn
+`,
+		},
+		{
+			source: "let xs = [1]; xs[0] += 2;",
+			want: `0000 OpConstant 0
+0003 OpArray 1
+0006 OpDefineGlobal 0
+0009 OpResult
+0010 OpGetGlobal 0
+0013 OpConstant 1
+0016 OpDup 2
+0018 OpIndex
+0019 OpConstant 2
+0022 OpAdd
+0023 OpSetIndex
+0024 OpResult
+0025 OpLastResult
+0026 OpReturn
+`,
+		},
+		{
+			source: "true && false;",
+			want: `0000 OpTrue
+0001 OpJumpIfFalseKeep 6
+0004 OpPop
+0005 OpFalse
+0006 OpResult
+0007 OpLastResult
+0008 OpReturn
+`,
+		},
+		{
+			source: "while (true) {}",
+			want: `0000 OpTrue
+0001 OpJumpIfFalse 9
+0004 OpNull
+0005 OpResult
+0006 OpJump 0
+0009 OpNull
+0010 OpResult
+0011 OpLastResult
+0012 OpReturn
+`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			bytecode := compileSource(t, tc.source)
+
+			got := compiler.Disassemble(bytecode.Main.Instructions)
+			if got != tc.want {
+				t.Errorf("Wrong instructions, got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompileClosure(t *testing.T) {
+	bytecode := compileSource(t, "{ let x = 1; fn f() { x } }")
+
+	var fn *object.CompiledFunction
+	for _, c := range bytecode.Constants {
+		if c, ok := c.(*object.CompiledFunction); ok {
+			fn = c
+		}
+	}
+
+	if fn == nil {
+		t.Fatalf("Compiled function not found in constants %v.", bytecode.Constants)
+	}
+	if fn.Name != "f" {
+		t.Errorf("Wrong function name, got %q, want %q.", fn.Name, "f")
+	}
+
+	want := []object.Capture{{Local: true, Index: 1}}
+	if len(fn.Captures) != len(want) || fn.Captures[0] != want[0] {
+		t.Errorf("Wrong captures, got %v, want %v.", fn.Captures, want)
+	}
+
+	got := compiler.Disassemble(fn.Instructions)
+	wantIns := `0000 OpGetUpvalue 0
+0003 OpResult
+0004 OpLastResult
+0005 OpReturn
+`
+	if got != wantIns {
+		t.Errorf("Wrong instructions, got\n%s\nwant\n%s", got, wantIns)
+	}
+}
+
+func TestCompileTailCall(t *testing.T) {
+	bytecode := compileSource(t, "fn f(n) { try { return f(n); } catch (e) {} return f(n); } return f(1);")
+
+	got := compiler.Disassemble(bytecode.Main.Instructions)
+	if !strings.Contains(got, "OpCall 1") || strings.Contains(got, "OpTailCall") {
+		t.Errorf("Return from program should not be a tail call, got\n%s", got)
+	}
+
+	fn := bytecode.Constants[0].(*object.CompiledFunction)
+	got = compiler.Disassemble(fn.Instructions)
+	if strings.Count(got, "OpCall 1") != 1 || strings.Count(got, "OpTailCall 1") != 1 {
+		t.Errorf("Only return outside of try should be a tail call, got\n%s", got)
+	}
+}
+
+func compileSource(t testing.TB, source string) *compiler.Bytecode {
+	t.Helper()
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Error while parsing %q.", source)
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("Error while compiling %q: %s.", source, err)
+	}
+
+	return c.Bytecode()
+}
@@ -0,0 +1,491 @@
+// Package dap exposes the debugger through Debug Adapter Protocol,
+// so programs run by the tree-walking evaluator can be debugged from editors.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/checker"
+	"monkey/debugger"
+	"monkey/diagnostics"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/transport"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// programs run on a single thread
+const threadID = 1
+
+// Server handles requests of a single debugging session.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// mu guards writes to out and the fields below
+	mu  sync.Mutex
+	seq int
+
+	debugger    *debugger.Debugger
+	program     *ast.Program
+	locals      map[ast.Expression]int
+	stopOnEntry bool
+	configured  bool
+	started     bool
+
+	// stop is the state of paused program, nil while it runs
+	stop *debugger.Stop
+	// handles are targets of variable references, reference is index + 1,
+	// they are valid until the program resumes
+	handles []interface{}
+
+	resume chan debugger.Action
+	done   chan struct{}
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	s := &Server{
+		in:     bufio.NewReader(in),
+		out:    out,
+		resume: make(chan debugger.Action),
+		done:   make(chan struct{}),
+	}
+	s.debugger = debugger.New(s)
+	s.debugger.SetStdout(outputWriter{s})
+	return s
+}
+
+// Debugger returns debugger running the launched program, it can be
+// configured before the session starts, e.g. granted capabilities.
+func (s *Server) Debugger() *debugger.Debugger {
+	return s.debugger
+}
+
+// Serve handles requests until the client disconnects or closes input.
+func (s *Server) Serve() error {
+	for {
+		content, err := transport.ReadMessage(s.in)
+		if err == io.EOF {
+			s.abort()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req Request
+		if err := json.Unmarshal(content, &req); err != nil {
+			return fmt.Errorf("invalid message: %s", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		if !s.handle(&req) {
+			return nil
+		}
+	}
+}
+
+// Done is closed when the launched program ends.
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// Output sends text printed by the program to the client.
+func (s *Server) Output(category string, text string) {
+	s.sendEvent("output", OutputEvent{Category: category, Output: text})
+}
+
+// outputWriter forwards text printed by the program to the client.
+type outputWriter struct {
+	s *Server
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.Output("stdout", string(p))
+	return len(p), nil
+}
+
+// handle responds to req, it returns false when the session ends.
+func (s *Server) handle(req *Request) bool {
+	var body interface{}
+	var err error
+
+	switch req.Command {
+	case "initialize":
+		body = Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}
+		s.respond(req, body, nil)
+		s.sendEvent("initialized", nil)
+		return true
+	case "launch":
+		err = s.launch(req.Arguments)
+	case "setBreakpoints":
+		body, err = s.setBreakpoints(req.Arguments)
+	case "setExceptionBreakpoints":
+		body = map[string]interface{}{"breakpoints": []Breakpoint{}}
+	case "configurationDone":
+		s.mu.Lock()
+		s.configured = true
+		s.mu.Unlock()
+	case "threads":
+		body = map[string]interface{}{"threads": []Thread{{ID: threadID, Name: "main"}}}
+	case "continue":
+		body = map[string]interface{}{"allThreadsContinued": true}
+		err = s.paused()
+	case "next", "stepIn", "stepOut":
+		err = s.paused()
+	case "stackTrace":
+		body, err = s.stackTrace(req.Arguments)
+	case "scopes":
+		body, err = s.scopes(req.Arguments)
+	case "variables":
+		body, err = s.variables(req.Arguments)
+	case "evaluate":
+		body, err = s.evaluate(req.Arguments)
+	case "disconnect", "terminate":
+	default:
+		err = fmt.Errorf("unsupported command %q", req.Command)
+	}
+
+	s.respond(req, body, err)
+	if err != nil {
+		return true
+	}
+
+	switch req.Command {
+	case "launch", "configurationDone":
+		s.start()
+	case "continue":
+		s.resume <- debugger.Continue
+	case "next":
+		s.resume <- debugger.StepOver
+	case "stepIn":
+		s.resume <- debugger.StepIn
+	case "stepOut":
+		s.resume <- debugger.StepOut
+	case "terminate":
+		s.abort()
+	case "disconnect":
+		s.abort()
+		return false
+	}
+	return true
+}
+
+func (s *Server) launch(arguments json.RawMessage) error {
+	var args LaunchArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(args.Program)
+	if err != nil {
+		return fmt.Errorf("can not read file %q: %s", args.Program, err)
+	}
+	source := string(data)
+
+	p := parser.New(lexer.NewFile(args.Program, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return parseError(source, p.Errors())
+	}
+	r := resolver.New()
+	r.Resolve(program)
+	if len(r.Errors()) != 0 {
+		return parseError(source, r.Errors())
+	}
+	c := checker.New(r.Definitions())
+	c.Check(program)
+	if diagnostics.HasErrors(c.Errors()) {
+		return parseError(source, c.Errors())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.program, s.locals, s.stopOnEntry = program, r.Locals(), args.StopOnEntry
+	return nil
+}
+
+func parseError(source string, errors []diagnostics.Diagnostic) error {
+	var out strings.Builder
+	diagnostics.RenderText(&out, source, errors)
+	return fmt.Errorf("%s", strings.TrimRight(out.String(), "\n"))
+}
+
+// start runs the program once it is launched and configured.
+func (s *Server) start() {
+	s.mu.Lock()
+	if s.started || !s.configured || s.program == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	go func() {
+		defer close(s.done)
+
+		exitCode := 0
+		result := s.debugger.Run(s.program, s.locals)
+		if err, ok := result.(*object.Error); ok {
+			s.Output("stderr", err.StackTrace()+"\n")
+			exitCode = 70
+		}
+
+		s.sendEvent("exited", ExitedEvent{ExitCode: exitCode})
+		s.sendEvent("terminated", nil)
+	}()
+}
+
+// abort stops the paused program.
+func (s *Server) abort() {
+	if s.paused() == nil {
+		s.resume <- debugger.Abort
+	}
+}
+
+func (s *Server) Paused(stop *debugger.Stop) debugger.Action {
+	if stop.Reason == debugger.ENTRY && !s.stopOnEntry {
+		return debugger.Continue
+	}
+
+	s.mu.Lock()
+	s.stop, s.handles = stop, nil
+	s.mu.Unlock()
+
+	s.sendEvent("stopped", StoppedEvent{Reason: stop.Reason, ThreadID: threadID, AllThreadsStopped: true})
+	action := <-s.resume
+
+	s.mu.Lock()
+	s.stop, s.handles = nil, nil
+	s.mu.Unlock()
+	return action
+}
+
+func (s *Server) paused() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return fmt.Errorf("program is not paused")
+	}
+	return nil
+}
+
+func (s *Server) setBreakpoints(arguments json.RawMessage) (interface{}, error) {
+	var args SetBreakpointsArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	s.debugger.ClearBreakpoints(args.Source.Path)
+	breakpoints := []Breakpoint{}
+	for _, bp := range args.Breakpoints {
+		s.debugger.SetBreakpoint(args.Source.Path, bp.Line)
+		breakpoints = append(breakpoints, Breakpoint{Verified: true, Line: bp.Line, Source: &args.Source})
+	}
+	return map[string]interface{}{"breakpoints": breakpoints}, nil
+}
+
+// frames returns frames of the paused program, frame id is index + 1.
+func (s *Server) frames() ([]debugger.StackFrame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return nil, fmt.Errorf("program is not paused")
+	}
+	return s.stop.Frames(), nil
+}
+
+func (s *Server) frame(id int) (debugger.StackFrame, error) {
+	frames, err := s.frames()
+	if err != nil {
+		return debugger.StackFrame{}, err
+	}
+	if id < 1 || id > len(frames) {
+		return debugger.StackFrame{}, fmt.Errorf("unknown frame %d", id)
+	}
+	return frames[id-1], nil
+}
+
+func (s *Server) stackTrace(arguments json.RawMessage) (interface{}, error) {
+	var args StackTraceArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	frames, err := s.frames()
+	if err != nil {
+		return nil, err
+	}
+
+	stackFrames := []StackFrame{}
+	for i, frame := range frames {
+		if i < args.StartFrame || args.Levels > 0 && len(stackFrames) == args.Levels {
+			continue
+		}
+		stackFrames = append(stackFrames, StackFrame{
+			ID:     i + 1,
+			Name:   frame.Name,
+			Source: source(frame.Pos.File),
+			Line:   frame.Pos.Line,
+			Column: frame.Pos.Column,
+		})
+	}
+	return map[string]interface{}{"stackFrames": stackFrames, "totalFrames": len(frames)}, nil
+}
+
+func source(path string) *Source {
+	if path == "" {
+		return nil
+	}
+	parts := strings.Split(path, string(os.PathSeparator))
+	return &Source{Name: parts[len(parts)-1], Path: path}
+}
+
+func (s *Server) scopes(arguments json.RawMessage) (interface{}, error) {
+	var args ScopesArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	if frame.Env != nil {
+		variables := debugger.Scopes(frame.Env)
+		for i, vars := range variables {
+			scope := Scope{Name: "Closure", VariablesReference: s.reference(vars)}
+			switch {
+			case i == len(variables)-1:
+				scope.Name = "Globals"
+			case i == 0:
+				scope.Name, scope.PresentationHint = "Locals", "locals"
+			}
+			scopes = append(scopes, scope)
+		}
+	}
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+// reference returns new variable reference of target, which is
+// a scope or an object with fields or elements.
+func (s *Server) reference(target interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handles = append(s.handles, target)
+	return len(s.handles)
+}
+
+func (s *Server) variables(arguments json.RawMessage) (interface{}, error) {
+	var args VariablesArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	ref := args.VariablesReference
+	if ref < 1 || ref > len(s.handles) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("unknown variables reference %d", ref)
+	}
+	target := s.handles[ref-1]
+	s.mu.Unlock()
+
+	variables := []Variable{}
+	for _, v := range children(target) {
+		variables = append(variables, s.variable(v.Name, v.Value))
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+func (s *Server) variable(name string, value object.Object) Variable {
+	v := Variable{Name: name, Value: value.Inspect(), Type: string(value.Type())}
+	if len(children(value)) > 0 {
+		v.VariablesReference = s.reference(value)
+	}
+	return v
+}
+
+// children returns variables of scope, fields of instance
+// and elements of array or hash.
+func children(target interface{}) []debugger.Variable {
+	vars := []debugger.Variable{}
+	switch target := target.(type) {
+	case []debugger.Variable:
+		vars = target
+	case *object.Instance:
+		for name, value := range target.Fields {
+			vars = append(vars, debugger.Variable{Name: name, Value: value})
+		}
+		sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	case *object.Array:
+		for i, el := range target.Elements {
+			vars = append(vars, debugger.Variable{Name: strconv.Itoa(i), Value: el})
+		}
+	case *object.Hash:
+		for _, pair := range target.Pairs() {
+			vars = append(vars, debugger.Variable{Name: pair.Key.Inspect(), Value: pair.Value})
+		}
+	}
+	return vars
+}
+
+func (s *Server) evaluate(arguments json.RawMessage) (interface{}, error) {
+	var args EvaluateArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	if frame.Env == nil {
+		return nil, fmt.Errorf("frame %s has no variables yet", frame.Name)
+	}
+
+	result, err := s.debugger.Evaluate(args.Expression, frame.Env)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := result.(*object.Error); ok {
+		return nil, fmt.Errorf("%s", result.Message)
+	}
+
+	v := s.variable("", result)
+	return EvaluateResponse{Result: v.Value, Type: v.Type, VariablesReference: v.VariablesReference}, nil
+}
+
+func (s *Server) respond(req *Request, body interface{}, err error) {
+	resp := &Response{Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message, resp.Body = err.Error(), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	resp.Seq = s.seq
+	transport.WriteMessage(s.out, resp)
+}
+
+func (s *Server) sendEvent(event string, body interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	transport.WriteMessage(s.out, &Event{Seq: s.seq, Type: "event", Event: event, Body: body})
+}
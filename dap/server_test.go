@@ -0,0 +1,357 @@
+package dap_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"monkey/dap"
+	"monkey/transport"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const script = `class Point {
+  fn init(x) { this.x = x; }
+  fn double() {
+    let y = this.x * 2;
+    return y;
+  }
+}
+let p = Point(3);
+let xs = [p.double(), {| "a": p |}];
+xs[0] + 1;`
+
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	Event      string          `json:"event"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+type client struct {
+	t        *testing.T
+	in       io.WriteCloser
+	messages chan message
+	events   []message
+	seq      int
+	served   chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, messages: make(chan message, 100), served: make(chan error, 1)}
+
+	s := dap.NewServer(inR, outW)
+	go func() { c.served <- s.Serve() }()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			content, err := transport.ReadMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var m message
+			if err := json.Unmarshal(content, &m); err != nil {
+				t.Errorf("Invalid message %s: %s", content, err)
+			}
+			c.messages <- m
+		}
+	}()
+	return c
+}
+
+func (c *client) next() message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("Server closed output.")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("Timeout waiting for message.")
+	}
+	return message{}
+}
+
+// request sends request and returns its response, events sent
+// before the response are queued.
+func (c *client) request(command string, args interface{}, body interface{}) message {
+	c.t.Helper()
+	c.seq++
+	req := dap.Request{Seq: c.seq, Type: "request", Command: command}
+	if args != nil {
+		data, _ := json.Marshal(args)
+		req.Arguments = data
+	}
+	if err := transport.WriteMessage(c.in, req); err != nil {
+		c.t.Fatalf("Can not send request: %s", err)
+	}
+
+	for {
+		m := c.next()
+		if m.Type == "event" {
+			c.events = append(c.events, m)
+			continue
+		}
+		if m.RequestSeq != c.seq || m.Command != command {
+			c.t.Fatalf("Unexpected response %+v to %s.", m, command)
+		}
+		if body != nil && m.Success {
+			if err := json.Unmarshal(m.Body, body); err != nil {
+				c.t.Fatalf("Invalid body of %s: %s", command, err)
+			}
+		}
+		return m
+	}
+}
+
+// event waits for event with name, skipping other events.
+func (c *client) event(name string, body interface{}) {
+	c.t.Helper()
+	for {
+		var m message
+		if len(c.events) > 0 {
+			m, c.events = c.events[0], c.events[1:]
+		} else {
+			m = c.next()
+		}
+		if m.Type == "event" && m.Event == name {
+			if body != nil {
+				json.Unmarshal(m.Body, body)
+			}
+			return
+		}
+	}
+}
+
+func writeScript(t *testing.T, source string) string {
+	path := filepath.Join(t.TempDir(), "test.mk")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSession(t *testing.T) {
+	path := writeScript(t, script)
+	c := newClient(t)
+
+	var caps dap.Capabilities
+	c.request("initialize", map[string]string{"adapterID": "monkey"}, &caps)
+	if !caps.SupportsConfigurationDoneRequest {
+		t.Errorf("Expect configurationDone support.")
+	}
+	c.event("initialized", nil)
+
+	if resp := c.request("launch", dap.LaunchArguments{Program: path}, nil); !resp.Success {
+		t.Fatalf("Launch failed: %s", resp.Message)
+	}
+
+	var bps struct{ Breakpoints []dap.Breakpoint }
+	c.request("setBreakpoints", dap.SetBreakpointsArguments{
+		Source:      dap.Source{Path: path},
+		Breakpoints: []dap.SourceBreakpoint{{Line: 5}, {Line: 6}},
+	}, &bps)
+	if len(bps.Breakpoints) != 2 || !bps.Breakpoints[0].Verified {
+		t.Errorf("Wrong breakpoints %+v.", bps.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+
+	var stopped dap.StoppedEvent
+	c.event("stopped", &stopped)
+	if stopped.Reason != "breakpoint" {
+		t.Errorf("Wrong stop reason %q.", stopped.Reason)
+	}
+
+	var trace struct{ StackFrames []dap.StackFrame }
+	c.request("stackTrace", dap.StackTraceArguments{ThreadID: 1}, &trace)
+	got := []string{}
+	for _, f := range trace.StackFrames {
+		got = append(got, f.Name+":"+strconv.Itoa(f.Line))
+	}
+	if strings.Join(got, " ") != "Point.double:5 <script>:9" {
+		t.Errorf("Wrong stack trace %q.", got)
+	}
+
+	var scopes struct{ Scopes []dap.Scope }
+	c.request("scopes", dap.ScopesArguments{FrameID: trace.StackFrames[0].ID}, &scopes)
+	names := []string{}
+	for _, s := range scopes.Scopes {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, " ") != "Locals Closure Globals" {
+		t.Fatalf("Wrong scopes %q.", names)
+	}
+
+	locals := variables(c, scopes.Scopes[0].VariablesReference)
+	if locals["y"].Value != "6" {
+		t.Errorf("Wrong locals %+v.", locals)
+	}
+
+	this := variables(c, scopes.Scopes[1].VariablesReference)["this"]
+	if this.VariablesReference == 0 {
+		t.Fatalf("Instance should have fields reference, got %+v.", this)
+	}
+	fields := variables(c, this.VariablesReference)
+	if fields["x"].Value != "3" || fields["x"].Type != "INTEGER" {
+		t.Errorf("Wrong fields %+v.", fields)
+	}
+
+	var result dap.EvaluateResponse
+	c.request("evaluate", dap.EvaluateArguments{Expression: "this.x + y", FrameID: 1}, &result)
+	if result.Result != "9" {
+		t.Errorf("Wrong evaluate result %q.", result.Result)
+	}
+
+	// caller frame sees only globals
+	c.request("scopes", dap.ScopesArguments{FrameID: trace.StackFrames[1].ID}, &scopes)
+	if len(scopes.Scopes) != 1 || scopes.Scopes[0].Name != "Globals" {
+		t.Errorf("Wrong caller scopes %+v.", scopes.Scopes)
+	}
+
+	c.request("next", map[string]int{"threadId": 1}, nil)
+	c.event("stopped", &stopped)
+	c.request("stackTrace", dap.StackTraceArguments{ThreadID: 1}, &trace)
+	if stopped.Reason != "step" || trace.StackFrames[0].Line != 10 {
+		t.Errorf("Wrong step stop %q at %+v.", stopped.Reason, trace.StackFrames[0])
+	}
+
+	c.request("scopes", dap.ScopesArguments{FrameID: 1}, &scopes)
+	xs := variables(c, scopes.Scopes[0].VariablesReference)["xs"]
+	elements := variables(c, xs.VariablesReference)
+	if elements["0"].Value != "6" || elements["1"].VariablesReference == 0 {
+		t.Errorf("Wrong elements %+v.", elements)
+	}
+	pairs := variables(c, elements["1"].VariablesReference)
+	if pairs["a"].Value != "<instance of Point>" {
+		t.Errorf("Wrong hash pairs %+v.", pairs)
+	}
+
+	if resp := c.request("continue", map[string]int{"threadId": 1}, nil); !resp.Success {
+		t.Errorf("Continue failed: %s", resp.Message)
+	}
+	var exited dap.ExitedEvent
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("Wrong exit code %d.", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	if resp := c.request("continue", map[string]int{"threadId": 1}, nil); resp.Success {
+		t.Errorf("Continue should fail when program is not paused.")
+	}
+
+	c.request("disconnect", nil, nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
+
+func TestStepInAndOut(t *testing.T) {
+	path := writeScript(t, script)
+	c := newClient(t)
+	c.request("initialize", nil, nil)
+	c.request("launch", dap.LaunchArguments{Program: path, StopOnEntry: true}, nil)
+	c.request("configurationDone", nil, nil)
+
+	lines := []string{}
+	var stopped dap.StoppedEvent
+	c.event("stopped", &stopped)
+	lines = append(lines, stopped.Reason+":"+currentLine(c))
+
+	for _, command := range []string{"next", "next", "stepIn", "stepOut"} {
+		c.request(command, map[string]int{"threadId": 1}, nil)
+		c.event("stopped", &stopped)
+		lines = append(lines, stopped.Reason+":"+currentLine(c))
+	}
+
+	want := "entry:1 step:8 step:9 step:4 step:10"
+	if strings.Join(lines, " ") != want {
+		t.Errorf("Wrong stops, got %q, want %q.", strings.Join(lines, " "), want)
+	}
+
+	c.request("disconnect", nil, nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", nil, nil)
+
+	resp := c.request("launch", dap.LaunchArguments{Program: writeScript(t, "let = 1;")}, nil)
+	if resp.Success || !strings.Contains(resp.Message, "error") {
+		t.Errorf("Launch should fail with parse error, got %+v.", resp)
+	}
+
+	resp = c.request("launch", dap.LaunchArguments{Program: filepath.Join(t.TempDir(), "missing.mk")}, nil)
+	if resp.Success {
+		t.Errorf("Launch of missing file should fail.")
+	}
+
+	if resp := c.request("bogus", nil, nil); resp.Success {
+		t.Errorf("Unknown command should fail.")
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", nil, nil)
+	c.request("launch", dap.LaunchArguments{Program: writeScript(t, "let a = 1;\na + true;")}, nil)
+	c.request("configurationDone", nil, nil)
+
+	var output dap.OutputEvent
+	c.event("output", &output)
+	if output.Category != "stderr" || !strings.Contains(output.Output, "type mismatch") {
+		t.Errorf("Wrong output %+v.", output)
+	}
+	var exited dap.ExitedEvent
+	c.event("exited", &exited)
+	if exited.ExitCode != 70 {
+		t.Errorf("Wrong exit code %d.", exited.ExitCode)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", nil, nil)
+	c.request("launch", dap.LaunchArguments{Program: writeScript(t, `println("hi");`)}, nil)
+	c.request("configurationDone", nil, nil)
+
+	var output dap.OutputEvent
+	c.event("output", &output)
+	if output.Category != "stdout" || output.Output != "hi\n" {
+		t.Errorf("Wrong output %+v.", output)
+	}
+}
+
+func currentLine(c *client) string {
+	var trace struct{ StackFrames []dap.StackFrame }
+	c.request("stackTrace", dap.StackTraceArguments{ThreadID: 1}, &trace)
+	return strconv.Itoa(trace.StackFrames[0].Line)
+}
+
+func variables(c *client, ref int) map[string]dap.Variable {
+	c.t.Helper()
+	var body struct{ Variables []dap.Variable }
+	if resp := c.request("variables", dap.VariablesArguments{VariablesReference: ref}, &body); !resp.Success {
+		c.t.Fatalf("Variables failed: %s", resp.Message)
+	}
+	vars := map[string]dap.Variable{}
+	for _, v := range body.Variables {
+		vars[v.Name] = v
+	}
+	return vars
+}
@@ -0,0 +1,303 @@
+// Package debugger pauses programs run by the tree-walking evaluator on
+// breakpoints and steps, front-ends decide what happens while paused.
+package debugger
+
+import (
+	"io"
+	"monkey/ast"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"sync"
+)
+
+// Action tells the debugger how to resume a paused program.
+type Action int
+
+const (
+	Continue Action = iota // run until the next breakpoint
+	StepIn                 // pause at the next statement, entering calls
+	StepOver               // pause at the next statement of the current function or its callers
+	StepOut                // pause after the current function returns
+	Abort                  // stop the program
+)
+
+// Reasons why program paused.
+const (
+	ENTRY      = "entry"
+	BREAKPOINT = "breakpoint"
+	STEP       = "step"
+)
+
+// Stop describes the state of paused program.
+type Stop struct {
+	Reason    string
+	Statement ast.Statement // statement about to be executed
+	Env       *object.Environment
+	Stack     []object.Frame // active calls, innermost last
+
+	// environments of the script and active calls, innermost last
+	envs []*object.Environment
+}
+
+// StackFrame is the script or a function call paused at Pos.
+type StackFrame struct {
+	Name string
+	Pos  token.Position
+	Env  *object.Environment // nil when the call did not execute any statement yet
+}
+
+// Pos returns position of the statement program is paused at.
+func (s *Stop) Pos() token.Position {
+	return s.Statement.Pos()
+}
+
+// Function returns name of the function program is paused in.
+func (s *Stop) Function() string {
+	if len(s.Stack) == 0 {
+		return object.SCRIPT_FRAME_NAME
+	}
+	return s.Stack[len(s.Stack)-1].Name()
+}
+
+// Frames returns the call stack, innermost frame first. Every caller
+// is paused at the call site of the frame it called.
+func (s *Stop) Frames() []StackFrame {
+	frames := []StackFrame{}
+	pos := s.Pos()
+	for i := len(s.Stack); i >= 0; i-- {
+		frame := StackFrame{Name: object.SCRIPT_FRAME_NAME, Pos: pos}
+		if i > 0 {
+			frame.Name = s.Stack[i-1].Name()
+			pos = s.Stack[i-1].CallSite.Start
+		}
+		if i < len(s.envs) {
+			frame.Env = s.envs[i]
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// Frontend is notified whenever the program pauses. Paused is called
+// on the goroutine running the program, which waits until it returns.
+type Frontend interface {
+	Paused(stop *Stop) Action
+}
+
+// Variable is a named value of an environment.
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+type location struct {
+	file string
+	line int
+}
+
+type aborted struct{}
+
+// Debugger runs a single program, pausing it as requested by the front-end.
+type Debugger struct {
+	evaluator *eval.Evaluator
+	frontend  Frontend
+
+	// breakpoints can be changed by front-ends while the program runs
+	mu          sync.Mutex
+	breakpoints map[location]bool
+
+	// envs holds environment of the last statement executed by every active call
+	envs []*object.Environment
+
+	// action and depth of the call stack when the program paused last
+	action Action
+	depth  int
+	last   location
+
+	// moved is set once a statement outside of the last paused line executes,
+	// so breakpoints and steps do not pause twice on the same line
+	moved bool
+}
+
+// New creates debugger which pauses the program before its first statement.
+func New(frontend Frontend) *Debugger {
+	return &Debugger{
+		evaluator:   eval.New(),
+		frontend:    frontend,
+		breakpoints: map[location]bool{},
+		action:      StepIn,
+		moved:       true,
+	}
+}
+
+// SetMaxCallDepth limits number of nested calls of the program, see
+// eval.Evaluator.SetMaxCallDepth.
+func (d *Debugger) SetMaxCallDepth(depth int) {
+	d.evaluator.SetMaxCallDepth(depth)
+}
+
+// SetStdout redirects output printed by the program, see
+// eval.Evaluator.SetStdout.
+func (d *Debugger) SetStdout(out io.Writer) {
+	d.evaluator.SetStdout(out)
+}
+
+// SetCapabilities grants the program builtins of given capabilities only,
+// see eval.Evaluator.SetCapabilities.
+func (d *Debugger) SetCapabilities(granted ...eval.Capability) {
+	d.evaluator.SetCapabilities(granted...)
+}
+
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[location{file, line}] = true
+}
+
+func (d *Debugger) ClearBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, location{file, line})
+}
+
+// ClearBreakpoints removes all breakpoints of file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for loc := range d.breakpoints {
+		if loc.file == file {
+			delete(d.breakpoints, loc)
+		}
+	}
+}
+
+// Breakpoints returns lines with breakpoints in file.
+func (d *Debugger) Breakpoints(file string) []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := []int{}
+	for loc := range d.breakpoints {
+		if loc.file == file {
+			lines = append(lines, loc.line)
+		}
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Run evaluates resolved program, it returns nil when the front-end aborted it.
+func (d *Debugger) Run(program *ast.Program, locals map[ast.Expression]int) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(aborted); !ok {
+				panic(r)
+			}
+			result = nil
+		}
+	}()
+
+	d.evaluator.SetLocals(locals)
+	d.evaluator.SetHook(d.statement)
+
+	return d.evaluator.Eval(program, object.NewEnvironment())
+}
+
+func (d *Debugger) statement(stmt ast.Statement, env *object.Environment) {
+	pos := stmt.Pos()
+	loc := location{pos.File, pos.Line}
+	depth := d.evaluator.CallDepth()
+
+	for len(d.envs) <= depth {
+		d.envs = append(d.envs, nil)
+	}
+	d.envs = d.envs[:depth+1]
+	d.envs[depth] = env
+
+	if loc != d.last || depth != d.depth {
+		d.moved = true
+	}
+	if !d.moved {
+		return
+	}
+
+	d.mu.Lock()
+	breakpoint := d.breakpoints[loc]
+	d.mu.Unlock()
+
+	reason := ""
+	switch {
+	case breakpoint:
+		reason = BREAKPOINT
+	case d.action == StepIn,
+		d.action == StepOver && depth <= d.depth,
+		d.action == StepOut && depth < d.depth:
+		reason = STEP
+	}
+	if reason == "" {
+		return
+	}
+	if reason == STEP && d.last == (location{}) {
+		reason = ENTRY
+	}
+
+	action := d.frontend.Paused(&Stop{
+		Reason:    reason,
+		Statement: stmt,
+		Env:       env,
+		Stack:     d.evaluator.CallStack(),
+		envs:      append([]*object.Environment(nil), d.envs...),
+	})
+	if action == Abort {
+		panic(aborted{})
+	}
+
+	d.action, d.depth, d.last, d.moved = action, depth, loc, false
+}
+
+// Scopes returns variables visible from env, innermost scope first
+// and global scope last.
+func Scopes(env *object.Environment) [][]Variable {
+	scopes := [][]Variable{}
+	for ; env != nil; env = env.Outer {
+		scope := []Variable{}
+		for _, name := range env.Names() {
+			value, _ := env.GetAt(0, name)
+			scope = append(scope, Variable{Name: name, Value: value})
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// Evaluate evaluates source with variables visible from env,
+// statements executed by it do not pause the program.
+func (d *Debugger) Evaluate(source string, env *object.Environment) (object.Object, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, p.Errors()[0]
+	}
+
+	// source is not resolved, so all its variables are looked up
+	// as globals of environment holding everything visible from env
+	visible := object.NewEnvironment()
+	scopes := Scopes(env)
+	for i := len(scopes) - 1; i >= 0; i-- {
+		for _, v := range scopes[i] {
+			visible.Set(v.Name, v.Value)
+		}
+	}
+
+	d.evaluator.SetHook(nil)
+	defer d.evaluator.SetHook(d.statement)
+
+	result := d.evaluator.Eval(program, visible)
+	if result == nil {
+		result = eval.NULL
+	}
+	return result, nil
+}
@@ -0,0 +1,230 @@
+package debugger_test
+
+import (
+	"fmt"
+	"monkey/debugger"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"strings"
+	"testing"
+)
+
+const script = `class Point {
+  fn init(x) { this.x = x; }
+  fn double() {
+    let y = this.x * 2;
+    return y;
+  }
+}
+let p = Point(3);
+let r = p.double();
+r + 1;`
+
+// scripted front-end answers with actions in order, then continues
+type scripted struct {
+	actions []debugger.Action
+	stops   []string
+	onPause func(stop *debugger.Stop)
+}
+
+func (s *scripted) Paused(stop *debugger.Stop) debugger.Action {
+	s.stops = append(s.stops, fmt.Sprintf("%s %d %s", stop.Reason, stop.Pos().Line, stop.Function()))
+	if s.onPause != nil {
+		s.onPause(stop)
+	}
+	if len(s.actions) == 0 {
+		return debugger.Continue
+	}
+	action := s.actions[0]
+	s.actions = s.actions[1:]
+	return action
+}
+
+func run(t *testing.T, d *debugger.Debugger, source string) object.Object {
+	t.Helper()
+	p := parser.New(lexer.NewFile("test.mk", source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Parse errors: %v", p.Errors())
+	}
+	r := resolver.New()
+	r.Resolve(program)
+	if len(r.Errors()) != 0 {
+		t.Fatalf("Resolve errors: %v", r.Errors())
+	}
+	return d.Run(program, r.Locals())
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		actions     []debugger.Action
+		breakpoints []int
+		want        []string
+	}{
+		{
+			"continue",
+			[]debugger.Action{debugger.Continue},
+			nil,
+			[]string{"entry 1 <script>"},
+		},
+		{
+			"breakpoint",
+			[]debugger.Action{debugger.Continue},
+			[]int{4, 10},
+			[]string{"entry 1 <script>", "breakpoint 4 Point.double", "breakpoint 10 <script>"},
+		},
+		{
+			"step over",
+			[]debugger.Action{debugger.StepOver, debugger.StepOver, debugger.StepOver, debugger.StepOver},
+			nil,
+			[]string{"entry 1 <script>", "step 8 <script>", "step 9 <script>", "step 10 <script>"},
+		},
+		{
+			"step in",
+			[]debugger.Action{debugger.StepOver, debugger.StepOver, debugger.StepIn, debugger.StepIn, debugger.StepIn},
+			nil,
+			[]string{"entry 1 <script>", "step 8 <script>", "step 9 <script>", "step 4 Point.double", "step 5 Point.double", "step 10 <script>"},
+		},
+		{
+			"step out",
+			[]debugger.Action{debugger.Continue, debugger.StepOut},
+			[]int{4},
+			[]string{"entry 1 <script>", "breakpoint 4 Point.double", "step 10 <script>"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			frontend := &scripted{actions: tc.actions}
+			d := debugger.New(frontend)
+			for _, line := range tc.breakpoints {
+				d.SetBreakpoint("test.mk", line)
+			}
+
+			result := run(t, d, script)
+
+			if got, ok := result.(*object.Integer); !ok || got.Value != 7 {
+				t.Errorf("Wrong result, got %v, want 7.", result)
+			}
+			if strings.Join(frontend.stops, ", ") != strings.Join(tc.want, ", ") {
+				t.Errorf("Wrong stops,\ngot  %q,\nwant %q.", frontend.stops, tc.want)
+			}
+		})
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := debugger.New(&scripted{})
+	d.SetBreakpoint("a.mk", 5)
+	d.SetBreakpoint("a.mk", 2)
+	d.SetBreakpoint("b.mk", 1)
+	d.ClearBreakpoint("a.mk", 5)
+
+	if got := fmt.Sprint(d.Breakpoints("a.mk")); got != "[2]" {
+		t.Errorf("Wrong breakpoints, got %s, want [2].", got)
+	}
+
+	d.ClearBreakpoints("b.mk")
+	if got := fmt.Sprint(d.Breakpoints("b.mk")); got != "[]" {
+		t.Errorf("Wrong breakpoints, got %s, want [].", got)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	var scopes, printed, stack string
+	frontend := &scripted{}
+	d := debugger.New(frontend)
+	frontend.onPause = func(stop *debugger.Stop) {
+		if stop.Reason != debugger.BREAKPOINT {
+			return
+		}
+		for _, scope := range debugger.Scopes(stop.Env) {
+			for _, v := range scope {
+				scopes += v.Name + "=" + v.Value.Inspect() + " "
+			}
+			scopes += "| "
+		}
+		result, err := d.Evaluate("this.x + y", stop.Env)
+		if err != nil {
+			t.Fatalf("Evaluate failed: %s", err)
+		}
+		printed = result.Inspect()
+		for _, frame := range stop.Stack {
+			stack += frame.Name() + " at " + frame.CallSite.Start.String()
+		}
+	}
+	d.SetBreakpoint("test.mk", 5)
+
+	run(t, d, script)
+
+	wantScopes := "y=6 | this=<instance of Point> | Point=<class Point> p=<instance of Point> | "
+	if scopes != wantScopes {
+		t.Errorf("Wrong scopes, got %q, want %q.", scopes, wantScopes)
+	}
+	if printed != "9" {
+		t.Errorf("Wrong printed value, got %q, want %q.", printed, "9")
+	}
+	if stack != "Point.double at test.mk:9:9" {
+		t.Errorf("Wrong stack, got %q.", stack)
+	}
+	if len(frontend.stops) != 2 {
+		t.Errorf("Evaluate should not pause the program, got stops %q.", frontend.stops)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	frontend := &scripted{actions: []debugger.Action{debugger.StepOver, debugger.Abort}}
+	d := debugger.New(frontend)
+
+	if result := run(t, d, script); result != nil {
+		t.Errorf("Aborted program should return nil, got %v.", result)
+	}
+	if len(frontend.stops) != 2 {
+		t.Errorf("Wrong stops, got %q.", frontend.stops)
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	d := debugger.New(&scripted{})
+	d.SetMaxCallDepth(10)
+
+	result := run(t, d, "fn f(n) { if (n == 0) { return 0; } 1 + f(n - 1) } f(20);")
+	err, ok := result.(*object.Error)
+	if !ok || err.Message != "stack overflow" {
+		t.Errorf("Expect stack overflow, got %v.", result)
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	input := "b 5\nc\nbt\nvars\np y + 1\nbogus\nn\n\n"
+	var out strings.Builder
+	term := debugger.NewTerminal("test.mk", script, strings.NewReader(input), &out)
+
+	if result := run(t, term.Debugger, script); result == nil || result.Inspect() != "7" {
+		t.Errorf("Wrong result, got %v, want 7.", result)
+	}
+
+	want := `test.mk:1: class Point {
+(debug) Breakpoint set at line 5.
+(debug) Breakpoint hit in Point.double.
+test.mk:5: return y;
+(debug) #0 Point.double at test.mk:5:5
+#1 <script> at test.mk:9:9
+(debug) Scope 0:
+  y = 6
+Scope 1:
+  this = <instance of Point>
+Globals:
+  Point = <class Point>
+  p = <instance of Point>
+(debug) 7
+(debug) Unknown command "bogus", type 'help' to list commands.
+(debug) test.mk:10: r + 1;
+(debug) `
+	if out.String() != want {
+		t.Errorf("Wrong output,\ngot:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
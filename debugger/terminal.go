@@ -0,0 +1,208 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const terminalHelp = `Commands:
+  break LINE   (b)   set breakpoint at line of the script
+  clear LINE         remove breakpoint
+  breakpoints        list breakpoints
+  continue     (c)   run until the next breakpoint
+  step         (s)   step to the next statement, entering calls
+  next         (n)   step to the next statement, over calls
+  out          (o)   run until the current function returns
+  stack        (bt)  show call stack
+  vars         (v)   show variables of all scopes, innermost first
+  print EXPR   (p)   evaluate expression in the current scope
+  list         (l)   show source around the current line
+  quit         (q)   stop the program
+  help         (h)   show this help
+Empty line repeats the previous command.`
+
+// maxValueWidth limits width of values printed by vars command.
+const maxValueWidth = 60
+
+// Terminal is a front-end driven by commands read line by line.
+type Terminal struct {
+	Debugger *Debugger
+
+	in    *bufio.Scanner
+	out   io.Writer
+	file  string
+	lines []string
+
+	previous string
+}
+
+// NewTerminal creates debugger of script file with source, commands are read from in.
+func NewTerminal(file string, source string, in io.Reader, out io.Writer) *Terminal {
+	t := &Terminal{
+		in:    bufio.NewScanner(in),
+		out:   out,
+		file:  file,
+		lines: strings.Split(source, "\n"),
+	}
+	t.Debugger = New(t)
+	return t
+}
+
+func (t *Terminal) Paused(stop *Stop) Action {
+	pos := stop.Pos()
+	if stop.Reason == BREAKPOINT {
+		fmt.Fprintf(t.out, "Breakpoint hit in %s.\n", stop.Function())
+	}
+	t.printLine(pos.File, pos.Line)
+
+	for {
+		io.WriteString(t.out, "(debug) ")
+		if !t.in.Scan() {
+			io.WriteString(t.out, "\n")
+			return Abort
+		}
+
+		line := strings.TrimSpace(t.in.Text())
+		if line == "" {
+			line = t.previous
+		}
+		t.previous = line
+
+		command, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+
+		switch command {
+		case "":
+		case "continue", "c":
+			return Continue
+		case "step", "s":
+			return StepIn
+		case "next", "n":
+			return StepOver
+		case "out", "o":
+			return StepOut
+		case "quit", "q":
+			return Abort
+		case "break", "b":
+			if n, ok := t.lineArg(arg); ok {
+				t.Debugger.SetBreakpoint(t.file, n)
+				fmt.Fprintf(t.out, "Breakpoint set at line %d.\n", n)
+			}
+		case "clear":
+			if n, ok := t.lineArg(arg); ok {
+				t.Debugger.ClearBreakpoint(t.file, n)
+				fmt.Fprintf(t.out, "Breakpoint cleared at line %d.\n", n)
+			}
+		case "breakpoints":
+			for _, n := range t.Debugger.Breakpoints(t.file) {
+				t.printLine(t.file, n)
+			}
+		case "stack", "bt":
+			t.printStack(stop)
+		case "vars", "v":
+			t.printScopes(stop)
+		case "print", "p":
+			t.print(arg, stop)
+		case "list", "l":
+			t.list(pos.File, pos.Line)
+		case "help", "h":
+			fmt.Fprintln(t.out, terminalHelp)
+		default:
+			fmt.Fprintf(t.out, "Unknown command %q, type 'help' to list commands.\n", command)
+		}
+	}
+}
+
+func (t *Terminal) lineArg(arg string) (int, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(t.lines) {
+		fmt.Fprintf(t.out, "Expect line number between 1 and %d.\n", len(t.lines))
+		return 0, false
+	}
+	return n, true
+}
+
+func (t *Terminal) printStack(stop *Stop) {
+	for i, frame := range stop.Frames() {
+		fmt.Fprintf(t.out, "#%d %s at %s\n", i, frame.Name, frame.Pos)
+	}
+}
+
+func (t *Terminal) printScopes(stop *Stop) {
+	scopes := Scopes(stop.Env)
+	for i, scope := range scopes {
+		if i == len(scopes)-1 {
+			fmt.Fprintln(t.out, "Globals:")
+		} else {
+			fmt.Fprintf(t.out, "Scope %d:\n", i)
+		}
+		for _, v := range scope {
+			fmt.Fprintf(t.out, "  %s = %s\n", v.Name, shorten(v.Value.Inspect()))
+		}
+	}
+}
+
+func (t *Terminal) print(source string, stop *Stop) {
+	result, err := t.Debugger.Evaluate(source, stop.Env)
+	if err != nil {
+		fmt.Fprintln(t.out, err)
+		return
+	}
+	fmt.Fprintln(t.out, result.Inspect())
+}
+
+// list prints lines around line, the current one is marked with '>'
+// and lines with breakpoints with '*'.
+func (t *Terminal) list(file string, line int) {
+	if file != t.file {
+		t.printLine(file, line)
+		return
+	}
+
+	breakpoints := map[int]bool{}
+	for _, n := range t.Debugger.Breakpoints(t.file) {
+		breakpoints[n] = true
+	}
+
+	from, to := line-5, line+5
+	if from < 1 {
+		from = 1
+	}
+	if to > len(t.lines) {
+		to = len(t.lines)
+	}
+	for n := from; n <= to; n++ {
+		mark := " "
+		if breakpoints[n] {
+			mark = "*"
+		}
+		if n == line {
+			mark += ">"
+		} else {
+			mark += " "
+		}
+		fmt.Fprintf(t.out, "%s %4d | %s\n", mark, n, t.lines[n-1])
+	}
+}
+
+func (t *Terminal) printLine(file string, line int) {
+	text := ""
+	if file == t.file && line <= len(t.lines) {
+		text = strings.TrimSpace(t.lines[line-1])
+	}
+	if file == "" {
+		file = "<input>"
+	}
+	fmt.Fprintf(t.out, "%s:%d: %s\n", file, line, text)
+}
+
+func shorten(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxValueWidth {
+		return s[:maxValueWidth-3] + "..."
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package diagnostics
+
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+	Hint
+)
+
+var severityNames = map[Severity]string{
+	Error:   "error",
+	Warning: "warning",
+	Info:    "info",
+	Hint:    "hint",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Diagnostic is a problem found in source code by one of the
+// interpreter stages.
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+	Span     token.Span
+	Notes    []string
+	Hints    []string
+}
+
+// Error formats diagnostic as a single line, e.g.
+//
+//	script.mk:2:7: error[P010]: Expect '=' after identifier in 'let' statement.
+func (d Diagnostic) Error() string {
+	var out strings.Builder
+
+	if d.Span.IsValid() {
+		out.WriteString(d.Span.String())
+		out.WriteString(": ")
+	}
+
+	out.WriteString(d.Severity.String())
+	if d.Code != "" {
+		out.WriteString("[" + d.Code + "]")
+	}
+	out.WriteString(": ")
+	out.WriteString(d.Message)
+
+	return out.String()
+}
+
+func New(severity Severity, code string, span token.Span, format string, args ...interface{}) Diagnostic {
+	msg := format
+	if len(args) != 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+
+	return Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  msg,
+		Span:     span,
+	}
+}
+
+func (d Diagnostic) WithNote(note string) Diagnostic {
+	d.Notes = append(d.Notes, note)
+	return d
+}
+
+func (d Diagnostic) WithHint(hint string) Diagnostic {
+	d.Hints = append(d.Hints, hint)
+	return d
+}
+
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
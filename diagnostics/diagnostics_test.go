@@ -0,0 +1,84 @@
+package diagnostics_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"monkey/diagnostics"
+	"monkey/token"
+	"testing"
+)
+
+var span = token.Span{
+	Start: token.Position{File: "a.mk", Offset: 10, Line: 2, Column: 5},
+	End:   token.Position{File: "a.mk", Offset: 13, Line: 2, Column: 8},
+}
+
+func TestDiagnosticError(t *testing.T) {
+	d := diagnostics.New(diagnostics.Error, "P001", span, "Bad %s.", "thing")
+
+	want := "a.mk:2:5: error[P001]: Bad thing."
+	if got := d.Error(); got != want {
+		t.Errorf("Wrong diagnostic string, got %q, want %q.", got, want)
+	}
+
+	d = diagnostics.New(diagnostics.Warning, "", token.Span{}, "No position.")
+
+	want = "warning: No position."
+	if got := d.Error(); got != want {
+		t.Errorf("Wrong diagnostic string, got %q, want %q.", got, want)
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	source := "let x = 1;\nlet yyy 5;\n"
+	d := diagnostics.New(diagnostics.Error, "P011", span, "Expect '='.").
+		WithNote("got INT '5'").
+		WithHint("use 'let name = value;'")
+
+	var out bytes.Buffer
+	diagnostics.RenderText(&out, source, []diagnostics.Diagnostic{d})
+
+	want := `a.mk:2:5: error[P011]: Expect '='.
+ 2 | let yyy 5;
+   |     ^~~
+   = note: got INT '5'
+   = hint: use 'let name = value;'
+`
+	if out.String() != want {
+		t.Errorf("Wrong text rendering, got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	d := diagnostics.New(diagnostics.Error, "R001", span, "Oops.").WithHint("fix it")
+
+	var out bytes.Buffer
+	if err := diagnostics.RenderJSON(&out, []diagnostics.Diagnostic{d}); err != nil {
+		t.Fatalf("Unexpected error %v.", err)
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("Can not decode rendered JSON: %v.", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("Wrong diagnostics count, got %d, want 1.", len(got))
+	}
+
+	for key, want := range map[string]interface{}{
+		"severity": "error",
+		"code":     "R001",
+		"message":  "Oops.",
+		"file":     "a.mk",
+	} {
+		if got[0][key] != want {
+			t.Errorf("Wrong %q, got %v, want %v.", key, got[0][key], want)
+		}
+	}
+
+	start := got[0]["start"].(map[string]interface{})
+	if start["line"] != float64(2) || start["column"] != float64(5) {
+		t.Errorf("Wrong start position %v.", start)
+	}
+}
@@ -0,0 +1,139 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/token"
+	"strings"
+)
+
+type Format int
+
+const (
+	Text Format = iota
+	JSON
+)
+
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "text":
+		return Text, nil
+	case "json":
+		return JSON, nil
+	default:
+		return Text, fmt.Errorf("unknown diagnostics format %q", name)
+	}
+}
+
+// Render writes diagnostics to out in given format. Source is used to show
+// offending lines in text format and may be empty.
+func Render(out io.Writer, format Format, source string, diags []Diagnostic) error {
+	if format == JSON {
+		return RenderJSON(out, diags)
+	}
+
+	RenderText(out, source, diags)
+	return nil
+}
+
+// RenderText writes human readable diagnostics:
+//
+//	script.mk:2:7: error[P010]: Expect '=' after identifier in 'let' statement.
+//	   2 | let y 5;
+//	     |       ^
+//	     = note: got INT '5'
+func RenderText(out io.Writer, source string, diags []Diagnostic) {
+	lines := strings.Split(source, "\n")
+
+	for _, d := range diags {
+		io.WriteString(out, d.Error()+"\n")
+
+		gutter := 0
+		if line := d.Span.Start.Line; source != "" && line > 0 && line <= len(lines) {
+			text := strings.TrimRight(lines[line-1], "\r")
+			number := fmt.Sprintf("%d", line)
+			gutter = len(number)
+
+			fmt.Fprintf(out, " %s | %s\n", number, text)
+			fmt.Fprintf(out, " %s | %s\n", strings.Repeat(" ", gutter), underline(text, d.Span))
+		}
+
+		for _, note := range d.Notes {
+			fmt.Fprintf(out, " %s = note: %s\n", strings.Repeat(" ", gutter), note)
+		}
+		for _, hint := range d.Hints {
+			fmt.Fprintf(out, " %s = hint: %s\n", strings.Repeat(" ", gutter), hint)
+		}
+	}
+}
+
+func underline(line string, span token.Span) string {
+	from := span.Start.Column - 1
+	if from > len(line) {
+		from = len(line)
+	}
+
+	to := from + 1
+	if span.End.Line == span.Start.Line && span.End.Column-1 > to {
+		to = span.End.Column - 1
+	}
+	if to > len(line) && from < len(line) {
+		to = len(line)
+	}
+
+	// keep tabs so caret lines up with the source line
+	prefix := []byte(line[:from])
+	for i, ch := range prefix {
+		if ch != '\t' {
+			prefix[i] = ' '
+		}
+	}
+
+	return string(prefix) + "^" + strings.Repeat("~", to-from-1)
+}
+
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonDiagnostic struct {
+	Severity string       `json:"severity"`
+	Code     string       `json:"code,omitempty"`
+	Message  string       `json:"message"`
+	File     string       `json:"file,omitempty"`
+	Start    jsonPosition `json:"start"`
+	End      jsonPosition `json:"end"`
+	Notes    []string     `json:"notes,omitempty"`
+	Hints    []string     `json:"hints,omitempty"`
+}
+
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonDiagnostic{
+		Severity: d.Severity.String(),
+		Code:     d.Code,
+		Message:  d.Message,
+		File:     d.Span.Start.File,
+		Start:    toJSONPosition(d.Span.Start),
+		End:      toJSONPosition(d.Span.End),
+		Notes:    d.Notes,
+		Hints:    d.Hints,
+	})
+}
+
+func toJSONPosition(p token.Position) jsonPosition {
+	return jsonPosition{Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+// RenderJSON writes diagnostics as a JSON array.
+func RenderJSON(out io.Writer, diags []Diagnostic) error {
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diags)
+}
@@ -0,0 +1,347 @@
+package eval
+
+import (
+	"monkey/object"
+	"sort"
+	"strings"
+)
+
+// Array builtins which take functions call them through object.CallFunction,
+// so callbacks run the same way in the evaluator and the vm. Errors
+// returned by callbacks stop the builtin and are returned as they are.
+
+// arrayArg checks that the first of args is an array and count of args
+// is between min and max.
+func arrayArg(name string, min, max int, args []object.Object) (*object.Array, *object.Error) {
+	if len(args) < min || len(args) > max {
+		return nil, wrongArgumentsCountError(max, len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, builtinTypeMismatchError(name, args...)
+	}
+
+	return arr, nil
+}
+
+// callbackArgs unpacks arguments of builtin taking an array and a function.
+func callbackArgs(name string, min, max int, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	arr, err := arrayArg(name, min, max, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isCallable(args[1]) {
+		return nil, nil, builtinTypeMismatchError(name, args...)
+	}
+
+	return arr, args[1], nil
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin, *object.Class:
+		return true
+	default:
+		return false
+	}
+}
+
+func copyElements(elements []object.Object) []object.Object {
+	result := make([]object.Object, len(elements))
+	copy(result, elements)
+	return result
+}
+
+var arrayBuiltins = map[string]*object.Builtin{
+	"push": {
+		// push(arr, values...) appends values to arr and returns it
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return wrongArgumentsCountError(2, len(args))
+			}
+			arr, err := arrayArg("push", 2, len(args), args)
+			if err != nil {
+				return err
+			}
+			arr.Elements = append(arr.Elements, args[1:]...)
+			return arr
+		},
+	},
+	"pop": {
+		// pop(arr) removes the last element of arr and returns it
+		Fn: func(args ...object.Object) object.Object {
+			arr, err := arrayArg("pop", 1, 1, args)
+			if err != nil {
+				return err
+			}
+			if len(arr.Elements) == 0 {
+				return NULL
+			}
+			last := arr.Elements[len(arr.Elements)-1]
+			arr.Elements = arr.Elements[:len(arr.Elements)-1]
+			return last
+		},
+	},
+	"first": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, err := arrayArg("first", 1, 1, args)
+			if err != nil {
+				return err
+			}
+			if len(arr.Elements) == 0 {
+				return NULL
+			}
+			return arr.Elements[0]
+		},
+	},
+	"last": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, err := arrayArg("last", 1, 1, args)
+			if err != nil {
+				return err
+			}
+			if len(arr.Elements) == 0 {
+				return NULL
+			}
+			return arr.Elements[len(arr.Elements)-1]
+		},
+	},
+	"rest": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, err := arrayArg("rest", 1, 1, args)
+			if err != nil {
+				return err
+			}
+			if len(arr.Elements) == 0 {
+				return NULL
+			}
+			return &object.Array{Elements: copyElements(arr.Elements[1:])}
+		},
+	},
+	"slice": {
+		// slice(arr, start) or slice(arr, start, end), same as arr[start:end]
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return wrongArgumentsCountError(3, len(args))
+			}
+			var high object.Object = NULL
+			if len(args) == 3 {
+				high = args[2]
+			}
+			return evalSliceExpression(args[0], args[1], high)
+		},
+	},
+	"concat": {
+		Fn: func(args ...object.Object) object.Object {
+			elements := []object.Object{}
+			for _, arg := range args {
+				arr, ok := arg.(*object.Array)
+				if !ok {
+					return builtinTypeMismatchError("concat", args...)
+				}
+				elements = append(elements, arr.Elements...)
+			}
+			return &object.Array{Elements: elements}
+		},
+		Size: func(args ...object.Object) int64 {
+			var count int64
+			for _, arg := range args {
+				if arr, ok := arg.(*object.Array); ok {
+					count += int64(len(arr.Elements))
+				}
+			}
+			return objectSize + referenceSize*count
+		},
+	},
+	"reverse": {
+		Fn: func(args ...object.Object) object.Object {
+			arr, err := arrayArg("reverse", 1, 1, args)
+			if err != nil {
+				return err
+			}
+			elements := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				elements[len(elements)-1-i] = el
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"map": {
+		HigherOrder: func(call object.CallFunction, args ...object.Object) object.Object {
+			arr, fn, err := callbackArgs("map", 2, 2, args)
+			if err != nil {
+				return err
+			}
+			elements := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				result := call(fn, el)
+				if isError(result) {
+					return result
+				}
+				elements[i] = result
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"filter": {
+		HigherOrder: func(call object.CallFunction, args ...object.Object) object.Object {
+			arr, fn, err := callbackArgs("filter", 2, 2, args)
+			if err != nil {
+				return err
+			}
+			elements := []object.Object{}
+			for _, el := range arr.Elements {
+				result := call(fn, el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					elements = append(elements, el)
+				}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"reduce": {
+		// reduce(arr, fn) or reduce(arr, fn, initial), fn is called with
+		// the accumulator and an element
+		HigherOrder: func(call object.CallFunction, args ...object.Object) object.Object {
+			arr, fn, err := callbackArgs("reduce", 2, 3, args)
+			if err != nil {
+				return err
+			}
+			elements := arr.Elements
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			} else if len(elements) > 0 {
+				acc, elements = elements[0], elements[1:]
+			} else {
+				return NULL
+			}
+			for _, el := range elements {
+				acc = call(fn, acc, el)
+				if isError(acc) {
+					return acc
+				}
+			}
+			return acc
+		},
+	},
+	"find": {
+		HigherOrder: func(call object.CallFunction, args ...object.Object) object.Object {
+			arr, fn, err := callbackArgs("find", 2, 2, args)
+			if err != nil {
+				return err
+			}
+			for _, el := range arr.Elements {
+				result := call(fn, el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return el
+				}
+			}
+			return NULL
+		},
+	},
+	"sort": {
+		// sort(arr) or sort(arr, cmp) returns sorted copy of arr, cmp(a, b)
+		// returns negative number when a goes before b, zero when they are
+		// equal and positive number otherwise. The sort is stable.
+		HigherOrder: func(call object.CallFunction, args ...object.Object) object.Object {
+			arr, err := arrayArg("sort", 1, 2, args)
+			if err != nil {
+				return err
+			}
+
+			compare := compareObjects
+			if len(args) == 2 {
+				if !isCallable(args[1]) {
+					return builtinTypeMismatchError("sort", args...)
+				}
+				compare = func(a, b object.Object) (int, *object.Error) {
+					return compareWith(call, args[1], a, b)
+				}
+			}
+
+			elements := copyElements(arr.Elements)
+			sort.SliceStable(elements, func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+				var order int
+				order, err = compare(elements[i], elements[j])
+				return order < 0
+			})
+			if err != nil {
+				return err
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+}
+
+// compareObjects orders numbers and strings, other values
+// can be sorted only with comparator.
+func compareObjects(a, b object.Object) (int, *object.Error) {
+	switch a := a.(type) {
+	case *object.Integer:
+		if b, ok := b.(*object.Integer); ok {
+			return compareIntegers(a.Value, b.Value), nil
+		}
+		if b, ok := b.(*object.Float); ok {
+			return compareNumbers(float64(a.Value), b.Value), nil
+		}
+	case *object.Float:
+		if b, ok := b.(*object.Integer); ok {
+			return compareNumbers(a.Value, float64(b.Value)), nil
+		}
+		if b, ok := b.(*object.Float); ok {
+			return compareNumbers(a.Value, b.Value), nil
+		}
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	}
+
+	return 0, builtinTypeMismatchError("sort", a, b)
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareIntegers(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareWith(call object.CallFunction, fn, a, b object.Object) (int, *object.Error) {
+	switch result := call(fn, a, b).(type) {
+	case *object.Error:
+		return 0, result
+	case *object.Integer:
+		return compareIntegers(result.Value, 0), nil
+	case *object.Float:
+		return compareNumbers(result.Value, 0), nil
+	default:
+		return 0, builtinTypeMismatchError("sort", result)
+	}
+}
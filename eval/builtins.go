@@ -2,13 +2,95 @@ package eval
 
 import (
 	"fmt"
+	"io"
+	"math"
 	"monkey/object"
-	"os"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-var builtins = map[string]*object.Builtin{
+// Capability names a set of builtins giving scripts access to a part of
+// the system, evaluators provide only builtins of granted capabilities.
+type Capability string
+
+const (
+	PURE       Capability = "pure"       // computations without side effects
+	STDOUT     Capability = "stdout"     // printing to standard output
+	FILESYSTEM Capability = "filesystem" // reading and writing files
+	ENV        Capability = "env"        // reading environment variables
+	CLOCK      Capability = "clock"      // reading current time
+	NETWORK    Capability = "network"    // HTTP requests
+)
+
+// AllCapabilities grant scripts every builtin.
+var AllCapabilities = []Capability{PURE, STDOUT, FILESYSTEM, ENV, CLOCK, NETWORK}
+
+// DefaultCapabilities are granted to new evaluators, capabilities reaching
+// files, environment and network have to be granted explicitly.
+var DefaultCapabilities = []Capability{PURE, STDOUT, CLOCK}
+
+// ParseCapabilities reads comma separated list of capability names granted
+// in addition to DefaultCapabilities.
+func ParseCapabilities(list string) ([]Capability, error) {
+	granted := append([]Capability{}, DefaultCapabilities...)
+	for _, name := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if _, ok := capabilities[Capability(name)]; !ok {
+			return nil, fmt.Errorf("unknown capability %q", name)
+		}
+		granted = append(granted, Capability(name))
+	}
+	return granted, nil
+}
+
+// capabilities lists builtins of every capability, each builtin belongs
+// to exactly one of them.
+var capabilities = map[Capability][]map[string]*object.Builtin{
+	PURE:       {pureBuiltins, arrayBuiltins, stringBuiltins, hashBuiltins},
+	STDOUT:     {stdoutBuiltins},
+	FILESYSTEM: {filesystemBuiltins},
+	ENV:        {envBuiltins},
+	CLOCK:      {clockBuiltins},
+	NETWORK:    {networkBuiltins},
+}
+
+// builtins of default capabilities.
+var builtins = newBuiltins(DefaultCapabilities)
+
+// newBuiltins assembles builtins of granted capabilities, builtins of
+// other capabilities are replaced with ones failing with permission error,
+// so denied calls are reported instead of unknown identifiers.
+func newBuiltins(granted []Capability) map[string]*object.Builtin {
+	table := map[string]*object.Builtin{}
+	for capability, sets := range capabilities {
+		for _, set := range sets {
+			for name := range set {
+				table[name] = deniedBuiltin(name, capability)
+			}
+		}
+	}
+
+	for _, capability := range granted {
+		for _, set := range capabilities[capability] {
+			for name, builtin := range set {
+				table[name] = builtin
+			}
+		}
+	}
+
+	return table
+}
+
+func deniedBuiltin(name string, capability Capability) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return permissionError(name, capability)
+		},
+	}
+}
+
+var pureBuiltins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -16,7 +98,7 @@ var builtins = map[string]*object.Builtin{
 			}
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			default:
@@ -24,18 +106,70 @@ var builtins = map[string]*object.Builtin{
 			}
 		},
 	},
-	"print": {
+	"int": {
 		Fn: func(args ...object.Object) object.Object {
-			fmt.Fprint(os.Stdout, stringifyArgs(args))
+			if len(args) != 1 {
+				return wrongArgumentsCountError(1, len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				// truncates toward zero
+				if math.IsNaN(arg.Value) || arg.Value < math.MinInt64 || arg.Value >= math.MaxInt64 {
+					return invalidNumberError("int", arg.Inspect())
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				val, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+				if err != nil {
+					return invalidNumberError("int", strconv.Quote(arg.Value))
+				}
+				return &object.Integer{Value: val}
+			default:
+				return builtinTypeMismatchError("int", args...)
+			}
+		},
+	},
+	"float": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return wrongArgumentsCountError(1, len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				val, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return invalidNumberError("float", strconv.Quote(arg.Value))
+				}
+				return &object.Float{Value: val}
+			default:
+				return builtinTypeMismatchError("float", args...)
+			}
+		},
+	},
+}
+
+var stdoutBuiltins = map[string]*object.Builtin{
+	"print": {
+		Output: func(out io.Writer, args ...object.Object) object.Object {
+			fmt.Fprint(out, stringifyArgs(args))
 			return NULL
 		},
 	},
 	"println": {
-		Fn: func(args ...object.Object) object.Object {
-			fmt.Fprintln(os.Stdout, stringifyArgs(args))
+		Output: func(out io.Writer, args ...object.Object) object.Object {
+			fmt.Fprintln(out, stringifyArgs(args))
 			return NULL
 		},
 	},
+}
+
+var clockBuiltins = map[string]*object.Builtin{
 	"clock": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 0 {
@@ -1,8 +1,11 @@
 package eval
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"monkey/object"
+	"strings"
 )
 
 const (
@@ -18,10 +21,50 @@ const (
 	ERR_INTERNAL              = "internal error: "
 	ERR_OUT_OF_BOUNDS         = "out of bounds: "
 	ERR_NOT_HASHABLE_KEY      = "unusable as hash key: "
+	ERR_NOT_ITERABLE          = "not iterable: "
+	ERR_INVALID_NUMBER        = "invalid number: "
+	ERR_STRING_TOO_LONG       = "string too long: "
+	ERR_IMPORT                = "could not import "
+	ERR_IMPORT_CYCLE          = "import cycle: "
+	ERR_UNDEFINED_MEMBER      = "undefined module member: "
+	ERR_INDEX_ASSIGNMENT      = "index assignment unsupported: "
+	ERR_DIVISION_BY_ZERO      = "division by zero"
+	ERR_NEGATIVE_SHIFT        = "negative shift count: "
+	ERR_STACK_OVERFLOW        = "stack overflow"
+	ERR_STEP_LIMIT            = "step limit exceeded: "
+	ERR_OBJECT_LIMIT          = "object limit exceeded: "
+	ERR_MEMORY_LIMIT          = "memory limit exceeded: "
+	ERR_TIMEOUT               = "evaluation timed out"
+	ERR_CANCELLED             = "evaluation cancelled"
+	ERR_PERMISSION_DENIED     = "permission denied: "
+	ERR_IO                    = "io error: "
+)
+
+// Kinds of runtime errors, visible to scripts as the 'kind' of a caught exception.
+const (
+	ERROR              = "Error" // values thrown by the script itself
+	TYPE_ERROR         = "TypeError"
+	NAME_ERROR         = "NameError"
+	ARGUMENT_ERROR     = "ArgumentError"
+	PROPERTY_ERROR     = "PropertyError"
+	INDEX_ERROR        = "IndexError"
+	ARITHMETIC_ERROR   = "ArithmeticError"
+	VALUE_ERROR        = "ValueError"
+	IMPORT_ERROR       = "ImportError"
+	RECURSION_ERROR    = "RecursionError"
+	STEP_LIMIT_ERROR   = "StepLimitError"
+	OBJECT_LIMIT_ERROR = "ObjectLimitError"
+	MEMORY_ERROR       = "MemoryError"
+	TIMEOUT_ERROR      = "TimeoutError"
+	CANCELLED_ERROR    = "CancelledError"
+	PERMISSION_ERROR   = "PermissionError"
+	IO_ERROR           = "IOError"
+	INTERNAL_ERROR     = "InternalError"
 )
 
 func unknownPrefixOperatorError(operator string, right object.ObjectType) *object.Error {
 	return &object.Error{
+		Kind:    TYPE_ERROR,
 		Message: fmt.Sprintf(ERR_UNKNOWN_OPERATOR+"%s%s", operator, right),
 	}
 }
@@ -31,21 +74,84 @@ func unknownInfixOperatorError(
 	operator string,
 	right object.ObjectType) *object.Error {
 	return &object.Error{
+		Kind:    TYPE_ERROR,
 		Message: fmt.Sprintf(ERR_UNKNOWN_OPERATOR+"%s %s %s", left, operator, right),
 	}
 }
 
 func indexOperatorError(left object.ObjectType, right object.ObjectType) *object.Error {
 	return &object.Error{
+		Kind:    TYPE_ERROR,
 		Message: fmt.Sprintf(ERR_UNKNOWN_OPERATOR+"%s[%s]", left, right),
 	}
 }
 
+func indexAssignmentError(left object.ObjectType, index object.ObjectType) *object.Error {
+	return &object.Error{
+		Kind:    TYPE_ERROR,
+		Message: fmt.Sprintf(ERR_INDEX_ASSIGNMENT+"%s[%s]", left, index),
+	}
+}
+
+func divisionByZeroError() *object.Error {
+	return &object.Error{Kind: ARITHMETIC_ERROR, Message: ERR_DIVISION_BY_ZERO}
+}
+
+func negativeShiftError(count int64) *object.Error {
+	return &object.Error{Kind: ARITHMETIC_ERROR, Message: fmt.Sprintf(ERR_NEGATIVE_SHIFT+"%d", count)}
+}
+
+// stackOverflowError is reported by call exceeding depth limit, the limit
+// is not part of the message to keep it the same as virtual machine's one.
+func stackOverflowError() *object.Error {
+	return &object.Error{Kind: RECURSION_ERROR, Message: ERR_STACK_OVERFLOW}
+}
+
+func stepLimitError(limit int64) *object.Error {
+	return &object.Error{Kind: STEP_LIMIT_ERROR, Message: fmt.Sprintf(ERR_STEP_LIMIT+"%d steps", limit)}
+}
+
+func objectLimitError(limit int64) *object.Error {
+	return &object.Error{Kind: OBJECT_LIMIT_ERROR, Message: fmt.Sprintf(ERR_OBJECT_LIMIT+"%d objects", limit)}
+}
+
+func memoryLimitError(limit int64) *object.Error {
+	return &object.Error{Kind: MEMORY_ERROR, Message: fmt.Sprintf(ERR_MEMORY_LIMIT+"%d bytes", limit)}
+}
+
+// contextError is reported when context of evaluation is done, err is
+// the reason returned by context.
+func contextError(err error) *object.Error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &object.Error{Kind: TIMEOUT_ERROR, Message: ERR_TIMEOUT}
+	}
+	return &object.Error{Kind: CANCELLED_ERROR, Message: ERR_CANCELLED}
+}
+
+func permissionError(name string, capability Capability) *object.Error {
+	return &object.Error{
+		Kind:    PERMISSION_ERROR,
+		Message: fmt.Sprintf(ERR_PERMISSION_DENIED+"'%s' needs %s capability", name, capability),
+	}
+}
+
+func ioError(err error) *object.Error {
+	return &object.Error{Kind: IO_ERROR, Message: ERR_IO + err.Error()}
+}
+
+func sliceOperatorError(left, low, high object.ObjectType) *object.Error {
+	return &object.Error{
+		Kind:    TYPE_ERROR,
+		Message: fmt.Sprintf(ERR_UNKNOWN_OPERATOR+"%s[%s:%s]", left, low, high),
+	}
+}
+
 func infixTypeMismatchError(
 	left object.ObjectType,
 	operator string,
 	right object.ObjectType) *object.Error {
 	return &object.Error{
+		Kind:    TYPE_ERROR,
 		Message: fmt.Sprintf(ERR_TYPE_MISMATCH+"%s %s %s", left, operator, right),
 	}
 }
@@ -65,59 +171,101 @@ func builtinTypeMismatchError(
 			argsStr += ", " + string(arg.Type())
 		}
 	}
-	return &object.Error{Message: ERR_TYPE_MISMATCH + name + "(" + argsStr + ")"}
+	return &object.Error{Kind: TYPE_ERROR, Message: ERR_TYPE_MISMATCH + name + "(" + argsStr + ")"}
 }
 
 func identifierNotFoundError(identifier string) *object.Error {
 	return &object.Error{
+		Kind:    NAME_ERROR,
 		Message: fmt.Sprintf(ERR_IDENTIFIER_NOT_FOUND+"'%s'", identifier),
 	}
 }
 
 func notAFunctionError(objType string, identifier string) *object.Error {
 	return &object.Error{
+		Kind:    TYPE_ERROR,
 		Message: fmt.Sprintf(ERR_NOT_A_FUNCTION+"%s '%s'", objType, identifier),
 	}
 }
 
 func wrongArgumentsCountError(expect int, got int) *object.Error {
 	return &object.Error{
+		Kind:    ARGUMENT_ERROR,
 		Message: fmt.Sprintf(ERR_WRONG_ARGUMENTS_COUNT+"expect %d, got %d", expect, got),
 	}
 }
 
 func wrongGetTargetError(target object.ObjectType, prop string) *object.Error {
 	return &object.Error{
+		Kind:    PROPERTY_ERROR,
 		Message: fmt.Sprintf(ERR_WRONG_GET_TARGET+"%s.%s", string(target), prop),
 	}
 }
 
 func wrongSetTargetError(target object.ObjectType, prop string) *object.Error {
 	return &object.Error{
+		Kind:    PROPERTY_ERROR,
 		Message: fmt.Sprintf(ERR_WRONG_SET_TARGET+"%s.%s", string(target), prop),
 	}
 }
 
 func undefinedPropertyError(prop string) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(ERR_UNDEFINED_PROP+"'%s'", prop)}
+	return &object.Error{Kind: PROPERTY_ERROR, Message: fmt.Sprintf(ERR_UNDEFINED_PROP+"'%s'", prop)}
 }
 
 func superclassMustBeClassError(class string, super object.ObjectType) *object.Error {
 	return &object.Error{
+		Kind:    TYPE_ERROR,
 		Message: fmt.Sprintf(ERR_SUPERCLASS_NOT_CLASS+"'%s < %s'", class, super),
 	}
 }
 
 func outOfBoundsError(left object.ObjectType, idx int64) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(ERR_OUT_OF_BOUNDS+"%s[%d]", left, idx)}
+	return &object.Error{Kind: INDEX_ERROR, Message: fmt.Sprintf(ERR_OUT_OF_BOUNDS+"%s[%d]", left, idx)}
 }
 
 func notHashableKeyError(key object.ObjectType) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(ERR_NOT_HASHABLE_KEY+"%s", key)}
+	return &object.Error{Kind: TYPE_ERROR, Message: fmt.Sprintf(ERR_NOT_HASHABLE_KEY+"%s", key)}
 }
 
 func internalResolveError(keyword string) *object.Error {
 	return &object.Error{
+		Kind:    INTERNAL_ERROR,
 		Message: fmt.Sprintf(ERR_INTERNAL+"looks like expression '%s' is not resolved correctly", keyword),
 	}
 }
+
+func notIterableError(obj object.ObjectType) *object.Error {
+	return &object.Error{Kind: TYPE_ERROR, Message: fmt.Sprintf(ERR_NOT_ITERABLE+"%s", obj)}
+}
+
+func invalidNumberError(name string, value string) *object.Error {
+	return &object.Error{Kind: VALUE_ERROR, Message: fmt.Sprintf(ERR_INVALID_NUMBER+"%s(%s)", name, value)}
+}
+
+func stringTooLongError(name string) *object.Error {
+	return &object.Error{
+		Kind:    VALUE_ERROR,
+		Message: fmt.Sprintf(ERR_STRING_TOO_LONG+"%s() exceeds %d bytes", name, MAX_STRING_LENGTH),
+	}
+}
+
+func importError(path string, reason string) *object.Error {
+	return &object.Error{Kind: IMPORT_ERROR, Message: fmt.Sprintf(ERR_IMPORT+"%q: %s", path, reason)}
+}
+
+func importCycleError(chain []string) *object.Error {
+	return &object.Error{Kind: IMPORT_ERROR, Message: ERR_IMPORT_CYCLE + strings.Join(chain, " -> ")}
+}
+
+func undefinedMemberError(module string, member string) *object.Error {
+	return &object.Error{Kind: PROPERTY_ERROR, Message: fmt.Sprintf(ERR_UNDEFINED_MEMBER+"'%s' in %q", member, module)}
+}
+
+func thrownError(value object.Object) *object.Error {
+	message := value.Inspect()
+	if str, ok := value.(*object.String); ok {
+		message = str.Value
+	}
+	return &object.Error{Kind: ERROR, Message: message, Value: value}
+}
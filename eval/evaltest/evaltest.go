@@ -0,0 +1,921 @@
+// Package evaltest holds behavioural test cases of the language shared by
+// the tree-walking evaluator and the bytecode virtual machine.
+package evaltest
+
+import (
+	"monkey/object"
+	"testing"
+)
+
+type ExpectFn struct {
+	Params []string
+	Body   string
+}
+
+type ExpectClass struct {
+	Name  string
+	Super string
+	Props []string
+}
+
+type ExpectInstance struct {
+	Class string
+}
+
+// Case is a program with the value it evaluates to.
+type Case struct {
+	Source string
+	Want   interface{}
+}
+
+// ErrorCase is a program failing with runtime error.
+type ErrorCase struct {
+	Source string
+	Want   string
+}
+
+// ExceptionCase is a program failing with uncaught exception.
+type ExceptionCase struct {
+	Source  string
+	Kind    string
+	Message string
+	Span    string
+}
+
+// StackCase is a program failing inside of calls Want names, outermost first.
+type StackCase struct {
+	Source string
+	Want   []string
+}
+
+var Cases = []Case{
+	{Source: "5;", Want: int64(5)},
+	{Source: "228322;", Want: int64(228322)},
+	{Source: "true;", Want: true},
+	{Source: "false;", Want: false},
+	{Source: `"string literal";`, Want: "string literal"},
+	{Source: "null;", Want: nil},
+	{Source: "!null;", Want: true},
+	{Source: "!!null;", Want: false},
+	{Source: "!true;", Want: false},
+	{Source: "!false;", Want: true},
+	{Source: "!5;", Want: false},
+	{Source: "!!true;", Want: true},
+	{Source: "!!false;", Want: false},
+	{Source: "!!5;", Want: true},
+	{Source: "-5;", Want: int64(-5)},
+	{Source: "--5;", Want: int64(5)},
+	{Source: "--5;", Want: int64(5)},
+	{Source: "5 + 5;", Want: int64(10)},
+	{Source: "5 - 5;", Want: int64(0)},
+	{Source: "5 * 5;", Want: int64(25)},
+	{Source: "5 / 5;", Want: int64(1)},
+	{Source: "5 > 5;", Want: false},
+	{Source: "5 < 5;", Want: false},
+	// {Source: "5 >= 5;", Want: true},
+	// {Source: "5 <= 5;", Want: true},
+	{Source: "null == null;", Want: true},
+	{Source: "null != null;", Want: false},
+	{Source: "null == 5;", Want: false},
+	{Source: "null == false;", Want: false},
+	{Source: "null == true;", Want: false},
+	{Source: "null == true;", Want: false},
+	{Source: "false || true;", Want: true},
+	{Source: "true && false;", Want: false},
+	{Source: "10 || 20;", Want: int64(10)},
+	{Source: "null || 10", Want: int64(10)},
+	{Source: "10 && 20;", Want: int64(20)},
+	{Source: "let x; x = x || 20", Want: int64(20)},
+	{Source: "let x; false || (x = 20); x", Want: int64(20)},
+	{Source: "null && 10", Want: nil},
+	{Source: "5 == 5;", Want: true},
+	{Source: "5 != 5;", Want: false},
+	{Source: `"string" + " " + "concatenation"`, Want: "string concatenation"},
+	{Source: `"str" == "str";`, Want: true},
+	{Source: `"str1" == "str2";`, Want: false},
+	{Source: "(2 + 2) * 2 == 8;", Want: true},
+	{Source: "2 + 2 * 2 == 6;", Want: true},
+	{Source: "(2 + 2) * 2 > 2 + 2 * 2;", Want: true},
+	{Source: "(5 < 5) == false;", Want: true},
+	{Source: "2 > 3 != 3 > 4;", Want: false},
+	{Source: "if (true) 10;", Want: int64(10)},
+	{Source: "if (1) 10;", Want: int64(10)},
+	{Source: "if (true) { 10; } else { 20; };", Want: int64(10)},
+	{Source: "if (false) { 10; } else { 20; };", Want: int64(20)},
+	{Source: "if (null) 10 else 20", Want: int64(20)},
+	{Source: "if (null) 10", Want: nil},
+	{Source: "if (1 > 2) 10;", Want: nil},
+	{Source: "if (2 > 1) 10;", Want: int64(10)},
+	{Source: "return 10;", Want: int64(10)},
+	{Source: "10; return;", Want: nil},
+	{Source: "return true; 10;", Want: true},
+	{Source: "if (1 > 2) 10;", Want: nil},
+	{Source: "10; return 2 == 3; 20;", Want: false},
+	{Source: "if (2 > 1) { if (3 > 2) { return 10; }; return 1;};", Want: int64(10)},
+	{Source: "let a = 10;", Want: int64(10)},
+	{Source: "let a;", Want: nil},
+	{Source: "let a; a;", Want: nil},
+	{Source: "let a; a = 10;", Want: int64(10)},
+	{Source: "let a = 10; let b = a = 20;", Want: int64(20)},
+	{Source: "{ 20; let a = 10; }", Want: int64(10)},
+	{Source: "let a = 10; a;", Want: int64(10)},
+	{Source: "let a = 10 * 5; a;", Want: int64(50)},
+	{Source: "let a = 10; let b = a; b;", Want: int64(10)},
+	{Source: "let a = 10; let b = a; let c = a + b + 20; c;", Want: int64(40)},
+	{Source: "let a = 10; a = 20;", Want: int64(20)},
+	{Source: "let a = 10; let b = 20; a = b = 30;", Want: int64(30)},
+	{Source: "let a = 1; let b = 2; let c = 3; a = b = c;", Want: int64(3)},
+	{Source: "fn(a, b) { a + b }", Want: &ExpectFn{[]string{"a", "b"}, "{ (a + b); }"}},
+	{Source: "let x = fn(a, b) { a + b }; x;", Want: &ExpectFn{[]string{"a", "b"}, "{ (a + b); }"}},
+	{Source: "let i = fn(x) { x }; i(10);", Want: int64(10)},
+	{Source: "let i = fn(x) { return x; }; i(10);", Want: int64(10)},
+	{Source: "fn f(x) { x }; f(10);", Want: int64(10)},
+	{Source: "fn f(x) { return x } f(10);", Want: int64(10)},
+	{Source: `len("Hello, World!")`, Want: int64(13)},
+	{Source: `len("")`, Want: int64(0)},
+	{Source: `len("héllo, 世界")`, Want: int64(9)},
+	{Source: `len("\u{1F600}")`, Want: int64(1)},
+	{Source: `"héllo"[1]`, Want: "é"},
+	{Source: `"héllo"[-1]`, Want: "o"},
+	{Source: `"a\tb\n\"c\"\\"`, Want: "a\tb\n\"c\"\\"},
+	{Source: `"\u{48}\u{20AC}"`, Want: "H€"},
+	{Source: "`a\\n \"b\"`", Want: `a\n "b"`},
+	{Source: "`line\nbreak`", Want: "line\nbreak"},
+	{Source: "let s = \"\"; for (c in \"añb\") { s = c + s; } s;", Want: "bña"},
+	{Source: `let user = "Ann"; "Hello ${user}, you have ${1 + 2} items"`, Want: "Hello Ann, you have 3 items"},
+	{Source: `let xs = [1, "a"]; "${xs} ${len(xs)} ${null} ${1.5}"`, Want: "[1, a] 2 null 1.5"},
+	{Source: `let h = {| "k": "v" |}; "${ h["k"] }-${ "in${h["k"]}" }"`, Want: "v-inv"},
+	{Source: `fn f(n) { "n=${n}" } f(7)`, Want: "n=7"},
+	{Source: `"\${not} ${"interpolated"}"`, Want: "${not} interpolated"},
+	{Source: `len("Hello, World!"); { let len = 10; len; }`, Want: int64(10)},
+	{Source: `len("Hello, World!"); { let len = 10; len; } len("Hello, World!")`, Want: int64(13)},
+	{Source: "let x = 10; let y = 10; { let x = x; x = 20; y = x; } x;", Want: int64(10)},
+	{Source: "let x = 10; let y = 10; { let x = x; x = 20; y = x; } y;", Want: int64(20)},
+	{Source: "let x = 10; let f = fn() { x }; { f() }", Want: int64(10)},
+	{Source: "let x = 10; { let f = fn() { x }; let x = 20; f(); }", Want: int64(10)},
+	{Source: "let x = 10; { let f = fn() { x }; let x = 20; f(); } x;", Want: int64(10)},
+	{Source: "let x = 10; { fn f() { x }; let x = 20; f(); } x;", Want: int64(10)},
+	// floats
+	{Source: "1.5;", Want: 1.5},
+	{Source: "2e3;", Want: float64(2000)},
+	{Source: "-2.5;", Want: -2.5},
+	{Source: "1.5 + 1;", Want: 2.5},
+	{Source: "1 + 1.5;", Want: 2.5},
+	{Source: "2.5 * 2;", Want: float64(5)},
+	{Source: "1 - 0.5;", Want: 0.5},
+	{Source: "10 / 4;", Want: int64(2)},
+	{Source: "10 / 4.0;", Want: 2.5},
+	{Source: "1 == 1.0;", Want: true},
+	{Source: "1.5 != 1.5;", Want: false},
+	{Source: "1.5 > 1;", Want: true},
+	{Source: "1 < 0.5;", Want: false},
+	{Source: "int(2.7);", Want: int64(2)},
+	{Source: "int(-2.7);", Want: int64(-2)},
+	{Source: `int("42");`, Want: int64(42)},
+	{Source: "int(5);", Want: int64(5)},
+	{Source: "float(3);", Want: float64(3)},
+	{Source: `float("0.5");`, Want: 0.5},
+	{Source: `{| 1: "one" |}[1.0];`, Want: "one"},
+	{Source: `{| 1.5: "one and a half" |}[1.5];`, Want: "one and a half"},
+	// classes
+	{Source: "class A {}", Want: &ExpectClass{Name: "A", Super: "", Props: []string{}}},
+	{Source: "class B {} class A < B {}", Want: &ExpectClass{Name: "A", Super: "B", Props: []string{}}},
+	{Source: "class A {} A();", Want: &ExpectInstance{Class: "A"}},
+	{Source: `class A {} A().field = 10;`, Want: int64(10)},
+	{Source: "class A {} let obj = A(); obj.field = 10; obj.field;", Want: int64(10)},
+	{Source: "class A { let method = fn() { 10; }; } let obj = A(); obj.method()", Want: int64(10)},
+	{Source: "class A { fn method() { 10; }} let obj = A(); obj.method()", Want: int64(10)},
+	{Source: "class A { fn method() { this.x; }} let obj = A(); obj.x = 1; obj.method()", Want: int64(1)},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x; }
+				}
+				let obj = A(); obj.method()`,
+		Want: int64(20),
+	},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x; }
+				}
+				let obj = A(); obj.x = 50; obj.init()`,
+		Want: &ExpectInstance{Class: "A"},
+	},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x; }
+				}
+				let obj = A(); obj.x = 50; obj.init(); obj.x`,
+		Want: int64(20),
+	},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x; }
+				}
+				class B < A{}
+				let obj = B();`,
+		Want: &ExpectInstance{Class: "B"},
+	},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x; }
+				}
+				class B < A{}
+				let obj = B(); obj.x;`,
+		Want: int64(20),
+	},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x; }
+				}
+				class B < A {
+					fn init() { super.init() }
+				}
+				let obj = B(); obj.x;`,
+		Want: int64(20),
+	},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x * 2; }
+				}
+				class B < A {
+					fn init() { super.init() }
+					fn method() { this.X }
+					fn doubleX() { super.method() }
+				}
+				class C < B {}
+				let obj = C(); obj.doubleX();`,
+		Want: int64(40),
+	},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x * 2; }
+				}
+				class B < A {
+					fn init() { super.init() }
+					fn method() { this.X }
+					fn doubleX() { super.method }
+				}
+				class C < B {}
+				let obj = C(); let d = obj.doubleX(); obj.x = 10; d()`,
+		Want: int64(20),
+	},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x; }
+				}
+				class B < A {}
+				let obj = B(); obj.x = 50; obj.init(); obj.method()`,
+		Want: int64(20),
+	},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x; }
+				}
+				class B {
+					fn init() {this.x = 10; }
+				}
+				let a = A(); let b = B(); b.method = a.method; b.method();`,
+		Want: int64(20),
+	},
+	{
+		Source: `class A {
+					fn init(n) { this.x = n; }
+					fn method() { this.x; }
+				}
+				class B < A{}
+				let obj = B(10); obj.x`,
+		Want: int64(10),
+	},
+	{
+		Source: `class A {
+					fn init(n) { this.x = n; }
+					fn method() {
+						class B < A {}
+						B(20);
+					}
+				}
+				let obj = A(10).method(); obj.x`,
+		Want: int64(20),
+	},
+	{
+		Source: `class A {
+					fn init(n) { this.x = n; }
+					fn new(x) {
+						A(x);
+					}
+				}
+				let obj = A(10).new(20); obj.x`,
+		Want: int64(20),
+	},
+	{
+		Source: "let x = 1; class B {} { class A < B { fn f() { x = 20; } } A().f() } x",
+		Want:   int64(20),
+	},
+	// arrays
+	{Source: "[1, 2, 3];", Want: []interface{}{int64(1), int64(2), int64(3)}},
+	{Source: "[1, 2, 3][1];", Want: int64(2)},
+	{Source: "[1, 2, 3][2];", Want: int64(3)},
+	{Source: "[1, 2, 3][-1];", Want: int64(3)},
+	{Source: "[1, 2, 3][-2];", Want: int64(2)},
+	{Source: "[1, 2, 3][-3];", Want: int64(1)},
+	{Source: "let i = 0; [1, 2, 3][i];", Want: int64(1)},
+	{Source: "[1, 2, 3][1 + 1];", Want: int64(3)},
+	{Source: "let arr = [1, 2, 3]; arr[1];", Want: int64(2)},
+	{Source: "let arr = [1, 2, 3]; arr[0] + arr[1] + arr[2];", Want: int64(6)},
+	{Source: "let arr = [1, 2, 3]; let i = arr[0]; arr[i]", Want: int64(2)},
+	{Source: "let arr = [1, 2, 3]; let i = arr[0]; arr[-i]", Want: int64(3)},
+	{Source: "len([1, 2, 3]);", Want: int64(3)},
+	{
+		Source: `{| 1: true, 2: "hello", "world": 3 |};`,
+		Want:   map[interface{}]interface{}{int64(1): true, int64(2): "hello", "world": int64(3)},
+	},
+	{Source: `{| 1: true, 2: "hello", "world": 3 |}[2];`, Want: "hello"},
+	{Source: `{| 1: true, 2: "hello" + " world", "world": 3 |}[2];`, Want: "hello world"},
+	{Source: `{| 1: true, 2: "hello" + " world", "world": 3 |}[true];`, Want: nil},
+	{Source: `{| 1: true, "fn": fn(x){ x + x }, "world": 3 |}["fn"]("wow");`, Want: "wowwow"},
+	{Source: `let x = 2; {| x: true, "fn": fn(x){ x + x }, "world": 3 |}[2];`, Want: true},
+	{
+		Source: `class A {} {| 1: true, "fn": fn(x){ x + x }, "world": A |}["world"]();`,
+		Want:   &ExpectInstance{Class: "A"},
+	},
+	{
+		Source: `let x= {| 1: "world", "fn": fn(x){ x + x }, "world": 3 |}; x["fn"](x[x[1]])`,
+		Want:   int64(6),
+	},
+	// loops
+	{Source: "let n = 0; while (n < 5) { n = n + 1; } n", Want: int64(5)},
+	{Source: "let n = 0; while (n < 5) n = n + 1; n", Want: int64(5)},
+	{Source: "while (false) { 10; }", Want: nil},
+	{Source: "let n = 0; while (true) { n = n + 1; if (n == 3) break; } n", Want: int64(3)},
+	{
+		Source: "let n = 0; let s = 0; while (n < 5) { n = n + 1; if (n == 2) continue; s = s + n; } s",
+		Want:   int64(13),
+	},
+	{Source: "let s = 0; for (let i = 0; i < 5; i = i + 1) { s = s + i; } s", Want: int64(10)},
+	{Source: "let s = 0; let i = 0; for (; i < 5;) { i = i + 1; s = s + i; } s", Want: int64(15)},
+	{Source: "let i = 0; for (;;) { i = i + 1; if (i > 9) break; } i", Want: int64(10)},
+	{
+		Source: "let s = 0; for (let i = 0; i < 5; i = i + 1) { if (i == 1) continue; s = s + i; } s",
+		Want:   int64(9),
+	},
+	{Source: "let s = 0; for (x in [1, 2, 3]) s = s + x; s", Want: int64(6)},
+	{Source: "let s = 0; for x in [1, 2, 3] { s = s + x; } s", Want: int64(6)},
+	{Source: `let s = ""; for (c in "abc") { s = c + s; } s`, Want: "cba"},
+	{Source: "let s = 0; for (k in {| 1: 10, 2: 20 |}) { s = s + k; } s", Want: int64(3)},
+	{
+		Source: "let fs = []; let r = 0; for (x in [1, 2]) { fs = [fn() { x }, fs]; } fs[0]() + fs[1][0]()",
+		Want:   int64(3),
+	},
+	{Source: "fn f() { for (x in [1, 2, 3]) { if (x == 2) return x; } } f()", Want: int64(2)},
+	{Source: "fn f() { while (true) { return 5; } } f()", Want: int64(5)},
+	{
+		Source: "let s = 0; for (x in [1, 2]) { for (y in [10, 20]) { if (y == 20) break; s = s + x * y; } } s",
+		Want:   int64(30),
+	},
+	{Source: `"héllo"[1:3]`, Want: "él"},
+	{Source: `"hello"[:2] + "hello"[3:]`, Want: "helo"},
+	{Source: `"hello"[-3:]`, Want: "llo"},
+	{Source: `"hello"[2:100]`, Want: "llo"},
+	{Source: `"hello"[4:1]`, Want: ""},
+	{Source: `"hello"[:]`, Want: "hello"},
+	{Source: "let xs = [1, 2, 3]; let ys = xs[1:]; ys[0]", Want: int64(2)},
+	{Source: "[1, 2, 3][:-1]", Want: []interface{}{int64(1), int64(2)}},
+	{Source: `split("a,b,,c", ",")`, Want: []interface{}{"a", "b", "", "c"}},
+	{Source: `join(["a", 1, true], "-")`, Want: "a-1-true"},
+	{Source: `join(split("a b c", " "), "")`, Want: "abc"},
+	{Source: `trim("  \t padded \n")`, Want: "padded"},
+	{Source: `replace("a-b-c", "-", "+")`, Want: "a+b+c"},
+	{Source: `contains("monkey", "key")`, Want: true},
+	{Source: `contains("monkey", "donkey")`, Want: false},
+	{Source: `startsWith("monkey", "mon")`, Want: true},
+	{Source: `endsWith("monkey", "mon")`, Want: false},
+	{Source: `indexOf("héllo", "l")`, Want: int64(2)},
+	{Source: `indexOf("hello", "z")`, Want: int64(-1)},
+	{Source: `upper("héllo")`, Want: "HÉLLO"},
+	{Source: `lower("MiXeD")`, Want: "mixed"},
+	{Source: `substr("héllo", 1)`, Want: "éllo"},
+	{Source: `substr("héllo", 1, 3)`, Want: "éll"},
+	{Source: `substr("hello", -2, 5)`, Want: "lo"},
+	{Source: `repeat("ab", 3)`, Want: "ababab"},
+	{Source: `repeat("", 9223372036854775807)`, Want: ""},
+	{Source: `substr("hello", 2, 9223372036854775807)`, Want: "llo"},
+	{Source: `substr("hello", -9223372036854775807, 9223372036854775807)`, Want: "hello"},
+	{Source: `format("%s has %d items, %.2f%%", "cart", 3, 12.5)`, Want: "cart has 3 items, 12.50%"},
+	{Source: `format("%v|%5s|%-3d|", [1], "ab", 7)`, Want: "[1]|   ab|7  |"},
+	// array builtins
+	{Source: "let xs = [1]; push(xs, 2, 3); xs", Want: []interface{}{int64(1), int64(2), int64(3)}},
+	{Source: `let a = [1]; push(a, a); "${a}"`, Want: "[1, [...]]"},
+	{Source: `let a = [1]; push(a, a); join(a, " ")`, Want: "1 [1, [...]]"},
+	{Source: "let xs = [1, 2]; [pop(xs), xs]", Want: []interface{}{int64(2), []interface{}{int64(1)}}},
+	{Source: "pop([])", Want: nil},
+	{Source: "[first([1, 2]), last([1, 2]), first([])]", Want: []interface{}{int64(1), int64(2), nil}},
+	{Source: "let xs = [1, 2, 3]; [rest(xs), xs]", Want: []interface{}{[]interface{}{int64(2), int64(3)}, []interface{}{int64(1), int64(2), int64(3)}}},
+	{Source: "rest([])", Want: nil},
+	{Source: "slice([1, 2, 3, 4], 1, -1)", Want: []interface{}{int64(2), int64(3)}},
+	{Source: "slice([1, 2, 3], 1)", Want: []interface{}{int64(2), int64(3)}},
+	{Source: "concat([1], [], [2, 3])", Want: []interface{}{int64(1), int64(2), int64(3)}},
+	{Source: "let xs = [1, 2, 3]; [reverse(xs), xs]", Want: []interface{}{[]interface{}{int64(3), int64(2), int64(1)}, []interface{}{int64(1), int64(2), int64(3)}}},
+	{Source: "map([1, 2, 3], fn(x) { x * 2 })", Want: []interface{}{int64(2), int64(4), int64(6)}},
+	{Source: `map(["a", "bc"], len)`, Want: []interface{}{int64(1), int64(2)}},
+	{Source: "let k = 10; map([1, 2], fn(x) { x + k })", Want: []interface{}{int64(11), int64(12)}},
+	{Source: "filter([1, 2, 3, 4], fn(x) { x > 2 })", Want: []interface{}{int64(3), int64(4)}},
+	{Source: "reduce([1, 2, 3], fn(acc, x) { acc + x })", Want: int64(6)},
+	{Source: `reduce([1, 2], fn(acc, x) { acc + "${x}" }, "")`, Want: "12"},
+	{Source: "reduce([], fn(acc, x) { acc + x })", Want: nil},
+	{Source: "find([1, 2, 3], fn(x) { x > 1 })", Want: int64(2)},
+	{Source: "find([1, 2, 3], fn(x) { x > 5 })", Want: nil},
+	{Source: "sort([3, 1.5, 2])", Want: []interface{}{1.5, int64(2), int64(3)}},
+	{Source: `sort(["b", "c", "a"])`, Want: []interface{}{"a", "b", "c"}},
+	{Source: "sort([1, 3, 2], fn(a, b) { b - a })", Want: []interface{}{int64(3), int64(2), int64(1)}},
+	{Source: `sort(["bb", "a", "cc", "d"], fn(a, b) { len(a) - len(b) })`, Want: []interface{}{"a", "d", "bb", "cc"}},
+	{
+		Source: "class C { fn init(n) { this.n = n; } fn add(x) { x + this.n } } map([1, 2], C(10).add)",
+		Want:   []interface{}{int64(11), int64(12)},
+	},
+	{Source: "class P { fn init(x) { this.x = x; } } map([1, 2], P)[1].x", Want: int64(2)},
+	{Source: "map([[1, 2], [3]], fn(xs) { map(xs, fn(x) { x * 10 }) })", Want: []interface{}{[]interface{}{int64(10), int64(20)}, []interface{}{int64(30)}}},
+	// hash builtins, pairs are kept in insertion order
+	{Source: `let h = {| "b": 1, "a": 2, 3: true |}; "${h}"`, Want: "{| b: 1, a: 2, 3: true |}"},
+	{Source: `keys({| "b": 1, "a": 2 |})`, Want: []interface{}{"b", "a"}},
+	{Source: `values({| "b": 1, "a": 2 |})`, Want: []interface{}{int64(1), int64(2)}},
+	{Source: `entries({| "b": 1, 2: "a" |})`, Want: []interface{}{[]interface{}{"b", int64(1)}, []interface{}{int64(2), "a"}}},
+	{Source: `let s = ""; for (k in {| "z": 1, "y": 2, "x": 3 |}) { s = s + k; } s`, Want: "zyx"},
+	{Source: `let h = {| "a": 1 |}; [has(h, "a"), has(h, "b"), has(h, 1.0)]`, Want: []interface{}{true, false, false}},
+	{Source: `let h = {| "a": 1, "b": 2 |}; [delete(h, "a"), delete(h, "a"), keys(h)]`, Want: []interface{}{int64(1), nil, []interface{}{"b"}}},
+	{Source: `let h = {| "a": 1, "b": 2 |}; "${merge(h, {| "c": 3, "a": 4 |})} ${h}"`, Want: "{| a: 4, b: 2, c: 3 |} {| a: 1, b: 2 |}"},
+	{Source: "merge()", Want: map[interface{}]interface{}{}},
+	// index and compound assignment
+	{Source: "let xs = [1, 2, 3]; xs[0] = 10; xs[-1] = 30; xs", Want: []interface{}{int64(10), int64(2), int64(30)}},
+	{Source: "let xs = [1]; xs[0] = 5;", Want: int64(5)},
+	{Source: "let m = [[1, 2], [3, 4]]; m[1][0] = 0; m", Want: []interface{}{[]interface{}{int64(1), int64(2)}, []interface{}{int64(0), int64(4)}}},
+	{Source: `let h = {| "a": 1 |}; h["b"] = 2; h["a"] = 3; "${h}"`, Want: "{| a: 3, b: 2 |}"},
+	{Source: `let h = {| "a": 1 |}; h["h"] = h; "${h}"`, Want: "{| a: 1, h: {|...|} |}"},
+	{Source: "let xs = [1]; let ys = xs; ys[0] = 2; xs[0]", Want: int64(2)},
+	{Source: "let x = 1; x += 2; x *= 4; x -= 2; x /= 5; x", Want: int64(2)},
+	{Source: `let s = "a"; s += "b"; s`, Want: "ab"},
+	{Source: "let xs = [1, 2]; xs[1] += 10; xs", Want: []interface{}{int64(1), int64(12)}},
+	{Source: `let h = {| "n": 1 |}; h["n"] *= 5; h["n"]`, Want: int64(5)},
+	{Source: "class C { fn init() { this.n = 1; } } let c = C(); c.n += 41; c.n", Want: int64(42)},
+	{Source: "let f = fn() { let n = 0; fn() { n += 1; n } }; let g = f(); g(); g()", Want: int64(2)},
+	{Source: "let i = 0; let xs = [0, 0]; let next = fn() { i += 1; i - 1 }; xs[next()] += 5; [xs, i]", Want: []interface{}{[]interface{}{int64(5), int64(0)}, int64(1)}},
+	{Source: "let a = [0]; let b = [0]; a[0] = b[0] = 7; a[0] + b[0]", Want: int64(14)},
+	// modulo, power, bitwise and shift operators
+	{Source: "7 % 3", Want: int64(1)},
+	{Source: "-7 % 3", Want: int64(-1)},
+	{Source: "7.5 % 2", Want: 1.5},
+	{Source: "2 ** 10", Want: int64(1024)},
+	{Source: "2 ** 3 ** 2", Want: int64(512)},
+	{Source: "-2 ** 2", Want: int64(-4)},
+	{Source: "2 ** -1", Want: 0.5},
+	{Source: "4 ** 0.5", Want: 2.0},
+	{Source: "2 ** 64", Want: int64(0)},
+	{Source: "6 & 3", Want: int64(2)},
+	{Source: "6 | 3", Want: int64(7)},
+	{Source: "6 ^ 3", Want: int64(5)},
+	{Source: "~5", Want: int64(-6)},
+	{Source: "1 << 10", Want: int64(1024)},
+	{Source: "-16 >> 2", Want: int64(-4)},
+	{Source: "1 << 64", Want: int64(0)},
+	{Source: "1 + 2 * 3 % 4 ** 2", Want: int64(7)},
+	{Source: "1 | 2 ^ 3 & 4 << 1", Want: int64(3)},
+	{Source: "4 & 1 == 0", Want: true},
+	{Source: "1 <= 1 && 2 >= 3", Want: false},
+	{Source: "{| 1: 2 |}[3 % 2]", Want: int64(2)},
+
+	// exceptions
+	{Source: "try { [1][5]; } catch (e) { e.kind; }", Want: "IndexError"},
+	{Source: "try { [1][5]; } catch (e) { e.message; }", Want: "out of bounds: ARRAY[5]"},
+	{Source: "try { x; } catch (e) { e.kind; }", Want: "NameError"},
+	{Source: "try { 1 / 0; } catch (e) { e.kind + \": \" + e.message; }", Want: "ArithmeticError: division by zero"},
+	{Source: "try { 1 + true; } catch (e) { e.value; }", Want: nil},
+	{Source: `try { throw "boom"; } catch (e) { e.kind + ": " + e.message; }`, Want: "Error: boom"},
+	{Source: "try { throw [1, 2]; } catch (e) { e.value[1]; }", Want: int64(2)},
+	{Source: "try { throw 1; } catch (e) { 2; } 3;", Want: int64(3)},
+	{Source: "let f = fn() { [][0] }; try { f(); } catch (e) { e.kind; }", Want: "IndexError"},
+	{Source: "try { if (1 / 0) { 1 } } catch (e) { e.kind; }", Want: "ArithmeticError"},
+	{Source: "try { try { 1 + true; } catch (e) { throw e; } } catch (e) { e.kind; }", Want: "TypeError"},
+	{Source: "let x = 0; try { x = 1; } finally { x = x + 10; } x;", Want: int64(11)},
+	{
+		Source: `let log = ""; try { throw "a"; } catch (e) { log = log + e.message; } finally { log = log + "f"; } log;`,
+		Want:   "af",
+	},
+	{Source: "let x = 0; let f = fn() { try { return 1; } finally { x = 2; } }; f() + x;", Want: int64(3)},
+	{Source: "let f = fn() { try { return 1; } finally { return 2; } }; f();", Want: int64(2)},
+	{Source: "let f = fn() { try { throw 1; } finally { return 2; } }; f();", Want: int64(2)},
+	{Source: "let n = 0; while (true) { try { break; } finally { n = n + 1; } } n;", Want: int64(1)},
+	{Source: "let n = 0; for (let i = 0; i < 3; i = i + 1) { try { continue; } finally { n = n + 1; } } n;", Want: int64(3)},
+	{Source: "let f = fn() { try { throw 1; } catch (e) { return e.value + 1; } }; f();", Want: int64(2)},
+	{Source: "let f = fn(x) { if (x > 2) { throw x; } x }; try { map([1, 2, 3], f); } catch (e) { e.value; }", Want: int64(3)},
+	{Source: "let f = fn(x) { try { 1 / x; } catch (e) { 0; } }; map([1, 0], f);", Want: []interface{}{int64(1), int64(0)}},
+	{Source: "let x = 1; let f = fn() { let y = 2; try { throw y; } catch (e) { x + e.value; } }; f();", Want: int64(3)},
+	{
+		Source: "let g = fn() { throw 5; }; let f = fn() { try { g(); } finally { 1; } }; try { f(); } catch (e) { e.value; }",
+		Want:   int64(5),
+	},
+}
+
+var ErrorCases = []ErrorCase{
+	{Source: "-true;", Want: "unknown operator: -BOOLEAN"},
+	{Source: "-null;", Want: "unknown operator: -NULL"},
+	{Source: "true - 5;", Want: "type mismatch: BOOLEAN - INTEGER"},
+	{Source: "5 > true;", Want: "type mismatch: INTEGER > BOOLEAN"},
+	{Source: "null > true;", Want: "type mismatch: NULL > BOOLEAN"},
+	{Source: "null > null;", Want: "unknown operator: NULL > NULL"},
+	{Source: "5 + true; 5;", Want: "type mismatch: INTEGER + BOOLEAN"},
+	{Source: "true + false;", Want: "unknown operator: BOOLEAN + BOOLEAN"},
+	{Source: "null + null;", Want: "unknown operator: NULL + NULL"},
+	{Source: "null + 10;", Want: "type mismatch: NULL + INTEGER"},
+	{Source: "null * 2;", Want: "type mismatch: NULL * INTEGER"},
+	{Source: "null + false;", Want: "type mismatch: NULL + BOOLEAN"},
+	{Source: "true + false;", Want: "unknown operator: BOOLEAN + BOOLEAN"},
+	{Source: `"str1" < "str2";`, Want: "unknown operator: STRING < STRING"},
+	{Source: `"str1" - "str2";`, Want: "unknown operator: STRING - STRING"},
+	{Source: "5; true - 1; 5;", Want: "type mismatch: BOOLEAN - INTEGER"},
+	{Source: "let x = fn() { true }; x(1)", Want: "wrong arguments count: expect 0, got 1"},
+	{Source: "len(10)", Want: "type mismatch: len(INTEGER)"},
+	{Source: "len(null)", Want: "type mismatch: len(NULL)"},
+	{Source: `len("one", "two")`, Want: "wrong arguments count: expect 1, got 2"},
+	{Source: "null()", Want: "not a function: NULL 'null'"},
+	{Source: "if (10 > 0) { return true + false; }; 6;", Want: "unknown operator: BOOLEAN + BOOLEAN"},
+	{Source: "(true - false) * 1", Want: "unknown operator: BOOLEAN - BOOLEAN"},
+	{Source: "x;", Want: "identifier not found: 'x'"},
+	{Source: "let a = 10; return y;", Want: "identifier not found: 'y'"},
+	{Source: "let a = 10; b = 20;", Want: "identifier not found: 'b'"},
+	{Source: "let a = 10; a = b;", Want: "identifier not found: 'b'"},
+	{Source: "let function = 1; function(false)", Want: "not a function: INTEGER '1'"},
+	{Source: "let x = 10; { let f = x; } f;", Want: "identifier not found: 'f'"},
+	{Source: `len("Hello, World!"); { let len = 10; len; len("Hello, World!")}`, Want: "not a function: INTEGER '10'"},
+	{Source: `class A {} A(1, "b");`, Want: "wrong arguments count: expect 0, got 2"},
+	{Source: `class A {} A.field;`, Want: "only instances have properties: CLASS.field"},
+	{Source: `class A {} A.field = 10;`, Want: "only instances have fields: CLASS.field"},
+	{Source: `class A {} A().field;`, Want: "undefined property: 'field'"},
+	{Source: "class A { fn method() { this.x; }} let obj = A(); obj.method()", Want: "undefined property: 'x'"},
+	{Source: `"hi".field;`, Want: "only instances have properties: STRING.field"},
+	{Source: `"hi".field = 10;`, Want: "only instances have fields: STRING.field"},
+	{Source: "class A < B {}", Want: "identifier not found: 'B'"},
+	{Source: "let B = 10; class A < B {}", Want: "superclass must be a class: 'A < INTEGER'"},
+	{
+		Source: `class A {
+					fn init() { this.x = 20; }
+					fn method() { this.x; }
+				}
+				class B < A{
+					fn init() {}
+				}
+				let obj = B(); obj.x;`,
+		Want: "undefined property: 'x'",
+	},
+	{
+		Source: `class A {
+					fn init(n) { this.x = n; }
+					fn method() { this.x; }
+				}
+				class B < A{}
+				let obj = B(); obj.x`,
+		Want: "wrong arguments count: expect 1, got 0",
+	},
+	{Source: "[1, 2, 3][3]", Want: "out of bounds: ARRAY[3]"},
+	{Source: "[1, 2, 3][-4]", Want: "out of bounds: ARRAY[-4]"},
+	{Source: `"hello"[5]`, Want: "out of bounds: STRING[5]"},
+	{Source: `"sum: ${1 + true}"`, Want: "type mismatch: INTEGER + BOOLEAN"},
+	{Source: `"héllo"[-6]`, Want: "out of bounds: STRING[-6]"},
+	{Source: `"hello"["h"]`, Want: "unknown operator: STRING[STRING]"},
+	{Source: `["hello", "world"]["first"]`, Want: "unknown operator: ARRAY[STRING]"},
+	{Source: `(fn (){})[0]`, Want: "unknown operator: FUNCTION[INTEGER]"},
+	{Source: `{| 1: true && false, 2 + 3: "hello", "world": 3, fn(){}: "oops" |}`, Want: "unusable as hash key: FUNCTION"},
+	{Source: `let arr = []; {| 1: true, false: 2, 3: "hello" |}[arr]`, Want: "unusable as hash key: ARRAY"},
+	{Source: `class A{} {| 1: true, false: 2, 3: "hello" |}[A]`, Want: "unusable as hash key: CLASS"},
+	{Source: `class A{} {| 1: true, false: 2, 3: "hello" |}[A()]`, Want: "unusable as hash key: INSTANCE"},
+	{Source: "1.5 + true;", Want: "type mismatch: FLOAT + BOOLEAN"},
+	{Source: `"a" * 1.5;`, Want: "type mismatch: STRING * FLOAT"},
+	{Source: "-[1.5];", Want: "unknown operator: -ARRAY"},
+	{Source: `int("abc");`, Want: `invalid number: int("abc")`},
+	{Source: `float("1.5.5");`, Want: `invalid number: float("1.5.5")`},
+	{Source: "float(null);", Want: "type mismatch: float(NULL)"},
+	{Source: "int(1, 2);", Want: "wrong arguments count: expect 1, got 2"},
+	{Source: "for (x in 10) x;", Want: "not iterable: INTEGER"},
+	{Source: "while (1 + true) {}", Want: "type mismatch: INTEGER + BOOLEAN"},
+	{Source: "for (let i = 0; i < 3; i = i + null) {}", Want: "type mismatch: INTEGER + NULL"},
+	{Source: `"hello"["a":]`, Want: "unknown operator: STRING[STRING:NULL]"},
+	{Source: "10[1:2]", Want: "unknown operator: INTEGER[INTEGER:INTEGER]"},
+	{Source: `split("a,b")`, Want: "wrong arguments count: expect 2, got 1"},
+	{Source: `split("a,b", 1)`, Want: "type mismatch: split(STRING, INTEGER)"},
+	{Source: `join("ab", "")`, Want: "type mismatch: join(STRING, STRING)"},
+	{Source: `upper(1)`, Want: "type mismatch: upper(INTEGER)"},
+	{Source: `substr("abc", "1")`, Want: "type mismatch: substr(STRING, STRING)"},
+	{Source: `substr("abc", 1, -1)`, Want: "invalid number: substr(-1)"},
+	{Source: `repeat("ab", -1)`, Want: "invalid number: repeat(-1)"},
+	{Source: `repeat("ab", 9223372036854775807)`, Want: "string too long: repeat() exceeds 1073741824 bytes"},
+	{Source: `repeat("ab", 4611686018427387904)`, Want: "string too long: repeat() exceeds 1073741824 bytes"},
+	{Source: `format(1)`, Want: "type mismatch: format(INTEGER)"},
+	{Source: "push(1, 2)", Want: "type mismatch: push(INTEGER, INTEGER)"},
+	{Source: "push([])", Want: "wrong arguments count: expect 2, got 1"},
+	{Source: "first([], 1)", Want: "wrong arguments count: expect 1, got 2"},
+	{Source: `concat([1], "a")`, Want: "type mismatch: concat(ARRAY, STRING)"},
+	{Source: "map([1], 2)", Want: "type mismatch: map(ARRAY, INTEGER)"},
+	{Source: "map([1], fn(a, b) { a })", Want: "wrong arguments count: expect 2, got 1"},
+	{Source: "map([1, 2], fn(x) { x + true })", Want: "type mismatch: INTEGER + BOOLEAN"},
+	{Source: `sort([1, "a"])`, Want: "type mismatch: sort(STRING, INTEGER)"},
+	{Source: "sort([1, 2], fn(a, b) { true })", Want: "type mismatch: sort(BOOLEAN)"},
+	{Source: "sort([1, 2], fn(a, b) { -a.x })", Want: "only instances have properties: INTEGER.x"},
+	{Source: "keys([1])", Want: "type mismatch: keys(ARRAY)"},
+	{Source: `has({||}, [1])`, Want: "unusable as hash key: ARRAY"},
+	{Source: `delete({||})`, Want: "wrong arguments count: expect 2, got 1"},
+	{Source: `merge({||}, 1)`, Want: "type mismatch: merge(HASH, INTEGER)"},
+	{Source: "let xs = [1]; xs[1] = 2;", Want: "out of bounds: ARRAY[1]"},
+	{Source: "let xs = [1]; xs[-2] = 2;", Want: "out of bounds: ARRAY[-2]"},
+	{Source: `let xs = [1]; xs["0"] = 2;`, Want: "index assignment unsupported: ARRAY[STRING]"},
+	{Source: `let s = "abc"; s[0] = "x";`, Want: "index assignment unsupported: STRING[INTEGER]"},
+	{Source: "let h = {||}; h[[1]] = 2;", Want: "unusable as hash key: ARRAY"},
+	{Source: `let h = {||}; h["x"] += 1;`, Want: "type mismatch: NULL + INTEGER"},
+	{Source: "let x = 1; x += true;", Want: "type mismatch: INTEGER + BOOLEAN"},
+	{Source: "y += 1;", Want: "identifier not found: 'y'"},
+	{Source: "let n = 1; n.x += 1;", Want: "only instances have properties: INTEGER.x"},
+	{Source: "let n = 1; n.x = 1;", Want: "only instances have fields: INTEGER.x"},
+	{Source: "1 / 0", Want: "division by zero"},
+	{Source: "1 % 0", Want: "division by zero"},
+	{Source: "1.5 / 0", Want: "division by zero"},
+	{Source: "1 % 0.0", Want: "division by zero"},
+	{Source: "let x = 1; x /= 0;", Want: "division by zero"},
+	{Source: "1 << -1", Want: "negative shift count: -1"},
+	{Source: "fn f(n) { 1 + f(n) } f(0)", Want: "stack overflow"},
+	{Source: "fn f(n) { return [f(n)]; } f(0)", Want: "stack overflow"},
+	{Source: "1.5 & 1", Want: "unknown operator: FLOAT & INTEGER"},
+	{Source: "~1.5", Want: "unknown operator: ~FLOAT"},
+	{Source: `"a" ** 2`, Want: "type mismatch: STRING ** INTEGER"},
+}
+
+var ExceptionCases = []ExceptionCase{
+	{Source: `throw "boom";`, Kind: "Error", Message: "boom", Span: "1:1"},
+	{Source: `try { throw "x"; } finally { 1; }`, Kind: "Error", Message: "x", Span: "1:7"},
+	{Source: "try { x; } catch (e) { throw e; }", Kind: "NameError", Message: "identifier not found: 'x'", Span: "1:7"},
+	{Source: "try { throw 1; } catch (e) { e.foo; }", Kind: "PropertyError", Message: "undefined property: 'foo'", Span: "1:30"},
+	{Source: "try { 1; } finally { -true; }", Kind: "TypeError", Message: "unknown operator: -BOOLEAN", Span: "1:22"},
+	{Source: `fn f() { throw "boom"; } if (f()) { "then" } else { "else" }`, Kind: "Error", Message: "boom", Span: "1:10"},
+	{Source: "if (1 / 0) { 1 }", Kind: "ArithmeticError", Message: "division by zero", Span: "1:5"},
+}
+
+// PositionCases are failing programs with position of erroneous expression.
+var PositionCases = []ErrorCase{
+	{Source: "5 + true;", Want: "1:1"},
+	{Source: "let x = 1;\n  x + y;", Want: "2:7"},
+	{Source: "fn f() {\n  null();\n}\nf();", Want: "2:3"},
+	{Source: "[1, 2][5];", Want: "1:1"},
+	{Source: "map([1],\n  fn(x) { -true });", Want: "2:11"},
+}
+
+var StackCases = []StackCase{
+	{Source: "5 + true;", Want: []string{}},
+	{Source: "fn f() { null(); } f();", Want: []string{"f"}},
+	{Source: "fn g() { 1 + true } fn f() { g() } f();", Want: []string{"f", "g"}},
+	{Source: "fn g() { 1 + true } fn f() { g() } f(); 10", Want: []string{"f", "g"}},
+	{Source: "let f = fn() { fn() { len(1) }() }; f();", Want: []string{"f", "<anonymous>"}},
+	{
+		Source: "class A { fn init() { this.m(); } fn m() { -true; } } A();",
+		Want:   []string{"A.init", "A.m"},
+	},
+	{
+		Source: "class A { fn m() { -true; } } class B < A {} B().m();",
+		Want:   []string{"A.m"},
+	},
+	{Source: "fn f(x) { x.y } fn g() { map([1], f) } g();", Want: []string{"g", "f"}},
+	{Source: "fn g() { 1 + true } fn f() { return g(); } f();", Want: []string{"g"}},
+}
+
+// TailCallCases return calls nested deeper than the default call depth
+// limits of both backends allow, unless the calls replace their callers.
+var TailCallCases = []Case{
+	{Source: "fn f(n) { if (n == 0) { return 0; } return f(n - 1); } f(20000)", Want: int64(0)},
+	{
+		Source: "fn sum(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); } sum(100000, 0);",
+		Want:   int64(5000050000),
+	},
+	{
+		Source: "fn even(n) { if (n == 0) { return true; } return odd(n - 1); }" +
+			" fn odd(n) { if (n == 0) { return false; } return even(n - 1); } even(50001);",
+		Want: false,
+	},
+	{
+		Source: "class C { fn count(n) { if (n == 0) { return \"done\"; } return this.count(n - 1); } } C().count(50000);",
+		Want:   "done",
+	},
+	{
+		Source: "fn f(n, acc) { let g = fn() { n }; if (n == 0) { return acc; } return f(n - 1, acc + g()); } f(20000, 0);",
+		Want:   int64(200010000),
+	},
+	{Source: "fn outer(n) { let k = n * 2; let g = fn() { k }; return g(); } outer(21);", Want: int64(42)},
+	{Source: "class P { fn init(x) { this.x = x; } } fn make(x) { return P(x); } make(7).x;", Want: int64(7)},
+	{Source: "fn f(s) { return len(s); } f(\"abc\");", Want: int64(3)},
+	{Source: "fn f(n) { try { return f(n + 1); } catch (e) { return e.kind; } } f(0);", Want: "RecursionError"},
+}
+
+// CheckObject compares obj with expected value of a Case.
+func CheckObject(t testing.TB, obj object.Object, want interface{}) {
+	switch obj.Type() {
+	case object.INTEGER_OBJ:
+		o, ok := obj.(*object.Integer)
+		if !ok {
+			t.Errorf("Object is not an Integer, got %T. (%+v)", obj, obj)
+		}
+		w, ok := want.(int64)
+		if !ok {
+			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+		}
+
+		if w != o.Value {
+			t.Errorf("Wrong object value. Got %v, want %v.", o.Value, w)
+		}
+	case object.FLOAT_OBJ:
+		o, ok := obj.(*object.Float)
+		if !ok {
+			t.Errorf("Object is not a Float, got %T. (%+v)", obj, obj)
+		}
+		w, ok := want.(float64)
+		if !ok {
+			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+		}
+
+		if w != o.Value {
+			t.Errorf("Wrong object value. Got %v, want %v.", o.Value, w)
+		}
+	case object.BOOLEAN_OBJ:
+		o, ok := obj.(*object.Boolean)
+		if !ok {
+			t.Errorf("Object is not an Boolean, got %T. (%+v)", obj, obj)
+		}
+		w, ok := want.(bool)
+		if !ok {
+			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+		}
+
+		if w != o.Value {
+			t.Errorf("Wrong object value. Got %v, want %v.", o.Value, w)
+		}
+	case object.STRING_OBJ:
+		o, ok := obj.(*object.String)
+		if !ok {
+			t.Errorf("Object is not an String, got %T. (%+v)", obj, obj)
+		}
+		w, ok := want.(string)
+		if !ok {
+			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+		}
+
+		if w != o.Value {
+			t.Errorf("Wrong object value. Got %v, want %v.", o.Value, w)
+		}
+	case object.ARRAY_OBJ:
+		a, ok := obj.(*object.Array)
+		if !ok {
+			t.Errorf("Object is not an Array, got %T. (%+v)", obj, obj)
+		}
+		w, ok := want.([]interface{})
+		if !ok {
+			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+		}
+
+		if len(w) != len(a.Elements) {
+			t.Fatalf("Wrong array value, got %s, want %v", a.Inspect(), w)
+		}
+
+		for i, el := range a.Elements {
+			CheckObject(t, el, w[i])
+		}
+	case object.HASH_OBJ:
+		h, ok := obj.(*object.Hash)
+		if !ok {
+			t.Errorf("Object is not an Hash, got %T. (%+v)", obj, obj)
+		}
+		w, ok := want.(map[interface{}]interface{})
+		if !ok {
+			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+		}
+
+		if len(w) != h.Len() {
+			t.Fatalf("Wrong hash value, got %s, want %v", h.Inspect(), w)
+		}
+
+		for _, pair := range h.Pairs() {
+			var ok bool
+			var want interface{}
+
+			switch k := pair.Key.(type) {
+			case *object.String:
+				want, ok = w[k.Value]
+			case *object.Boolean:
+				want, ok = w[k.Value]
+			case *object.Integer:
+				want, ok = w[k.Value]
+			default:
+				t.Fatalf("Unknown key type.")
+			}
+
+			if !ok {
+				t.Errorf("Want %v, got %+v.", w, h.Inspect())
+				t.Errorf("Didn't expect key '%v' in pair '%v: %v'", pair.Key, pair.Key, pair.Value)
+			} else {
+				CheckObject(t, pair.Value, want)
+			}
+		}
+	case object.NULL_OBJ:
+		_, ok := obj.(*object.Null)
+		if !ok {
+			t.Errorf("Object is not an Null, got %T. (%+v)", obj, obj)
+		}
+
+		if want != nil {
+			t.Errorf("Object is Null, but want %v.", want)
+		}
+	case object.FUNCTION_OBJ:
+		fn, ok := obj.(*object.Function)
+		if !ok {
+			t.Errorf("Object is not an Function, got %T. (%+v)", obj, obj)
+		}
+		w, ok := want.(*ExpectFn)
+		if !ok {
+			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+		}
+
+		if len(fn.Parameters) != len(w.Params) {
+			t.Errorf("Wrong parameters number, got %d, want %d.", len(fn.Parameters), len(w.Params))
+		}
+
+		for i, p := range fn.Parameters {
+			if p.Value != w.Params[i] {
+				t.Errorf("Wrong parameter name, parameter index=%d, got %q, want %q.", i, p.Value, w.Params[i])
+			}
+		}
+
+		if fn.Body.String() != w.Body {
+			t.Errorf("Wrong function body, got %q, want %q.", fn.Body.String(), w.Body)
+		}
+	case object.CLASS_OBJ:
+		class, ok := obj.(*object.Class)
+		if !ok {
+			t.Errorf("Object is not an Class, got %T. (%+v)", obj, obj)
+		}
+		w, ok := want.(*ExpectClass)
+		if !ok {
+			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+		}
+
+		if class.Name.Value != w.Name {
+			t.Errorf("Wrong class name, want %q, got %q.", w.Name, class.Name.Value)
+		}
+
+		gotSuper := ""
+		if class.Super != nil {
+			gotSuper = class.Super.Name.Value
+		}
+		if gotSuper != w.Super {
+			t.Errorf("Wrong superclass name, want %q, got %q.", w.Super, gotSuper)
+		}
+
+		if len(class.Methods) != len(w.Props) {
+			t.Errorf("Wrong properties number, got %d, want %d.", len(class.Methods), len(w.Props))
+		}
+
+		methods := []string{}
+		for m := range class.Methods {
+			methods = append(methods, m)
+		}
+		for i, m := range methods {
+			if m != w.Props[i] {
+				t.Errorf("Wrong property name, prop index=%d, got %q, want %q.", i, m, w.Props[i])
+			}
+		}
+
+		// if class.Body.String() != w.Body {
+		// 	t.Errorf("Wrong function body, got %q, want %q.", class.Body.String(), w.Body)
+		// }
+	case object.INSTANCE_OBJ:
+		inst, ok := obj.(*object.Instance)
+		if !ok {
+			t.Errorf("Object is not an Instance, got %T. (%+v)", obj, obj)
+		}
+
+		w, ok := want.(*ExpectInstance)
+		if !ok {
+			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+		}
+
+		if inst.Class.Name.Value != w.Class {
+			t.Errorf("Wrong instance class, want %q, got %q.", w.Class, inst.Class.Name.Value)
+		}
+
+	case object.ERROR_OBJ:
+		t.Errorf("Got unexpected error object: %v.", obj)
+	default:
+		t.Errorf("Unknown object type %q.", obj.Type())
+	}
+}
@@ -0,0 +1,67 @@
+package evaltest
+
+import (
+	"monkey/eval"
+	"time"
+)
+
+// LimitCase is a program failing once it exceeds limits with error of
+// given kind and message.
+type LimitCase struct {
+	Source  string
+	Limits  eval.Limits
+	Kind    string
+	Message string
+}
+
+var LimitCases = []LimitCase{
+	{
+		Source:  "while (true) {}",
+		Limits:  eval.Limits{MaxSteps: 1000},
+		Kind:    eval.STEP_LIMIT_ERROR,
+		Message: "step limit exceeded: 1000 steps",
+	},
+	{
+		Source:  "while (true) {}",
+		Limits:  eval.Limits{Timeout: 10 * time.Millisecond},
+		Kind:    eval.TIMEOUT_ERROR,
+		Message: "evaluation timed out",
+	},
+	{
+		Source:  "let a = []; while (true) { a = push(a, [1]); }",
+		Limits:  eval.Limits{MaxObjects: 100},
+		Kind:    eval.OBJECT_LIMIT_ERROR,
+		Message: "object limit exceeded: 100 objects",
+	},
+	{
+		Source:  "let a = []; while (true) { push(a, 1); }",
+		Limits:  eval.Limits{MaxMemory: 1 << 16},
+		Kind:    eval.MEMORY_ERROR,
+		Message: "memory limit exceeded: 65536 bytes",
+	},
+	{
+		Source:  `let s = "a"; while (true) { s = s + s; }`,
+		Limits:  eval.Limits{MaxMemory: 1 << 20},
+		Kind:    eval.MEMORY_ERROR,
+		Message: "memory limit exceeded: 1048576 bytes",
+	},
+	{
+		Source:  "let h = {||}; let i = 0; while (true) { h[i] = i; i = i + 1; }",
+		Limits:  eval.Limits{MaxMemory: 1 << 16},
+		Kind:    eval.MEMORY_ERROR,
+		Message: "memory limit exceeded: 65536 bytes",
+	},
+	{
+		// exceeded limits fail again in the catch block
+		Source:  "try { while (true) {} } catch (e) { 1; }",
+		Limits:  eval.Limits{MaxSteps: 100},
+		Kind:    eval.STEP_LIMIT_ERROR,
+		Message: "step limit exceeded: 100 steps",
+	},
+	{
+		Source:  "while (true) { let f = fn() { 1 }; }",
+		Limits:  eval.Limits{MaxObjects: 100},
+		Kind:    eval.OBJECT_LIMIT_ERROR,
+		Message: "object limit exceeded: 100 objects",
+	},
+}
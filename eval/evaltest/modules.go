@@ -0,0 +1,78 @@
+package evaltest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Modules are files imported by programs of ImportCases and
+// ImportErrorCases, paths are relative to the importing program.
+var Modules = map[string]string{
+	"lib/math.mk": `
+			let pi = 3;
+			fn area(r) { pi * r * r }
+			class Point { fn init(x) { this.x = x; } }`,
+	"lib/calls.mk":   `let calls = 0; fn call() { calls = calls + 1; }`,
+	"lib/util.mk":    `import "math.mk" as m; let double = fn(x) { m.area(1) * 2 * x };`,
+	"lib/counter.mk": `let count = 0; count = count + 1;`,
+	"broken.mk":      `let x = ;`,
+	"failing.mk":     `let x = 1; x + true;`,
+	"mistyped.mk":    `let x: int = "s";`,
+	"throwing.mk":    "let x = 1;\n-true;",
+	"cycle/a.mk":     `import "b.mk" as b;`,
+	"cycle/b.mk":     `import "a.mk" as a;`,
+	"secret.txt":     "api key 42",
+}
+
+var ImportCases = []Case{
+	{Source: `import "lib/math.mk" as math; math.pi`, Want: int64(3)},
+	{Source: `import "lib/math.mk" as math; math.area(2)`, Want: int64(12)},
+	{Source: `import "lib/math.mk" as math; let pi = 4; math.area(1) + pi`, Want: int64(7)},
+	{Source: `import "lib/math.mk" as math; math.Point(5).x`, Want: int64(5)},
+	{Source: `import "lib/calls.mk" as c; c.call(); c.call(); c.calls`, Want: int64(2)},
+	{Source: `import "lib/util.mk" as util; util.double(2)`, Want: int64(12)},
+	{
+		Source: `import "lib/counter.mk" as a; import "lib/counter.mk" as b; a.count + b.count`,
+		Want:   int64(2),
+	},
+	{Source: `fn f() { import "lib/math.mk" as m; m.pi } f()`, Want: int64(3)},
+	{Source: `try { import "failing.mk" as m; } catch (e) { e.kind }`, Want: "TypeError"},
+}
+
+// ImportErrorCases are programs failing with error which message
+// contains Want.
+var ImportErrorCases = []ErrorCase{
+	{Source: `import "missing.mk" as m;`, Want: `missing.mk": open `},
+	{Source: `import "broken.mk" as m;`, Want: "broken.mk:1:9: No prefix parslet found"},
+	{Source: `import "failing.mk" as m;`, Want: "type mismatch: INTEGER + BOOLEAN"},
+	{Source: `import "mistyped.mk" as m;`, Want: "Can not assign string to 'x' of type int."},
+	{Source: `import "lib/math.mk" as m; m.missing`, Want: "undefined module member: 'missing'"},
+	{Source: `import "lib/math.mk" as m; m.pi = 4;`, Want: "only instances have fields: MODULE.pi"},
+	{Source: `import "cycle/a.mk" as a;`, Want: "import cycle: "},
+}
+
+// DeniedImportCases run without FILESYSTEM capability, their imports must
+// fail before anything is read.
+var DeniedImportCases = []Case{
+	{
+		Source: `try { import "secret.txt" as s; } catch (e) { e.message; }`,
+		Want:   "permission denied: 'import' needs filesystem capability",
+	},
+	{Source: `try { import "lib/math.mk" as m; m.pi; } catch (e) { e.kind; }`, Want: "PermissionError"},
+}
+
+// WriteModules writes Modules to dir.
+func WriteModules(t testing.TB, dir string) {
+	t.Helper()
+
+	for name, content := range Modules {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -1,34 +1,211 @@
 package eval
 
 import (
+	"context"
+	"io"
+	"math"
 	"monkey/ast"
 	"monkey/object"
 	"monkey/token"
+	"os"
+	"strings"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
-var Locals = map[ast.Expression]int{}
+// DEFAULT_MAX_CALL_DEPTH is the limit of nested calls of new evaluators,
+// it is far below the depth which exhausts the Go stack.
+const DEFAULT_MAX_CALL_DEPTH = 10000
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+// Evaluator walks the syntax tree, it keeps state of a single program:
+// resolved variables, active calls and loaded modules.
+type Evaluator struct {
+	// locals are depths of resolved variables of the code being evaluated,
+	// functions carry locals of the program defining them, see SetLocals
+	locals map[ast.Expression]int
+
+	// callStack holds frames of functions being evaluated, innermost last.
+	callStack []object.Frame
+
+	// modules caches evaluated modules by absolute path,
+	// so every file is evaluated once no matter how many times it is imported.
+	modules map[string]*object.Module
+
+	// importing is a chain of modules being evaluated, used to detect cycles.
+	importing []string
+
+	// hook is called before every statement, see SetHook.
+	hook Hook
+
+	// maxCallDepth limits length of callStack, see SetMaxCallDepth.
+	maxCallDepth int
+
+	// tailCalls reports whether return statements being evaluated belong
+	// to a function body, so calls they return may reuse its frame.
+	tailCalls bool
+
+	// limits bound every evaluation, see SetLimits.
+	limits Limits
+	// budget tracks resources used by the running evaluation, usage is
+	// what the last one used, see Usage.
+	budget *budget
+	usage  Usage
+
+	// builtins available to the program and capabilities granting them,
+	// see SetCapabilities.
+	builtins map[string]*object.Builtin
+	granted  []Capability
+
+	// stdout receives output printed by the program, see SetStdout.
+	stdout io.Writer
+}
+
+// Hook is called before evaluator executes a statement other than a block,
+// debuggers use it to pause the program.
+type Hook func(stmt ast.Statement, env *object.Environment)
+
+func New() *Evaluator {
+	return &Evaluator{
+		locals:       map[ast.Expression]int{},
+		modules:      map[string]*object.Module{},
+		maxCallDepth: DEFAULT_MAX_CALL_DEPTH,
+		builtins:     builtins,
+		granted:      DefaultCapabilities,
+		stdout:       os.Stdout,
+	}
+}
+
+// SetLocals makes variables resolved by the resolver in the program evaluated
+// next known to the evaluator. Functions keep locals of the program defining
+// them, so the locals are released together with the program and its functions.
+func (e *Evaluator) SetLocals(locals map[ast.Expression]int) {
+	e.locals = locals
+}
+
+// SetHook installs hook observing executed statements, nil removes it.
+func (e *Evaluator) SetHook(hook Hook) {
+	e.hook = hook
+}
+
+// SetMaxCallDepth limits number of nested calls, deeper calls fail with
+// stack overflow error. Calls returned by functions replace them on the
+// call stack, so they do not count.
+func (e *Evaluator) SetMaxCallDepth(depth int) {
+	e.maxCallDepth = depth
+}
+
+// SetLimits bounds resources used by following evaluations, every call
+// of Eval or EvalContext from outside of evaluation gets a new budget.
+func (e *Evaluator) SetLimits(limits Limits) {
+	e.limits = limits
+}
+
+// Usage returns resources used by the last evaluation, see SetLimits.
+func (e *Evaluator) Usage() Usage {
+	return e.usage
+}
+
+// SetCapabilities grants program builtins of given capabilities only, calls
+// of other builtins fail with PermissionError. New evaluators are granted
+// DefaultCapabilities. Imports read module files, so they need FILESYSTEM.
+func (e *Evaluator) SetCapabilities(granted ...Capability) {
+	e.builtins = newBuiltins(granted)
+	e.granted = granted
+}
+
+// SetStdout redirects output printed by the program, new evaluators
+// print to os.Stdout.
+func (e *Evaluator) SetStdout(out io.Writer) {
+	e.stdout = out
+}
+
+// CallStack returns frames of active calls, innermost last.
+func (e *Evaluator) CallStack() []object.Frame {
+	return append([]object.Frame(nil), e.callStack...)
+}
+
+// CallDepth returns number of active calls.
+func (e *Evaluator) CallDepth() int {
+	return len(e.callStack)
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	if e.budget == nil {
+		return e.EvalContext(context.Background(), node, env)
+	}
+	if err := e.budget.step(); err != nil && node != nil {
+		return e.blame(err, ast.Span(node))
+	}
+
+	if e.hook != nil {
+		if stmt, ok := node.(ast.Statement); ok && !isBlock(stmt) {
+			e.hook(stmt, env)
+		}
+	}
+
+	result := e.eval(node, env)
+	if isError(result) {
+		return e.blame(result, ast.Span(node))
+	}
+	if allocates(node) {
+		if err := e.budget.allocate(result); err != nil {
+			return e.blame(err, ast.Span(node))
+		}
+	}
+	return result
+}
+
+// EvalContext evaluates node like Eval, evaluation fails when ctx is done.
+// Builtins are not interrupted, context is checked between steps.
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	if e.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.limits.Timeout)
+		defer cancel()
+	}
+
+	enclosing := e.budget
+	e.budget = newBudget(ctx, e.limits)
+	defer func() { e.budget, e.usage = enclosing, e.budget.usage() }()
+
+	return e.Eval(node, env)
+}
+
+// blame attributes error produced by evaluation to span, unless the error
+// comes from a node inside of it, which is the better place to blame.
+func (e *Evaluator) blame(result object.Object, span token.Span) object.Object {
+	if err, ok := result.(*object.Error); ok && !err.Span.IsValid() {
+		err.Span = span
+		err.Stack = append([]object.Frame(nil), e.callStack...)
+	}
+	return result
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 	case *ast.BlockStmt:
-		return evalBlockStatement(node.Statements, object.NewEnclosedEnvironment(env))
+		return e.evalBlockStatement(node.Statements, object.NewEnclosedEnvironment(env))
 	case *ast.ExpressionStmt:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 	case *ast.ReturnStmt:
+		if call, ok := node.Value.(*ast.CallExpr); ok && e.tailCalls {
+			return e.evalTailCall(call, env)
+		}
+
 		var val object.Object
 
 		if node.Value == nil {
 			val = NULL
 		} else {
-			val = Eval(node.Value, env)
+			val = e.Eval(node.Value, env)
 		}
 
 		if isError(val) {
@@ -37,28 +214,51 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStmt:
-		val := Eval(node.Value, env)
+		val := e.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, val)
 		return val
 	case *ast.ClassStmt:
-		return evalClassStmt(node, env)
+		return e.evalClassStmt(node, env)
+	case *ast.WhileStmt:
+		return e.evalWhileStmt(node, env)
+	case *ast.ForStmt:
+		return e.evalForStmt(node, env)
+	case *ast.ForInStmt:
+		return e.evalForInStmt(node, env)
+	case *ast.ImportStmt:
+		return e.evalImportStmt(node, env)
+	case *ast.TryStmt:
+		return e.evalTryStmt(node, env)
+	case *ast.ThrowStmt:
+		return e.evalThrowStmt(node, env)
+	case *ast.BreakStmt:
+		return BREAK
+	case *ast.ContinueStmt:
+		return CONTINUE
 	case *ast.ThisExpr:
-		return lookupVariable(token.THIS_KEYWORD, node, env)
+		return e.lookupVariable(token.THIS_KEYWORD, node, env)
 	case *ast.SuperExpr:
-		return evalSuperExpr(node, env)
+		return e.evalSuperExpr(node, env)
 	case *ast.IntLiteralExpr:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteralExpr:
+		return &object.Float{Value: node.Value}
 	case *ast.BoolLiteralExpr:
 		return boolToBooleanObject(node.Value)
 	case *ast.StringLiteralExpr:
 		return &object.String{Value: node.Value}
+	case *ast.TemplateExpr:
+		return e.evalTemplateExpr(node, env)
 	case *ast.ArrayLiteralExpr:
 		arr := &object.Array{}
 		for _, el := range node.Elements {
-			val := Eval(el, env)
+			val := e.Eval(el, env)
 			if isError(val) {
 				return val
 			}
@@ -66,9 +266,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return arr
 	case *ast.HashLiteralExpr:
-		h := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
-		for k, v := range node.Pairs {
-			key := Eval(k, env)
+		h := &object.Hash{}
+		for _, pair := range node.Pairs {
+			key := e.Eval(pair.Key, env)
 			if isError(key) {
 				return key
 			}
@@ -78,84 +278,94 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 				return notHashableKeyError(key.Type())
 			}
 
-			val := Eval(v, env)
+			val := e.Eval(pair.Value, env)
 			if isError(val) {
 				return val
 			}
 
-			hashed := hashKey.HashKey()
-			h.Pairs[hashed] = object.HashPair{Key: key, Value: val}
+			h.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: val})
 		}
 		return h
 	case *ast.NullExpr:
 		return NULL
 	case *ast.PrefixExpr:
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpr(node.Operator, right)
 	case *ast.InfixExpr:
 		if node.Token.Type == token.OR || node.Token.Type == token.AND {
-			return evalLogicalExpr(node.Left, node.Operator, node.Right, env)
+			return e.evalLogicalExpr(node.Left, node.Operator, node.Right, env)
 		}
 
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		// TODO: definitely need a more elegant way to handle errors
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 
 		return evalInfixExpr(left, node.Operator, right)
 	case *ast.IndexExpr:
-		left := Eval(node.Left, env)
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		idx := Eval(node.Index, env)
+		idx := e.Eval(node.Index, env)
 		if isError(idx) {
 			return idx
 		}
 
 		return evalIndexExpression(left, idx)
+	case *ast.SliceExpr:
+		return e.evalSliceExpr(node, env)
 	case *ast.GetExpr:
-		return evalGetExpr(node, env)
+		return e.evalGetExpr(node, env)
 	case *ast.SetExpr:
-		return evalSetExpr(node, env)
+		return e.evalSetExpr(node, env)
+	case *ast.IndexSetExpr:
+		return e.evalIndexSetExpr(node, env)
 	case *ast.AssignExpr:
-		val := Eval(node.Expression, env)
+		val := e.evalAssignedValue(node.Token, node.Expression, env, func() object.Object {
+			return e.evalIdentifier(node.Identifier, env)
+		})
 		if isError(val) {
 			return val
 		}
 
-		if depth, ok := Locals[node.Identifier]; ok {
+		if depth, ok := e.locals[node.Identifier]; ok {
 			env.AssignAt(depth, node.Identifier.Value, val)
-		} else {
+		} else if !env.AssignGlobal(node.Identifier.Value, val) {
 			return identifierNotFoundError(node.Identifier.Value)
 		}
 
 		return val
 	case *ast.IfExpr:
-		return evalIfExpr(node, env)
+		return e.evalIfExpr(node, env)
 	case *ast.IdentifierExpr:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 	case *ast.FunctionExpr:
-		return evalFunctionExpr(node, env, false)
+		return e.evalFunctionExpr(node, env, false)
 	case *ast.CallExpr:
-		return evalCallExpr(node, env)
+		return e.evalCallExpr(node, env)
 	default:
 		return NULL
 	}
 }
 
-func evalProgram(statements []ast.Statement, env *object.Environment) (result object.Object) {
+func (e *Evaluator) evalProgram(statements []ast.Statement, env *object.Environment) (result object.Object) {
+	// returns of programs and modules are not in functions
+	enclosingTailCalls := e.tailCalls
+	e.tailCalls = false
+	defer func() { e.tailCalls = enclosingTailCalls }()
+
 	for _, stmt := range statements {
-		result = Eval(stmt, env)
+		result = e.Eval(stmt, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -172,13 +382,13 @@ func evalProgram(statements []ast.Statement, env *object.Environment) (result ob
 	return
 }
 
-func evalBlockStatement(statements []ast.Statement, env *object.Environment) (result object.Object) {
+func (e *Evaluator) evalBlockStatement(statements []ast.Statement, env *object.Environment) (result object.Object) {
 	for _, stmt := range statements {
-		result = Eval(stmt, env)
+		result = e.Eval(stmt, env)
 
 		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 				return result
 			}
 		}
@@ -191,10 +401,155 @@ func evalBlockStatement(statements []ast.Statement, env *object.Environment) (re
 	return
 }
 
-func evalClassStmt(node *ast.ClassStmt, env *object.Environment) object.Object {
+func (e *Evaluator) evalWhileStmt(node *ast.WhileStmt, env *object.Environment) object.Object {
+	for {
+		condition := e.Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := e.Eval(node.Body, env)
+		if stop, value := loopControl(result); stop {
+			return value
+		}
+	}
+}
+
+func (e *Evaluator) evalForStmt(node *ast.ForStmt, env *object.Environment) object.Object {
+	env = object.NewEnclosedEnvironment(env)
+
+	if node.Init != nil {
+		if init := e.Eval(node.Init, env); isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if node.Condition != nil {
+			condition := e.Eval(node.Condition, env)
+			if isError(condition) {
+				return condition
+			}
+
+			if !isTruthy(condition) {
+				return NULL
+			}
+		}
+
+		result := e.Eval(node.Body, env)
+		if stop, value := loopControl(result); stop {
+			return value
+		}
+
+		if node.Update != nil {
+			if update := e.Eval(node.Update, env); isError(update) {
+				return update
+			}
+		}
+	}
+}
+
+func (e *Evaluator) evalForInStmt(node *ast.ForInStmt, env *object.Environment) object.Object {
+	iterable := e.Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	items, err := iterationItems(iterable)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		// every iteration gets own variable so closures capture current item
+		iterEnv := object.NewEnclosedEnvironment(env)
+		iterEnv.Set(node.Variable.Value, item)
+
+		result := e.Eval(node.Body, iterEnv)
+		if stop, value := loopControl(result); stop {
+			return value
+		}
+	}
+
+	return NULL
+}
+
+func (e *Evaluator) evalTryStmt(node *ast.TryStmt, env *object.Environment) object.Object {
+	// calls returned from try statement must complete before it does,
+	// they may throw errors to catch and finally block runs after them
+	enclosingTailCalls := e.tailCalls
+	e.tailCalls = false
+	defer func() { e.tailCalls = enclosingTailCalls }()
+
+	result := e.Eval(node.Body, env)
+
+	if err, ok := result.(*object.Error); ok && node.Catch != nil {
+		catchEnv := object.NewEnclosedEnvironment(env)
+		catchEnv.Set(node.Param.Value, &object.Exception{Error: err})
+		result = e.Eval(node.Catch, catchEnv)
+	}
+
+	if node.Finally != nil {
+		// finally block completing abruptly replaces the pending outcome
+		switch final := e.Eval(node.Finally, env).(type) {
+		case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+			return final
+		}
+	}
+
+	return result
+}
+
+func (e *Evaluator) evalThrowStmt(node *ast.ThrowStmt, env *object.Environment) object.Object {
+	val := e.Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	return Throw(val)
+}
+
+// iterationItems returns snapshot of values visited by for-in loop.
+func iterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+	var items []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		items = append(items, iterable.Elements...)
+	case *object.String:
+		for _, ch := range iterable.Value {
+			items = append(items, &object.String{Value: string(ch)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Pairs() {
+			items = append(items, pair.Key)
+		}
+	default:
+		return nil, notIterableError(iterable.Type())
+	}
+	return items, nil
+}
+
+// loopControl reports whether loop should stop after body evaluated to result,
+// and which value the loop statement should produce in that case.
+func loopControl(result object.Object) (bool, object.Object) {
+	switch result.(type) {
+	case *object.Break:
+		return true, NULL
+	case *object.ReturnValue, *object.Error:
+		return true, result
+	default:
+		return false, nil
+	}
+}
+
+func (e *Evaluator) evalClassStmt(node *ast.ClassStmt, env *object.Environment) object.Object {
 	var super *object.Class = nil
 	if node.Superclass != nil {
-		sc := Eval(node.Superclass, env)
+		sc := e.Eval(node.Superclass, env)
 
 		if isError(sc) {
 			return sc
@@ -221,7 +576,8 @@ func evalClassStmt(node *ast.ClassStmt, env *object.Environment) object.Object {
 			if field.Name.Value == token.INITIALIZER_KEYWORD {
 				isInit = true
 			}
-			fn := evalFunctionExpr(method, env, isInit)
+			fn := e.evalFunctionExpr(method, env, isInit)
+			fn.Name = field.Name.Value
 			methods[field.Name.Value] = fn
 		}
 	}
@@ -232,6 +588,10 @@ func evalClassStmt(node *ast.ClassStmt, env *object.Environment) object.Object {
 		Methods: methods,
 	}
 
+	for _, method := range methods {
+		method.Class = class
+	}
+
 	if super != nil {
 		env = env.Outer
 	}
@@ -240,58 +600,158 @@ func evalClassStmt(node *ast.ClassStmt, env *object.Environment) object.Object {
 	return class
 }
 
-func evalGetExpr(node *ast.GetExpr, env *object.Environment) object.Object {
-	obj := Eval(node.Expression, env)
+func (e *Evaluator) evalGetExpr(node *ast.GetExpr, env *object.Environment) object.Object {
+	obj := e.Eval(node.Expression, env)
 	if isError(obj) {
 		return obj
 	}
 
+	return getProperty(obj, node.Field.Value)
+}
+
+func getProperty(obj object.Object, name string) object.Object {
+	if module, ok := obj.(*object.Module); ok {
+		if member, ok := module.Get(name); ok {
+			return member
+		}
+		return undefinedMemberError(module.Path, name)
+	}
+
+	if exc, ok := obj.(*object.Exception); ok {
+		return exceptionField(exc, name)
+	}
+
 	if obj.Type() != object.INSTANCE_OBJ {
-		return wrongGetTargetError(obj.Type(), node.Field.Value)
+		return wrongGetTargetError(obj.Type(), name)
 	}
 
 	inst := obj.(*object.Instance)
-	if field, ok := inst.Fields[node.Field.Value]; ok {
+	if field, ok := inst.Fields[name]; ok {
 		return field
-	} else if method := inst.Class.FindMethod(node.Field.Value); method != nil {
+	} else if method := inst.Class.FindMethod(name); method != nil {
 		return method.Bind(inst)
 	} else {
-		return undefinedPropertyError(node.Field.Value)
+		return undefinedPropertyError(name)
+	}
+}
+
+func exceptionField(exc *object.Exception, field string) object.Object {
+	switch field {
+	case "message":
+		return &object.String{Value: exc.Error.Message}
+	case "kind":
+		return &object.String{Value: exc.Error.Kind}
+	case "value":
+		if exc.Error.Value == nil {
+			return NULL
+		}
+		return exc.Error.Value
+	default:
+		return undefinedPropertyError(field)
 	}
 }
 
-func evalSetExpr(node *ast.SetExpr, env *object.Environment) object.Object {
-	obj := Eval(node.Expression, env)
+func (e *Evaluator) evalSetExpr(node *ast.SetExpr, env *object.Environment) object.Object {
+	obj := e.Eval(node.Expression, env)
 	if isError(obj) {
 		return obj
 	}
 
+	val := e.evalAssignedValue(node.Token, node.Value, env, func() object.Object {
+		return getProperty(obj, node.Field.Value)
+	})
+	if isError(val) {
+		return val
+	}
+
 	inst, ok := obj.(*object.Instance)
 	if !ok {
 		return wrongSetTargetError(obj.Type(), node.Field.Value)
 	}
 
-	val := Eval(node.Value, env)
+	size := e.budget.size(inst)
+	inst.Fields[node.Field.Value] = val
+	if err := e.budget.grow(inst, size); err != nil {
+		return err
+	}
+
+	return val
+}
+
+func (e *Evaluator) evalIndexSetExpr(node *ast.IndexSetExpr, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	index := e.Eval(node.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	val := e.evalAssignedValue(node.Token, node.Value, env, func() object.Object {
+		return evalIndexExpression(left, index)
+	})
 	if isError(val) {
 		return val
 	}
 
-	inst.Fields[node.Field.Value] = val
-
+	size := e.budget.size(left)
+	if result := setIndex(left, index, val); isError(result) {
+		return result
+	}
+	if err := e.budget.grow(left, size); err != nil {
+		return err
+	}
 	return val
 }
 
+// evalAssignedValue evaluates value of assignment, compound assignment
+// combines it with the current value of the target read before it.
+func (e *Evaluator) evalAssignedValue(
+	assignment token.Token,
+	value ast.Expression,
+	env *object.Environment,
+	current func() object.Object,
+) object.Object {
+	operator, ok := token.CompoundOperator(assignment.Type)
+	if !ok {
+		return e.Eval(value, env)
+	}
+
+	left := current()
+	if isError(left) {
+		return left
+	}
+
+	right := e.Eval(value, env)
+	if isError(right) {
+		return right
+	}
+
+	return evalInfixExpr(left, operator, right)
+}
+
 func evalPrefixExpr(operator string, right object.Object) object.Object {
 	switch operator {
 	case token.BANG:
 		return evalBangOperatorExpr(right)
 	case token.MINUS:
 		return evalMinusOperatorExpr(right)
+	case token.BIT_NOT:
+		return evalBitNotOperatorExpr(right)
 	default:
 		return unknownPrefixOperatorError(operator, "")
 	}
 }
 
+func evalBitNotOperatorExpr(right object.Object) object.Object {
+	if right, ok := right.(*object.Integer); ok {
+		return &object.Integer{Value: ^right.Value}
+	}
+	return unknownPrefixOperatorError(token.BIT_NOT, right.Type())
+}
+
 func evalBangOperatorExpr(right object.Object) object.Object {
 	switch right {
 	case NULL:
@@ -306,21 +766,23 @@ func evalBangOperatorExpr(right object.Object) object.Object {
 }
 
 func evalMinusOperatorExpr(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
 		return unknownPrefixOperatorError("-", right.Type())
 	}
-
-	val := right.(*object.Integer).Value
-	return &object.Integer{Value: -val}
 }
 
-func evalLogicalExpr(
+func (e *Evaluator) evalLogicalExpr(
 	leftExpr ast.Expression,
 	operator string,
 	rightExpr ast.Expression,
 	env *object.Environment,
 ) object.Object {
-	left := Eval(leftExpr, env)
+	left := e.Eval(leftExpr, env)
 
 	if isError(left) {
 		return left
@@ -336,7 +798,7 @@ func evalLogicalExpr(
 		}
 	}
 
-	right := Eval(rightExpr, env)
+	right := e.Eval(rightExpr, env)
 	return right
 }
 
@@ -344,6 +806,9 @@ func evalInfixExpr(left object.Object, operator string, right object.Object) obj
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpr(left, operator, right)
+	case isNumber(left) && isNumber(right):
+		// mixed arithmetic promotes integers to floats
+		return evalFloatInfixExpr(left, operator, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpr(left, operator, right)
 	case operator == token.EQUAL_EQUAL:
@@ -368,7 +833,32 @@ func evalIntegerInfixExpr(left object.Object, operator string, right object.Obje
 	case token.STAR:
 		return &object.Integer{Value: leftValue * rightValue}
 	case token.SLASH:
+		if rightValue == 0 {
+			return divisionByZeroError()
+		}
 		return &object.Integer{Value: leftValue / rightValue}
+	case token.PERCENT:
+		// result has the sign of the dividend, -7 % 3 == -1
+		if rightValue == 0 {
+			return divisionByZeroError()
+		}
+		return &object.Integer{Value: leftValue % rightValue}
+	case token.POWER:
+		return integerPower(leftValue, rightValue)
+	case token.BIT_AND:
+		return &object.Integer{Value: leftValue & rightValue}
+	case token.BIT_OR:
+		return &object.Integer{Value: leftValue | rightValue}
+	case token.BIT_XOR:
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case token.SHIFT_LEFT, token.SHIFT_RIGHT:
+		if rightValue < 0 {
+			return negativeShiftError(rightValue)
+		}
+		if operator == token.SHIFT_LEFT {
+			return &object.Integer{Value: leftValue << uint64(rightValue)}
+		}
+		return &object.Integer{Value: leftValue >> uint64(rightValue)}
 	case token.GREATER:
 		return boolToBooleanObject(leftValue > rightValue)
 	case token.GREATER_EQUAL:
@@ -386,6 +876,79 @@ func evalIntegerInfixExpr(left object.Object, operator string, right object.Obje
 	}
 }
 
+// integerPower raises base to exp, negative exponents give float result.
+// Like other integer operations it wraps around on overflow.
+func integerPower(base, exp int64) object.Object {
+	if exp < 0 {
+		return &object.Float{Value: math.Pow(float64(base), float64(exp))}
+	}
+
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+
+	return &object.Integer{Value: result}
+}
+
+func evalFloatInfixExpr(left object.Object, operator string, right object.Object) object.Object {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+	switch operator {
+	case token.PLUS:
+		return &object.Float{Value: leftValue + rightValue}
+	case token.MINUS:
+		return &object.Float{Value: leftValue - rightValue}
+	case token.STAR:
+		return &object.Float{Value: leftValue * rightValue}
+	case token.SLASH:
+		if rightValue == 0 {
+			return divisionByZeroError()
+		}
+		return &object.Float{Value: leftValue / rightValue}
+	case token.PERCENT:
+		if rightValue == 0 {
+			return divisionByZeroError()
+		}
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case token.POWER:
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
+	case token.GREATER:
+		return boolToBooleanObject(leftValue > rightValue)
+	case token.GREATER_EQUAL:
+		return boolToBooleanObject(leftValue >= rightValue)
+	case token.LESS:
+		return boolToBooleanObject(leftValue < rightValue)
+	case token.LESS_EQUAL:
+		return boolToBooleanObject(leftValue <= rightValue)
+	case token.EQUAL_EQUAL:
+		return boolToBooleanObject(leftValue == rightValue)
+	case token.NOT_EQUAL:
+		return boolToBooleanObject(leftValue != rightValue)
+	default:
+		return unknownInfixOperatorError(left.Type(), operator, right.Type())
+	}
+}
+
+func (e *Evaluator) evalTemplateExpr(node *ast.TemplateExpr, env *object.Environment) object.Object {
+	var out strings.Builder
+	for i, text := range node.Texts {
+		out.WriteString(text.Value)
+		if i < len(node.Exprs) {
+			val := e.Eval(node.Exprs[i], env)
+			if isError(val) {
+				return val
+			}
+			out.WriteString(val.Inspect())
+		}
+	}
+	return &object.String{Value: out.String()}
+}
+
 func evalStringInfixExpr(left object.Object, operator string, right object.Object) object.Object {
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
@@ -405,6 +968,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -425,6 +990,123 @@ func evalArrayIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
+// setIndex stores val as element of array or value of hash, strings
+// are immutable.
+func setIndex(left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return indexAssignmentError(left.Type(), index.Type())
+		}
+		l := int64(len(left.Elements))
+		i := idx.Value
+		if i < 0 {
+			i += l
+		}
+		if i < 0 || i >= l {
+			return outOfBoundsError(left.Type(), idx.Value)
+		}
+		left.Elements[i] = val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return notHashableKeyError(index.Type())
+		}
+		left.Set(key.HashKey(), object.HashPair{Key: index, Value: val})
+	default:
+		return indexAssignmentError(left.Type(), index.Type())
+	}
+
+	return val
+}
+
+// evalStringIndexExpression indexes characters, not bytes of the string.
+func evalStringIndexExpression(left, index object.Object) object.Object {
+	runes := []rune(left.(*object.String).Value)
+	l := int64(len(runes))
+	i := index.(*object.Integer).Value
+	if i >= 0 && i < l {
+		return &object.String{Value: string(runes[i])}
+	} else if i < 0 && l+i >= 0 {
+		return &object.String{Value: string(runes[l+i])}
+	} else {
+		return outOfBoundsError(left.Type(), i)
+	}
+}
+
+func (e *Evaluator) evalSliceExpr(node *ast.SliceExpr, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := []object.Object{NULL, NULL}
+	for i, bound := range []ast.Expression{node.Low, node.High} {
+		if bound != nil {
+			bounds[i] = e.Eval(bound, env)
+			if isError(bounds[i]) {
+				return bounds[i]
+			}
+		}
+	}
+
+	return evalSliceExpression(left, bounds[0], bounds[1])
+}
+
+// evalSliceExpression cuts characters of string or elements of array
+// between bounds, null bounds mean start and end of the sequence.
+func evalSliceExpression(left, low, high object.Object) object.Object {
+	isBound := func(obj object.Object) bool {
+		return obj.Type() == object.INTEGER_OBJ || obj == NULL
+	}
+	if !isBound(low) || !isBound(high) {
+		return sliceOperatorError(left.Type(), low.Type(), high.Type())
+	}
+
+	switch left := left.(type) {
+	case *object.String:
+		runes := []rune(left.Value)
+		from, to := sliceBounds(len(runes), low, high)
+		return &object.String{Value: string(runes[from:to])}
+	case *object.Array:
+		from, to := sliceBounds(len(left.Elements), low, high)
+		elements := make([]object.Object, to-from)
+		copy(elements, left.Elements[from:to])
+		return &object.Array{Elements: elements}
+	default:
+		return sliceOperatorError(left.Type(), low.Type(), high.Type())
+	}
+}
+
+// sliceBounds converts bounds to valid range of sequence of given length,
+// negative bounds count from the end and out of range ones are clamped.
+func sliceBounds(length int, low, high object.Object) (int, int) {
+	bound := func(obj object.Object, missing int) int {
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return missing
+		}
+		n := i.Value
+		if n < 0 {
+			n += int64(length)
+		}
+		if n < 0 {
+			return 0
+		}
+		if n > int64(length) {
+			return length
+		}
+		return int(n)
+	}
+
+	from, to := bound(low, 0), bound(high, length)
+	if from > to {
+		from = to
+	}
+	return from, to
+}
+
 func evalHashIndexExpression(left, index object.Object) object.Object {
 	hash := left.(*object.Hash)
 
@@ -433,7 +1115,7 @@ func evalHashIndexExpression(left, index object.Object) object.Object {
 		return notHashableKeyError(index.Type())
 	}
 
-	pair, ok := hash.Pairs[key.HashKey()]
+	pair, ok := hash.Get(key.HashKey())
 	if !ok {
 		return NULL
 	}
@@ -441,20 +1123,23 @@ func evalHashIndexExpression(left, index object.Object) object.Object {
 	return pair.Value
 }
 
-func evalIfExpr(expr *ast.IfExpr, env *object.Environment) object.Object {
-	condition := Eval(expr.Condition, env)
+func (e *Evaluator) evalIfExpr(expr *ast.IfExpr, env *object.Environment) object.Object {
+	condition := e.Eval(expr.Condition, env)
+	if isError(condition) {
+		return condition
+	}
 
 	if isTruthy(condition) {
-		return Eval(expr.Then, env)
+		return e.Eval(expr.Then, env)
 	} else if expr.Else != nil {
-		return Eval(expr.Else, env)
+		return e.Eval(expr.Else, env)
 	} else {
 		return NULL
 	}
 }
 
-func evalSuperExpr(node *ast.SuperExpr, env *object.Environment) object.Object {
-	depth, ok := Locals[node]
+func (e *Evaluator) evalSuperExpr(node *ast.SuperExpr, env *object.Environment) object.Object {
+	depth, ok := e.locals[node]
 	if !ok {
 		return internalResolveError(node.String())
 	}
@@ -487,12 +1172,14 @@ func evalSuperExpr(node *ast.SuperExpr, env *object.Environment) object.Object {
 	return fn.Bind(instObj)
 }
 
-func evalIdentifier(node *ast.IdentifierExpr, env *object.Environment) object.Object {
-	return lookupVariable(node.Value, node, env)
+// localDepth returns how many environments up from the current one
+// the variable referred by expr lives, as computed by resolver.
+func (e *Evaluator) evalIdentifier(node *ast.IdentifierExpr, env *object.Environment) object.Object {
+	return e.lookupVariable(node.Value, node, env)
 }
 
-func lookupVariable(name string, node ast.Expression, env *object.Environment) object.Object {
-	if depth, ok := Locals[node]; ok {
+func (e *Evaluator) lookupVariable(name string, node ast.Expression, env *object.Environment) object.Object {
+	if depth, ok := e.locals[node]; ok {
 		if val, ok := env.GetAt(depth, name); ok {
 			return val
 		}
@@ -500,38 +1187,114 @@ func lookupVariable(name string, node ast.Expression, env *object.Environment) o
 		return val
 	}
 
-	if builtin, ok := builtins[name]; ok {
+	if builtin, ok := e.builtins[name]; ok {
 		return builtin
 	}
 
 	return identifierNotFoundError(name)
 }
 
-func evalFunctionExpr(node *ast.FunctionExpr, env *object.Environment, isInit bool) *object.Function {
+func (e *Evaluator) evalFunctionExpr(node *ast.FunctionExpr, env *object.Environment, isInit bool) *object.Function {
 	return &object.Function{
 		Parameters: node.Parameters,
 		Body:       node.Body,
 		Env:        env,
 		IsInit:     isInit,
+		Locals:     e.locals,
 	}
 }
 
-func evalCallExpr(node *ast.CallExpr, env *object.Environment) object.Object {
-	fn := Eval(node.Function, env)
+func (e *Evaluator) evalCallExpr(node *ast.CallExpr, env *object.Environment) object.Object {
+	fn := e.Eval(node.Function, env)
 	if isError(fn) {
 		return fn
 	}
-	args := evalExpressions(node.Arguments, env)
+	args := e.evalExpressions(node.Arguments, env)
 	if len(args) > 0 && isError(args[0]) {
 		return args[0]
 	}
 
-	return applyFunction(fn, args)
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return e.applyBuiltin(builtin, args, ast.Span(node))
+	}
+
+	return e.call(fn, args, ast.Span(node))
+}
+
+// call applies function in a new frame of the call stack.
+func (e *Evaluator) call(fn object.Object, args []object.Object, callSite token.Span) object.Object {
+	if len(e.callStack) >= e.maxCallDepth {
+		return stackOverflowError()
+	}
+
+	e.callStack = append(e.callStack, newFrame(fn, callSite))
+	result := e.applyFunction(fn, args)
+	e.callStack = e.callStack[:len(e.callStack)-1]
+
+	return result
+}
+
+// tailCall is a call returned by function, applyFunction makes it in place
+// of the function, so tail recursion does not grow the call stack.
+type tailCall struct {
+	fn       object.Object
+	args     []object.Object
+	callSite token.Span
+}
+
+func (t *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (t *tailCall) Inspect() string         { return "tail call" }
+
+// evalTailCall evaluates callee and arguments of call returned by function
+// and returns the call to be made after the function returns.
+func (e *Evaluator) evalTailCall(node *ast.CallExpr, env *object.Environment) object.Object {
+	fn := e.Eval(node.Function, env)
+	if isError(fn) {
+		return fn
+	}
+	args := e.evalExpressions(node.Arguments, env)
+	if len(args) > 0 && isError(args[0]) {
+		return args[0]
+	}
+
+	if builtin, ok := fn.(*object.Builtin); ok {
+		val := e.blame(e.applyBuiltin(builtin, args, ast.Span(node)), ast.Span(node))
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	}
+
+	return &object.ReturnValue{Value: &tailCall{fn: fn, args: args, callSite: ast.Span(node)}}
+}
+
+func isBlock(stmt ast.Statement) bool {
+	_, ok := stmt.(*ast.BlockStmt)
+	return ok
+}
+
+func newFrame(fn object.Object, callSite token.Span) object.Frame {
+	frame := object.Frame{Function: "<anonymous>", CallSite: callSite}
+
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Name != "" {
+			frame.Function = fn.Name
+		}
+		if fn.Class != nil {
+			frame.Class = fn.Class.Name.Value
+		}
+	case *object.Class:
+		frame.Class = fn.Name.Value
+		frame.Function = token.INITIALIZER_KEYWORD
+	}
+
+	return frame
 }
 
-func evalExpressions(expressions []ast.Expression, env *object.Environment) (result []object.Object) {
+func (e *Evaluator) evalExpressions(expressions []ast.Expression, env *object.Environment) (result []object.Object) {
 	for _, expr := range expressions {
-		arg := Eval(expr, env)
+		arg := e.Eval(expr, env)
 		if isError(arg) {
 			return []object.Object{arg}
 		}
@@ -540,17 +1303,46 @@ func evalExpressions(expressions []ast.Expression, env *object.Environment) (res
 	return
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction calls fn in the innermost frame of the call stack, calls
+// returned by the function replace it in the frame.
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
+	result := e.applyOnce(fn, args)
+
+	for {
+		call, ok := result.(*tailCall)
+		if !ok {
+			return result
+		}
+
+		if len(e.callStack) > 0 {
+			e.callStack[len(e.callStack)-1] = newFrame(call.fn, call.callSite)
+		}
+		result = e.blame(e.applyOnce(call.fn, call.args), call.callSite)
+	}
+}
+
+func (e *Evaluator) applyOnce(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
 		if len(fn.Parameters) != len(args) {
 			return wrongArgumentsCountError(len(fn.Parameters), len(args))
 		}
 
+		enclosingTailCalls, enclosingLocals := e.tailCalls, e.locals
+		e.tailCalls = !fn.IsInit
+		e.locals = fn.Locals
+
 		extendedEnv := extendFunctionEnv(fn, args)
-		result := evalBlockStatement(fn.Body.Statements, extendedEnv)
+		result := e.evalBlockStatement(fn.Body.Statements, extendedEnv)
+		e.tailCalls, e.locals = enclosingTailCalls, enclosingLocals
+		if isError(result) {
+			return result
+		}
 
 		if returnValue, ok := result.(*object.ReturnValue); ok {
+			if call, ok := returnValue.Value.(*tailCall); ok {
+				return call
+			}
 			if fn.IsInit {
 				this, ok := fn.Env.GetAt(0, token.THIS_KEYWORD)
 				if ok {
@@ -572,7 +1364,7 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 		return result
 
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return e.applyBuiltin(fn, args, token.Span{})
 
 	case *object.Class:
 		init := fn.FindMethod(token.INITIALIZER_KEYWORD)
@@ -590,9 +1382,14 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 			Class:  fn,
 			Fields: make(map[string]object.Object),
 		}
+		if err := e.budget.allocate(inst); err != nil {
+			return err
+		}
 
 		if init != nil {
-			applyFunction(init.Bind(inst), args)
+			if result := e.applyFunction(init.Bind(inst), args); isError(result) {
+				return result
+			}
 		}
 
 		return inst
@@ -601,6 +1398,41 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	return notAFunctionError(string(fn.Type()), fn.Inspect())
 }
 
+// applyBuiltin calls builtin, functions called back by the builtin
+// appear on the call stack as called from callSite.
+func (e *Evaluator) applyBuiltin(fn *object.Builtin, args []object.Object, callSite token.Span) object.Object {
+	return e.budget.callBuiltin(fn, args, func() object.Object {
+		return e.invokeBuiltin(fn, args, callSite)
+	})
+}
+
+func (e *Evaluator) invokeBuiltin(fn *object.Builtin, args []object.Object, callSite token.Span) object.Object {
+	if fn.Cancellable != nil {
+		result := fn.Cancellable(e.budget.builtinCtx, args...)
+		// interrupted builtin fails the evaluation, not only the call
+		if err := e.budget.interrupted(); err != nil {
+			return err
+		}
+		return result
+	}
+	if fn.Output != nil {
+		return fn.Output(e.stdout, args...)
+	}
+	if fn.HigherOrder == nil {
+		return fn.Fn(args...)
+	}
+
+	call := func(callee object.Object, args ...object.Object) object.Object {
+		if builtin, ok := callee.(*object.Builtin); ok {
+			return e.applyBuiltin(builtin, args, callSite)
+		}
+
+		return e.call(callee, args, callSite)
+	}
+
+	return fn.HigherOrder(call, args...)
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for i, arg := range args {
@@ -629,6 +1461,18 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat converts number object to float64, obj must be a number.
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func isError(obj object.Object) bool {
 	return obj != nil && obj.Type() == object.ERROR_OBJ
 }
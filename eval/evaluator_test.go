@@ -1,594 +1,336 @@
 package eval_test
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/ast"
 	"monkey/eval"
+	"monkey/eval/evaltest"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/resolver"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
-type expectFn struct {
-	params []string
-	body   string
-}
+func TestEval(t *testing.T) {
+	for _, tc := range evaltest.Cases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := evalSource(t, tc.Source)
 
-type expectClass struct {
-	name  string
-	super string
-	props []string
+			if got == nil {
+				t.Errorf("Error while evaluating %q, got nil, want '%v'.", tc.Source, tc.Want)
+			}
+
+			evaltest.CheckObject(t, got, tc.Want)
+		})
+	}
 }
 
-type expectInstance struct {
-	class string
+func TestRuntimeErrorHandling(t *testing.T) {
+	for _, tc := range evaltest.ErrorCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := evalSource(t, tc.Source)
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+			if err.Message != tc.Want {
+				t.Errorf("Wrong error message, got %q, want %q.", err.Message, tc.Want)
+			}
+		})
+	}
 }
 
-func TestEval(t *testing.T) {
-	tt := []struct {
-		source string
-		want   interface{}
-	}{
-		{source: "5;", want: int64(5)},
-		{source: "228322;", want: int64(228322)},
-		{source: "true;", want: true},
-		{source: "false;", want: false},
-		{source: `"string literal";`, want: "string literal"},
-		{source: "null;", want: nil},
-		{source: "!null;", want: true},
-		{source: "!!null;", want: false},
-		{source: "!true;", want: false},
-		{source: "!false;", want: true},
-		{source: "!5;", want: false},
-		{source: "!!true;", want: true},
-		{source: "!!false;", want: false},
-		{source: "!!5;", want: true},
-		{source: "-5;", want: int64(-5)},
-		{source: "--5;", want: int64(5)},
-		{source: "--5;", want: int64(5)},
-		{source: "5 + 5;", want: int64(10)},
-		{source: "5 - 5;", want: int64(0)},
-		{source: "5 * 5;", want: int64(25)},
-		{source: "5 / 5;", want: int64(1)},
-		{source: "5 > 5;", want: false},
-		{source: "5 < 5;", want: false},
-		// {source: "5 >= 5;", want: true},
-		// {source: "5 <= 5;", want: true},
-		{source: "null == null;", want: true},
-		{source: "null != null;", want: false},
-		{source: "null == 5;", want: false},
-		{source: "null == false;", want: false},
-		{source: "null == true;", want: false},
-		{source: "null == true;", want: false},
-		{source: "false || true;", want: true},
-		{source: "true && false;", want: false},
-		{source: "10 || 20;", want: int64(10)},
-		{source: "null || 10", want: int64(10)},
-		{source: "10 && 20;", want: int64(20)},
-		{source: "let x; x = x || 20", want: int64(20)},
-		{source: "let x; false || (x = 20); x", want: int64(20)},
-		{source: "null && 10", want: nil},
-		{source: "5 == 5;", want: true},
-		{source: "5 != 5;", want: false},
-		{source: `"string" + " " + "concatenation"`, want: "string concatenation"},
-		{source: `"str" == "str";`, want: true},
-		{source: `"str1" == "str2";`, want: false},
-		{source: "(2 + 2) * 2 == 8;", want: true},
-		{source: "2 + 2 * 2 == 6;", want: true},
-		{source: "(2 + 2) * 2 > 2 + 2 * 2;", want: true},
-		{source: "(5 < 5) == false;", want: true},
-		{source: "2 > 3 != 3 > 4;", want: false},
-		{source: "if (true) 10;", want: int64(10)},
-		{source: "if (1) 10;", want: int64(10)},
-		{source: "if (true) { 10; } else { 20; };", want: int64(10)},
-		{source: "if (false) { 10; } else { 20; };", want: int64(20)},
-		{source: "if (null) 10 else 20", want: int64(20)},
-		{source: "if (null) 10", want: nil},
-		{source: "if (1 > 2) 10;", want: nil},
-		{source: "if (2 > 1) 10;", want: int64(10)},
-		{source: "return 10;", want: int64(10)},
-		{source: "10; return;", want: nil},
-		{source: "return true; 10;", want: true},
-		{source: "if (1 > 2) 10;", want: nil},
-		{source: "10; return 2 == 3; 20;", want: false},
-		{source: "if (2 > 1) { if (3 > 2) { return 10; }; return 1;};", want: int64(10)},
-		{source: "let a = 10;", want: int64(10)},
-		{source: "let a;", want: nil},
-		{source: "let a; a;", want: nil},
-		{source: "let a; a = 10;", want: int64(10)},
-		{source: "let a = 10; let b = a = 20;", want: int64(20)},
-		{source: "{ 20; let a = 10; }", want: int64(10)},
-		{source: "let a = 10; a;", want: int64(10)},
-		{source: "let a = 10 * 5; a;", want: int64(50)},
-		{source: "let a = 10; let b = a; b;", want: int64(10)},
-		{source: "let a = 10; let b = a; let c = a + b + 20; c;", want: int64(40)},
-		{source: "let a = 10; a = 20;", want: int64(20)},
-		{source: "let a = 10; let b = 20; a = b = 30;", want: int64(30)},
-		{source: "let a = 1; let b = 2; let c = 3; a = b = c;", want: int64(3)},
-		{source: "fn(a, b) { a + b }", want: &expectFn{[]string{"a", "b"}, "{ (a + b); }"}},
-		{source: "let x = fn(a, b) { a + b }; x;", want: &expectFn{[]string{"a", "b"}, "{ (a + b); }"}},
-		{source: "let i = fn(x) { x }; i(10);", want: int64(10)},
-		{source: "let i = fn(x) { return x; }; i(10);", want: int64(10)},
-		{source: "fn f(x) { x }; f(10);", want: int64(10)},
-		{source: "fn f(x) { return x } f(10);", want: int64(10)},
-		{source: `len("Hello, World!")`, want: int64(13)},
-		{source: `len("")`, want: int64(0)},
-		{source: `len("Hello, World!"); { let len = 10; len; }`, want: int64(10)},
-		{source: `len("Hello, World!"); { let len = 10; len; } len("Hello, World!")`, want: int64(13)},
-		{source: "let x = 10; let y = 10; { let x = x; x = 20; y = x; } x;", want: int64(10)},
-		{source: "let x = 10; let y = 10; { let x = x; x = 20; y = x; } y;", want: int64(20)},
-		{source: "let x = 10; let f = fn() { x }; { f() }", want: int64(10)},
-		{source: "let x = 10; { let f = fn() { x }; let x = 20; f(); }", want: int64(10)},
-		{source: "let x = 10; { let f = fn() { x }; let x = 20; f(); } x;", want: int64(10)},
-		{source: "let x = 10; { fn f() { x }; let x = 20; f(); } x;", want: int64(10)},
-		// classes
-		{source: "class A {}", want: &expectClass{name: "A", super: "", props: []string{}}},
-		{source: "class B {} class A < B {}", want: &expectClass{name: "A", super: "B", props: []string{}}},
-		{source: "class A {} A();", want: &expectInstance{class: "A"}},
-		{source: `class A {} A().field = 10;`, want: int64(10)},
-		{source: "class A {} let obj = A(); obj.field = 10; obj.field;", want: int64(10)},
-		{source: "class A { let method = fn() { 10; }; } let obj = A(); obj.method()", want: int64(10)},
-		{source: "class A { fn method() { 10; }} let obj = A(); obj.method()", want: int64(10)},
-		{source: "class A { fn method() { this.x; }} let obj = A(); obj.x = 1; obj.method()", want: int64(1)},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x; }
-					}
-					let obj = A(); obj.method()`,
-			want: int64(20),
-		},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x; }
-					}
-					let obj = A(); obj.x = 50; obj.init()`,
-			want: &expectInstance{class: "A"},
-		},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x; }
-					}
-					let obj = A(); obj.x = 50; obj.init(); obj.x`,
-			want: int64(20),
-		},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x; }
-					}
-					class B < A{}
-					let obj = B();`,
-			want: &expectInstance{class: "B"},
-		},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x; }
-					}
-					class B < A{}
-					let obj = B(); obj.x;`,
-			want: int64(20),
-		},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x; }
-					}
-					class B < A {
-						fn init() { super.init() }
-					}
-					let obj = B(); obj.x;`,
-			want: int64(20),
-		},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x * 2; }
-					}
-					class B < A {
-						fn init() { super.init() }
-						fn method() { this.X }
-						fn doubleX() { super.method() }
-					}
-					class C < B {}
-					let obj = C(); obj.doubleX();`,
-			want: int64(40),
-		},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x * 2; }
-					}
-					class B < A {
-						fn init() { super.init() }
-						fn method() { this.X }
-						fn doubleX() { super.method }
-					}
-					class C < B {}
-					let obj = C(); let d = obj.doubleX(); obj.x = 10; d()`,
-			want: int64(20),
-		},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x; }
-					}
-					class B < A {}
-					let obj = B(); obj.x = 50; obj.init(); obj.method()`,
-			want: int64(20),
-		},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x; }
-					}
-					class B {
-						fn init() {this.x = 10; }
-					}
-					let a = A(); let b = B(); b.method = a.method; b.method();`,
-			want: int64(20),
-		},
-		{
-			source: `class A {
-						fn init(n) { this.x = n; }
-						fn method() { this.x; }
-					}
-					class B < A{}
-					let obj = B(10); obj.x`,
-			want: int64(10),
-		},
-		{
-			source: `class A {
-						fn init(n) { this.x = n; }
-						fn method() {
-							class B < A {}
-							B(20);
-						}
-					}
-					let obj = A(10).method(); obj.x`,
-			want: int64(20),
-		},
-		{
-			source: `class A {
-						fn init(n) { this.x = n; }
-						fn new(x) {
-							A(x);
-						}
-					}
-					let obj = A(10).new(20); obj.x`,
-			want: int64(20),
-		},
-		{
-			source: "let x = 1; class B {} { class A < B { fn f() { x = 20; } } A().f() } x",
-			want:   int64(20),
-		},
-		// arrays
-		{source: "[1, 2, 3];", want: []interface{}{int64(1), int64(2), int64(3)}},
-		{source: "[1, 2, 3][1];", want: int64(2)},
-		{source: "[1, 2, 3][2];", want: int64(3)},
-		{source: "[1, 2, 3][-1];", want: int64(3)},
-		{source: "[1, 2, 3][-2];", want: int64(2)},
-		{source: "[1, 2, 3][-3];", want: int64(1)},
-		{source: "let i = 0; [1, 2, 3][i];", want: int64(1)},
-		{source: "[1, 2, 3][1 + 1];", want: int64(3)},
-		{source: "let arr = [1, 2, 3]; arr[1];", want: int64(2)},
-		{source: "let arr = [1, 2, 3]; arr[0] + arr[1] + arr[2];", want: int64(6)},
-		{source: "let arr = [1, 2, 3]; let i = arr[0]; arr[i]", want: int64(2)},
-		{source: "let arr = [1, 2, 3]; let i = arr[0]; arr[-i]", want: int64(3)},
-		{source: "len([1, 2, 3]);", want: int64(3)},
-		{
-			source: `{| 1: true, 2: "hello", "world": 3 |};`,
-			want:   map[interface{}]interface{}{int64(1): true, int64(2): "hello", "world": int64(3)},
-		},
-		{source: `{| 1: true, 2: "hello", "world": 3 |}[2];`, want: "hello"},
-		{source: `{| 1: true, 2: "hello" + " world", "world": 3 |}[2];`, want: "hello world"},
-		{source: `{| 1: true, 2: "hello" + " world", "world": 3 |}[true];`, want: nil},
-		{source: `{| 1: true, "fn": fn(x){ x + x }, "world": 3 |}["fn"]("wow");`, want: "wowwow"},
-		{source: `let x = 2; {| x: true, "fn": fn(x){ x + x }, "world": 3 |}[2];`, want: true},
-		{
-			source: `class A {} {| 1: true, "fn": fn(x){ x + x }, "world": A |}["world"]();`,
-			want:   &expectInstance{class: "A"},
-		},
-		{
-			source: `let x= {| 1: "world", "fn": fn(x){ x + x }, "world": 3 |}; x["fn"](x[x[1]])`,
-			want:   int64(6),
-		},
+func TestRuntimeErrorPosition(t *testing.T) {
+	for _, tc := range evaltest.PositionCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := evalSource(t, tc.Source)
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+			if err.Span.String() != tc.Want {
+				t.Errorf("Wrong error position, got %s, want %s.", err.Span, tc.Want)
+			}
+		})
 	}
+}
 
-	for _, tc := range tt {
-		t.Run(tc.source, func(t *testing.T) {
-			got := evalSource(t, tc.source)
+func TestRuntimeErrorStack(t *testing.T) {
+	for _, tc := range evaltest.StackCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := evalSource(t, tc.Source)
 
-			if got == nil {
-				t.Errorf("Error while evaluating %q, got nil, want '%v'.", tc.source, tc.want)
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+
+			names := []string{}
+			for _, frame := range err.Stack {
+				names = append(names, frame.Name())
 			}
 
-			testObject(t, got, tc.want)
+			if strings.Join(names, " ") != strings.Join(tc.Want, " ") {
+				t.Errorf("Wrong error stack, got %v, want %v.", names, tc.Want)
+			}
 		})
 	}
 }
 
-func TestRuntimeErrorHandling(t *testing.T) {
-	tt := []struct {
-		source string
-		want   string
-	}{
-		{source: "-true;", want: "unknown operator: -BOOLEAN"},
-		{source: "-null;", want: "unknown operator: -NULL"},
-		{source: "true - 5;", want: "type mismatch: BOOLEAN - INTEGER"},
-		{source: "5 > true;", want: "type mismatch: INTEGER > BOOLEAN"},
-		{source: "null > true;", want: "type mismatch: NULL > BOOLEAN"},
-		{source: "null > null;", want: "unknown operator: NULL > NULL"},
-		{source: "5 + true; 5;", want: "type mismatch: INTEGER + BOOLEAN"},
-		{source: "true + false;", want: "unknown operator: BOOLEAN + BOOLEAN"},
-		{source: "null + null;", want: "unknown operator: NULL + NULL"},
-		{source: "null + 10;", want: "type mismatch: NULL + INTEGER"},
-		{source: "null * 2;", want: "type mismatch: NULL * INTEGER"},
-		{source: "null + false;", want: "type mismatch: NULL + BOOLEAN"},
-		{source: "true + false;", want: "unknown operator: BOOLEAN + BOOLEAN"},
-		{source: `"str1" < "str2";`, want: "unknown operator: STRING < STRING"},
-		{source: `"str1" - "str2";`, want: "unknown operator: STRING - STRING"},
-		{source: "5; true - 1; 5;", want: "type mismatch: BOOLEAN - INTEGER"},
-		{source: "let x = fn() { true }; x(1)", want: "wrong arguments count: expect 0, got 1"},
-		{source: "len(10)", want: "type mismatch: len(INTEGER)"},
-		{source: "len(null)", want: "type mismatch: len(NULL)"},
-		{source: `len("one", "two")`, want: "wrong arguments count: expect 1, got 2"},
-		{source: "null()", want: "not a function: NULL 'null'"},
-		{source: "if (10 > 0) { return true + false; }; 6;", want: "unknown operator: BOOLEAN + BOOLEAN"},
-		{source: "(true - false) * 1", want: "unknown operator: BOOLEAN - BOOLEAN"},
-		{source: "x;", want: "identifier not found: 'x'"},
-		{source: "let a = 10; return y;", want: "identifier not found: 'y'"},
-		{source: "let a = 10; b = 20;", want: "identifier not found: 'b'"},
-		{source: "let a = 10; a = b;", want: "identifier not found: 'b'"},
-		{source: "let function = 1; function(false)", want: "not a function: INTEGER '1'"},
-		{source: "let x = 10; { let f = x; } f;", want: "identifier not found: 'f'"},
-		{source: `len("Hello, World!"); { let len = 10; len; len("Hello, World!")}`, want: "not a function: INTEGER '10'"},
-		{source: `class A {} A(1, "b");`, want: "wrong arguments count: expect 0, got 2"},
-		{source: `class A {} A.field;`, want: "only instances have properties: CLASS.field"},
-		{source: `class A {} A.field = 10;`, want: "only instances have fields: CLASS.field"},
-		{source: `class A {} A().field;`, want: "undefined property: 'field'"},
-		{source: "class A { fn method() { this.x; }} let obj = A(); obj.method()", want: "undefined property: 'x'"},
-		{source: `"hi".field;`, want: "only instances have properties: STRING.field"},
-		{source: `"hi".field = 10;`, want: "only instances have fields: STRING.field"},
-		{source: "class A < B {}", want: "identifier not found: 'B'"},
-		{source: "let B = 10; class A < B {}", want: "superclass must be a class: 'A < INTEGER'"},
-		{
-			source: `class A {
-						fn init() { this.x = 20; }
-						fn method() { this.x; }
-					}
-					class B < A{
-						fn init() {}
-					}
-					let obj = B(); obj.x;`,
-			want: "undefined property: 'x'",
-		},
-		{
-			source: `class A {
-						fn init(n) { this.x = n; }
-						fn method() { this.x; }
-					}
-					class B < A{}
-					let obj = B(); obj.x`,
-			want: "wrong arguments count: expect 1, got 0",
-		},
-		{source: "[1, 2, 3][3]", want: "out of bounds: ARRAY[3]"},
-		{source: "[1, 2, 3][-4]", want: "out of bounds: ARRAY[-4]"},
-		{source: `"hello"[-4]`, want: "unknown operator: STRING[INTEGER]"},
-		{source: `["hello", "world"]["first"]`, want: "unknown operator: ARRAY[STRING]"},
-		{source: `(fn (){})[0]`, want: "unknown operator: FUNCTION[INTEGER]"},
-		{source: `{| 1: true && false, 2 + 3: "hello", "world": 3, fn(){}: "oops" |}`, want: "unusable as hash key: FUNCTION"},
-		{source: `let arr = []; {| 1: true, false: 2, 3: "hello" |}[arr]`, want: "unusable as hash key: ARRAY"},
-		{source: `class A{} {| 1: true, false: 2, 3: "hello" |}[A]`, want: "unusable as hash key: CLASS"},
-		{source: `class A{} {| 1: true, false: 2, 3: "hello" |}[A()]`, want: "unusable as hash key: INSTANCE"},
+func evalSource(t testing.TB, source string) object.Object {
+	t.Helper()
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Error while parsing %q.", source)
 	}
 
-	for _, tc := range tt {
-		t.Run(tc.source, func(t *testing.T) {
-			got := evalSource(t, tc.source)
+	r := resolver.New()
+	r.Resolve(program)
+
+	e := eval.New()
+	e.SetLocals(r.Locals())
+
+	return e.Eval(program, object.NewEnvironment())
+}
+
+func TestUncaughtException(t *testing.T) {
+	for _, tc := range evaltest.ExceptionCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := evalSource(t, tc.Source)
 
 			err, ok := got.(*object.Error)
 			if !ok {
-				t.Errorf("No error object returned, got %T (%+v).", got, got)
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+			if err.Kind != tc.Kind {
+				t.Errorf("Wrong error kind, got %q, want %q.", err.Kind, tc.Kind)
 			}
-			if err.Message != tc.want {
-				t.Errorf("Wrong error message, got %q, want %q.", err.Message, tc.want)
+			if err.Message != tc.Message {
+				t.Errorf("Wrong error message, got %q, want %q.", err.Message, tc.Message)
+			}
+			if err.Span.String() != tc.Span {
+				t.Errorf("Wrong error position, got %s, want %s.", err.Span, tc.Span)
 			}
 		})
 	}
 }
 
-func testObject(t testing.TB, obj object.Object, want interface{}) {
-	switch obj.Type() {
-	case object.INTEGER_OBJ:
-		o, ok := obj.(*object.Integer)
-		if !ok {
-			t.Errorf("Object is not an Integer, got %T. (%+v)", obj, obj)
-		}
-		w, ok := want.(int64)
-		if !ok {
-			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
-		}
+func TestTailCalls(t *testing.T) {
+	for _, tc := range evaltest.TailCallCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			evaltest.CheckObject(t, evalSource(t, tc.Source), tc.Want)
+		})
+	}
+}
 
-		if w != o.Value {
-			t.Errorf("Wrong object value. Got %v, want %v.", o.Value, w)
-		}
-	case object.BOOLEAN_OBJ:
-		o, ok := obj.(*object.Boolean)
-		if !ok {
-			t.Errorf("Object is not an Boolean, got %T. (%+v)", obj, obj)
-		}
-		w, ok := want.(bool)
-		if !ok {
-			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
-		}
+func TestDefaultCapabilities(t *testing.T) {
+	tt := []evaltest.Case{
+		{Source: "try { clock(); 1; } catch (e) { e.kind; }", Want: int64(1)},
+		{Source: `try { readFile("a"); } catch (e) { e.kind; }`, Want: eval.PERMISSION_ERROR},
+		{Source: `try { getEnv("HOME"); } catch (e) { e.kind; }`, Want: eval.PERMISSION_ERROR},
+		{Source: `try { fetch("http://a"); } catch (e) { e.kind; }`, Want: eval.PERMISSION_ERROR},
+	}
 
-		if w != o.Value {
-			t.Errorf("Wrong object value. Got %v, want %v.", o.Value, w)
-		}
-	case object.STRING_OBJ:
-		o, ok := obj.(*object.String)
-		if !ok {
-			t.Errorf("Object is not an String, got %T. (%+v)", obj, obj)
-		}
-		w, ok := want.(string)
-		if !ok {
-			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
-		}
+	for _, tc := range tt {
+		t.Run(tc.Source, func(t *testing.T) {
+			evaltest.CheckObject(t, evalSource(t, tc.Source), tc.Want)
+		})
+	}
+}
 
-		if w != o.Value {
-			t.Errorf("Wrong object value. Got %v, want %v.", o.Value, w)
-		}
-	case object.ARRAY_OBJ:
-		a, ok := obj.(*object.Array)
-		if !ok {
-			t.Errorf("Object is not an Array, got %T. (%+v)", obj, obj)
-		}
-		w, ok := want.([]interface{})
-		if !ok {
-			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
-		}
+func TestFetchCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
 
-		if len(w) != len(a.Elements) {
-			t.Fatalf("Wrong array value, got %s, want %v", a.Inspect(), w)
-		}
+	e, program := newEvaluator(t, fmt.Sprintf("try { fetch(%q); } catch (e) { e.kind; }", server.URL))
+	e.SetCapabilities(eval.NETWORK)
 
-		for i, el := range a.Elements {
-			testObject(t, el, w[i])
-		}
-	case object.HASH_OBJ:
-		h, ok := obj.(*object.Hash)
-		if !ok {
-			t.Errorf("Object is not an Hash, got %T. (%+v)", obj, obj)
-		}
-		w, ok := want.(map[interface{}]interface{})
-		if !ok {
-			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
 
-		if len(w) != len(h.Pairs) {
-			t.Fatalf("Wrong hash value, got %s, want %v", h.Inspect(), w)
-		}
+	got := e.EvalContext(ctx, program, object.NewEnvironment())
 
-		for _, pair := range h.Pairs {
-			var ok bool
-			var want interface{}
-
-			switch k := pair.Key.(type) {
-			case *object.String:
-				want, ok = w[k.Value]
-			case *object.Boolean:
-				want, ok = w[k.Value]
-			case *object.Integer:
-				want, ok = w[k.Value]
-			default:
-				t.Fatalf("Unknown key type.")
-			}
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("No error object returned, got %T (%+v).", got, got)
+	}
+	if err.Kind != eval.TIMEOUT_ERROR {
+		t.Errorf("Wrong error kind, got %q, want %q.", err.Kind, eval.TIMEOUT_ERROR)
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	source := "fn f(n) { if (n == 0) { return 0; } 1 + f(n - 1) }" +
+		" let a = f(99); let b = null; try { f(100); } catch (e) { b = e.kind; } b;"
+
+	e, program := newEvaluator(t, source)
+	e.SetMaxCallDepth(100)
+
+	evaltest.CheckObject(t, e.Eval(program, object.NewEnvironment()), eval.RECURSION_ERROR)
+}
+
+func TestLimits(t *testing.T) {
+	for _, tc := range evaltest.LimitCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			e, program := newEvaluator(t, tc.Source)
+			e.SetLimits(tc.Limits)
 
+			got := e.Eval(program, object.NewEnvironment())
+
+			err, ok := got.(*object.Error)
 			if !ok {
-				t.Errorf("Want %v, got %+v.", w, h.Pairs)
-				t.Errorf("Didn't expect key '%v' in pair '%v: %v'", pair.Key, pair.Key, pair.Value)
-			} else {
-				testObject(t, pair.Value, want)
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
 			}
-		}
-	case object.NULL_OBJ:
-		_, ok := obj.(*object.Null)
-		if !ok {
-			t.Errorf("Object is not an Null, got %T. (%+v)", obj, obj)
-		}
+			if err.Kind != tc.Kind {
+				t.Errorf("Wrong error kind, got %q, want %q.", err.Kind, tc.Kind)
+			}
+			if err.Message != tc.Message {
+				t.Errorf("Wrong error message, got %q, want %q.", err.Message, tc.Message)
+			}
+		})
+	}
+}
 
-		if want != nil {
-			t.Errorf("Object is Null, but want %v.", want)
-		}
-	case object.FUNCTION_OBJ:
-		fn, ok := obj.(*object.Function)
-		if !ok {
-			t.Errorf("Object is not an Function, got %T. (%+v)", obj, obj)
-		}
-		w, ok := want.(*expectFn)
-		if !ok {
-			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
-		}
+func TestBuiltinMemoryCheckedBeforeAllocation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "big.txt")
+	if err := os.WriteFile(file, make([]byte, 1<<23), 0o644); err != nil {
+		t.Fatal(err)
+	}
 
-		if len(fn.Parameters) != len(w.params) {
-			t.Errorf("Wrong parameters number, got %d, want %d.", len(fn.Parameters), len(w.params))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// chunked response of unknown length
+		for i := 0; i < 1<<10; i++ {
+			w.Write(make([]byte, 1<<13))
+			w.(http.Flusher).Flush()
 		}
+	}))
+	defer server.Close()
 
-		for i, p := range fn.Parameters {
-			if p.Value != w.params[i] {
-				t.Errorf("Wrong parameter name, parameter index=%d, got %q, want %q.", i, p.Value, w.params[i])
+	// memory is what arguments took, strings take 16 bytes and their length
+	tt := []struct {
+		source string
+		memory int64
+	}{
+		{`repeat("ab", 268435456)`, 16 + 2},
+		{
+			`join(["", "", "", "", "", "", "", "", "", ""], repeat(",", 1048576))`,
+			10*16 + (16 + 10*16) + (16 + 1) + (16 + 1048576),
+		},
+		{`replace(repeat("a", 1024), "", repeat("b", 1048576))`, (16 + 1) + (16 + 1024) + 16 + (16 + 1) + (16 + 1048576)},
+		{`split(repeat(",", 1048576), ",")`, (16 + 1) + (16 + 1048576) + (16 + 1)},
+		{fmt.Sprintf("readFile(%q)", file), 16 + int64(len(file))},
+		{fmt.Sprintf("fetch(%q)", server.URL), 16 + int64(len(server.URL))},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			e, program := newEvaluator(t, tc.source)
+			e.SetCapabilities(eval.AllCapabilities...)
+			e.SetLimits(eval.Limits{MaxMemory: 1 << 22})
+
+			got := e.Eval(program, object.NewEnvironment())
+
+			err, ok := got.(*object.Error)
+			if !ok || err.Kind != eval.MEMORY_ERROR {
+				t.Fatalf("No memory error returned, got %T (%+v).", got, got)
 			}
-		}
+			if memory := e.Usage().Memory; memory != tc.memory {
+				t.Errorf("Result of builtin accounted, got %d bytes, want %d.", memory, tc.memory)
+			}
+		})
+	}
+}
 
-		if fn.Body.String() != w.body {
-			t.Errorf("Wrong function body, got %q, want %q.", fn.Body.String(), w.body)
-		}
-	case object.CLASS_OBJ:
-		class, ok := obj.(*object.Class)
-		if !ok {
-			t.Errorf("Object is not an Class, got %T. (%+v)", obj, obj)
-		}
-		w, ok := want.(*expectClass)
-		if !ok {
-			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
-		}
+func TestLimitsAreNotShared(t *testing.T) {
+	e, program := newEvaluator(t, "let i = 0; while (i < 100) { i = i + 1; } i;")
+	e.SetLimits(eval.Limits{MaxSteps: 1000, MaxObjects: 10})
 
-		if class.Name.Value != w.name {
-			t.Errorf("Wrong class name, want %q, got %q.", w.name, class.Name.Value)
-		}
+	for i := 0; i < 3; i++ {
+		evaltest.CheckObject(t, e.Eval(program, object.NewEnvironment()), int64(100))
+	}
+}
 
-		gotSuper := ""
-		if class.Super != nil {
-			gotSuper = class.Super.Name.Value
-		}
-		if gotSuper != w.super {
-			t.Errorf("Wrong superclass name, want %q, got %q.", w.super, gotSuper)
-		}
+func TestEvalContextCancel(t *testing.T) {
+	e, program := newEvaluator(t, "fn f() { while (true) {} } f();")
 
-		if len(class.Methods) != len(w.props) {
-			t.Errorf("Wrong properties number, got %d, want %d.", len(class.Methods), len(w.props))
-		}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
 
-		methods := []string{}
-		for m := range class.Methods {
-			methods = append(methods, m)
-		}
-		for i, m := range methods {
-			if m != w.props[i] {
-				t.Errorf("Wrong property name, prop index=%d, got %q, want %q.", i, m, w.props[i])
-			}
-		}
+	got := e.EvalContext(ctx, program, object.NewEnvironment())
 
-		// if class.Body.String() != w.body {
-		// 	t.Errorf("Wrong function body, got %q, want %q.", class.Body.String(), w.body)
-		// }
-	case object.INSTANCE_OBJ:
-		inst, ok := obj.(*object.Instance)
-		if !ok {
-			t.Errorf("Object is not an Instance, got %T. (%+v)", obj, obj)
-		}
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("No error object returned, got %T (%+v).", got, got)
+	}
+	if err.Kind != eval.CANCELLED_ERROR {
+		t.Errorf("Wrong error kind, got %q, want %q.", err.Kind, eval.CANCELLED_ERROR)
+	}
+	if len(err.Stack) != 1 || err.Stack[0].Name() != "f" {
+		t.Errorf("Wrong error stack, got %v.", err.Stack)
+	}
+}
 
-		w, ok := want.(*expectInstance)
-		if !ok {
-			t.Fatalf("Can not compare %q value with %T .", obj.Type(), want)
+func TestLocalsReleased(t *testing.T) {
+	e := eval.New()
+	env := object.NewEnvironment()
+	defer runtime.KeepAlive(e)
+
+	// watched reports when the last expression of the program is collected
+	evalProgram := func(source string, watched chan struct{}) object.Object {
+		p := parser.New(lexer.New(source))
+		program := p.ParseProgram()
+		r := resolver.New()
+		r.Resolve(program)
+		if len(p.Errors()) != 0 || len(r.Errors()) != 0 {
+			t.Fatalf("Error while parsing %q.", source)
 		}
 
-		if inst.Class.Name.Value != w.class {
-			t.Errorf("Wrong instance class, want %q, got %q.", w.class, inst.Class.Name.Value)
+		if watched != nil {
+			last := program.Statements[len(program.Statements)-1].(*ast.ExpressionStmt)
+			runtime.SetFinalizer(last.Expression.(*ast.IdentifierExpr), func(*ast.IdentifierExpr) { close(watched) })
 		}
 
-	case object.ERROR_OBJ:
-		t.Errorf("Got unexpected error object: %v.", obj)
-	default:
-		t.Errorf("Unknown object type %q.", obj.Type())
+		e.SetLocals(r.Locals())
+		return e.Eval(program, env)
+	}
+
+	released := make(chan struct{})
+	evalProgram("fn f(n) { let m = n * 2; m }", nil)
+	evaltest.CheckObject(t, evalProgram("let x = f(1); x;", released), int64(2))
+	// functions keep locals of the program defining them
+	evaltest.CheckObject(t, evalProgram("f(x)", nil), int64(4))
+
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		select {
+		case <-released:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
 	}
+	t.Error("Locals of evaluated program are not released.")
 }
 
-func evalSource(t testing.TB, source string) object.Object {
+func newEvaluator(t *testing.T, source string) (*eval.Evaluator, *ast.Program) {
 	t.Helper()
 
 	p := parser.New(lexer.New(source))
@@ -600,7 +342,67 @@ func evalSource(t testing.TB, source string) object.Object {
 	r := resolver.New()
 	r.Resolve(program)
 
-	eval.Locals = r.Locals()
+	e := eval.New()
+	e.SetLocals(r.Locals())
+
+	return e, program
+}
+
+func TestCapabilities(t *testing.T) {
+	tt := []evaltest.Case{
+		{Source: `len("ab") + len([1])`, Want: int64(3)},
+		{Source: `upper(join(map(["a"], fn(s) { s + "b" }), ""))`, Want: "AB"},
+		{Source: "try { println(1); } catch (e) { e.kind; }", Want: eval.PERMISSION_ERROR},
+		{Source: "try { clock(); } catch (e) { e.message; }", Want: "permission denied: 'clock' needs clock capability"},
+		{Source: `try { getEnv("HOME"); } catch (e) { e.message; }`, Want: "permission denied: 'getEnv' needs env capability"},
+		{Source: `try { readFile("a"); } catch (e) { e.message; }`, Want: "permission denied: 'readFile' needs filesystem capability"},
+		{Source: `try { fetch("http://a"); } catch (e) { e.message; }`, Want: "permission denied: 'fetch' needs network capability"},
+		{Source: "let f = print; try { map([1], f); } catch (e) { e.kind; }", Want: eval.PERMISSION_ERROR},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Source, func(t *testing.T) {
+			e, program := newEvaluator(t, tc.Source)
+			e.SetCapabilities(eval.PURE)
+
+			evaltest.CheckObject(t, e.Eval(program, object.NewEnvironment()), tc.Want)
+		})
+	}
+}
 
-	return eval.Eval(program, object.NewEnvironment())
+func TestSystemBuiltins(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.txt")
+	t.Setenv("MONKEY_TEST_VAR", "banana")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "pong")
+	}))
+	defer server.Close()
+
+	tt := []evaltest.Case{
+		{Source: fmt.Sprintf(`writeFile(%q, "hi"); readFile(%[1]q);`, file), Want: "hi"},
+		{
+			Source: fmt.Sprintf(`try { readFile(%q); } catch (e) { e.kind; }`, file+".missing"),
+			Want:   eval.IO_ERROR,
+		},
+		{Source: `getEnv("MONKEY_TEST_VAR")`, Want: "banana"},
+		{Source: `getEnv("MONKEY_TEST_UNSET_VAR")`, Want: nil},
+		{Source: fmt.Sprintf("fetch(%q)", server.URL+"/ok"), Want: "pong"},
+		{
+			Source: fmt.Sprintf("try { fetch(%q); } catch (e) { e.message; }", server.URL+"/missing"),
+			Want:   "io error: GET " + server.URL + "/missing: 404 Not Found",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.Source, func(t *testing.T) {
+			e, program := newEvaluator(t, tc.Source)
+			e.SetCapabilities(eval.AllCapabilities...)
+			evaltest.CheckObject(t, e.Eval(program, object.NewEnvironment()), tc.Want)
+		})
+	}
 }
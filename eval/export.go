@@ -0,0 +1,185 @@
+package eval
+
+import (
+	"context"
+	"monkey/ast"
+	"monkey/object"
+	"sort"
+)
+
+// Semantics of operators, builtins and runtime errors are shared with
+// the bytecode virtual machine, so both backends behave the same way.
+
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpr(operator, right)
+}
+
+func Infix(left object.Object, operator string, right object.Object) object.Object {
+	return evalInfixExpr(left, operator, right)
+}
+
+func Index(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+func SetIndex(left, index, val object.Object) object.Object {
+	return setIndex(left, index, val)
+}
+
+func Slice(left, low, high object.Object) object.Object {
+	return evalSliceExpression(left, low, high)
+}
+
+func IterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+	return iterationItems(iterable)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// GetProperty returns field or bound method of instance, member of module
+// or field of caught exception.
+func GetProperty(obj object.Object, name string) object.Object {
+	return getProperty(obj, name)
+}
+
+// Throw returns error raised by throwing value, rethrown exception keeps
+// its original kind, position and stack.
+func Throw(value object.Object) *object.Error {
+	if exc, ok := value.(*object.Exception); ok {
+		return exc.Error
+	}
+	return thrownError(value)
+}
+
+// ImportPermission returns error unless granted capabilities allow
+// importing modules.
+func ImportPermission(granted []Capability) *object.Error {
+	return importPermission(granted)
+}
+
+// ModulePath returns path of module imported by importer file and its
+// absolute path, which identifies the module.
+func ModulePath(path string, importer string) (string, string, *object.Error) {
+	return modulePath(path, importer)
+}
+
+// ImportCycle returns error when module is in the chain of modules
+// being imported.
+func ImportCycle(importing []string, key string) *object.Error {
+	return importCycle(importing, key)
+}
+
+// ParseModule reads, parses and checks module.
+func ParseModule(path string) (*ast.Program, *object.Error) {
+	program, _, err := parseModule(path)
+	return program, err
+}
+
+func ImportError(path string, reason string) *object.Error {
+	return importError(path, reason)
+}
+
+// Budget tracks resources used by a single run of the virtual machine
+// against limits, like the evaluator tracks its evaluations.
+type Budget struct {
+	b *budget
+}
+
+// NewBudget starts tracking run which fails once ctx is done.
+func NewBudget(ctx context.Context, limits Limits) *Budget {
+	return &Budget{b: newBudget(ctx, limits)}
+}
+
+// Step counts executed instruction.
+func (b *Budget) Step() *object.Error {
+	return b.b.step()
+}
+
+// Allocate accounts newly created object.
+func (b *Budget) Allocate(obj object.Object) *object.Error {
+	return b.b.allocate(obj)
+}
+
+// Size returns size of obj to pass to Grow after obj is modified.
+func (b *Budget) Size(obj object.Object) int64 {
+	return b.b.size(obj)
+}
+
+// Grow accounts memory taken by obj since it had given size.
+func (b *Budget) Grow(obj object.Object, size int64) *object.Error {
+	return b.b.grow(obj, size)
+}
+
+// CallBuiltin calls fn with args through invoke, memory the builtin is
+// about to allocate is checked before and its result is accounted after.
+func (b *Budget) CallBuiltin(fn *object.Builtin, args []object.Object, invoke func() object.Object) object.Object {
+	return b.b.callBuiltin(fn, args, invoke)
+}
+
+// Context returns context to call cancellable builtins with.
+func (b *Budget) Context() context.Context {
+	return b.b.builtinCtx
+}
+
+// Interrupted returns error failing the run once its context is done.
+func (b *Budget) Interrupted() *object.Error {
+	return b.b.interrupted()
+}
+
+func (b *Budget) Usage() Usage {
+	return b.b.usage()
+}
+
+// Builtins returns builtins of granted capabilities, builtins of other
+// capabilities fail with PermissionError.
+func Builtins(granted ...Capability) map[string]*object.Builtin {
+	return newBuiltins(granted)
+}
+
+// BuiltinNames returns sorted names of all builtins.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func IdentifierNotFoundError(identifier string) *object.Error {
+	return identifierNotFoundError(identifier)
+}
+
+func StackOverflowError() *object.Error {
+	return stackOverflowError()
+}
+
+func NotAFunctionError(fn object.Object) *object.Error {
+	return notAFunctionError(string(fn.Type()), fn.Inspect())
+}
+
+func WrongArgumentsCountError(expect int, got int) *object.Error {
+	return wrongArgumentsCountError(expect, got)
+}
+
+func WrongGetTargetError(target object.ObjectType, prop string) *object.Error {
+	return wrongGetTargetError(target, prop)
+}
+
+func WrongSetTargetError(target object.ObjectType, prop string) *object.Error {
+	return wrongSetTargetError(target, prop)
+}
+
+func UndefinedPropertyError(prop string) *object.Error {
+	return undefinedPropertyError(prop)
+}
+
+func SuperclassMustBeClassError(class string, super object.ObjectType) *object.Error {
+	return superclassMustBeClassError(class, super)
+}
+
+func NotHashableKeyError(key object.ObjectType) *object.Error {
+	return notHashableKeyError(key)
+}
@@ -0,0 +1,120 @@
+package eval
+
+import "monkey/object"
+
+// Hash builtins list pairs in insertion order of their keys.
+
+// hashArg checks that args are a hash followed by count-1 other values.
+func hashArg(name string, count int, args []object.Object) (*object.Hash, *object.Error) {
+	if len(args) != count {
+		return nil, wrongArgumentsCountError(count, len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return nil, builtinTypeMismatchError(name, args...)
+	}
+
+	return hash, nil
+}
+
+// hashKeyArg checks that args are a hash and a key which can be used with it.
+func hashKeyArg(name string, args []object.Object) (*object.Hash, object.HashKey, *object.Error) {
+	hash, err := hashArg(name, 2, args)
+	if err != nil {
+		return nil, object.HashKey{}, err
+	}
+
+	key, ok := args[1].(object.Hashable)
+	if !ok {
+		return nil, object.HashKey{}, notHashableKeyError(args[1].Type())
+	}
+
+	return hash, key.HashKey(), nil
+}
+
+var hashBuiltins = map[string]*object.Builtin{
+	"keys": {
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("keys", 1, args)
+			if err != nil {
+				return err
+			}
+			elements := []object.Object{}
+			for _, pair := range hash.Pairs() {
+				elements = append(elements, pair.Key)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"values": {
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("values", 1, args)
+			if err != nil {
+				return err
+			}
+			elements := []object.Object{}
+			for _, pair := range hash.Pairs() {
+				elements = append(elements, pair.Value)
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"entries": {
+		// entries(hash) returns [key, value] arrays
+		Fn: func(args ...object.Object) object.Object {
+			hash, err := hashArg("entries", 1, args)
+			if err != nil {
+				return err
+			}
+			elements := []object.Object{}
+			for _, pair := range hash.Pairs() {
+				elements = append(elements, &object.Array{Elements: []object.Object{pair.Key, pair.Value}})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"has": {
+		Fn: func(args ...object.Object) object.Object {
+			hash, key, err := hashKeyArg("has", args)
+			if err != nil {
+				return err
+			}
+			_, ok := hash.Get(key)
+			return boolToBooleanObject(ok)
+		},
+	},
+	"delete": {
+		// delete(hash, key) removes key from hash and returns its value,
+		// or null when hash does not have the key
+		Fn: func(args ...object.Object) object.Object {
+			hash, key, err := hashKeyArg("delete", args)
+			if err != nil {
+				return err
+			}
+			pair, ok := hash.Get(key)
+			if !ok {
+				return NULL
+			}
+			hash.Delete(key)
+			return pair.Value
+		},
+	},
+	"merge": {
+		// merge(hashes...) returns new hash with pairs of all hashes,
+		// values of later hashes win
+		Fn: func(args ...object.Object) object.Object {
+			merged := &object.Hash{}
+			for _, arg := range args {
+				hash, ok := arg.(*object.Hash)
+				if !ok {
+					return builtinTypeMismatchError("merge", args...)
+				}
+				for _, pair := range hash.Pairs() {
+					merged.Set(pair.Key.(object.Hashable).HashKey(), pair)
+				}
+			}
+			return merged
+		},
+	},
+}
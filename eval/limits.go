@@ -0,0 +1,300 @@
+package eval
+
+import (
+	"context"
+	"math"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"time"
+)
+
+// Limits bound resources used by a single evaluation, zero fields mean
+// no limit. Exceeded limit fails the evaluation with an error of its own
+// kind, scripts may catch it, but the limit stays exceeded, so evaluation
+// fails again at the next step.
+type Limits struct {
+	// MaxSteps limits number of evaluated syntax tree nodes, or executed
+	// instructions in the virtual machine.
+	MaxSteps int64
+	// Timeout limits wall-clock time of evaluation.
+	Timeout time.Duration
+	// MaxObjects limits number of allocated strings, arrays, hashes,
+	// functions and instances, numbers and booleans are not counted.
+	MaxObjects int64
+	// MaxMemory limits approximate size of allocated objects in bytes.
+	MaxMemory int64
+}
+
+// Usage is amount of resources used by an evaluation, objects and memory
+// are counted only when MaxObjects or MaxMemory is limited.
+type Usage struct {
+	Steps   int64
+	Objects int64
+	Memory  int64
+}
+
+// Approximate sizes used to account memory, they need not be exact
+// as long as they grow with values.
+const (
+	objectSize    = 16 // any object
+	referenceSize = 16 // element of array
+	entrySize     = 48 // pair of hash or field of instance
+)
+
+// contextCheckInterval is number of steps between checks whether context
+// is done, checking it on every step would slow evaluation down.
+const contextCheckInterval = 1 << 10
+
+// budget tracks resources used by evaluation against limits.
+type budget struct {
+	limits Limits
+	ctx    context.Context
+	done   <-chan struct{}
+	// builtinCtx is passed to cancellable builtins, it carries the budget,
+	// so builtins reading data of unknown size can stop once it is spent
+	builtinCtx context.Context
+
+	steps   int64
+	objects int64
+	memory  int64
+
+	// exceeded is the error of the first exceeded limit.
+	exceeded *object.Error
+}
+
+func newBudget(ctx context.Context, limits Limits) *budget {
+	b := &budget{limits: limits, ctx: ctx, done: ctx.Done()}
+	b.builtinCtx = context.WithValue(ctx, budgetKey{}, b)
+	return b
+}
+
+type budgetKey struct{}
+
+// contextBudget returns budget of evaluation calling cancellable builtin
+// with ctx, nil when the builtin is called from elsewhere.
+func contextBudget(ctx context.Context) *budget {
+	b, _ := ctx.Value(budgetKey{}).(*budget)
+	return b
+}
+
+func (b *budget) usage() Usage {
+	return Usage{Steps: b.steps, Objects: b.objects, Memory: b.memory}
+}
+
+// step counts evaluated node.
+func (b *budget) step() *object.Error {
+	if b.exceeded != nil {
+		return b.fail(b.exceeded)
+	}
+
+	b.steps++
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return b.fail(stepLimitError(b.limits.MaxSteps))
+	}
+
+	if b.done != nil && b.steps%contextCheckInterval == 0 {
+		select {
+		case <-b.done:
+			return b.fail(contextError(b.ctx.Err()))
+		default:
+		}
+	}
+
+	return nil
+}
+
+// fail remembers exceeded limit and returns a copy of its error, errors
+// are positioned where they are raised, so every step needs a new one.
+func (b *budget) fail(err *object.Error) *object.Error {
+	if b.exceeded == nil {
+		b.exceeded = err
+	}
+	return &object.Error{Kind: b.exceeded.Kind, Message: b.exceeded.Message}
+}
+
+func (b *budget) accounting() bool {
+	return b != nil && (b.limits.MaxObjects > 0 || b.limits.MaxMemory > 0)
+}
+
+// allocate accounts newly created object.
+func (b *budget) allocate(obj object.Object) *object.Error {
+	if !b.accounting() || !counted(obj) {
+		return nil
+	}
+
+	b.objects++
+	if b.limits.MaxObjects > 0 && b.objects > b.limits.MaxObjects {
+		return b.fail(objectLimitError(b.limits.MaxObjects))
+	}
+	return b.grow(obj, 0)
+}
+
+// size returns size of obj to pass to grow after obj is modified.
+func (b *budget) size(obj object.Object) int64 {
+	if !b.accounting() {
+		return 0
+	}
+	return shallowSize(obj)
+}
+
+// grow accounts memory taken by obj since it had given size.
+func (b *budget) grow(obj object.Object, size int64) *object.Error {
+	if !b.accounting() {
+		return nil
+	}
+
+	if grown := shallowSize(obj) - size; grown > 0 {
+		b.memory += grown
+	}
+	if b.limits.MaxMemory > 0 && b.memory > b.limits.MaxMemory {
+		return b.fail(memoryLimitError(b.limits.MaxMemory))
+	}
+	return nil
+}
+
+// sizes returns sizes of builtin arguments to pass to allocateBuiltin.
+func (b *budget) sizes(args []object.Object) []int64 {
+	if !b.accounting() {
+		return nil
+	}
+
+	sizes := make([]int64, len(args))
+	for i, arg := range args {
+		sizes[i] = shallowSize(arg)
+	}
+	return sizes
+}
+
+// reserve checks that size bytes about to be allocated fit into memory
+// left, so huge results fail before they are built.
+func (b *budget) reserve(size int64) *object.Error {
+	if !b.accounting() || b.limits.MaxMemory == 0 {
+		return nil
+	}
+	if size > b.limits.MaxMemory-b.memory {
+		return b.fail(memoryLimitError(b.limits.MaxMemory))
+	}
+	return nil
+}
+
+// memoryLeft returns number of bytes which may be allocated before memory
+// limit is exceeded, it reports false when memory is not limited.
+func (b *budget) memoryLeft() (int64, bool) {
+	if !b.accounting() || b.limits.MaxMemory == 0 {
+		return 0, false
+	}
+	if b.memory > b.limits.MaxMemory {
+		return 0, true
+	}
+	return b.limits.MaxMemory - b.memory, true
+}
+
+// interrupted returns error failing the evaluation once its context is done,
+// builtins are not interrupted by steps, so it is checked after them.
+func (b *budget) interrupted() *object.Error {
+	if err := b.ctx.Err(); err != nil {
+		return b.fail(contextError(err))
+	}
+	return nil
+}
+
+// callBuiltin calls fn with args through invoke, memory the builtin is
+// about to allocate is checked before and its result is accounted after.
+func (b *budget) callBuiltin(fn *object.Builtin, args []object.Object, invoke func() object.Object) object.Object {
+	if fn.Size != nil && b.accounting() {
+		if err := b.reserve(fn.Size(args...)); err != nil {
+			return err
+		}
+	}
+
+	sizes := b.sizes(args)
+	result := invoke()
+	if isError(result) {
+		return result
+	}
+	if err := b.allocateBuiltin(args, sizes, result); err != nil {
+		return err
+	}
+	return result
+}
+
+// allocateBuiltin accounts result of builtin unless it is one of its
+// arguments, and growth of arguments modified by the builtin.
+func (b *budget) allocateBuiltin(args []object.Object, sizes []int64, result object.Object) *object.Error {
+	if !b.accounting() {
+		return nil
+	}
+
+	fresh := true
+	for i, arg := range args {
+		if arg == result {
+			fresh = false
+		}
+		if err := b.grow(arg, sizes[i]); err != nil {
+			return err
+		}
+	}
+
+	if fresh {
+		return b.allocate(result)
+	}
+	return nil
+}
+
+// multiplySize returns a*b of non-negative sizes, saturated instead of
+// overflowing.
+func multiplySize(a, b int64) int64 {
+	if a != 0 && b > math.MaxInt64/a {
+		return math.MaxInt64
+	}
+	return a * b
+}
+
+// addSize returns a+b of non-negative sizes, saturated instead of
+// overflowing.
+func addSize(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// allocates reports whether evaluation of node creates new object.
+func allocates(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.StringLiteralExpr, *ast.TemplateExpr, *ast.ArrayLiteralExpr, *ast.HashLiteralExpr,
+		*ast.SliceExpr, *ast.FunctionExpr:
+		return true
+	case *ast.InfixExpr:
+		// logical operators return one of the operands
+		return node.Operator != token.AND && node.Operator != token.OR
+	default:
+		return false
+	}
+}
+
+func counted(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.String, *object.Array, *object.Hash, *object.Function, *object.Instance:
+		return true
+	default:
+		return false
+	}
+}
+
+// shallowSize estimates memory taken by obj without objects it refers to.
+func shallowSize(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.String:
+		return objectSize + int64(len(obj.Value))
+	case *object.Array:
+		return objectSize + referenceSize*int64(len(obj.Elements))
+	case *object.Hash:
+		return objectSize + entrySize*int64(obj.Len())
+	case *object.Instance:
+		return objectSize + entrySize*int64(len(obj.Fields))
+	default:
+		return objectSize
+	}
+}
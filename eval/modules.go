@@ -0,0 +1,156 @@
+package eval
+
+import (
+	"monkey/ast"
+	"monkey/checker"
+	"monkey/diagnostics"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const MODULE_FRAME_NAME = "<module>"
+
+func (e *Evaluator) evalImportStmt(node *ast.ImportStmt, env *object.Environment) object.Object {
+	module := e.importModule(node.Path.Value, node.Pos().File, ast.Span(node))
+	if isError(module) {
+		return module
+	}
+
+	env.Set(node.Name.Value, module)
+	return module
+}
+
+// importModule loads module from path relative to the importing file.
+func (e *Evaluator) importModule(path string, importer string, importSite token.Span) object.Object {
+	if err := importPermission(e.granted); err != nil {
+		return err
+	}
+
+	path, key, err := modulePath(path, importer)
+	if err != nil {
+		return err
+	}
+
+	if module, ok := e.modules[key]; ok {
+		return module
+	}
+	if err := importCycle(e.importing, key); err != nil {
+		return err
+	}
+
+	program, locals, err := parseModule(path)
+	if err != nil {
+		return err
+	}
+	env := object.NewEnvironment()
+
+	enclosingLocals := e.locals
+	e.locals = locals
+	e.importing = append(e.importing, key)
+	e.callStack = append(e.callStack, object.Frame{Function: MODULE_FRAME_NAME, CallSite: importSite})
+	result := e.Eval(program, env)
+	e.callStack = e.callStack[:len(e.callStack)-1]
+	e.importing = e.importing[:len(e.importing)-1]
+	e.locals = enclosingLocals
+
+	if isError(result) {
+		return result
+	}
+
+	module := &object.Module{Path: path, Env: env}
+	e.modules[key] = module
+	return module
+}
+
+// importPermission returns error unless granted capabilities include
+// FILESYSTEM, importing reads module sources from the host.
+func importPermission(granted []Capability) *object.Error {
+	for _, capability := range granted {
+		if capability == FILESYSTEM {
+			return nil
+		}
+	}
+	return permissionError("import", FILESYSTEM)
+}
+
+// modulePath returns path of module imported by importer file and its
+// absolute path, which identifies the module.
+func modulePath(path string, importer string) (string, string, *object.Error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(importer), path)
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", importError(path, err.Error())
+	}
+	return path, key, nil
+}
+
+// importCycle returns error when module is in the chain of modules
+// being imported.
+func importCycle(importing []string, key string) *object.Error {
+	for i, p := range importing {
+		if p == key {
+			chain := append(append([]string{}, importing[i:]...), key)
+			return importCycleError(relativePaths(chain))
+		}
+	}
+	return nil
+}
+
+// parseModule reads, parses and checks module, it returns the program
+// with depths of its resolved variables.
+func parseModule(path string) (*ast.Program, map[ast.Expression]int, *object.Error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, importError(path, err.Error())
+	}
+
+	p := parser.New(lexer.NewFile(path, string(data)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, nil, importError(path, describeDiagnostics(p.Errors()))
+	}
+
+	r := resolver.New()
+	r.Resolve(program)
+	if len(r.Errors()) != 0 {
+		return nil, nil, importError(path, describeDiagnostics(r.Errors()))
+	}
+
+	c := checker.New(r.Definitions())
+	c.Check(program)
+	if diagnostics.HasErrors(c.Errors()) {
+		return nil, nil, importError(path, describeDiagnostics(c.Errors()))
+	}
+
+	return program, r.Locals(), nil
+}
+
+func relativePaths(paths []string) []string {
+	wd, _ := os.Getwd()
+
+	result := make([]string, len(paths))
+	for i, path := range paths {
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			path = rel
+		}
+		result[i] = path
+	}
+	return result
+}
+
+func describeDiagnostics(diags []diagnostics.Diagnostic) string {
+	messages := make([]string, len(diags))
+	for i, d := range diags {
+		messages[i] = d.Span.Start.String() + ": " + d.Message
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,98 @@
+package eval_test
+
+import (
+	"monkey/eval"
+	"monkey/eval/evaltest"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	evaltest.WriteModules(t, dir)
+
+	for _, tc := range evaltest.ImportCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := evalFile(t, filepath.Join(dir, "main.mk"), tc.Source)
+			evaltest.CheckObject(t, got, tc.Want)
+		})
+	}
+
+	for _, tc := range evaltest.ImportErrorCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := evalFile(t, filepath.Join(dir, "main.mk"), tc.Source)
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+			if !strings.Contains(err.Message, tc.Want) {
+				t.Errorf("Wrong error message, got %q, want it to contain %q.", err.Message, tc.Want)
+			}
+		})
+	}
+}
+
+func TestImportErrorStack(t *testing.T) {
+	dir := t.TempDir()
+	evaltest.WriteModules(t, dir)
+
+	got := evalFile(t, filepath.Join(dir, "main.mk"), `import "throwing.mk" as lib;`)
+
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("No error object returned, got %T (%+v).", got, got)
+	}
+
+	if err.Span.Start.File != filepath.Join(dir, "throwing.mk") || err.Span.Start.Line != 2 {
+		t.Errorf("Wrong error position, got %s.", err.Span)
+	}
+
+	if len(err.Stack) != 1 || err.Stack[0].Name() != eval.MODULE_FRAME_NAME {
+		t.Errorf("Wrong error stack, got %v.", err.Stack)
+	}
+}
+
+func TestImportPermission(t *testing.T) {
+	dir := t.TempDir()
+	evaltest.WriteModules(t, dir)
+
+	for _, tc := range evaltest.DeniedImportCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			p := parser.New(lexer.NewFile(filepath.Join(dir, "main.mk"), tc.Source))
+			program := p.ParseProgram()
+			r := resolver.New()
+			r.Resolve(program)
+
+			e := eval.New()
+			e.SetLocals(r.Locals())
+			e.SetCapabilities(eval.PURE, eval.STDOUT)
+
+			evaltest.CheckObject(t, e.Eval(program, object.NewEnvironment()), tc.Want)
+		})
+	}
+}
+
+func evalFile(t testing.TB, file string, source string) object.Object {
+	t.Helper()
+
+	p := parser.New(lexer.NewFile(file, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Error while parsing %q.", source)
+	}
+
+	r := resolver.New()
+	r.Resolve(program)
+
+	e := eval.New()
+	e.SetLocals(r.Locals())
+	e.SetCapabilities(append([]eval.Capability{eval.FILESYSTEM}, eval.DefaultCapabilities...)...)
+
+	return e.Eval(program, object.NewEnvironment())
+}
@@ -0,0 +1,244 @@
+package eval
+
+import (
+	"fmt"
+	"monkey/object"
+	"strings"
+	"unicode/utf8"
+)
+
+// String builtins work with characters, so indices and lengths
+// are counted in runes, not bytes.
+
+// MAX_STRING_LENGTH limits bytes of strings built by builtins, so a huge
+// count fails the call instead of the whole process.
+const MAX_STRING_LENGTH = 1 << 30
+
+// stringArgs unpacks arguments of builtin which takes only strings.
+func stringArgs(name string, count int, args []object.Object) ([]string, *object.Error) {
+	if len(args) != count {
+		return nil, wrongArgumentsCountError(count, len(args))
+	}
+
+	values := make([]string, count)
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, builtinTypeMismatchError(name, args...)
+		}
+		values[i] = str.Value
+	}
+
+	return values, nil
+}
+
+// stringFunction builds builtin from function of string arguments.
+func stringFunction(name string, count int, fn func(args []string) object.Object) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			values, err := stringArgs(name, count, args)
+			if err != nil {
+				return err
+			}
+			return fn(values)
+		},
+	}
+}
+
+// sized sets estimate of bytes allocated by builtin, see object.Builtin.Size.
+func sized(builtin *object.Builtin, size func(args ...object.Object) int64) *object.Builtin {
+	builtin.Size = size
+	return builtin
+}
+
+var stringBuiltins = map[string]*object.Builtin{
+	"split": sized(stringFunction("split", 2, func(args []string) object.Object {
+		parts := strings.Split(args[0], args[1])
+		elements := make([]object.Object, len(parts))
+		for i, part := range parts {
+			elements[i] = &object.String{Value: part}
+		}
+		return &object.Array{Elements: elements}
+	}), func(args ...object.Object) int64 {
+		values, err := stringArgs("split", 2, args)
+		if err != nil {
+			return 0
+		}
+		// every part is a string referred by the array, parts together are
+		// not longer than the split string
+		parts := int64(strings.Count(values[0], values[1])) + 1
+		return addSize(objectSize+int64(len(values[0])), multiplySize(parts, referenceSize+objectSize))
+	}),
+	"trim": stringFunction("trim", 1, func(args []string) object.Object {
+		return &object.String{Value: strings.TrimSpace(args[0])}
+	}),
+	"replace": sized(stringFunction("replace", 3, func(args []string) object.Object {
+		return &object.String{Value: strings.ReplaceAll(args[0], args[1], args[2])}
+	}), func(args ...object.Object) int64 {
+		values, err := stringArgs("replace", 3, args)
+		if err != nil {
+			return 0
+		}
+		grown := int64(len(values[2]) - len(values[1]))
+		if grown <= 0 {
+			return objectSize + int64(len(values[0]))
+		}
+		count := int64(strings.Count(values[0], values[1]))
+		return addSize(objectSize+int64(len(values[0])), multiplySize(count, grown))
+	}),
+	"contains": stringFunction("contains", 2, func(args []string) object.Object {
+		return boolToBooleanObject(strings.Contains(args[0], args[1]))
+	}),
+	"startsWith": stringFunction("startsWith", 2, func(args []string) object.Object {
+		return boolToBooleanObject(strings.HasPrefix(args[0], args[1]))
+	}),
+	"endsWith": stringFunction("endsWith", 2, func(args []string) object.Object {
+		return boolToBooleanObject(strings.HasSuffix(args[0], args[1]))
+	}),
+	"indexOf": stringFunction("indexOf", 2, func(args []string) object.Object {
+		i := strings.Index(args[0], args[1])
+		if i >= 0 {
+			i = utf8.RuneCountInString(args[0][:i])
+		}
+		return &object.Integer{Value: int64(i)}
+	}),
+	"upper": stringFunction("upper", 1, func(args []string) object.Object {
+		return &object.String{Value: strings.ToUpper(args[0])}
+	}),
+	"lower": stringFunction("lower", 1, func(args []string) object.Object {
+		return &object.String{Value: strings.ToLower(args[0])}
+	}),
+	"join": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return wrongArgumentsCountError(2, len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			sep, ok2 := args[1].(*object.String)
+			if !ok || !ok2 {
+				return builtinTypeMismatchError("join", args...)
+			}
+
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				parts[i] = el.Inspect()
+			}
+			return &object.String{Value: strings.Join(parts, sep.Value)}
+		},
+		// only strings are counted, other elements inspect to short values
+		Size: func(args ...object.Object) int64 {
+			if len(args) != 2 {
+				return 0
+			}
+			arr, ok := args[0].(*object.Array)
+			sep, ok2 := args[1].(*object.String)
+			if !ok || !ok2 || len(arr.Elements) == 0 {
+				return 0
+			}
+
+			size := multiplySize(int64(len(sep.Value)), int64(len(arr.Elements)-1))
+			for _, el := range arr.Elements {
+				if str, ok := el.(*object.String); ok {
+					size = addSize(size, int64(len(str.Value)))
+				}
+			}
+			return addSize(size, objectSize)
+		},
+	},
+	"substr": {
+		// substr(s, start) or substr(s, start, length)
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return wrongArgumentsCountError(3, len(args))
+			}
+			str, ok := args[0].(*object.String)
+			start, ok2 := args[1].(*object.Integer)
+			if !ok || !ok2 {
+				return builtinTypeMismatchError("substr", args...)
+			}
+
+			var high object.Object = NULL
+			if len(args) == 3 {
+				length, ok := args[2].(*object.Integer)
+				if !ok {
+					return builtinTypeMismatchError("substr", args...)
+				}
+				if length.Value < 0 {
+					return invalidNumberError("substr", length.Inspect())
+				}
+				size := utf8.RuneCountInString(str.Value)
+				from, _ := sliceBounds(size, start, NULL)
+				// clamped before adding, huge lengths would overflow
+				if length.Value > int64(size-from) {
+					length = &object.Integer{Value: int64(size - from)}
+				}
+				high = &object.Integer{Value: int64(from) + length.Value}
+			}
+			return evalSliceExpression(str, start, high)
+		},
+	},
+	"repeat": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return wrongArgumentsCountError(2, len(args))
+			}
+			str, ok := args[0].(*object.String)
+			count, ok2 := args[1].(*object.Integer)
+			if !ok || !ok2 {
+				return builtinTypeMismatchError("repeat", args...)
+			}
+			if count.Value < 0 {
+				return invalidNumberError("repeat", count.Inspect())
+			}
+			if len(str.Value) > 0 && count.Value > int64(MAX_STRING_LENGTH/len(str.Value)) {
+				return stringTooLongError("repeat")
+			}
+			return &object.String{Value: strings.Repeat(str.Value, int(count.Value))}
+		},
+		Size: func(args ...object.Object) int64 {
+			if len(args) != 2 {
+				return 0
+			}
+			str, ok := args[0].(*object.String)
+			count, ok2 := args[1].(*object.Integer)
+			if !ok || !ok2 || count.Value < 0 {
+				return 0
+			}
+			return addSize(objectSize, multiplySize(int64(len(str.Value)), count.Value))
+		},
+	},
+	"format": {
+		// format(template, args...) formats like fmt.Sprintf, e.g. "%5.2f", "%-10s", "%d%%"
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return wrongArgumentsCountError(1, len(args))
+			}
+			template, ok := args[0].(*object.String)
+			if !ok {
+				return builtinTypeMismatchError("format", args...)
+			}
+
+			values := make([]interface{}, len(args)-1)
+			for i, arg := range args[1:] {
+				values[i] = formatValue(arg)
+			}
+			return &object.String{Value: fmt.Sprintf(template.Value, values...)}
+		},
+	},
+}
+
+// formatValue converts object to Go value printed by format verbs.
+func formatValue(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	default:
+		return obj.Inspect()
+	}
+}
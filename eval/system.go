@@ -0,0 +1,118 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/object"
+	"net/http"
+	"os"
+	"time"
+)
+
+// System builtins reach files, environment and network of the host,
+// failures are reported as IOError.
+
+var filesystemBuiltins = map[string]*object.Builtin{
+	"readFile": sized(stringFunction("readFile", 1, func(args []string) object.Object {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return ioError(err)
+		}
+		return &object.String{Value: string(data)}
+	}), func(args ...object.Object) int64 {
+		values, err := stringArgs("readFile", 1, args)
+		if err != nil {
+			return 0
+		}
+		// missing files fail to be read
+		info, statErr := os.Stat(values[0])
+		if statErr != nil {
+			return 0
+		}
+		return objectSize + info.Size()
+	}),
+	"writeFile": stringFunction("writeFile", 2, func(args []string) object.Object {
+		if err := os.WriteFile(args[0], []byte(args[1]), 0o644); err != nil {
+			return ioError(err)
+		}
+		return NULL
+	}),
+}
+
+var envBuiltins = map[string]*object.Builtin{
+	// getEnv(name) returns value of environment variable or null when it is not set
+	"getEnv": stringFunction("getEnv", 1, func(args []string) object.Object {
+		value, ok := os.LookupEnv(args[0])
+		if !ok {
+			return NULL
+		}
+		return &object.String{Value: value}
+	}),
+}
+
+// httpClient limits time of requests of programs which are not cancelled.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+var networkBuiltins = map[string]*object.Builtin{
+	// fetch(url) returns body of response to GET request
+	"fetch": {Cancellable: func(ctx context.Context, args ...object.Object) object.Object {
+		values, err := stringArgs("fetch", 1, args)
+		if err != nil {
+			return err
+		}
+		return fetch(ctx, values[0])
+	}},
+}
+
+func fetch(ctx context.Context, url string) object.Object {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return ioError(err)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return ioError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return ioError(fmt.Errorf("GET %s: %s", url, resp.Status))
+	}
+
+	body, readErr := readBody(ctx, resp)
+	if readErr != nil {
+		return readErr
+	}
+	return &object.String{Value: string(body)}
+}
+
+// readBody reads body of response, length of the body is not known before,
+// so under memory limit reading stops once the body does not fit into
+// memory left to the evaluation.
+func readBody(ctx context.Context, resp *http.Response) ([]byte, *object.Error) {
+	b := contextBudget(ctx)
+	left, limited := b.memoryLeft()
+	if !limited {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, ioError(err)
+		}
+		return body, nil
+	}
+
+	// string holding the body takes some memory too
+	left -= objectSize
+	if resp.ContentLength > left {
+		return nil, b.fail(memoryLimitError(b.limits.MaxMemory))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, left+1))
+	if err != nil {
+		return nil, ioError(err)
+	}
+	if int64(len(body)) > left {
+		return nil, b.fail(memoryLimitError(b.limits.MaxMemory))
+	}
+	return body, nil
+}
@@ -0,0 +1,542 @@
+// Package format prints monkey programs in canonical form. Blocks and class
+// bodies are indented with two spaces, binary operators are surrounded by
+// single spaces and only parentheses required by precedence are kept.
+// Comments and single blank lines between statements are preserved.
+package format
+
+import (
+	"bytes"
+	"math"
+	"monkey/ast"
+	"monkey/diagnostics"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+const indentation = "  "
+
+// Source returns formatted source, or syntax errors when source
+// can not be parsed.
+func Source(file string, source string) (string, []diagnostics.Diagnostic) {
+	l := lexer.NewFile(file, source)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", p.Errors()
+	}
+
+	pr := &printer{comments: l.Comments()}
+	pr.statements(program.Statements, math.MaxInt)
+
+	// every statement is printed on a new line
+	out := strings.TrimPrefix(string(pr.buf), "\n")
+	if out != "" {
+		out += "\n"
+	}
+	return out, nil
+}
+
+type printer struct {
+	buf    []byte
+	indent int
+
+	// comments which are not printed yet, in source order
+	comments []token.Token
+
+	// source line of the last printed statement or comment,
+	// it is 0 at the start of a list of statements
+	line int
+}
+
+func (p *printer) write(parts ...string) {
+	for _, part := range parts {
+		p.buf = append(p.buf, part...)
+	}
+}
+
+func (p *printer) newline() {
+	p.buf = append(p.buf, '\n')
+	for i := 0; i < p.indent; i++ {
+		p.buf = append(p.buf, indentation...)
+	}
+}
+
+// startLine begins new line for an item at source line, a single blank line
+// separating the item from the previous one in the source is kept.
+func (p *printer) startLine(line int) {
+	if line < p.line {
+		// comment moved out of an expression spanning several lines
+		line = p.line
+	}
+	if p.line > 0 && line > p.line+1 {
+		p.buf = append(p.buf, '\n')
+	}
+	p.newline()
+	p.line = line
+}
+
+func (p *printer) hasComments(before int) bool {
+	return len(p.comments) > 0 && p.comments[0].Start.Offset < before
+}
+
+// leadingComments prints comments preceding offset, each on its own line.
+func (p *printer) leadingComments(before int) {
+	for p.hasComments(before) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		p.startLine(c.Start.Line)
+		p.write(c.Literal)
+	}
+}
+
+// trailingComments prints comments following end on the same line.
+func (p *printer) trailingComments(end token.Position) {
+	for len(p.comments) > 0 && p.comments[0].Start.Line == end.Line && p.comments[0].Start.Offset >= end.Offset {
+		p.write(" ", p.comments[0].Literal)
+		p.comments = p.comments[1:]
+	}
+}
+
+// openingComments prints comments following opening delimiter which ends
+// at open on the same line, comments after the first item starting at
+// first belong to the item.
+func (p *printer) openingComments(open token.Position, first int) {
+	for p.hasComments(first) && p.comments[0].Start.Line == open.Line {
+		p.write(" ", p.comments[0].Literal)
+		p.comments = p.comments[1:]
+	}
+}
+
+// innerComments prints comments preceding offset inside of a line of code
+// after the token they follow, the code continues on the next line which
+// is indented once more.
+func (p *printer) innerComments(before int) {
+	if !p.hasComments(before) {
+		return
+	}
+
+	p.buf = bytes.TrimRight(p.buf, " ")
+	p.indent++
+	p.write(" ", p.comments[0].Literal)
+	p.comments = p.comments[1:]
+	for p.hasComments(before) {
+		p.newline()
+		p.write(p.comments[0].Literal)
+		p.comments = p.comments[1:]
+	}
+	p.newline()
+	p.indent--
+}
+
+// statements prints every statement on its own line, comments are flushed
+// up to offset which is the position of the closing brace of the list.
+func (p *printer) statements(stmts []ast.Statement, offset int) {
+	p.line = 0
+	semicolon := -1
+
+	for _, stmt := range stmts {
+		p.leadingComments(stmt.Pos().Offset)
+		p.startLine(stmt.Pos().Line)
+
+		start := len(p.buf)
+		p.statement(stmt)
+
+		// if statement is printed without semicolon, it is added back
+		// when the next statement would continue the if expression
+		if semicolon >= 0 && strings.IndexByte("([-", p.buf[start]) >= 0 {
+			p.buf = append(p.buf[:semicolon+1], p.buf[semicolon:]...)
+			p.buf[semicolon] = ';'
+		}
+		semicolon = -1
+		if isIfStmt(stmt) {
+			semicolon = len(p.buf)
+		}
+
+		p.line = stmt.End().Line
+		p.trailingComments(stmt.End())
+	}
+
+	p.leadingComments(offset)
+}
+
+func isIfStmt(stmt ast.Statement) bool {
+	if s, ok := stmt.(*ast.ExpressionStmt); ok {
+		_, ok := s.Expression.(*ast.IfExpr)
+		return ok
+	}
+	return false
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.LetStmt:
+		if fn, ok := s.Value.(*ast.FunctionExpr); ok && s.Token.Start == fn.Token.Start {
+			// fn name() {} definition
+			p.write("fn ", s.Name.Value)
+			p.function(fn)
+			return
+		}
+		p.write("let ", s.Name.Value)
+		p.annotation(s.Type)
+		if s.Value != nil {
+			p.write(" = ")
+			p.expression(s.Value, parser.LOWEST)
+		}
+		p.write(";")
+
+	case *ast.ReturnStmt:
+		p.write("return")
+		if s.Value != nil {
+			p.write(" ")
+			p.expression(s.Value, parser.LOWEST)
+		}
+		p.write(";")
+
+	case *ast.ExpressionStmt:
+		p.expression(s.Expression, parser.LOWEST)
+		if !isIfStmt(s) {
+			p.write(";")
+		}
+
+	case *ast.BlockStmt:
+		p.block(s)
+
+	case *ast.ClassStmt:
+		header := s.Name
+		p.write("class ", s.Name.Value)
+		if s.Superclass != nil {
+			header = s.Superclass
+			p.write(" < ", s.Superclass.Value)
+		}
+		p.write(" ")
+
+		methods := make([]ast.Statement, len(s.Methods))
+		for i, m := range s.Methods {
+			methods[i] = m
+		}
+		p.braces(header.End(), methods, s.Rbrace)
+
+	case *ast.WhileStmt:
+		p.write("while (")
+		p.expression(s.Condition, parser.LOWEST)
+		p.write(") ")
+		p.statement(s.Body)
+
+	case *ast.ForStmt:
+		p.write("for (")
+		if s.Init != nil {
+			p.statement(s.Init)
+		} else {
+			p.write(";")
+		}
+		if s.Condition != nil {
+			p.write(" ")
+			p.expression(s.Condition, parser.LOWEST)
+		}
+		p.write(";")
+		if s.Update != nil {
+			p.write(" ")
+			p.expression(s.Update, parser.LOWEST)
+		}
+		p.write(") ")
+		p.statement(s.Body)
+
+	case *ast.ForInStmt:
+		p.write("for (", s.Variable.Value, " in ")
+		p.expression(s.Iterable, parser.LOWEST)
+		p.write(") ")
+		p.statement(s.Body)
+
+	case *ast.BreakStmt:
+		p.write("break;")
+
+	case *ast.ContinueStmt:
+		p.write("continue;")
+
+	case *ast.ImportStmt:
+		p.write("import ", s.Path.String(), " as ", s.Name.Value, ";")
+
+	case *ast.ThrowStmt:
+		p.write("throw ")
+		p.expression(s.Value, parser.LOWEST)
+		p.write(";")
+
+	case *ast.TryStmt:
+		p.write("try ")
+		p.block(s.Body)
+		if s.Catch != nil {
+			p.write(" catch (", s.Param.Value, ") ")
+			p.block(s.Catch)
+		}
+		if s.Finally != nil {
+			p.write(" finally ")
+			p.block(s.Finally)
+		}
+	}
+}
+
+func (p *printer) block(b *ast.BlockStmt) {
+	p.braces(b.Token.End, b.Statements, b.Rbrace)
+}
+
+// braces prints indented statements between braces, open is the end of
+// the line where the opening brace is.
+func (p *printer) braces(open token.Position, stmts []ast.Statement, rbrace token.Position) {
+	if len(stmts) == 0 && !p.hasComments(rbrace.Offset) {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	first := rbrace.Offset
+	if len(stmts) > 0 {
+		first = stmts[0].Pos().Offset
+	}
+	p.openingComments(open, first)
+
+	p.indent++
+	p.statements(stmts, rbrace.Offset)
+	p.indent--
+
+	p.newline()
+	p.write("}")
+}
+
+func (p *printer) function(fn *ast.FunctionExpr) {
+	p.write("(")
+	for i, param := range fn.Parameters {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.innerComments(param.Pos().Offset)
+		p.write(param.Value)
+		p.annotation(fn.ParameterType(i))
+	}
+	p.write(")")
+	p.annotation(fn.ReturnType)
+	p.write(" ")
+	p.block(fn.Body)
+}
+
+func (p *printer) annotation(t *ast.TypeAnnotation) {
+	if t != nil {
+		p.write(": ", t.Name)
+	}
+}
+
+// precedence returns how tightly expression binds its operands, atoms and
+// postfix expressions like calls never need parentheses around them.
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpr:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpr:
+		return parser.PREFIX
+	case *ast.AssignExpr, *ast.SetExpr, *ast.IndexSetExpr:
+		return parser.ASSIGN
+	case *ast.IfExpr:
+		// branches which are not blocks would swallow following operators
+		return parser.LOWEST
+	default:
+		return parser.CALL
+	}
+}
+
+// expression prints e, wrapping it in parentheses when it binds looser than min.
+func (p *printer) expression(e ast.Expression, min int) {
+	p.innerComments(e.Pos().Offset)
+
+	if precedence(e) < min {
+		p.write("(")
+		p.expression(e, parser.LOWEST)
+		p.write(")")
+		return
+	}
+
+	switch e := e.(type) {
+	case *ast.IdentifierExpr:
+		p.write(e.Value)
+
+	case *ast.IntLiteralExpr, *ast.FloatLiteralExpr, *ast.BoolLiteralExpr, *ast.NullExpr, *ast.ThisExpr:
+		p.write(e.TokenLiteral())
+
+	case *ast.SuperExpr:
+		p.write("super.", e.Method.Value)
+
+	case *ast.StringLiteralExpr:
+		p.write(e.String())
+
+	case *ast.TemplateExpr:
+		p.write(`"`)
+		for i, text := range e.Texts {
+			p.write(text.TokenLiteral())
+			if i < len(e.Exprs) {
+				p.write("${")
+				p.expression(e.Exprs[i], parser.LOWEST)
+				p.write("}")
+			}
+		}
+		p.write(`"`)
+
+	case *ast.ArrayLiteralExpr:
+		p.list("[", "]", e.Token, p.expressions(e.Elements), e.Rbracket)
+
+	case *ast.HashLiteralExpr:
+		pairs := make([]element, len(e.Pairs))
+		for i, pair := range e.Pairs {
+			pair := pair
+			pairs[i] = element{pair.Key.Pos(), pair.Value.End(), func() {
+				p.expression(pair.Key, parser.LOWEST)
+				p.write(": ")
+				p.expression(pair.Value, parser.LOWEST)
+			}}
+		}
+		p.list("{|", "|}", e.Token, pairs, e.Rbrace)
+
+	case *ast.PrefixExpr:
+		p.write(e.Operator)
+		p.expression(e.Right, parser.PREFIX)
+
+	case *ast.InfixExpr:
+		prec := parser.Precedence(e.Token.Type)
+		left, right := prec, prec+1
+		if e.Token.Type == token.POWER {
+			// right associative
+			left, right = prec+1, prec
+		}
+		if _, ok := e.Right.(*ast.PrefixExpr); ok {
+			// operand of prefix operator never extends to the left
+			right = parser.PREFIX
+		}
+		p.expression(e.Left, left)
+		p.write(" ", e.Operator, " ")
+		p.expression(e.Right, right)
+
+	case *ast.AssignExpr:
+		p.write(e.Identifier.Value, " ", e.TokenLiteral(), " ")
+		p.expression(e.Expression, parser.LOWEST)
+
+	case *ast.SetExpr:
+		p.expression(e.Expression, parser.CALL)
+		p.write(".", e.Field.Value, " ", e.TokenLiteral(), " ")
+		p.expression(e.Value, parser.LOWEST)
+
+	case *ast.IndexSetExpr:
+		p.expression(e.Left, parser.CALL)
+		p.write("[")
+		p.expression(e.Index, parser.LOWEST)
+		p.write("] ", e.TokenLiteral(), " ")
+		p.expression(e.Value, parser.LOWEST)
+
+	case *ast.IfExpr:
+		p.write("if (")
+		p.expression(e.Condition, parser.LOWEST)
+		p.write(") ")
+		p.statement(e.Then)
+		if e.Else != nil {
+			p.write(" else ")
+			p.statement(e.Else)
+		}
+
+	case *ast.FunctionExpr:
+		p.write("fn")
+		p.function(e)
+
+	case *ast.CallExpr:
+		p.expression(e.Function, parser.CALL)
+		p.list("(", ")", e.Token, p.expressions(e.Arguments), e.Rparen)
+
+	case *ast.IndexExpr:
+		p.expression(e.Left, parser.CALL)
+		p.write("[")
+		p.expression(e.Index, parser.LOWEST)
+		p.write("]")
+
+	case *ast.SliceExpr:
+		p.expression(e.Left, parser.CALL)
+		p.write("[")
+		if e.Low != nil {
+			p.expression(e.Low, parser.LOWEST)
+		}
+		p.write(":")
+		if e.High != nil {
+			p.expression(e.High, parser.LOWEST)
+		}
+		p.write("]")
+
+	case *ast.GetExpr:
+		p.expression(e.Expression, parser.CALL)
+		p.write(".", e.Field.Value)
+	}
+}
+
+// element is an item of comma separated list.
+type element struct {
+	start, end token.Position
+	print      func()
+}
+
+func (p *printer) expressions(exprs []ast.Expression) []element {
+	elements := make([]element, len(exprs))
+	for i, e := range exprs {
+		e := e
+		elements[i] = element{e.Pos(), e.End(), func() { p.expression(e, parser.LOWEST) }}
+	}
+	return elements
+}
+
+// list prints elements between delimiters. When the first element starts
+// on a line after the opening delimiter or the list contains comments,
+// every element is put on its own line and followed by a comma, so the
+// comments stay with elements they follow.
+func (p *printer) list(open, close string, opening token.Token, elements []element, closing token.Position) {
+	commented := p.hasComments(closing.Offset) && p.comments[0].Start.Offset > opening.Start.Offset
+	multiline := len(elements) > 0 && elements[0].start.Line > opening.End.Line || commented
+
+	p.write(open)
+
+	if !multiline {
+		spaced := open == "{|" && len(elements) > 0
+		if spaced {
+			p.write(" ")
+		}
+		for i, el := range elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			el.print()
+		}
+		if spaced {
+			p.write(" ")
+		}
+		p.write(close)
+		return
+	}
+
+	first := closing.Offset
+	if len(elements) > 0 {
+		first = elements[0].start.Offset
+	}
+	p.openingComments(opening.End, first)
+
+	line := p.line
+	p.line = 0
+	p.indent++
+	for _, el := range elements {
+		p.leadingComments(el.start.Offset)
+		p.startLine(el.start.Line)
+		el.print()
+		p.write(",")
+		p.line = el.end.Line
+		p.trailingComments(el.end)
+	}
+	p.leadingComments(closing.Offset)
+	p.indent--
+	p.line = line
+
+	p.newline()
+	p.write(close)
+}
@@ -0,0 +1,199 @@
+package format_test
+
+import (
+	"monkey/eval"
+	"monkey/eval/evaltest"
+	"monkey/format"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"testing"
+)
+
+func formatSource(t *testing.T, source string) string {
+	t.Helper()
+
+	formatted, errors := format.Source("", source)
+	if len(errors) != 0 {
+		t.Fatalf("Can not format %q: %s", source, errors[0])
+	}
+
+	again, errors := format.Source("", formatted)
+	if len(errors) != 0 {
+		t.Fatalf("Can not parse formatted %q: %s", formatted, errors[0])
+	}
+	if again != formatted {
+		t.Fatalf("Formatting is not idempotent, got\n%s\nthen\n%s", formatted, again)
+	}
+
+	return formatted
+}
+
+func TestFormat(t *testing.T) {
+	tt := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"empty", "", ""},
+		{"spacing", "let   x=1+2*3;x+=-1", "let x = 1 + 2 * 3;\nx += -1;\n"},
+		{"statement per line", "let a = 1; let b; return;", "let a = 1;\nlet b;\nreturn;\n"},
+		{
+			"parentheses",
+			"(1 + 2) * 3; 1 + (2 * 3); (1 - 2) - 3; 1 - (2 - 3); -(a + b); (-a).b; (a = 1) + 2;",
+			"(1 + 2) * 3;\n1 + 2 * 3;\n1 - 2 - 3;\n1 - (2 - 3);\n-(a + b);\n(-a).b;\n(a = 1) + 2;\n",
+		},
+		{
+			"power",
+			"-2 ** 2; (-2) ** 2; 2 ** 3 ** 2; (2 ** 3) ** 2; 2 ** -1;",
+			"-2 ** 2;\n(-2) ** 2;\n2 ** 3 ** 2;\n(2 ** 3) ** 2;\n2 ** -1;\n",
+		},
+		{"postfix", "a.b(1,2)[0][1:]; (a.b)[:2]; a[0] *= 2; a.b.c = 1;", "a.b(1, 2)[0][1:];\na.b[:2];\na[0] *= 2;\na.b.c = 1;\n"},
+		{"literals", "[1,2.5,true,null,\"s\\n\",`r\\n`]; {|\"a\":1,2:[ ]|}; {| |};", "[1, 2.5, true, null, \"s\\n\", `r\\n`];\n{| \"a\": 1, 2: [] |};\n{||};\n"},
+		{"template", `"a ${ x+1 } b ${"${y}"}";`, `"a ${x + 1} b ${"${y}"}";` + "\n"},
+		{
+			"functions",
+			"fn add(a,b){return a+b;} let f = fn(){}; let g = fn(x){x}(1);",
+			"fn add(a, b) {\n  return a + b;\n}\nlet f = fn() {};\nlet g = fn(x) {\n  x;\n}(1);\n",
+		},
+		{
+			"annotations",
+			"let x:int=1; let p :Point; fn add(a:int,b):float{a+b} let f = fn():fn{};",
+			"let x: int = 1;\nlet p: Point;\nfn add(a: int, b): float {\n  a + b;\n}\nlet f = fn(): fn {};\n",
+		},
+		{
+			"class",
+			"class A < B { fn init(x) { super.init(); this.x = x; } let m = fn() { this.x; }; }\nclass C {}",
+			"class A < B {\n  fn init(x) {\n    super.init();\n    this.x = x;\n  }\n  let m = fn() {\n    this.x;\n  };\n}\nclass C {}\n",
+		},
+		{
+			"if",
+			"if (a) { b } else if (c) { d } else { e }\nif (a) b; else c;\nlet x = if (a) { 1 } else { 2 } + 1;",
+			"if (a) {\n  b;\n} else if (c) {\n  d;\n} else {\n  e;\n}\nif (a) b; else c;\nlet x = (if (a) {\n  1;\n} else {\n  2;\n}) + 1;\n",
+		},
+		{
+			"if followed by expression",
+			"if (a) { b };\n(c + d).e(); if (a) {} ; [1]; if (a) {}; -1;",
+			"if (a) {\n  b;\n};\n(c + d).e();\nif (a) {};\n[1];\nif (a) {};\n-1;\n",
+		},
+		{
+			"loops",
+			"while(x>0){x-=1;} for(let i=0;i<3;i+=1) puts(i); for(;;){break;} for(;i;){continue;} for(x in [1]){}",
+			"while (x > 0) {\n  x -= 1;\n}\nfor (let i = 0; i < 3; i += 1) puts(i);\nfor (;;) {\n  break;\n}\nfor (; i;) {\n  continue;\n}\nfor (x in [1]) {}\n",
+		},
+		{
+			"try",
+			"try{throw 1;}catch(e){puts(e);}finally{} try {} finally {}",
+			"try {\n  throw 1;\n} catch (e) {\n  puts(e);\n} finally {}\ntry {} finally {}\n",
+		},
+		{"import", `import   "lib.mk"   as   lib;`, `import "lib.mk" as lib;` + "\n"},
+		{
+			"blank lines",
+			"\n\nlet a = 1;\n\n\n\nlet b = 2;\nlet c = 3;\nfn f() {\n\n  a;\n\n  b;\n\n}\n\n",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\nfn f() {\n  a;\n\n  b;\n}\n",
+		},
+		{
+			"multiline lists",
+			"let a = [\n1, 2,\n3];\nf(\n  x,\n  y\n);\nlet h = {|\n\"a\": 1\n|};\nf(a, fn() {\nb;\n});",
+			"let a = [\n  1,\n  2,\n  3,\n];\nf(\n  x,\n  y,\n);\nlet h = {|\n  \"a\": 1,\n|};\nf(a, fn() {\n  b;\n});\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := formatSource(t, tc.source); got != tc.want {
+				t.Errorf("Wrong formatting of %q, got\n%s\nwant\n%s", tc.source, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	tt := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"only comments", "// a\n\n\n// b", "// a\n\n// b\n"},
+		{"leading and trailing", "// head\nlet x = 1;   // x  \nlet y = 2;", "// head\nlet x = 1; // x\nlet y = 2;\n"},
+		{
+			"blocks",
+			"fn f() { // f\n  // first\n  a; // a\n  // last\n}\nfn g() {\n// todo\n}",
+			"fn f() { // f\n  // first\n  a; // a\n  // last\n}\nfn g() {\n  // todo\n}\n",
+		},
+		{
+			"class",
+			"class A { // A\n// m\nfn m() {}\n\n  // end\n}",
+			"class A { // A\n  // m\n  fn m() {}\n\n  // end\n}\n",
+		},
+		{
+			"lists",
+			"let a = [ // a\n  1, // one\n  // two\n  2\n  // end\n];\nlet h = {|\n  // empty\n|};",
+			"let a = [ // a\n  1, // one\n  // two\n  2,\n  // end\n];\nlet h = {|\n  // empty\n|};\n",
+		},
+		{
+			"after element on the first line",
+			"let h = {| \"a\": 1, // key a\n  \"b\": [2, // two\n 3] |};\nlet x = 1 + // one\n  [2];",
+			"let h = {|\n  \"a\": 1, // key a\n  \"b\": [\n    2, // two\n    3,\n  ],\n|};\nlet x = 1 + // one\n  [2];\n",
+		},
+		{
+			"inside expression",
+			"let x = 1 + // one\n  2;\nlet y = 3;",
+			"let x = 1 + // one\n  2;\nlet y = 3;\n",
+		},
+		{
+			"after operator",
+			"1 + // plus\n2;\nlet y = // first\n  // second\n  3;",
+			"1 + // plus\n  2;\nlet y = // first\n  // second\n  3;\n",
+		},
+		{
+			"after parameter",
+			"fn g(a, // first\nb) { a + b; }",
+			"fn g(a, // first\n  b) {\n  a + b;\n}\n",
+		},
+		{
+			"after first statement of block",
+			"while (x < 3) { x += 1; // c\n}\nif (x) { y; // d\n z; }",
+			"while (x < 3) {\n  x += 1; // c\n}\nif (x) {\n  y; // d\n  z;\n}\n",
+		},
+		{"in strings", "let s = \"// no\"; let r = `// no`;", "let s = \"// no\";\nlet r = `// no`;\n"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := formatSource(t, tc.source); got != tc.want {
+				t.Errorf("Wrong formatting of %q, got\n%s\nwant\n%s", tc.source, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	formatted, errors := format.Source("f.mk", "let x = ;")
+	if len(errors) == 0 || formatted != "" {
+		t.Fatalf("Expect syntax errors, got %q.", formatted)
+	}
+	if errors[0].Span.Start.File != "f.mk" {
+		t.Errorf("Wrong error position %s.", errors[0].Span.Start)
+	}
+}
+
+// TestSemantics checks that formatted programs of the shared test cases
+// evaluate to the same values.
+func TestSemantics(t *testing.T) {
+	for _, tc := range evaltest.Cases {
+		formatted := formatSource(t, tc.Source)
+
+		p := parser.New(lexer.New(formatted))
+		program := p.ParseProgram()
+
+		r := resolver.New()
+		r.Resolve(program)
+
+		e := eval.New()
+		e.SetLocals(r.Locals())
+
+		evaltest.CheckObject(t, e.Eval(program, object.NewEnvironment()), tc.Want)
+	}
+}
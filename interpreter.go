@@ -0,0 +1,156 @@
+// Package monkey embeds the Monkey language into Go programs.
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/checker"
+	"monkey/diagnostics"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"os"
+	"strings"
+)
+
+// SyntaxError is returned when source code can not be parsed, resolved
+// or type checked, nothing is evaluated in that case.
+type SyntaxError struct {
+	Diagnostics []diagnostics.Diagnostic
+}
+
+func (e *SyntaxError) Error() string {
+	messages := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		messages[i] = d.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// RuntimeError is returned when evaluated program fails.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Inspect()
+}
+
+// Interpreter evaluates source code with the tree-walking evaluator,
+// globals defined by one call are visible to the following ones.
+//
+// Interpreters do not share any state, but a single Interpreter must not be
+// used from several goroutines at once.
+type Interpreter struct {
+	env       *object.Environment
+	evaluator *eval.Evaluator
+}
+
+func New() *Interpreter {
+	return &Interpreter{
+		env:       object.NewEnvironment(),
+		evaluator: eval.New(),
+	}
+}
+
+// Eval evaluates source and returns value of its last statement.
+func (i *Interpreter) Eval(source string) (object.Object, error) {
+	return i.run(context.Background(), lexer.New(source))
+}
+
+// EvalContext evaluates source like Eval, evaluation fails with
+// eval.CANCELLED_ERROR or eval.TIMEOUT_ERROR when ctx is done.
+func (i *Interpreter) EvalContext(ctx context.Context, source string) (object.Object, error) {
+	return i.run(ctx, lexer.New(source))
+}
+
+// EvalFile evaluates named script, its imports are relative to the script directory.
+func (i *Interpreter) EvalFile(name string) (object.Object, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return i.run(context.Background(), lexer.NewFile(name, string(data)))
+}
+
+func (i *Interpreter) run(ctx context.Context, l *lexer.Lexer) (object.Object, error) {
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &SyntaxError{Diagnostics: p.Errors()}
+	}
+
+	// every source is resolved on its own, globals defined by previous
+	// ones are looked up in the environment while evaluating
+	r := resolver.New()
+	r.Resolve(program)
+	if len(r.Errors()) != 0 {
+		return nil, &SyntaxError{Diagnostics: r.Errors()}
+	}
+
+	c := checker.New(r.Definitions())
+	c.Check(program)
+	if diagnostics.HasErrors(c.Errors()) {
+		return nil, &SyntaxError{Diagnostics: c.Errors()}
+	}
+
+	i.evaluator.SetLocals(r.Locals())
+
+	result := i.evaluator.EvalContext(ctx, program, i.env)
+	if err, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+
+	return result, nil
+}
+
+// Set defines global variable, replacing previous value of the same name.
+func (i *Interpreter) Set(name string, value object.Object) {
+	i.env.Set(name, value)
+}
+
+// Get returns value of global variable.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.GetGlobal(name)
+}
+
+// Register makes Go function callable by evaluated code,
+// it hides builtin function of the same name.
+func (i *Interpreter) Register(name string, fn object.BuiltinFunction) {
+	i.Set(name, &object.Builtin{Fn: fn})
+}
+
+// SetMaxCallDepth limits number of nested calls, deeper calls fail with
+// RecursionError. Calls in return statements do not count.
+func (i *Interpreter) SetMaxCallDepth(depth int) {
+	i.evaluator.SetMaxCallDepth(depth)
+}
+
+// SetCapabilities grants scripts builtins of given capabilities only, e.g.
+// eval.PURE for untrusted code, calls of other builtins fail with
+// eval.PERMISSION_ERROR. Imports need eval.FILESYSTEM. Functions added by
+// Register are not restricted. New interpreters are granted eval.DefaultCapabilities.
+func (i *Interpreter) SetCapabilities(granted ...eval.Capability) {
+	i.evaluator.SetCapabilities(granted...)
+}
+
+// SetStdout redirects output of print and println, new interpreters
+// print to os.Stdout.
+func (i *Interpreter) SetStdout(out io.Writer) {
+	i.evaluator.SetStdout(out)
+}
+
+// SetLimits bounds steps, time and memory of every following evaluation,
+// exceeded limits fail it with RuntimeError of their own kind.
+func (i *Interpreter) SetLimits(limits eval.Limits) {
+	i.evaluator.SetLimits(limits)
+}
+
+// NewError creates error for registered functions to return, evaluated
+// code can catch it as an exception of given kind, e.g. eval.VALUE_ERROR.
+func NewError(kind string, format string, args ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,214 @@
+package monkey_test
+
+import (
+	"context"
+	"errors"
+	"monkey"
+	"monkey/eval"
+	"monkey/eval/evaltest"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpreterEval(t *testing.T) {
+	interp := monkey.New()
+
+	steps := []evaltest.Case{
+		{Source: "let x = 10;", Want: int64(10)},
+		{Source: "fn double(n) { n * 2 }", Want: &evaltest.ExpectFn{Params: []string{"n"}, Body: "{ (n * 2); }"}},
+		{Source: "double(x) + y", Want: int64(25)},
+		{Source: "x = x + y; y = 0; x + y", Want: int64(15)},
+	}
+
+	interp.Set("y", &object.Integer{Value: 5})
+
+	for _, step := range steps {
+		got, err := interp.Eval(step.Source)
+		if err != nil {
+			t.Fatalf("Unexpected error while evaluating %q: %s", step.Source, err)
+		}
+		evaltest.CheckObject(t, got, step.Want)
+	}
+
+	x, ok := interp.Get("x")
+	if !ok {
+		t.Fatal("Global 'x' is not defined.")
+	}
+	evaltest.CheckObject(t, x, int64(15))
+}
+
+func TestInterpreterRegister(t *testing.T) {
+	interp := monkey.New()
+
+	var printed []string
+	interp.Register("print", func(args ...object.Object) object.Object {
+		for _, arg := range args {
+			printed = append(printed, arg.Inspect())
+		}
+		return eval.NULL
+	})
+	interp.Register("half", func(args ...object.Object) object.Object {
+		n, ok := args[0].(*object.Integer)
+		if !ok || n.Value%2 != 0 {
+			return monkey.NewError(eval.VALUE_ERROR, "can not halve %s", args[0].Inspect())
+		}
+		return &object.Integer{Value: n.Value / 2}
+	})
+
+	got, err := interp.Eval(`print(half(10)); try { half(3) } catch (e) { e.kind + ": " + e.message }`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	evaltest.CheckObject(t, got, "ValueError: can not halve 3")
+
+	if len(printed) != 1 || printed[0] != "5" {
+		t.Errorf("Wrong printed values, got %v.", printed)
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	interp := monkey.New()
+
+	_, err := interp.Eval("let x = ;")
+	var syntaxErr *monkey.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Expect SyntaxError, got %T (%v).", err, err)
+	}
+	if len(syntaxErr.Diagnostics) == 0 {
+		t.Error("SyntaxError has no diagnostics.")
+	}
+
+	_, err = interp.Eval(`fn half(n: int) { n / 2 } half("4");`)
+	if !errors.As(err, &syntaxErr) || syntaxErr.Diagnostics[0].Code != "T006" {
+		t.Errorf("Expect type error, got %T (%v).", err, err)
+	}
+
+	// mismatch of inferred types is only a warning, the code may handle it
+	got, err := interp.Eval(`try { let x = 1 + "a"; } catch (e) { e.kind }`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	evaltest.CheckObject(t, got, "TypeError")
+
+	_, err = interp.Eval("let y = 1;\n[1][y];")
+	var runtimeErr *monkey.RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("Expect RuntimeError, got %T (%v).", err, err)
+	}
+	if runtimeErr.Err.Kind != eval.INDEX_ERROR {
+		t.Errorf("Wrong error kind, got %q.", runtimeErr.Err.Kind)
+	}
+	if want := "Runtime error at 2:1: out of bounds: ARRAY[1]"; err.Error() != want {
+		t.Errorf("Wrong error message, got %q, want %q.", err.Error(), want)
+	}
+
+	// failed evaluation keeps globals defined before the error
+	got, err = interp.Eval("y + 1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	evaltest.CheckObject(t, got, int64(2))
+}
+
+func TestInterpreterLimits(t *testing.T) {
+	interp := monkey.New()
+	interp.SetLimits(eval.Limits{MaxSteps: 10000})
+
+	_, err := interp.Eval("let n = 0; while (true) { n = n + 1; }")
+	var runtimeErr *monkey.RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != eval.STEP_LIMIT_ERROR {
+		t.Fatalf("Expect step limit error, got %T (%v).", err, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = interp.EvalContext(ctx, "while (true) {}")
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != eval.CANCELLED_ERROR {
+		t.Fatalf("Expect cancelled error, got %T (%v).", err, err)
+	}
+
+	// every evaluation has its own budget
+	got, err := interp.Eval("n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if n := got.(*object.Integer).Value; n <= 0 || n >= 10000 {
+		t.Errorf("Wrong number of iterations, got %d.", n)
+	}
+}
+
+func TestInterpreterCapabilities(t *testing.T) {
+	interp := monkey.New()
+	interp.SetCapabilities(eval.PURE)
+	interp.Register("double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+
+	got, err := interp.Eval("double(len([1, 2]))")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	evaltest.CheckObject(t, got, int64(4))
+
+	_, err = interp.Eval(`println("hi");`)
+	var runtimeErr *monkey.RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != eval.PERMISSION_ERROR {
+		t.Fatalf("Expect permission error, got %T (%v).", err, err)
+	}
+	if want := "permission denied: 'println' needs stdout capability"; runtimeErr.Err.Message != want {
+		t.Errorf("Wrong error message, got %q, want %q.", runtimeErr.Err.Message, want)
+	}
+}
+
+func TestInterpretersAreIndependent(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.mk")
+	main := filepath.Join(dir, "main.mk")
+	if err := os.WriteFile(lib, []byte("let count = 0; fn bump() { count = count + 1; }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(main, []byte(`import "lib.mk" as lib; lib.bump() + x;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := monkey.New(), monkey.New()
+	a.SetCapabilities(eval.AllCapabilities...)
+	b.SetCapabilities(eval.AllCapabilities...)
+	a.Set("x", &object.Integer{Value: 1})
+	b.Set("x", &object.Integer{Value: 100})
+
+	for _, tc := range []struct {
+		interp *monkey.Interpreter
+		want   int64
+	}{{a, 2}, {b, 101}, {a, 3}} {
+		got, err := tc.interp.EvalFile(main)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		evaltest.CheckObject(t, got, tc.want)
+	}
+}
+
+func TestInterpreterStdout(t *testing.T) {
+	var a, b strings.Builder
+	first, second := monkey.New(), monkey.New()
+	first.SetStdout(&a)
+	second.SetStdout(&b)
+
+	if _, err := first.Eval(`print("a", 1); println([2]);`); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := second.Eval(`map([1, 2], println);`); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if got, want := a.String(), "a, 1[2]\n"; got != want {
+		t.Errorf("Wrong output of the first interpreter, got %q, want %q.", got, want)
+	}
+	if got, want := b.String(), "1\n2\n"; got != want {
+		t.Errorf("Wrong output of the second interpreter, got %q, want %q.", got, want)
+	}
+}
@@ -1,34 +1,91 @@
 package lexer
 
-import "monkey/token"
+import (
+	"monkey/token"
+	"strings"
+)
 
 type Lexer struct {
 	input        string
+	file         string
 	position     int
 	readPosition int
 	ch           byte
+
+	// line and column of ch
+	line   int
+	column int
+
+	// templates holds depth of braces nested in every embedded expression
+	// of string templates being read, innermost last
+	templates []int
+
+	// comments skipped so far, in source order
+	comments []token.Token
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer which marks every token position with file name.
+func NewFile(file string, input string) *Lexer {
+	l := &Lexer{input: input, file: file, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespaceAndComments()
 
+	start := l.pos()
+	tok := l.readToken()
+	tok.Start = start
+	tok.End = l.pos()
+
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '+':
-		tok = makeToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.PLUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = makeToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = makeToken(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.MINUS_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = makeToken(token.MINUS, l.ch)
+		}
 	case '*':
-		tok = makeToken(token.STAR, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.STAR_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '*' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.POWER, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = makeToken(token.STAR, l.ch)
+		}
 	case '/':
-		tok = makeToken(token.SLASH, l.ch)
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SLASH_ASSIGN, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = makeToken(token.SLASH, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -50,6 +107,10 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.LESS_EQUAL, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '<' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SHIFT_LEFT, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = makeToken(token.LESS, l.ch)
 		}
@@ -58,6 +119,10 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.GREATER_EQUAL, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SHIFT_RIGHT, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = makeToken(token.GREATER, l.ch)
 		}
@@ -67,7 +132,7 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			tok = token.Token{Type: token.AND, Literal: string(ch) + string(l.ch)}
 		} else {
-			tok = makeToken(token.ILLEGAL, l.ch)
+			tok = makeToken(token.BIT_AND, l.ch)
 		}
 	case '|':
 		ch := l.ch
@@ -77,14 +142,24 @@ func (l *Lexer) NextToken() token.Token {
 		} else if l.peekChar() == '}' {
 			l.readChar()
 			tok = token.Token{Type: token.RHASHBRACE, Literal: string(ch) + string(l.ch)}
+			l.closeBrace()
 		} else {
-			tok = makeToken(token.ILLEGAL, l.ch)
+			tok = makeToken(token.BIT_OR, l.ch)
 		}
+	case '%':
+		tok = makeToken(token.PERCENT, l.ch)
+	case '^':
+		tok = makeToken(token.BIT_XOR, l.ch)
+	case '~':
+		tok = makeToken(token.BIT_NOT, l.ch)
 	case '(':
 		tok = makeToken(token.LPAREN, l.ch)
 	case ')':
 		tok = makeToken(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.templates); n > 0 {
+			l.templates[n-1]++
+		}
 		if l.peekChar() == '|' {
 			ch := l.ch
 			l.readChar()
@@ -93,7 +168,17 @@ func (l *Lexer) NextToken() token.Token {
 			tok = makeToken(token.LBRACE, l.ch)
 		}
 	case '}':
-		tok = makeToken(token.RBRACE, l.ch)
+		if n := len(l.templates); n > 0 && l.templates[n-1] == 0 {
+			// end of embedded expression, the template continues
+			l.templates = l.templates[:n-1]
+			tok.Literal, tok.Type = l.readString(token.TEMPLATE_MIDDLE, token.TEMPLATE_TAIL)
+			if tok.Type == token.UNTERMINATED_STRING {
+				return tok
+			}
+		} else {
+			tok = makeToken(token.RBRACE, l.ch)
+			l.closeBrace()
+		}
 	case '[':
 		tok = makeToken(token.LBRACKET, l.ch)
 	case ']':
@@ -109,16 +194,22 @@ func (l *Lexer) NextToken() token.Token {
 	case 0:
 		tok = makeToken(token.EOF, 0)
 	case '"':
-		tok.Literal = l.readString()
-		tok.Type = token.STRING
+		tok.Literal, tok.Type = l.readString(token.TEMPLATE_HEAD, token.STRING)
+		if tok.Type == token.UNTERMINATED_STRING {
+			return tok
+		}
+	case '`':
+		tok.Literal, tok.Type = l.readRawString()
+		if tok.Type == token.UNTERMINATED_STRING {
+			return tok
+		}
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupKeyword(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readInt()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = makeToken(token.ILLEGAL, l.ch)
@@ -138,26 +229,107 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
+// readString reads string up to the closing quote, skipping escaped characters.
+// Reading stops at '${' starting embedded expression of a template, then the
+// part read so far has partType, otherwise the string has endType.
+// String which is not closed on the same line is unterminated and its literal
+// is everything read including the opening quote.
+func (l *Lexer) readString(partType, endType token.TokenType) (string, token.TokenType) {
+	start := l.position
 	l.readChar()
 	from := l.position
-	for l.ch != '"' && l.ch != 0 {
+	for l.ch != '"' {
+		if l.ch == '$' && l.peekChar() == '{' {
+			literal := l.input[from:l.position]
+			l.readChar()
+			l.templates = append(l.templates, 0)
+			return literal, partType
+		} else if l.ch == '\\' && l.peekChar() != '\n' && l.peekChar() != 0 {
+			l.readChar()
+		} else if l.ch == '\n' || l.ch == 0 {
+			return l.input[start:l.position], token.UNTERMINATED_STRING
+		}
 		l.readChar()
 	}
 
-	return l.input[from:l.position]
+	return l.input[from:l.position], endType
 }
 
-func (l *Lexer) readInt() string {
+func (l *Lexer) readRawString() (string, token.TokenType) {
+	start := l.position
+	l.readChar()
+	from := l.position
+	for l.ch != '`' {
+		if l.ch == 0 {
+			return l.input[start:l.position], token.UNTERMINATED_STRING
+		}
+		l.readChar()
+	}
+
+	return l.input[from:l.position], token.RAW_STRING
+}
+
+// closeBrace leaves brace nested in embedded expression of a template.
+func (l *Lexer) closeBrace() {
+	if n := len(l.templates); n > 0 && l.templates[n-1] > 0 {
+		l.templates[n-1]--
+	}
+}
+
+// readNumber reads integer or float literal, floats have fraction part,
+// exponent or both: 1.5, 2e10, 2.5E-3. Exponent without digits or
+// without digits of the fraction part before it makes the number invalid.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
-	for isDigit(l.ch) {
+	var tokenType token.TokenType = token.INT
+
+	l.readDigits()
+
+	// '.' without digits after it is not a part of the number, e.g. 1.field,
+	// unless it is followed by exponent, e.g. 1.e3
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	} else if l.ch == '.' && (l.peekChar() == 'e' || l.peekChar() == 'E') &&
+		(isDigit(l.peekCharAt(1)) || l.peekCharAt(1) == '+' || l.peekCharAt(1) == '-') {
+		tokenType = token.INVALID_NUMBER
 		l.readChar()
 	}
 
-	return l.input[position:l.position]
+	if l.ch == 'e' || l.ch == 'E' {
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		if !isDigit(l.ch) {
+			tokenType = token.INVALID_NUMBER
+		} else if tokenType != token.INVALID_NUMBER {
+			tokenType = token.FLOAT
+		}
+		l.readDigits()
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) {
+		l.readChar()
+	}
 }
 
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		// already at EOF
+		return
+	}
+
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -166,13 +338,28 @@ func (l *Lexer) readChar() {
 
 	l.position = l.readPosition
 	l.readPosition += 1
+	l.column += 1
+}
+
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		File:   l.file,
+		Offset: l.position,
+		Line:   l.line,
+		Column: l.column,
+	}
 }
 
 func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+	return l.peekCharAt(0)
+}
+
+// peekCharAt returns character offset positions after the next one.
+func (l *Lexer) peekCharAt(offset int) byte {
+	if l.readPosition+offset >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		return l.input[l.readPosition+offset]
 	}
 }
 
@@ -191,9 +378,21 @@ func (l *Lexer) skipWhitespace() {
 }
 
 func (l *Lexer) skipComment() {
+	start := l.pos()
 	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+
+	literal := strings.TrimRight(l.input[start.Offset:l.position], " \t\r")
+	end := start
+	end.Offset += len(literal)
+	end.Column += len(literal)
+	l.comments = append(l.comments, token.Token{Type: token.COMMENT, Literal: literal, Start: start, End: end})
+}
+
+// Comments returns comments skipped by the tokens read so far.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
 }
 
 func isLetter(ch byte) bool {
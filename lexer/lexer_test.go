@@ -185,7 +185,7 @@ func TestNextToken(t *testing.T) {
 		{token.AND, "&&"},
 		{token.INT, "10"},
 		{token.SEMICOLON, ";"},
-		{token.ILLEGAL, "&"},
+		{token.BIT_AND, "&"},
 		{token.SEMICOLON, ";"},
 
 		// 11 || 10; |;
@@ -193,7 +193,7 @@ func TestNextToken(t *testing.T) {
 		{token.OR, "||"},
 		{token.INT, "10"},
 		{token.SEMICOLON, ";"},
-		{token.ILLEGAL, "|"},
+		{token.BIT_OR, "|"},
 		{token.SEMICOLON, ";"},
 
 		// "string indeed";
@@ -265,3 +265,237 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestNumbers(t *testing.T) {
+	input := "10 1.5 0.25 2e10 2.5E-3 1e+2 1.field 3e x 1e 1.e3 2E- 1.5e 1.else"
+
+	tt := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "10"},
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "0.25"},
+		{token.FLOAT, "2e10"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "1e+2"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.IDENTIFIER, "field"},
+		{token.INVALID_NUMBER, "3e"},
+		{token.IDENTIFIER, "x"},
+		{token.INVALID_NUMBER, "1e"},
+		{token.INVALID_NUMBER, "1.e3"},
+		{token.INVALID_NUMBER, "2E-"},
+		{token.INVALID_NUMBER, "1.5e"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.ELSE, "else"},
+		{token.EOF, "\x00"},
+	}
+
+	l := lexer.New(input)
+
+	for i, tc := range tt {
+		tok := l.NextToken()
+
+		if tok.Type != tc.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected %q, got %q", i, tc.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tc.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected %q, got %q", i, tc.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := "\"a\\\"b\" `raw\n\\n` \"\u00e9\" \"open\nx `open"
+
+	tt := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, `a\"b`},
+		{token.RAW_STRING, "raw\n\\n"},
+		{token.STRING, "\u00e9"},
+		{token.UNTERMINATED_STRING, `"open`},
+		{token.IDENTIFIER, "x"},
+		{token.UNTERMINATED_STRING, "`open"},
+		{token.EOF, "\x00"},
+	}
+
+	l := lexer.New(input)
+
+	for i, tc := range tt {
+		tok := l.NextToken()
+
+		if tok.Type != tc.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected %q, got %q", i, tc.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tc.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected %q, got %q", i, tc.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := "= += -= *= /= + / % ** * & | ^ ~ << <= < >> >= > // comment"
+
+	tt := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.ASSIGN, "="},
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS_ASSIGN, "-="},
+		{token.STAR_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.PLUS, "+"},
+		{token.SLASH, "/"},
+		{token.PERCENT, "%"},
+		{token.POWER, "**"},
+		{token.STAR, "*"},
+		{token.BIT_AND, "&"},
+		{token.BIT_OR, "|"},
+		{token.BIT_XOR, "^"},
+		{token.BIT_NOT, "~"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.LESS_EQUAL, "<="},
+		{token.LESS, "<"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.GREATER_EQUAL, ">="},
+		{token.GREATER, ">"},
+		{token.EOF, "\x00"},
+	}
+
+	l := lexer.New(input)
+
+	for i, tc := range tt {
+		tok := l.NextToken()
+
+		if tok.Type != tc.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected %q, got %q", i, tc.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tc.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected %q, got %q", i, tc.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	input := `"a ${x} b ${ {| 1: "${y}" |}[1] }" "\${z}"`
+
+	tt := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.TEMPLATE_HEAD, "a "},
+		{token.IDENTIFIER, "x"},
+		{token.TEMPLATE_MIDDLE, " b "},
+		{token.LHASHBRACE, "{|"},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.TEMPLATE_HEAD, ""},
+		{token.IDENTIFIER, "y"},
+		{token.TEMPLATE_TAIL, ""},
+		{token.RHASHBRACE, "|}"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.RBRACKET, "]"},
+		{token.TEMPLATE_TAIL, ""},
+		{token.STRING, `\${z}`},
+		{token.EOF, "\x00"},
+	}
+
+	l := lexer.New(input)
+
+	for i, tc := range tt {
+		tok := l.NextToken()
+
+		if tok.Type != tc.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected %q, got %q", i, tc.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tc.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected %q, got %q", i, tc.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPosition(t *testing.T) {
+	input := "let x = 10;\n  x >= 5;\n\"str\""
+
+	tt := []struct {
+		literal string
+		start   token.Position
+		end     token.Position
+	}{
+		{"let", token.Position{File: "f.mk", Offset: 0, Line: 1, Column: 1}, token.Position{File: "f.mk", Offset: 3, Line: 1, Column: 4}},
+		{"x", token.Position{File: "f.mk", Offset: 4, Line: 1, Column: 5}, token.Position{File: "f.mk", Offset: 5, Line: 1, Column: 6}},
+		{"=", token.Position{File: "f.mk", Offset: 6, Line: 1, Column: 7}, token.Position{File: "f.mk", Offset: 7, Line: 1, Column: 8}},
+		{"10", token.Position{File: "f.mk", Offset: 8, Line: 1, Column: 9}, token.Position{File: "f.mk", Offset: 10, Line: 1, Column: 11}},
+		{";", token.Position{File: "f.mk", Offset: 10, Line: 1, Column: 11}, token.Position{File: "f.mk", Offset: 11, Line: 1, Column: 12}},
+		{"x", token.Position{File: "f.mk", Offset: 14, Line: 2, Column: 3}, token.Position{File: "f.mk", Offset: 15, Line: 2, Column: 4}},
+		{">=", token.Position{File: "f.mk", Offset: 16, Line: 2, Column: 5}, token.Position{File: "f.mk", Offset: 18, Line: 2, Column: 7}},
+		{"5", token.Position{File: "f.mk", Offset: 19, Line: 2, Column: 8}, token.Position{File: "f.mk", Offset: 20, Line: 2, Column: 9}},
+		{";", token.Position{File: "f.mk", Offset: 20, Line: 2, Column: 9}, token.Position{File: "f.mk", Offset: 21, Line: 2, Column: 10}},
+		{"str", token.Position{File: "f.mk", Offset: 22, Line: 3, Column: 1}, token.Position{File: "f.mk", Offset: 27, Line: 3, Column: 6}},
+		{"\x00", token.Position{File: "f.mk", Offset: 27, Line: 3, Column: 6}, token.Position{File: "f.mk", Offset: 27, Line: 3, Column: 6}},
+	}
+
+	l := lexer.NewFile("f.mk", input)
+
+	for i, tc := range tt {
+		tok := l.NextToken()
+
+		if tok.Literal != tc.literal {
+			t.Fatalf("tests[%d] - literal wrong. expected %q, got %q", i, tc.literal, tok.Literal)
+		}
+
+		if tok.Start != tc.start {
+			t.Errorf("tests[%d] - start wrong. expected %+v, got %+v", i, tc.start, tok.Start)
+		}
+
+		if tok.End != tc.end {
+			t.Errorf("tests[%d] - end wrong. expected %+v, got %+v", i, tc.end, tok.End)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// head\nlet x = 1; // trailing  \n\"// not a comment\"\n//"
+
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type == token.COMMENT {
+			t.Fatalf("NextToken returned comment %q", tok.Literal)
+		}
+	}
+
+	tt := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"// head", 1, 1},
+		{"// trailing", 2, 12},
+		{"//", 4, 1},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(tt) {
+		t.Fatalf("wrong number of comments. expected %d, got %d", len(tt), len(comments))
+	}
+	for i, tc := range tt {
+		c := comments[i]
+		if c.Literal != tc.literal || c.Start.Line != tc.line || c.Start.Column != tc.column {
+			t.Errorf("comments[%d] wrong. expected %q at %d:%d, got %q at %s", i, tc.literal, tc.line, tc.column, c.Literal, c.Start)
+		}
+		if c.End.Offset != c.Start.Offset+len(tc.literal) {
+			t.Errorf("comments[%d] - end wrong. got %+v", i, c.End)
+		}
+	}
+}
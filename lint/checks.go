@@ -0,0 +1,343 @@
+package lint
+
+import (
+	"monkey/ast"
+	"monkey/token"
+	"strings"
+)
+
+// kind of declaration
+const (
+	VARIABLE = iota
+	PARAMETER
+)
+
+type linter struct {
+	definitions map[ast.Expression]*ast.IdentifierExpr
+	warnings    []warning
+
+	// names declared at top level, they may be used before declaration
+	// by functions and are visible to importing modules
+	globals map[string]*ast.IdentifierExpr
+
+	declarations map[*ast.IdentifierExpr]int
+	functions    map[*ast.IdentifierExpr]*ast.FunctionExpr
+	classes      map[*ast.IdentifierExpr]*ast.ClassStmt
+
+	// declarations read somewhere and assigned somewhere
+	used     map[*ast.IdentifierExpr]bool
+	assigned map[*ast.IdentifierExpr]bool
+	// names of variables read which are not resolved to declarations
+	unresolved map[string]bool
+}
+
+func newLinter(program *ast.Program, definitions map[ast.Expression]*ast.IdentifierExpr) *linter {
+	lt := &linter{
+		definitions:  definitions,
+		globals:      map[string]*ast.IdentifierExpr{},
+		declarations: map[*ast.IdentifierExpr]int{},
+		functions:    map[*ast.IdentifierExpr]*ast.FunctionExpr{},
+		classes:      map[*ast.IdentifierExpr]*ast.ClassStmt{},
+		used:         map[*ast.IdentifierExpr]bool{},
+		assigned:     map[*ast.IdentifierExpr]bool{},
+		unresolved:   map[string]bool{},
+	}
+
+	for _, stmt := range program.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStmt:
+			lt.globals[stmt.Name.Value] = stmt.Name
+		case *ast.ClassStmt:
+			lt.globals[stmt.Name.Value] = stmt.Name
+		case *ast.ImportStmt:
+			lt.globals[stmt.Name.Value] = stmt.Name
+		}
+	}
+
+	lt.collect(program)
+
+	return lt
+}
+
+func (lt *linter) warn(rule *Rule, node ast.Node, args ...interface{}) *warning {
+	lt.warnings = append(lt.warnings, warning{rule: rule, span: ast.Span(node), args: args})
+	return &lt.warnings[len(lt.warnings)-1]
+}
+
+// collect finds declarations and usages of variables.
+func (lt *linter) collect(program *ast.Program) {
+	// identifiers which are names of declarations, fields or assignment targets
+	names := map[*ast.IdentifierExpr]bool{}
+	methods := map[*ast.LetStmt]bool{}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStmt:
+			names[node.Name] = true
+			if methods[node] {
+				break
+			}
+			lt.declarations[node.Name] = VARIABLE
+			if fn, ok := node.Value.(*ast.FunctionExpr); ok {
+				lt.functions[node.Name] = fn
+			}
+		case *ast.ClassStmt:
+			names[node.Name] = true
+			lt.declarations[node.Name] = VARIABLE
+			lt.classes[node.Name] = node
+			for _, method := range node.Methods {
+				methods[method] = true
+			}
+		case *ast.FunctionExpr:
+			for _, param := range node.Parameters {
+				names[param] = true
+				lt.declarations[param] = PARAMETER
+			}
+		case *ast.ImportStmt:
+			names[node.Name] = true
+			lt.declarations[node.Name] = VARIABLE
+		case *ast.ForInStmt:
+			names[node.Variable] = true
+			lt.declarations[node.Variable] = VARIABLE
+		case *ast.TryStmt:
+			if node.Param != nil {
+				names[node.Param] = true
+				lt.declarations[node.Param] = VARIABLE
+			}
+		case *ast.AssignExpr:
+			names[node.Identifier] = true
+			if decl, ok := lt.definitions[node.Identifier]; ok {
+				lt.assigned[decl] = true
+			}
+		case *ast.GetExpr:
+			names[node.Field] = true
+		case *ast.SetExpr:
+			names[node.Field] = true
+		case *ast.SuperExpr:
+			names[node.Method] = true
+		case *ast.IdentifierExpr:
+			if names[node] {
+				break
+			}
+			if decl, ok := lt.definitions[node]; ok {
+				lt.used[decl] = true
+			} else {
+				lt.unresolved[node.Value] = true
+			}
+		}
+		return true
+	})
+}
+
+func (lt *linter) check(program *ast.Program) {
+	lt.checkUnused(program)
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			lt.checkUnreachable(node.Statements)
+		case *ast.BlockStmt:
+			lt.checkUnreachable(node.Statements)
+		case *ast.AssignExpr:
+			lt.checkAssignment(node)
+		case *ast.CallExpr:
+			lt.checkArguments(node)
+		case *ast.ClassStmt:
+			lt.checkFields(node)
+		}
+		return true
+	})
+}
+
+// checkUnused reports local variables and parameters which are never read,
+// top level variables are skipped since modules importing the program may
+// use them.
+func (lt *linter) checkUnused(program *ast.Program) {
+	ast.Inspect(program, func(node ast.Node) bool {
+		decl, ok := node.(*ast.IdentifierExpr)
+		if !ok {
+			return true
+		}
+		kind, ok := lt.declarations[decl]
+		if !ok || lt.globals[decl.Value] == decl || strings.HasPrefix(decl.Value, "_") {
+			return true
+		}
+		// unresolved name may refer to variable declared after function using it
+		if lt.used[decl] || lt.unresolved[decl.Value] {
+			return true
+		}
+
+		if kind == PARAMETER {
+			lt.warn(UnusedParameter, decl, decl.Value)
+		} else {
+			lt.warn(UnusedVariable, decl, decl.Value)
+		}
+		return true
+	})
+}
+
+func (lt *linter) checkShadows(shadows map[*ast.IdentifierExpr]*ast.IdentifierExpr) {
+	for decl, shadowed := range shadows {
+		if strings.HasPrefix(decl.Value, "_") {
+			continue
+		}
+		w := lt.warn(Shadow, decl, decl.Value)
+		w.notes = append(w.notes, "shadowed variable is declared at "+shadowed.Pos().String())
+	}
+}
+
+// checkUnreachable reports statements following the one which always
+// leaves the list.
+func (lt *linter) checkUnreachable(stmts []ast.Statement) {
+	for i := 0; i+1 < len(stmts); i++ {
+		var keyword string
+		switch stmts[i].(type) {
+		case *ast.ReturnStmt:
+			keyword = "return"
+		case *ast.ThrowStmt:
+			keyword = "throw"
+		case *ast.BreakStmt:
+			keyword = "break"
+		case *ast.ContinueStmt:
+			keyword = "continue"
+		default:
+			continue
+		}
+
+		span := token.Span{Start: stmts[i+1].Pos(), End: stmts[len(stmts)-1].End()}
+		lt.warnings = append(lt.warnings, warning{rule: Unreachable, span: span, args: []interface{}{keyword}})
+		return
+	}
+}
+
+func (lt *linter) checkAssignment(assign *ast.AssignExpr) {
+	if _, ok := lt.definitions[assign.Identifier]; ok {
+		return
+	}
+	if _, ok := lt.globals[assign.Identifier.Value]; ok {
+		return
+	}
+	lt.warn(UndeclaredAssignment, assign.Identifier, assign.Identifier.Value)
+}
+
+// checkArguments compares number of arguments with parameters of function
+// or initializer of class called by name, unless the name is reassigned.
+func (lt *linter) checkArguments(call *ast.CallExpr) {
+	ident, ok := call.Function.(*ast.IdentifierExpr)
+	if !ok {
+		return
+	}
+	decl, ok := lt.definitions[ident]
+	if !ok {
+		decl = lt.globals[ident.Value]
+	}
+	if decl == nil || lt.assigned[decl] {
+		return
+	}
+
+	expect, ok := lt.arity(decl)
+	if ok && expect != len(call.Arguments) {
+		lt.warn(ArgumentCount, call, ident.Value, expect, len(call.Arguments))
+	}
+}
+
+func (lt *linter) arity(decl *ast.IdentifierExpr) (int, bool) {
+	if fn, ok := lt.functions[decl]; ok {
+		return len(fn.Parameters), true
+	}
+
+	class := lt.classes[decl]
+	for i := 0; class != nil && i < len(lt.classes); i++ {
+		if init := findMethod(class, token.INITIALIZER_KEYWORD); init != nil {
+			return len(init.Parameters), true
+		}
+		if class.Superclass == nil {
+			return 0, true
+		}
+		class = lt.superclass(class)
+	}
+
+	return 0, false
+}
+
+func (lt *linter) superclass(class *ast.ClassStmt) *ast.ClassStmt {
+	if decl, ok := lt.definitions[class.Superclass]; ok {
+		return lt.classes[decl]
+	}
+	return nil
+}
+
+func findMethod(class *ast.ClassStmt, name string) *ast.FunctionExpr {
+	for _, method := range class.Methods {
+		if method.Name.Value == name {
+			fn, _ := method.Value.(*ast.FunctionExpr)
+			return fn
+		}
+	}
+	return nil
+}
+
+// checkFields reports fields of this which are read in methods, but are
+// not assigned in initializer of the class or its superclasses. Classes
+// with superclasses declared elsewhere are skipped.
+func (lt *linter) checkFields(class *ast.ClassStmt) {
+	methods := map[string]bool{}
+	initialized := map[string]bool{}
+
+	c := class
+	for i := 0; c != nil && i <= len(lt.classes); i++ {
+		for _, method := range c.Methods {
+			methods[method.Name.Value] = true
+		}
+		if init := findMethod(c, token.INITIALIZER_KEYWORD); init != nil {
+			inspectThis(init, func(node ast.Node) {
+				if set, ok := node.(*ast.SetExpr); ok {
+					initialized[set.Field.Value] = true
+				}
+			})
+		}
+
+		if c.Superclass == nil {
+			break
+		}
+		if c = lt.superclass(c); c == nil {
+			return
+		}
+	}
+
+	reported := map[string]bool{}
+	for _, method := range class.Methods {
+		inspectThis(method, func(node ast.Node) {
+			get, ok := node.(*ast.GetExpr)
+			if !ok {
+				return
+			}
+			name := get.Field.Value
+			if methods[name] || initialized[name] || reported[name] {
+				return
+			}
+			reported[name] = true
+			lt.warn(UninitializedField, get, name)
+		})
+	}
+}
+
+// inspectThis calls f for field accesses of this in node,
+// classes nested in node are skipped.
+func inspectThis(node ast.Node, f func(ast.Node)) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ClassStmt:
+			return false
+		case *ast.GetExpr:
+			if _, ok := n.Expression.(*ast.ThisExpr); ok {
+				f(n)
+			}
+		case *ast.SetExpr:
+			if _, ok := n.Expression.(*ast.ThisExpr); ok {
+				f(n)
+			}
+		}
+		return true
+	})
+}
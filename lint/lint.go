@@ -0,0 +1,205 @@
+// Package lint finds suspicious code which is valid, but most likely
+// wrong, using declarations found by the resolver.
+//
+// Warnings are suppressed with comments
+//
+//	// lint:ignore unused-variable, shadow
+//
+// which apply to the line they are on or, when the comment is alone on its
+// line, to the next one. Comment without rule names suppresses all rules.
+package lint
+
+import (
+	"fmt"
+	"monkey/diagnostics"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// Rule is a kind of problem reported by linter.
+type Rule struct {
+	Name    string // used to enable, disable and suppress the rule
+	Code    string
+	Message string // format of warning message
+}
+
+var (
+	UnusedVariable       = &Rule{"unused-variable", "L001", "Variable '%s' is declared but never used."}
+	UnusedParameter      = &Rule{"unused-parameter", "L002", "Parameter '%s' is never used."}
+	Shadow               = &Rule{"shadow", "L003", "Declaration of '%s' shadows variable of enclosing scope."}
+	Unreachable          = &Rule{"unreachable", "L004", "Unreachable code after '%s'."}
+	UndeclaredAssignment = &Rule{"undeclared-assignment", "L005", "Assignment to undeclared variable '%s'."}
+	ArgumentCount        = &Rule{"argument-count", "L006", "'%s' expects %d arguments, got %d."}
+	UninitializedField   = &Rule{"uninitialized-field", "L007", "Field '%s' is read but never assigned in 'init'."}
+)
+
+// Rules lists all rules in order of their codes.
+var Rules = []*Rule{
+	UnusedVariable,
+	UnusedParameter,
+	Shadow,
+	Unreachable,
+	UndeclaredAssignment,
+	ArgumentCount,
+	UninitializedField,
+}
+
+var hints = map[*Rule]string{
+	UnusedVariable:       "remove it or start its name with '_'",
+	UnusedParameter:      "start its name with '_' to mark it as unused",
+	UndeclaredAssignment: "declare the variable with 'let' first",
+}
+
+const IGNORE_DIRECTIVE = "lint:ignore"
+
+// Config selects rules to check, the zero Config checks all of them.
+type Config struct {
+	disabled map[*Rule]bool
+}
+
+// ParseConfig reads comma separated lists of rule names. When enable is
+// not empty only the listed rules are checked.
+func ParseConfig(enable string, disable string) (Config, error) {
+	config := Config{disabled: map[*Rule]bool{}}
+
+	enabled, err := parseRules(enable)
+	if err != nil {
+		return config, err
+	}
+	disabled, err := parseRules(disable)
+	if err != nil {
+		return config, err
+	}
+
+	for _, rule := range Rules {
+		if len(enabled) > 0 && !enabled[rule] || disabled[rule] {
+			config.disabled[rule] = true
+		}
+	}
+
+	return config, nil
+}
+
+func parseRules(list string) (map[*Rule]bool, error) {
+	rules := map[*Rule]bool{}
+	for _, name := range splitNames(list) {
+		rule := findRule(name)
+		if rule == nil {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+		rules[rule] = true
+	}
+	return rules, nil
+}
+
+func findRule(name string) *Rule {
+	for _, rule := range Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+func splitNames(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+}
+
+// Source checks source code. Syntax and resolver errors are returned
+// instead of warnings when source has any.
+func Source(file string, source string, config Config) []diagnostics.Diagnostic {
+	l := lexer.NewFile(file, source)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return p.Errors()
+	}
+
+	r := resolver.New()
+	r.Resolve(program)
+	if len(r.Errors()) != 0 {
+		return r.Errors()
+	}
+
+	lt := newLinter(program, r.Definitions())
+	lt.check(program)
+	lt.checkShadows(r.Shadows())
+
+	warnings := []diagnostics.Diagnostic{}
+	suppressed := suppressions(source, l.Comments())
+	for _, w := range lt.warnings {
+		if config.disabled[w.rule] || suppressed.has(w.span.Start.Line, w.rule) {
+			continue
+		}
+		warnings = append(warnings, w.diagnostic())
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Span.Start.Offset < warnings[j].Span.Start.Offset
+	})
+
+	return warnings
+}
+
+type warning struct {
+	rule  *Rule
+	span  token.Span
+	args  []interface{}
+	notes []string
+}
+
+func (w warning) diagnostic() diagnostics.Diagnostic {
+	d := diagnostics.New(diagnostics.Warning, w.rule.Code, w.span, w.rule.Message, w.args...)
+	for _, note := range w.notes {
+		d = d.WithNote(note)
+	}
+	if hint, ok := hints[w.rule]; ok {
+		d = d.WithHint(hint)
+	}
+	return d
+}
+
+// suppressed maps lines to rules suppressed on them, nil rule stands for all rules.
+type suppressed map[int]map[*Rule]bool
+
+func (s suppressed) has(line int, rule *Rule) bool {
+	return s[line][nil] || s[line][rule]
+}
+
+func suppressions(source string, comments []token.Token) suppressed {
+	s := suppressed{}
+
+	for _, c := range comments {
+		fields := splitNames(strings.TrimPrefix(c.Literal, "//"))
+		if len(fields) == 0 || fields[0] != IGNORE_DIRECTIVE {
+			continue
+		}
+
+		line := c.Start.Line
+		lineStart := strings.LastIndexByte(source[:c.Start.Offset], '\n') + 1
+		if strings.TrimSpace(source[lineStart:c.Start.Offset]) == "" {
+			line++
+		}
+
+		if s[line] == nil {
+			s[line] = map[*Rule]bool{}
+		}
+
+		names := fields[1:]
+		if len(names) == 0 {
+			s[line][nil] = true
+		}
+		for _, name := range names {
+			if rule := findRule(name); rule != nil {
+				s[line][rule] = true
+			}
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,167 @@
+package lint_test
+
+import (
+	"fmt"
+	"monkey/diagnostics"
+	"monkey/lint"
+	"reflect"
+	"testing"
+)
+
+func describe(diags []diagnostics.Diagnostic) []string {
+	result := []string{}
+	for _, d := range diags {
+		result = append(result, fmt.Sprintf("%s %s: %s", d.Span.Start, d.Code, d.Message))
+	}
+	return result
+}
+
+func TestRules(t *testing.T) {
+	tt := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			"unused variables",
+			"let top = 1; fn f() { let x = 1; let y = 2; let _z = 3; x = 4; return y; } for (i in []) {} try {} catch (e) {}",
+			[]string{
+				"1:27 L001: Variable 'x' is declared but never used.",
+				"1:81 L001: Variable 'i' is declared but never used.",
+				"1:107 L001: Variable 'e' is declared but never used.",
+			},
+		},
+		{
+			"unused parameters",
+			"fn f(a, b, _c) { return a; } class A { fn m(x) {} }",
+			[]string{
+				"1:9 L002: Parameter 'b' is never used.",
+				"1:45 L002: Parameter 'x' is never used.",
+			},
+		},
+		{
+			"used before declaration",
+			"{ fn a() { return b(); } fn b() { return 1; } a(); }",
+			[]string{},
+		},
+		{
+			"shadow",
+			"let x = 1; fn f(x) { return x; } { let y = 1; { let y = 2; y; } y; }",
+			[]string{
+				"1:17 L003: Declaration of 'x' shadows variable of enclosing scope.",
+				"1:53 L003: Declaration of 'y' shadows variable of enclosing scope.",
+			},
+		},
+		{
+			"unreachable",
+			"fn f() { return 1; puts(1); puts(2); } while (true) { break; puts(3); } fn g() { throw 1; 2; } 4;",
+			[]string{
+				"1:20 L004: Unreachable code after 'return'.",
+				"1:62 L004: Unreachable code after 'break'.",
+				"1:91 L004: Unreachable code after 'throw'.",
+			},
+		},
+		{
+			"undeclared assignment",
+			"let a = 1; a = 2; fn f() { b = 1; c += 1; d = 2; } let d = 3;",
+			[]string{
+				"1:28 L005: Assignment to undeclared variable 'b'.",
+				"1:35 L005: Assignment to undeclared variable 'c'.",
+			},
+		},
+		{
+			"argument count",
+			"fn f(a, b) { a + b } f(1); f(1, 2); later(); let later = fn(x) { x }; let g = fn() {}; g = f; g(1);",
+			[]string{
+				"1:22 L006: 'f' expects 2 arguments, got 1.",
+				"1:37 L006: 'later' expects 1 arguments, got 0.",
+			},
+		},
+		{
+			"class argument count",
+			"class A { fn init(x) { this.x = x; } } class B < A {} class C {} class D < E {} A(); B(1); B(); C(1); D(1);",
+			[]string{
+				"1:81 L006: 'A' expects 1 arguments, got 0.",
+				"1:92 L006: 'B' expects 1 arguments, got 0.",
+				"1:97 L006: 'C' expects 0 arguments, got 1.",
+			},
+		},
+		{
+			"uninitialized field",
+			"class A { fn init() { this.a = 1; } fn get() { return this.a + this.b + this.b + this.get(); } }" +
+				" class B < A { fn m() { return this.a + this.c; } fn reset() { this.c = 0; } }" +
+				" class C < Unknown { fn m() { return this.d; } }",
+			[]string{
+				"1:64 L007: Field 'b' is read but never assigned in 'init'.",
+				"1:137 L007: Field 'c' is read but never assigned in 'init'.",
+			},
+		},
+		{
+			"errors",
+			"let x = 1; let x = 2;",
+			[]string{"1:16 R002: Variable 'x' is already declared in current scope."},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got := describe(lint.Source("", tc.source, lint.Config{}))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Wrong diagnostics of %q\ngot  %q\nwant %q", tc.source, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSuppression(t *testing.T) {
+	source := `fn f(a) {
+  let x = 1; // lint:ignore
+  // lint:ignore unused-variable, shadow
+  let y = 1;
+  let z = 1; // lint:ignore shadow
+  // lint:ignored
+  let w = 1;
+}`
+
+	want := []string{
+		"1:6 L002: Parameter 'a' is never used.",
+		"5:7 L001: Variable 'z' is declared but never used.",
+		"7:7 L001: Variable 'w' is declared but never used.",
+	}
+	if got := describe(lint.Source("", source, lint.Config{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrong diagnostics\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	source := "fn f(a) { let x = 1; return 1; 2; }"
+
+	tt := []struct {
+		enable, disable string
+		want            []string
+	}{
+		{"", "", []string{"L002", "L001", "L004"}},
+		{"unused-variable,unreachable", "", []string{"L001", "L004"}},
+		{"", "unused-parameter, unreachable", []string{"L001"}},
+		{"unreachable", "unreachable", []string{}},
+	}
+
+	for _, tc := range tt {
+		config, err := lint.ParseConfig(tc.enable, tc.disable)
+		if err != nil {
+			t.Fatalf("Can not parse config: %s", err)
+		}
+
+		got := []string{}
+		for _, d := range lint.Source("", source, config) {
+			got = append(got, d.Code)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Wrong codes with enable=%q disable=%q, got %q, want %q", tc.enable, tc.disable, got, tc.want)
+		}
+	}
+
+	if _, err := lint.ParseConfig("unused", ""); err == nil {
+		t.Errorf("Expect error for unknown rule.")
+	}
+}
@@ -0,0 +1,482 @@
+package lsp
+
+import (
+	"math"
+	"monkey/ast"
+	"monkey/checker"
+	"monkey/diagnostics"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/token"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// lines converts between byte offsets of text and LSP positions.
+type lines struct {
+	text   string
+	starts []int // offsets of line starts
+}
+
+func newLines(text string) lines {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return lines{text: text, starts: starts}
+}
+
+func (l lines) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(l.starts) {
+		return len(l.text)
+	}
+
+	offset, units := l.starts[pos.Line], 0
+	for offset < len(l.text) && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(l.text[offset:])
+		if r == '\n' {
+			break
+		}
+		units += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func (l lines) position(offset int) Position {
+	if offset > len(l.text) {
+		offset = len(l.text)
+	}
+	line := sort.Search(len(l.starts), func(i int) bool { return l.starts[i] > offset }) - 1
+
+	units := 0
+	for _, r := range l.text[l.starts[line]:offset] {
+		units += utf16Len(r)
+	}
+	return Position{Line: line, Character: units}
+}
+
+func (l lines) rangeOf(node ast.Node) Range {
+	return Range{Start: l.position(node.Pos().Offset), End: l.position(node.End().Offset)}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// declaration is a name introduced by let, class or import statement,
+// function parameter, loop variable or catch parameter.
+type declaration struct {
+	ident  *ast.IdentifierExpr
+	detail string // signature shown on hover, e.g. 'fn add(a, b)'
+	kind   int    // completion item kind
+
+	// offsets of source where the name is visible
+	from, to int
+}
+
+// analysis holds results of parsing and resolving a document.
+type analysis struct {
+	lines
+
+	program *ast.Program
+	errors  []diagnostics.Diagnostic
+	// indexed is false when document has syntax errors,
+	// then only errors are available
+	indexed bool
+
+	// definitions maps variables to identifiers declaring them
+	definitions  map[ast.Expression]*ast.IdentifierExpr
+	idents       []*ast.IdentifierExpr
+	declarations map[*ast.IdentifierExpr]*declaration
+	globals      map[string]*declaration
+	// fields are names of properties and methods used after '.' or 'super.'
+	fields map[*ast.IdentifierExpr]bool
+	// methods maps names of methods to their classes
+	methods map[*ast.IdentifierExpr]*ast.ClassStmt
+	classes []*ast.ClassStmt
+}
+
+func analyze(text string) *analysis {
+	a := &analysis{
+		lines:        newLines(text),
+		declarations: map[*ast.IdentifierExpr]*declaration{},
+		globals:      map[string]*declaration{},
+		fields:       map[*ast.IdentifierExpr]bool{},
+		methods:      map[*ast.IdentifierExpr]*ast.ClassStmt{},
+	}
+
+	p := parser.New(lexer.New(text))
+	a.program = p.ParseProgram()
+	a.errors = p.Errors()
+	if len(a.errors) != 0 {
+		return a
+	}
+
+	r := resolver.New()
+	r.Resolve(a.program)
+	a.errors = r.Errors()
+	a.definitions = r.Definitions()
+	if len(a.errors) == 0 {
+		c := checker.New(a.definitions)
+		c.Check(a.program)
+		a.errors = c.Errors()
+	}
+	a.index()
+	a.indexed = true
+
+	return a
+}
+
+func (a *analysis) index() {
+	// ancestors of the visited node, AST is traversed in source order,
+	// so nodes which end before the visited one are not its ancestors
+	ancestors := []ast.Node{}
+
+	ast.Inspect(a.program, func(node ast.Node) bool {
+		for len(ancestors) > 0 && !contains(ancestors[len(ancestors)-1], node) {
+			ancestors = ancestors[:len(ancestors)-1]
+		}
+		var parent ast.Node
+		if len(ancestors) > 0 {
+			parent = ancestors[len(ancestors)-1]
+		}
+		ancestors = append(ancestors, node)
+
+		switch n := node.(type) {
+		case *ast.IdentifierExpr:
+			a.idents = append(a.idents, n)
+		case *ast.LetStmt:
+			if class, ok := parent.(*ast.ClassStmt); ok {
+				a.methods[n.Name] = class
+				break
+			}
+			if fn, ok := n.Value.(*ast.FunctionExpr); ok {
+				a.declare(n.Name, "fn "+signature(n.Name.Value, fn), COMPLETION_FUNCTION, parent, n.Pos().Offset)
+			} else {
+				a.declare(n.Name, "let "+n.Name.Value, COMPLETION_VARIABLE, parent, n.Pos().Offset)
+			}
+		case *ast.ClassStmt:
+			a.classes = append(a.classes, n)
+			a.declare(n.Name, classDetail(n), COMPLETION_CLASS, parent, n.Pos().Offset)
+		case *ast.ImportStmt:
+			a.declare(n.Name, "import "+n.Path.String()+" as "+n.Name.Value, COMPLETION_VARIABLE, parent, n.Pos().Offset)
+		case *ast.FunctionExpr:
+			for _, param := range n.Parameters {
+				a.declareIn(param, "(parameter) "+param.Value, node)
+			}
+		case *ast.ForInStmt:
+			a.declareIn(n.Variable, "(loop variable) "+n.Variable.Value, node)
+		case *ast.TryStmt:
+			if n.Param != nil && n.Catch != nil {
+				a.declareIn(n.Param, "(exception) "+n.Param.Value, n.Catch)
+			}
+		case *ast.GetExpr:
+			a.fields[n.Field] = true
+		case *ast.SetExpr:
+			a.fields[n.Field] = true
+		case *ast.SuperExpr:
+			a.fields[n.Method] = true
+		}
+		return true
+	})
+}
+
+func contains(parent, node ast.Node) bool {
+	return parent.Pos().Offset <= node.Pos().Offset && node.Pos().Offset < parent.End().Offset
+}
+
+// declare adds declaration visible from offset to the end of its parent,
+// declarations of the top-level code are visible everywhere.
+func (a *analysis) declare(ident *ast.IdentifierExpr, detail string, kind int, parent ast.Node, from int) {
+	d := &declaration{ident: ident, detail: detail, kind: kind, from: from, to: math.MaxInt}
+	if _, ok := parent.(*ast.Program); ok {
+		d.from = 0
+		a.globals[ident.Value] = d
+	} else if parent != nil {
+		d.to = parent.End().Offset
+	}
+	a.declarations[ident] = d
+}
+
+// declareIn adds declaration visible in scope node.
+func (a *analysis) declareIn(ident *ast.IdentifierExpr, detail string, scope ast.Node) {
+	a.declarations[ident] = &declaration{
+		ident:  ident,
+		detail: detail,
+		kind:   COMPLETION_VARIABLE,
+		from:   scope.Pos().Offset,
+		to:     scope.End().Offset,
+	}
+}
+
+func signature(name string, fn *ast.FunctionExpr) string {
+	params := []string{}
+	for _, p := range fn.Parameters {
+		params = append(params, p.Value)
+	}
+	return name + "(" + strings.Join(params, ", ") + ")"
+}
+
+func classDetail(class *ast.ClassStmt) string {
+	detail := "class " + class.Name.Value
+	if class.Superclass != nil {
+		detail += " < " + class.Superclass.Value
+	}
+	return detail
+}
+
+func methodDetail(class *ast.ClassStmt, method *ast.LetStmt) string {
+	if fn, ok := method.Value.(*ast.FunctionExpr); ok {
+		return "fn " + class.Name.Value + "." + signature(method.Name.Value, fn)
+	}
+	return class.Name.Value + "." + method.Name.Value
+}
+
+// identAt returns identifier at offset, including the offset right after it.
+func (a *analysis) identAt(offset int) *ast.IdentifierExpr {
+	for _, ident := range a.idents {
+		if ident.Pos().Offset <= offset && offset <= ident.End().Offset {
+			return ident
+		}
+	}
+	return nil
+}
+
+func (a *analysis) isMember(ident *ast.IdentifierExpr) bool {
+	return a.fields[ident] || a.methods[ident] != nil
+}
+
+// methodsNamed returns declarations of methods with name in all classes,
+// classes of properties are not known before runtime.
+func (a *analysis) methodsNamed(name string) []*ast.LetStmt {
+	methods := []*ast.LetStmt{}
+	for _, class := range a.classes {
+		for _, method := range class.Methods {
+			if method.Name.Value == name {
+				methods = append(methods, method)
+			}
+		}
+	}
+	return methods
+}
+
+// declarationOf returns declaration of variable ident or nil,
+// variables which resolver did not find are globals declared later.
+func (a *analysis) declarationOf(ident *ast.IdentifierExpr) *declaration {
+	if d, ok := a.declarations[ident]; ok {
+		return d
+	}
+	if decl, ok := a.definitions[ident]; ok {
+		return a.declarations[decl]
+	}
+	return a.globals[ident.Value]
+}
+
+// definition returns identifiers declaring ident.
+func (a *analysis) definition(ident *ast.IdentifierExpr) []*ast.IdentifierExpr {
+	if a.isMember(ident) {
+		idents := []*ast.IdentifierExpr{}
+		for _, method := range a.methodsNamed(ident.Value) {
+			idents = append(idents, method.Name)
+		}
+		return idents
+	}
+
+	if d := a.declarationOf(ident); d != nil {
+		return []*ast.IdentifierExpr{d.ident}
+	}
+	return nil
+}
+
+// references returns identifiers referring to the same variable
+// or method as ident.
+func (a *analysis) references(ident *ast.IdentifierExpr, includeDeclaration bool) []*ast.IdentifierExpr {
+	refs := []*ast.IdentifierExpr{}
+
+	if a.isMember(ident) {
+		for _, other := range a.idents {
+			if other.Value == ident.Value && a.isMember(other) &&
+				(includeDeclaration || a.methods[other] == nil) {
+				refs = append(refs, other)
+			}
+		}
+		return refs
+	}
+
+	target := a.declarationOf(ident)
+	if target == nil {
+		return refs
+	}
+	for _, other := range a.idents {
+		if a.isMember(other) || a.declarationOf(other) != target {
+			continue
+		}
+		if includeDeclaration || other != target.ident {
+			refs = append(refs, other)
+		}
+	}
+	return refs
+}
+
+// hover returns markdown description of ident.
+func (a *analysis) hover(ident *ast.IdentifierExpr) string {
+	if a.isMember(ident) {
+		details := []string{}
+		for _, class := range a.classes {
+			for _, method := range class.Methods {
+				if method.Name.Value == ident.Value {
+					details = append(details, methodDetail(class, method))
+				}
+			}
+		}
+		if len(details) == 0 {
+			return ""
+		}
+		return codeBlock(strings.Join(details, "\n"))
+	}
+
+	if d := a.declarationOf(ident); d != nil {
+		return codeBlock(d.detail)
+	}
+	if doc, ok := builtinDocs[ident.Value]; ok {
+		return codeBlock("builtin "+doc.Signature) + "\n" + doc.Doc
+	}
+	return ""
+}
+
+func codeBlock(code string) string {
+	return "```monkey\n" + code + "\n```"
+}
+
+func (a *analysis) symbols() []DocumentSymbol {
+	return a.symbolsOf(a.program)
+}
+
+// symbolsOf returns classes and functions declared in node,
+// functions declared inside of them are their children.
+func (a *analysis) symbolsOf(node ast.Node) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == node {
+			return true
+		}
+		switch n := n.(type) {
+		case *ast.ClassStmt:
+			class := DocumentSymbol{
+				Name:           n.Name.Value,
+				Detail:         classDetail(n),
+				Kind:           SYMBOL_CLASS,
+				Range:          a.rangeOf(n),
+				SelectionRange: a.rangeOf(n.Name),
+			}
+			for _, method := range n.Methods {
+				kind := SYMBOL_METHOD
+				if method.Name.Value == token.INITIALIZER_KEYWORD {
+					kind = SYMBOL_CONSTRUCTOR
+				}
+				class.Children = append(class.Children, DocumentSymbol{
+					Name:           method.Name.Value,
+					Detail:         methodDetail(n, method),
+					Kind:           kind,
+					Range:          a.rangeOf(method),
+					SelectionRange: a.rangeOf(method.Name),
+					Children:       a.symbolsOf(method.Value),
+				})
+			}
+			symbols = append(symbols, class)
+			return false
+		case *ast.LetStmt:
+			fn, ok := n.Value.(*ast.FunctionExpr)
+			if !ok {
+				return true
+			}
+			symbols = append(symbols, DocumentSymbol{
+				Name:           n.Name.Value,
+				Detail:         "fn " + signature(n.Name.Value, fn),
+				Kind:           SYMBOL_FUNCTION,
+				Range:          a.rangeOf(n),
+				SelectionRange: a.rangeOf(n.Name),
+				Children:       a.symbolsOf(fn),
+			})
+			return false
+		}
+		return true
+	})
+
+	return symbols
+}
+
+// completion returns names visible at offset, or names
+// of methods when afterDot is set.
+func (a *analysis) completion(offset int, afterDot bool) []CompletionItem {
+	items := []CompletionItem{}
+
+	if afterDot {
+		seen := map[string]bool{}
+		for _, class := range a.classes {
+			for _, method := range class.Methods {
+				if !seen[method.Name.Value] {
+					seen[method.Name.Value] = true
+					items = append(items, CompletionItem{
+						Label:  method.Name.Value,
+						Kind:   COMPLETION_METHOD,
+						Detail: methodDetail(class, method),
+					})
+				}
+			}
+		}
+		sortItems(items)
+		return items
+	}
+
+	// inner declarations shadow outer ones, they start later
+	visible := map[string]*declaration{}
+	for _, d := range a.declarations {
+		if d.from <= offset && offset < d.to {
+			if other, ok := visible[d.ident.Value]; !ok || other.from < d.from {
+				visible[d.ident.Value] = d
+			}
+		}
+	}
+	for name, d := range visible {
+		items = append(items, CompletionItem{Label: name, Kind: d.kind, Detail: d.detail})
+	}
+
+	for _, class := range a.classes {
+		if class.Pos().Offset < offset && offset < class.End().Offset {
+			items = append(items, CompletionItem{Label: "this", Kind: COMPLETION_KEYWORD, Detail: classDetail(class)})
+			if class.Superclass != nil {
+				items = append(items, CompletionItem{Label: "super", Kind: COMPLETION_KEYWORD, Detail: "class " + class.Superclass.Value})
+			}
+			break
+		}
+	}
+
+	for _, name := range eval.BuiltinNames() {
+		if _, shadowed := visible[name]; shadowed {
+			continue
+		}
+		doc := builtinDocs[name]
+		items = append(items, CompletionItem{Label: name, Kind: COMPLETION_FUNCTION, Detail: doc.Signature, Documentation: doc.Doc})
+	}
+
+	sortItems(items)
+	return items
+}
+
+func sortItems(items []CompletionItem) {
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+}
@@ -0,0 +1,56 @@
+package lsp
+
+// builtinDoc describes builtin function shown on hover and completion.
+type builtinDoc struct {
+	Signature string
+	Doc       string
+}
+
+var builtinDocs = map[string]builtinDoc{
+	"len":     {"len(value)", "Returns number of characters of string or elements of array."},
+	"print":   {"print(values...)", "Prints values separated by commas."},
+	"println": {"println(values...)", "Prints values separated by commas followed by new line."},
+	"int":     {"int(value)", "Converts number or numeric string to integer, floats are truncated toward zero."},
+	"float":   {"float(value)", "Converts number or numeric string to float."},
+	"clock":   {"clock()", "Returns current time in nanoseconds."},
+
+	"readFile":  {"readFile(path)", "Returns content of file."},
+	"writeFile": {"writeFile(path, content)", "Writes content to file, replacing it when it exists."},
+	"getEnv":    {"getEnv(name)", "Returns value of environment variable, or null when it is not set."},
+	"fetch":     {"fetch(url)", "Sends GET request to url and returns body of the response."},
+
+	"push":    {"push(arr, values...)", "Appends values to arr and returns it."},
+	"pop":     {"pop(arr)", "Removes the last element of arr and returns it, or null when arr is empty."},
+	"first":   {"first(arr)", "Returns the first element of arr, or null when arr is empty."},
+	"last":    {"last(arr)", "Returns the last element of arr, or null when arr is empty."},
+	"rest":    {"rest(arr)", "Returns new array without the first element of arr, or null when arr is empty."},
+	"slice":   {"slice(arr, start, end?)", "Returns elements between start and end, same as arr[start:end]."},
+	"concat":  {"concat(arrays...)", "Returns new array with elements of all arrays."},
+	"reverse": {"reverse(arr)", "Returns new array with elements of arr in reverse order."},
+	"map":     {"map(arr, fn)", "Returns new array with results of calling fn with every element."},
+	"filter":  {"filter(arr, fn)", "Returns new array with elements for which fn returns truthy value."},
+	"reduce":  {"reduce(arr, fn, initial?)", "Combines elements calling fn(acc, element), the first element is initial accumulator when it is not given."},
+	"find":    {"find(arr, fn)", "Returns the first element for which fn returns truthy value, or null."},
+	"sort":    {"sort(arr, cmp?)", "Returns sorted copy of arr. cmp(a, b) returns negative number when a goes before b, zero when they are equal and positive number otherwise."},
+
+	"keys":    {"keys(hash)", "Returns keys of hash in insertion order."},
+	"values":  {"values(hash)", "Returns values of hash in insertion order."},
+	"entries": {"entries(hash)", "Returns [key, value] arrays of hash in insertion order."},
+	"has":     {"has(hash, key)", "Returns true when hash has key."},
+	"delete":  {"delete(hash, key)", "Removes key from hash and returns its value, or null when hash does not have the key."},
+	"merge":   {"merge(hashes...)", "Returns new hash with pairs of all hashes, values of later hashes win."},
+
+	"split":      {"split(s, sep)", "Splits s into array of substrings separated by sep."},
+	"trim":       {"trim(s)", "Returns s without leading and trailing white space."},
+	"replace":    {"replace(s, old, new)", "Replaces all occurrences of old in s with new."},
+	"contains":   {"contains(s, substr)", "Returns true when s contains substr."},
+	"startsWith": {"startsWith(s, prefix)", "Returns true when s starts with prefix."},
+	"endsWith":   {"endsWith(s, suffix)", "Returns true when s ends with suffix."},
+	"indexOf":    {"indexOf(s, substr)", "Returns index of the first character of substr in s, or -1."},
+	"upper":      {"upper(s)", "Returns s with all letters in upper case."},
+	"lower":      {"lower(s)", "Returns s with all letters in lower case."},
+	"join":       {"join(arr, sep)", "Joins elements of arr into string separated by sep."},
+	"substr":     {"substr(s, start, length?)", "Returns length characters of s from start, or all characters from start."},
+	"repeat":     {"repeat(s, count)", "Returns s repeated count times."},
+	"format":     {"format(template, values...)", "Formats values with verbs like %d, %5.2f or %-10s."},
+}
@@ -0,0 +1,312 @@
+// Package lsp implements Language Server Protocol server for monkey
+// scripts on top of the lexer, parser and resolver.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/diagnostics"
+	"monkey/transport"
+)
+
+type document struct {
+	lines // current text
+	// analysis of the last version without syntax errors, it is used
+	// for navigation while the document is being edited
+	analysis *analysis
+}
+
+// Server handles requests of a single client, documents are synchronized
+// by sending their full text on every change.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	documents map[string]*document
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]*document{},
+	}
+}
+
+// requestError is returned to client as JSON-RPC error.
+type requestError struct {
+	code    int
+	message string
+}
+
+func (e *requestError) Error() string { return e.message }
+
+// Serve handles messages until client sends exit notification or closes input.
+func (s *Server) Serve() error {
+	for {
+		// LSP uses the same base protocol as DAP
+		content, err := transport.ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg Message
+		if err := json.Unmarshal(content, &msg); err != nil {
+			return fmt.Errorf("invalid message: %s", err)
+		}
+
+		if msg.ID == nil {
+			if msg.Method == "exit" {
+				return nil
+			}
+			s.notification(&msg)
+			continue
+		}
+
+		result, err := s.request(&msg)
+		s.respond(msg.ID, result, err)
+	}
+}
+
+func (s *Server) notification(msg *Message) {
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			changes := params.ContentChanges
+			s.update(params.TextDocument.URI, changes[len(changes)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		}
+	}
+}
+
+func (s *Server) request(msg *Message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		result := InitializeResult{Capabilities: ServerCapabilities{
+			TextDocumentSync:       SYNC_FULL,
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			DocumentSymbolProvider: true,
+			CompletionProvider:     &CompletionOptions{TriggerCharacters: []string{"."}},
+		}}
+		result.ServerInfo.Name = "monkey"
+		return result, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/definition":
+		return s.definition(msg.Params)
+	case "textDocument/references":
+		return s.references(msg.Params)
+	case "textDocument/hover":
+		return s.hover(msg.Params)
+	case "textDocument/documentSymbol":
+		return s.documentSymbol(msg.Params)
+	case "textDocument/completion":
+		return s.completion(msg.Params)
+	default:
+		return nil, &requestError{METHOD_NOT_FOUND, "unsupported method " + msg.Method}
+	}
+}
+
+// update analyzes new text of document and publishes its diagnostics.
+func (s *Server) update(uri string, text string) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		doc = &document{}
+		s.documents[uri] = doc
+	}
+
+	a := analyze(text)
+	doc.lines = a.lines
+	if a.indexed || doc.analysis == nil {
+		doc.analysis = a
+	}
+
+	diags := []Diagnostic{}
+	for _, d := range a.errors {
+		diags = append(diags, a.diagnostic(d))
+	}
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+var severities = map[diagnostics.Severity]int{
+	diagnostics.Error:   SEVERITY_ERROR,
+	diagnostics.Warning: SEVERITY_WARNING,
+	diagnostics.Info:    SEVERITY_INFORMATION,
+	diagnostics.Hint:    SEVERITY_HINT,
+}
+
+func (a *analysis) diagnostic(d diagnostics.Diagnostic) Diagnostic {
+	start, end := d.Span.Start, d.Span.End
+	if !end.IsValid() || end.Offset < start.Offset {
+		end = start
+	}
+
+	message := d.Message
+	for _, hint := range d.Hints {
+		message += "\nhint: " + hint
+	}
+
+	return Diagnostic{
+		Range:    Range{Start: a.position(start.Offset), End: a.position(end.Offset)},
+		Severity: severities[d.Severity],
+		Code:     d.Code,
+		Source:   "monkey",
+		Message:  message,
+	}
+}
+
+// identAt returns analysis of document and identifier at position,
+// identifier is nil when there is none.
+func (s *Server) identAt(params TextDocumentPositionParams) (*analysis, *ast.IdentifierExpr, error) {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, nil, &requestError{REQUEST_FAILED, "unknown document " + params.TextDocument.URI}
+	}
+	a := doc.analysis
+	if !a.indexed {
+		return a, nil, nil
+	}
+	return a, a.identAt(a.offset(params.Position)), nil
+}
+
+func (a *analysis) locations(uri string, idents []*ast.IdentifierExpr) []Location {
+	locations := []Location{}
+	for _, ident := range idents {
+		locations = append(locations, Location{URI: uri, Range: a.rangeOf(ident)})
+	}
+	return locations
+}
+
+func (s *Server) definition(raw json.RawMessage) (interface{}, error) {
+	var params TextDocumentPositionParams
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	a, ident, err := s.identAt(params)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+	return a.locations(params.TextDocument.URI, a.definition(ident)), nil
+}
+
+func (s *Server) references(raw json.RawMessage) (interface{}, error) {
+	var params ReferenceParams
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	a, ident, err := s.identAt(params.TextDocumentPositionParams)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+	refs := a.references(ident, params.Context.IncludeDeclaration)
+	return a.locations(params.TextDocument.URI, refs), nil
+}
+
+func (s *Server) hover(raw json.RawMessage) (interface{}, error) {
+	var params TextDocumentPositionParams
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	a, ident, err := s.identAt(params)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+
+	text := a.hover(ident)
+	if text == "" {
+		return nil, nil
+	}
+	r := a.rangeOf(ident)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}, nil
+}
+
+func (s *Server) documentSymbol(raw json.RawMessage) (interface{}, error) {
+	var params DocumentSymbolParams
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, &requestError{REQUEST_FAILED, "unknown document " + params.TextDocument.URI}
+	}
+	if !doc.analysis.indexed {
+		return []DocumentSymbol{}, nil
+	}
+	return doc.analysis.symbols(), nil
+}
+
+func (s *Server) completion(raw json.RawMessage) (interface{}, error) {
+	var params TextDocumentPositionParams
+	if err := unmarshalParams(raw, &params); err != nil {
+		return nil, err
+	}
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, &requestError{REQUEST_FAILED, "unknown document " + params.TextDocument.URI}
+	}
+
+	// skip the part of identifier which is being typed
+	offset := doc.offset(params.Position)
+	start := offset
+	for start > 0 && isIdentifierChar(doc.text[start-1]) {
+		start--
+	}
+	afterDot := start > 0 && doc.text[start-1] == '.'
+
+	// text of the last analysis can differ from the current one,
+	// so position is converted again
+	a := doc.analysis
+	return a.completion(a.offset(params.Position), afterDot), nil
+}
+
+func isIdentifierChar(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '_'
+}
+
+func unmarshalParams(raw json.RawMessage, params interface{}) error {
+	if err := json.Unmarshal(raw, params); err != nil {
+		return &requestError{INVALID_PARAMS, err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) respond(id *json.RawMessage, result interface{}, err error) {
+	resp := Response{JSONRPC: "2.0", ID: id}
+	if err != nil {
+		code := REQUEST_FAILED
+		if e, ok := err.(*requestError); ok {
+			code = e.code
+		}
+		resp.Error = &ResponseError{Code: code, Message: err.Error()}
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			data = []byte("null")
+		}
+		resp.Result = data
+	}
+	transport.WriteMessage(s.out, resp)
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	transport.WriteMessage(s.out, Notification{JSONRPC: "2.0", Method: method, Params: params})
+}
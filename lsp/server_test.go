@@ -0,0 +1,378 @@
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/lsp"
+	"monkey/transport"
+	"strings"
+	"testing"
+	"time"
+)
+
+const uri = "file:///test.mk"
+
+const source = `let base = 10;
+fn add(a, b) {
+  let sum = a + b;
+  return sum + base + later;
+}
+class Point {
+  fn init(x) { this.x = x; }
+  fn double() { return add(this.x, this.x); }
+}
+let p = Point(1);
+p.double();
+let later = len("abc");`
+
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *lsp.ResponseError
+}
+
+type client struct {
+	t             *testing.T
+	in            io.WriteCloser
+	messages      chan message
+	notifications []message
+	id            int
+	served        chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, in: inW, messages: make(chan message, 100), served: make(chan error, 1)}
+
+	go func() { c.served <- lsp.NewServer(inR, outW).Serve() }()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			content, err := transport.ReadMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var m message
+			if err := json.Unmarshal(content, &m); err != nil {
+				t.Errorf("Invalid message %s: %s", content, err)
+			}
+			c.messages <- m
+		}
+	}()
+
+	c.request("initialize", map[string]interface{}{}, nil)
+	c.notify("initialized", map[string]interface{}{})
+	return c
+}
+
+func (c *client) send(msg interface{}) {
+	c.t.Helper()
+	if err := transport.WriteMessage(c.in, msg); err != nil {
+		c.t.Fatalf("Can not send message: %s", err)
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *client) next() message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("Server closed output.")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("Timeout waiting for message.")
+	}
+	return message{}
+}
+
+// request sends request and decodes its result into result,
+// notifications received before the response are queued.
+func (c *client) request(method string, params interface{}, result interface{}) *lsp.ResponseError {
+	c.t.Helper()
+	c.id++
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params})
+
+	for {
+		m := c.next()
+		if m.ID == nil {
+			c.notifications = append(c.notifications, m)
+			continue
+		}
+		if *m.ID != c.id {
+			c.t.Fatalf("Unexpected response %+v to %s.", m, method)
+		}
+		if m.Error == nil && result != nil {
+			if err := json.Unmarshal(m.Result, result); err != nil {
+				c.t.Fatalf("Invalid result of %s: %s", method, err)
+			}
+		}
+		return m.Error
+	}
+}
+
+// diagnostics waits for the next published diagnostics.
+func (c *client) diagnostics() []lsp.Diagnostic {
+	c.t.Helper()
+	for {
+		var m message
+		if len(c.notifications) > 0 {
+			m, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			m = c.next()
+		}
+		if m.Method == "textDocument/publishDiagnostics" {
+			var params lsp.PublishDiagnosticsParams
+			json.Unmarshal(m.Params, &params)
+			return params.Diagnostics
+		}
+	}
+}
+
+func (c *client) open(text string) {
+	c.notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, Version: 1, Text: text},
+	})
+}
+
+func (c *client) change(text string) {
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": text}},
+	})
+}
+
+func at(line, character int) lsp.TextDocumentPositionParams {
+	return lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: character},
+	}
+}
+
+func locations(locs []lsp.Location) string {
+	result := []string{}
+	for _, loc := range locs {
+		result = append(result, fmt.Sprintf("%d:%d-%d", loc.Range.Start.Line, loc.Range.Start.Character, loc.Range.End.Character))
+	}
+	return strings.Join(result, " ")
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+
+	c.open(source)
+	if diags := c.diagnostics(); len(diags) != 0 {
+		t.Errorf("Expect no diagnostics, got %+v.", diags)
+	}
+
+	c.change("let x = 1;\nlet x = 2;\n{ let = 3; }")
+	diags := c.diagnostics()
+	if len(diags) == 0 {
+		t.Fatalf("Expect syntax errors.")
+	}
+	if diags[0].Range.Start != (lsp.Position{Line: 2, Character: 6}) || diags[0].Severity != lsp.SEVERITY_ERROR {
+		t.Errorf("Wrong diagnostic %+v.", diags[0])
+	}
+
+	c.change("let x = 1;\nlet x = 2;")
+	diags = c.diagnostics()
+	if len(diags) != 1 || diags[0].Code != "R002" || diags[0].Range.Start != (lsp.Position{Line: 1, Character: 4}) {
+		t.Errorf("Expect resolver error, got %+v.", diags)
+	}
+
+	c.change("let x: int = 1;\nx = \"s\";")
+	diags = c.diagnostics()
+	if len(diags) != 1 || diags[0].Code != "T005" || diags[0].Range.Start != (lsp.Position{Line: 1, Character: 4}) {
+		t.Errorf("Expect type error, got %+v.", diags)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+	c.diagnostics()
+
+	tests := []struct {
+		name   string
+		method string
+		params interface{}
+		want   string
+	}{
+		{"local", "textDocument/definition", at(3, 10), "2:6-9"},
+		{"global", "textDocument/definition", at(3, 15), "0:4-8"},
+		{"global declared later", "textDocument/definition", at(3, 23), "11:4-9"},
+		{"function", "textDocument/definition", at(7, 24), "1:3-6"},
+		{"method", "textDocument/definition", at(10, 3), "7:5-11"},
+		{"declaration", "textDocument/definition", at(9, 4), "9:4-5"},
+		{"builtin", "textDocument/definition", at(11, 13), ""},
+		{
+			"parameter references",
+			"textDocument/references",
+			lsp.ReferenceParams{TextDocumentPositionParams: at(1, 7)},
+			"2:12-13",
+		},
+		{
+			"references with declaration",
+			"textDocument/references",
+			map[string]interface{}{"textDocument": at(0, 0).TextDocument, "position": lsp.Position{Line: 3, Character: 23}, "context": map[string]bool{"includeDeclaration": true}},
+			"3:22-27 11:4-9",
+		},
+		{
+			"method references",
+			"textDocument/references",
+			lsp.ReferenceParams{TextDocumentPositionParams: at(7, 6)},
+			"10:2-8",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var locs []lsp.Location
+			if err := c.request(tc.method, tc.params, &locs); err != nil {
+				t.Fatalf("Request failed: %s", err.Message)
+			}
+			if got := locations(locs); got != tc.want {
+				t.Errorf("Wrong locations, got %q, want %q.", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+	c.diagnostics()
+
+	tests := []struct {
+		line, character int
+		want            string
+	}{
+		{7, 24, "fn add(a, b)"},
+		{3, 10, "let sum"},
+		{1, 10, "(parameter) b"},
+		{10, 4, "fn Point.double()"},
+		{9, 9, "class Point"},
+		{11, 13, "builtin len(value)"},
+		{6, 20, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			var hover *lsp.Hover
+			if err := c.request("textDocument/hover", at(tc.line, tc.character), &hover); err != nil {
+				t.Fatalf("Request failed: %s", err.Message)
+			}
+			if tc.want == "" {
+				if hover != nil {
+					t.Errorf("Expect no hover, got %q.", hover.Contents.Value)
+				}
+				return
+			}
+			if hover == nil || !strings.Contains(hover.Contents.Value, tc.want) {
+				t.Errorf("Wrong hover %+v, want %q.", hover, tc.want)
+			}
+		})
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.open(source + "\nfn outer() { fn inner() {} }")
+	c.diagnostics()
+
+	var symbols []lsp.DocumentSymbol
+	c.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": at(0, 0).TextDocument}, &symbols)
+
+	var describe func(symbols []lsp.DocumentSymbol) string
+	describe = func(symbols []lsp.DocumentSymbol) string {
+		parts := []string{}
+		for _, s := range symbols {
+			part := fmt.Sprintf("%s:%d@%d", s.Name, s.Kind, s.SelectionRange.Start.Line)
+			if len(s.Children) > 0 {
+				part += "(" + describe(s.Children) + ")"
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	want := "add:12@1 Point:5@5(init:9@6 double:6@7) outer:12@12(inner:12@12)"
+	if got := describe(symbols); got != want {
+		t.Errorf("Wrong symbols, got %q, want %q.", got, want)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+	c.diagnostics()
+
+	labels := func(line, character int) []string {
+		var items []lsp.CompletionItem
+		if err := c.request("textDocument/completion", at(line, character), &items); err != nil {
+			t.Fatalf("Request failed: %s", err.Message)
+		}
+		result := []string{}
+		for _, item := range items {
+			result = append(result, item.Label)
+		}
+		return result
+	}
+	has := func(labels []string, label string) bool {
+		for _, l := range labels {
+			if l == label {
+				return true
+			}
+		}
+		return false
+	}
+
+	inAdd := labels(3, 2)
+	for _, name := range []string{"a", "b", "sum", "base", "add", "Point", "p", "later", "len", "push"} {
+		if !has(inAdd, name) {
+			t.Errorf("Expect %q in completion %q.", name, inAdd)
+		}
+	}
+	if has(inAdd, "x") || has(inAdd, "this") {
+		t.Errorf("Unexpected names of other scopes in %q.", inAdd)
+	}
+
+	inMethod := labels(6, 16)
+	if !has(inMethod, "x") || !has(inMethod, "this") || has(inMethod, "sum") {
+		t.Errorf("Wrong completion in method %q.", inMethod)
+	}
+
+	// document with syntax error uses the last analysis
+	c.change(source + "\np.")
+	c.diagnostics()
+	if got := strings.Join(labels(12, 2), " "); got != "double init" {
+		t.Errorf("Wrong method completion %q.", got)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	c := newClient(t)
+
+	if err := c.request("workspace/symbol", map[string]string{"query": ""}, nil); err == nil || err.Code != lsp.METHOD_NOT_FOUND {
+		t.Errorf("Expect method not found error, got %+v.", err)
+	}
+	if err := c.request("textDocument/hover", at(0, 0), nil); err == nil {
+		t.Errorf("Expect error for unknown document.")
+	}
+
+	c.request("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
@@ -1,5 +1,7 @@
 package object
 
+import "sort"
+
 type Environment struct {
 	store map[string]Object
 	Outer *Environment
@@ -27,11 +29,34 @@ func (e *Environment) GetGlobal(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Assign to existing variable of the outermost env
+func (e *Environment) AssignGlobal(name string, value Object) bool {
+	env := e
+	for env.Outer != nil {
+		env = env.Outer
+	}
+	if _, ok := env.store[name]; !ok {
+		return false
+	}
+	env.store[name] = value
+	return true
+}
+
 func (e *Environment) GetAt(depth int, name string) (Object, bool) {
 	obj, ok := e.ancestor(depth).store[name]
 	return obj, ok
 }
 
+// Names returns sorted names of variables defined in e, not in its outer environments.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (e *Environment) Set(name string, value Object) Object {
 	e.store[name] = value
 	return value
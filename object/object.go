@@ -2,7 +2,10 @@ package object
 
 import (
 	"bytes"
+	"context"
 	"hash/fnv"
+	"io"
+	"math"
 	"monkey/ast"
 	"monkey/token"
 	"strconv"
@@ -15,16 +18,22 @@ type BuiltinFunction func(args ...Object) Object
 const (
 	NULL_OBJ         = "NULL"
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	STRING_OBJ       = "STRING"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
+	COMPILED_FN_OBJ  = "COMPILED_FUNCTION"
 	BUILTIN_OBJ      = "BUILTIN"
 	CLASS_OBJ        = "CLASS"
 	INSTANCE_OBJ     = "INSTANCE"
+	MODULE_OBJ       = "MODULE"
+	EXCEPTION_OBJ    = "EXCEPTION"
 )
 
 type Object interface {
@@ -43,6 +52,20 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return strconv.FormatInt(i.Value, 10) }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	// keep floats with integral values distinguishable from integers
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -62,17 +85,47 @@ type Array struct {
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
-func (a *Array) Inspect() string {
+func (a *Array) Inspect() string  { return inspect(a, map[Object]bool{}) }
+
+// inspect prints obj with arrays and hashes which are being printed,
+// i.e. which contain themselves, replaced by [...] or {|...|}.
+func inspect(obj Object, visiting map[Object]bool) string {
 	var out bytes.Buffer
 
-	els := []string{}
-	for _, e := range a.Elements {
-		els = append(els, e.Inspect())
-	}
+	switch obj := obj.(type) {
+	case *Array:
+		if visiting[obj] {
+			return "[...]"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		els := []string{}
+		for _, e := range obj.Elements {
+			els = append(els, inspect(e, visiting))
+		}
 
-	out.WriteString("[")
-	out.WriteString(strings.Join(els, ", "))
-	out.WriteString("]")
+		out.WriteString("[")
+		out.WriteString(strings.Join(els, ", "))
+		out.WriteString("]")
+	case *Hash:
+		if visiting[obj] {
+			return "{|...|}"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		els := []string{}
+		for _, pair := range obj.Pairs() {
+			els = append(els, inspect(pair.Key, visiting)+": "+inspect(pair.Value, visiting))
+		}
+
+		out.WriteString("{| ")
+		out.WriteString(strings.Join(els, ", "))
+		out.WriteString(" |}")
+	default:
+		return obj.Inspect()
+	}
 
 	return out.String()
 }
@@ -82,26 +135,59 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash keeps pairs in insertion order, setting value of
+// an existing key does not change its position.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs map[HashKey]HashPair
+	keys  []HashKey
 }
 
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
+func (h *Hash) Len() int { return len(h.keys) }
 
-	els := []string{}
-	for _, pair := range h.Pairs {
-		els = append(els, pair.Key.Inspect()+": "+pair.Value.Inspect())
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	pair, ok := h.pairs[key]
+	return pair, ok
+}
+
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if h.pairs == nil {
+		h.pairs = make(map[HashKey]HashPair)
+	}
+	if _, ok := h.pairs[key]; !ok {
+		h.keys = append(h.keys, key)
 	}
+	h.pairs[key] = pair
+}
 
-	out.WriteString("{| ")
-	out.WriteString(strings.Join(els, ", "))
-	out.WriteString(" |}")
+// Delete removes pair with key and reports whether it was present.
+func (h *Hash) Delete(key HashKey) bool {
+	if _, ok := h.pairs[key]; !ok {
+		return false
+	}
 
-	return out.String()
+	delete(h.pairs, key)
+	for i, k := range h.keys {
+		if k == key {
+			h.keys = append(h.keys[:i], h.keys[i+1:]...)
+			break
+		}
+	}
+
+	return true
 }
 
+// Pairs returns pairs of the hash in insertion order.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, len(h.keys))
+	for i, key := range h.keys {
+		pairs[i] = h.pairs[key]
+	}
+	return pairs
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string  { return inspect(h, map[Object]bool{}) }
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType { return NULL_OBJ }
@@ -114,18 +200,57 @@ type ReturnValue struct {
 func (r *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (r *ReturnValue) Inspect() string  { return r.Value.Inspect() }
 
+// Break and Continue are signals which unwind evaluation up to enclosing loop.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
+	Kind    string
 	Message string
+	Value   Object // set when the error was raised by 'throw'
+	Span    token.Span
+	Stack   []Frame // active calls at the moment of error, outermost first
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "Runtime error: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Span.IsValid() {
+		return "Runtime error at " + e.Span.String() + ": " + e.Message
+	}
+	return "Runtime error: " + e.Message
+}
+
+// Exception is an Error caught by a 'catch' clause, it is an ordinary value
+// which does not unwind the evaluation until thrown again.
+type Exception struct {
+	Error *Error
+}
+
+func (e *Exception) Type() ObjectType { return EXCEPTION_OBJ }
+func (e *Exception) Inspect() string  { return e.Error.Kind + ": " + e.Error.Message }
 
 type Function struct {
+	Name       string
+	Class      *Class // set for methods
 	Parameters []*ast.IdentifierExpr
 	Body       *ast.BlockStmt
 	Env        *Environment
 	IsInit     bool
+	// Locals are depths of variables resolved in the program defining
+	// the function, the evaluator looks variables of Body up in them
+	Locals map[ast.Expression]int
+
+	// closures created by the virtual machine carry bytecode instead of Env
+	Compiled *CompiledFunction
+	Upvalues []*Upvalue
+	Receiver *Instance // bound 'this' of compiled methods
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -147,18 +272,87 @@ func (f *Function) Inspect() string {
 }
 
 func (f *Function) Bind(inst *Instance) *Function {
+	if f.Compiled != nil {
+		bound := *f
+		bound.Receiver = inst
+		return &bound
+	}
+
 	env := NewEnclosedEnvironment(f.Env)
 	env.Set(token.THIS_KEYWORD, inst)
 	return &Function{
+		Name:       f.Name,
+		Class:      f.Class,
 		Parameters: f.Parameters,
 		Body:       f.Body,
 		Env:        env,
 		IsInit:     f.IsInit,
+		Locals:     f.Locals,
 	}
 }
 
+// CompiledFunction is a function body lowered to bytecode by the compiler.
+// Parameters and Body are kept for inspection only.
+type CompiledFunction struct {
+	Instructions []byte
+	Spans        []token.Span // source span of the instruction at given offset
+	NumParams    int
+	Captures     []Capture
+	Name         string
+	IsInit       bool
+	Parameters   []*ast.IdentifierExpr
+	Body         *ast.BlockStmt
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FN_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Name == "" {
+		return "<compiled fn>"
+	}
+	return "<compiled fn " + cf.Name + ">"
+}
+
+// Capture tells where a closure takes its upvalue from when created: a local
+// variable slot of the enclosing function or one of the enclosing upvalues.
+type Capture struct {
+	Local bool
+	Index int
+}
+
+// Upvalue is a variable captured by a compiled closure. While the variable
+// still lives on the VM stack Location points into the stack, once it goes
+// out of scope the value is moved to Closed and Location points there.
+type Upvalue struct {
+	Location *Object
+	Closed   Object
+}
+
+func (u *Upvalue) Close() {
+	u.Closed = *u.Location
+	u.Location = &u.Closed
+}
+
+// CallFunction calls function value of the running program,
+// it is provided to builtins by the backend executing the program.
+type CallFunction func(fn Object, args ...Object) Object
+
 type Builtin struct {
 	Fn BuiltinFunction
+
+	// HigherOrder is used instead of Fn by builtins calling back functions passed to them
+	HigherOrder func(call CallFunction, args ...Object) Object
+
+	// Cancellable is used instead of Fn by builtins waiting for the system,
+	// they give up once ctx of the running program is done
+	Cancellable func(ctx context.Context, args ...Object) Object
+
+	// Output is used instead of Fn by builtins printing, they write to
+	// out of the running program
+	Output func(out io.Writer, args ...Object) Object
+
+	// Size estimates bytes the builtin allocates for args, limits of memory
+	// are checked against it before the builtin runs, nil means small results
+	Size func(args ...Object) int64
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -198,6 +392,28 @@ func (i *Instance) Inspect() string {
 	return "<instance of " + i.Class.Name.Value + ">"
 }
 
+// Module is a namespace holding top-level bindings of imported file.
+type Module struct {
+	Path string
+	Env  *Environment // top-level environment of the module
+
+	// Lookup finds top-level bindings instead of Env in modules run by
+	// the virtual machine, which keeps globals outside of environments.
+	Lookup func(name string) (Object, bool)
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string {
+	return "<module " + m.Path + ">"
+}
+
+func (m *Module) Get(name string) (Object, bool) {
+	if m.Lookup != nil {
+		return m.Lookup(name)
+	}
+	return m.Env.GetAt(0, name)
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -219,6 +435,15 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey of float with integral value is the key of equal integer,
+// so 1 and 1.0 address the same hash entry as 1 == 1.0 holds.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -2,6 +2,7 @@ package object_test
 
 import (
 	"monkey/object"
+	"monkey/token"
 	"testing"
 )
 
@@ -18,3 +19,123 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("Strings %v and %v should have different HashKey.", h1, diff)
 	}
 }
+
+func TestFloat(t *testing.T) {
+	tt := []struct {
+		value float64
+		want  string
+	}{
+		{value: 1.5, want: "1.5"},
+		{value: 2, want: "2.0"},
+		{value: -0.25, want: "-0.25"},
+		{value: 1e21, want: "1e+21"},
+	}
+
+	for _, tc := range tt {
+		f := &object.Float{Value: tc.value}
+		if f.Inspect() != tc.want {
+			t.Errorf("Wrong float representation, got %q, want %q.", f.Inspect(), tc.want)
+		}
+	}
+
+	if (&object.Float{Value: 2}).HashKey() != (&object.Integer{Value: 2}).HashKey() {
+		t.Errorf("Float 2.0 and integer 2 should have same HashKey.")
+	}
+
+	if (&object.Float{Value: 2.5}).HashKey() == (&object.Float{Value: 2.25}).HashKey() {
+		t.Errorf("Floats 2.5 and 2.25 should have different HashKey.")
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	h := &object.Hash{}
+	set := func(key string, value int64) {
+		k := &object.String{Value: key}
+		h.Set(k.HashKey(), object.HashPair{Key: k, Value: &object.Integer{Value: value}})
+	}
+
+	set("c", 1)
+	set("a", 2)
+	set("b", 3)
+	set("a", 4)
+	h.Delete((&object.String{Value: "c"}).HashKey())
+	set("c", 5)
+
+	want := "{| a: 4, b: 3, c: 5 |}"
+	if h.Inspect() != want {
+		t.Errorf("Wrong hash order, got %s, want %s.", h.Inspect(), want)
+	}
+
+	if h.Delete((&object.String{Value: "x"}).HashKey()) {
+		t.Errorf("Delete of missing key should report false.")
+	}
+	if h.Len() != 3 {
+		t.Errorf("Wrong hash length, got %d, want 3.", h.Len())
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	at := func(line, col int) token.Span {
+		return token.Span{Start: token.Position{File: "a.mk", Line: line, Column: col}}
+	}
+
+	err := &object.Error{
+		Message: "oops",
+		Span:    at(3, 5),
+		Stack: []object.Frame{
+			{Function: "f", CallSite: at(10, 1)},
+			{Function: "m", Class: "A", CallSite: at(7, 2)},
+		},
+	}
+
+	want := `Traceback (most recent call last):
+  File "a.mk", line 10, column 1, in <script>
+  File "a.mk", line 7, column 2, in f
+  File "a.mk", line 3, column 5, in A.m
+Runtime error: oops`
+
+	if got := err.StackTrace(); got != want {
+		t.Errorf("Wrong stack trace, got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestErrorStackTraceRepeated(t *testing.T) {
+	at := func(line, col int) token.Span {
+		return token.Span{Start: token.Position{File: "a.mk", Line: line, Column: col}}
+	}
+
+	err := &object.Error{Message: "stack overflow", Span: at(1, 15)}
+	err.Stack = append(err.Stack, object.Frame{Function: "f", CallSite: at(2, 1)})
+	for i := 0; i < 10; i++ {
+		err.Stack = append(err.Stack, object.Frame{Function: "f", CallSite: at(1, 15)})
+	}
+
+	want := `Traceback (most recent call last):
+  File "a.mk", line 2, column 1, in <script>
+  File "a.mk", line 1, column 15, in f
+  File "a.mk", line 1, column 15, in f
+  File "a.mk", line 1, column 15, in f
+  [Previous line repeated 8 more times]
+Runtime error: stack overflow`
+
+	if got := err.StackTrace(); got != want {
+		t.Errorf("Wrong stack trace, got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	inner := &object.Array{Elements: []object.Object{&object.Integer{Value: 1}}}
+	arr := &object.Array{Elements: []object.Object{inner, inner}}
+	arr.Elements = append(arr.Elements, arr)
+
+	h := &object.Hash{}
+	key := &object.String{Value: "k"}
+	h.Set(key.HashKey(), object.HashPair{Key: key, Value: h})
+	arr.Elements = append(arr.Elements, h)
+
+	// only containers which contain themselves are elided, shared ones are printed
+	want := "[[1], [1], [...], {| k: {|...|} |}]"
+	if arr.Inspect() != want {
+		t.Errorf("Wrong inspection, got %s, want %s.", arr.Inspect(), want)
+	}
+}
@@ -0,0 +1,86 @@
+package object
+
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+// Frame is a single function call in evaluator call stack.
+type Frame struct {
+	Function string
+	Class    string
+	CallSite token.Span
+}
+
+func (f Frame) Name() string {
+	if f.Class != "" {
+		return f.Class + "." + f.Function
+	}
+	return f.Function
+}
+
+const SCRIPT_FRAME_NAME = "<script>"
+
+const MAX_REPEATED_LINES = 3
+
+// StackTrace formats error in a way similar to Python traceback:
+//
+//	Traceback (most recent call last):
+//	  File "script.mk", line 10, column 1, in <script>
+//	  File "script.mk", line 3, column 5, in Point.init
+//	Runtime error: type mismatch: INTEGER + STRING
+//
+// Lines repeated more than MAX_REPEATED_LINES times in a row, like calls
+// of deep recursion, are collapsed.
+func (e *Error) StackTrace() string {
+	if len(e.Stack) == 0 {
+		return e.Inspect()
+	}
+
+	var out strings.Builder
+
+	out.WriteString("Traceback (most recent call last):\n")
+
+	// every frame is executing the call to the next one,
+	// the innermost frame is executing the erroneous expression
+	lines := []string{}
+	name := SCRIPT_FRAME_NAME
+	for _, frame := range e.Stack {
+		lines = append(lines, traceLine(frame.CallSite.Start, name))
+		name = frame.Name()
+	}
+	lines = append(lines, traceLine(e.Span.Start, name))
+
+	repeated := 0
+	for i, line := range lines {
+		if i > 0 && line == lines[i-1] {
+			repeated++
+		} else {
+			writeRepeated(&out, repeated)
+			repeated = 0
+		}
+		if repeated < MAX_REPEATED_LINES {
+			out.WriteString(line)
+		}
+	}
+	writeRepeated(&out, repeated)
+
+	out.WriteString("Runtime error: " + e.Message)
+
+	return out.String()
+}
+
+func traceLine(pos token.Position, name string) string {
+	file := pos.File
+	if file == "" {
+		file = "<input>"
+	}
+	return fmt.Sprintf("  File %q, line %d, column %d, in %s\n", file, pos.Line, pos.Column, name)
+}
+
+func writeRepeated(out *strings.Builder, repeated int) {
+	if repeated >= MAX_REPEATED_LINES {
+		fmt.Fprintf(out, "  [Previous line repeated %d more times]\n", repeated-MAX_REPEATED_LINES+1)
+	}
+}
@@ -26,6 +26,7 @@ func (p *Parser) parseArrayLiteralExpr() ast.Expression {
 	if !p.expectPeek(token.RBRACKET, ERR_ARR_LITERAL_END_BRACKET) {
 		return nil
 	}
+	arr.Rbracket = p.currToken.Start
 
 	return arr
 }
@@ -4,8 +4,9 @@ import (
 	"monkey/ast"
 )
 
-const ERR_WRONG_ASSIGNMENT_TARGET = "Assignment target should be an identifier or field."
+const ERR_WRONG_ASSIGNMENT_TARGET = "Assignment target should be an identifier, field or index."
 
+// parseAssignExpr parses both plain '=' and compound assignments like '+='.
 func (p *Parser) parseAssignExpr(left ast.Expression) ast.Expression {
 	switch node := left.(type) {
 	case *ast.IdentifierExpr:
@@ -29,6 +30,17 @@ func (p *Parser) parseAssignExpr(left ast.Expression) ast.Expression {
 
 		return expr
 
+	case *ast.IndexExpr:
+		expr := &ast.IndexSetExpr{
+			Token: p.currToken,
+			Left:  node.Left,
+			Index: node.Index,
+		}
+		p.nextToken()
+		expr.Value = p.parseExpression(LOWEST)
+
+		return expr
+
 	default:
 		p.error(ERR_WRONG_ASSIGNMENT_TARGET)
 		return nil
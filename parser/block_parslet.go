@@ -18,6 +18,9 @@ func (p *Parser) parseBlockStmt() *ast.BlockStmt {
 	}
 
 	p.nextToken()
+	if p.currToken.Type == token.RBRACE {
+		block.Rbrace = p.currToken.Start
+	}
 
 	return block
 }
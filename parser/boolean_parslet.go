@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"fmt"
 	"monkey/ast"
 	"strconv"
 )
@@ -15,8 +14,7 @@ func (p *Parser) parseBoolLiteralExpr() ast.Expression {
 			Value: val,
 		}
 	} else {
-		msg := fmt.Sprintf(ERR_COULD_NOT_PARSE_BOOL, p.currToken.Literal, err)
-		p.error(msg)
+		p.error(ERR_COULD_NOT_PARSE_BOOL, p.currToken.Literal, err)
 		return nil
 	}
 }
@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+func (p *Parser) parseBreakStmt() *ast.BreakStmt {
+	stmt := &ast.BreakStmt{Token: p.currToken}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStmt() *ast.ContinueStmt {
+	stmt := &ast.ContinueStmt{Token: p.currToken}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
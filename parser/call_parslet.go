@@ -9,8 +9,8 @@ const ERR_CALL_ARGUMENTS_END_RPAREN = "Expect function call arguments list to en
 
 func (p *Parser) parseCallExpr(left ast.Expression) ast.Expression {
 	expr := &ast.CallExpr{
-		Token:     p.currToken,
-		Function:  left,
+		Token:    p.currToken,
+		Function: left,
 	}
 
 	for !p.peekTokenIs(token.EOF) && !p.peekTokenIs(token.RPAREN) {
@@ -26,6 +26,7 @@ func (p *Parser) parseCallExpr(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RPAREN, ERR_CALL_ARGUMENTS_END_RPAREN) {
 		return nil
 	}
+	expr.Rparen = p.currToken.Start
 
 	return expr
 }
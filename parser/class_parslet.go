@@ -54,6 +54,7 @@ func (p *Parser) parseClassStmt() *ast.ClassStmt {
 	if !p.expectPeek(token.RBRACE, ERR_CLASS_BODY_END_RBRACE) {
 		return nil
 	}
+	class.Rbrace = p.currToken.Start
 
 	return class
 }
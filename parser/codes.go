@@ -0,0 +1,77 @@
+package parser
+
+// errorCodes maps error message formats to stable diagnostic codes.
+var errorCodes = map[string]string{
+	ERR_NO_PREFIX_PARSLET_FOUND:           "P001",
+	ERR_ILLEGAL_TOKEN:                     "P002",
+	ERR_COULD_NOT_PARSE_INT:               "P003",
+	ERR_COULD_NOT_PARSE_BOOL:              "P004",
+	ERR_COULD_NOT_PARSE_FLOAT:             "P005",
+	ERR_INVALID_ESCAPE:                    "P006",
+	ERR_UNTERMINATED_STRING:               "P007",
+	ERR_TEMPLATE_EMPTY_EXPR:               "P008",
+	ERR_TEMPLATE_NO_RBRACE:                "P009",
+	ERR_LET_NO_IDENTIFIER_AFTER_LET:       "P010",
+	ERR_LET_NO_ASSIGN_AFTER_IDENTIFIER:    "P011",
+	ERR_LET_NO_SEMI_AFTER_LET_STMT:        "P012",
+	ERR_WRONG_ASSIGNMENT_TARGET:           "P013",
+	ERR_INVALID_NUMBER:                    "P014",
+	ERR_GROUPING_RIGHT_PAREN_MISSING:      "P020",
+	ERR_IF_CONDITION_START_LPAREN:         "P021",
+	ERR_IF_CONDITION_END_RPAREN:           "P022",
+	ERR_FN_PARAMETERS_START_LPAREN:        "P030",
+	ERR_FN_PARAMETERS_END_RPAREN:          "P031",
+	ERR_FN_PARAMETER_SHOULD_BE_IDENTIFIER: "P032",
+	ERR_FN_BODY_START_LBRACE:              "P033",
+	ERR_FN_BODY_END_RBRACE:                "P034",
+	ERR_CALL_ARGUMENTS_END_RPAREN:         "P035",
+	ERR_CLASS_NO_CLASSNAME:                "P040",
+	ERR_CLASS_NO_SUPER_NAME:               "P041",
+	ERR_CLASS_BODY_START_LBRACE:           "P042",
+	ERR_CLASS_BODY_END_RBRACE:             "P043",
+	ERR_CLASS_WRONG_DEFINITION:            "P044",
+	ERR_GET_NO_PROP_NAME:                  "P045",
+	ERR_SUPER_NO_DOT:                      "P046",
+	ERR_SUPER_NO_IDENTIFIER_AFTER_DOT:     "P047",
+	ERR_ARR_LITERAL_END_BRACKET:           "P050",
+	ERR_INDEX_END_BRACKET:                 "P051",
+	ERR_HASH_LITERAL_END_BRACE:            "P052",
+	ERR_HASH_COLON_AFTER_KEY:              "P053",
+	ERR_HASH_NO_COMMA:                     "P054",
+	ERR_WHILE_CONDITION_START_LPAREN:      "P060",
+	ERR_WHILE_CONDITION_END_RPAREN:        "P061",
+	ERR_FOR_START_LPAREN:                  "P062",
+	ERR_FOR_END_RPAREN:                    "P063",
+	ERR_FOR_NO_SEMI_AFTER_INIT:            "P064",
+	ERR_FOR_NO_SEMI_AFTER_COND:            "P065",
+	ERR_FOR_IN_NO_IN:                      "P066",
+	ERR_FOR_IN_BODY_LBRACE:                "P067",
+	ERR_IMPORT_NO_PATH:                    "P070",
+	ERR_IMPORT_NO_AS:                      "P071",
+	ERR_IMPORT_NO_NAME:                    "P072",
+	ERR_IMPORT_NO_SEMI:                    "P073",
+	ERR_TRY_BODY_START_LBRACE:             "P080",
+	ERR_TRY_NO_CATCH_OR_FINALLY:           "P081",
+	ERR_CATCH_PARAM_START_LPAREN:          "P082",
+	ERR_CATCH_NO_PARAM:                    "P083",
+	ERR_CATCH_PARAM_END_RPAREN:            "P084",
+	ERR_CATCH_BODY_START_LBRACE:           "P085",
+	ERR_FINALLY_BODY_START_LBRACE:         "P086",
+	ERR_THROW_NO_VALUE:                    "P087",
+	ERR_TYPE_NO_NAME:                      "P090",
+}
+
+// errorHints are suggestions attached to diagnostics with given message format.
+var errorHints = map[string]string{
+	ERR_LET_NO_SEMI_AFTER_LET_STMT:     "add ';' at the end of the statement",
+	ERR_LET_NO_ASSIGN_AFTER_IDENTIFIER: "use 'let name = value;' or 'let name;'",
+	ERR_WRONG_ASSIGNMENT_TARGET:        "only variables, instance fields and elements of arrays or hashes can be assigned to",
+	ERR_CLASS_WRONG_DEFINITION:         "declare methods with 'fn name() { ... }'",
+	ERR_HASH_NO_COMMA:                  "hash literals look like '{| key: value, key: value |}'",
+	ERR_IMPORT_NO_AS:                   "use 'import \"path/to/module.mk\" as name;'",
+	ERR_TRY_NO_CATCH_OR_FINALLY:        "use 'try { ... } catch (e) { ... }' or add a 'finally { ... }' block",
+	ERR_INVALID_ESCAPE:                 "valid escapes are \\n, \\t, \\r, \\0, \\\\, \\\", \\$ and \\u{hex code}",
+	ERR_TYPE_NO_NAME:                   "types are int, float, string, bool, null, array, hash, fn, any or class names",
+	ERR_INVALID_NUMBER:                 "exponents need digits and follow digits of the fraction, e.g. 1e3, 1.0e3 or 2.5E-3",
+	ERR_UNTERMINATED_STRING:            "close the string with '\"' on the same line or use `...` for multiline strings",
+}
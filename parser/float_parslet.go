@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"monkey/ast"
+	"strconv"
+)
+
+const (
+	ERR_COULD_NOT_PARSE_FLOAT = "Could not parse %q as float: %v"
+	ERR_INVALID_NUMBER        = "Invalid number literal '%s'."
+)
+
+func (p *Parser) parseFloatLiteralExpr() ast.Expression {
+	if val, err := strconv.ParseFloat(p.currToken.Literal, 64); err == nil {
+		return &ast.FloatLiteralExpr{
+			Token: p.currToken,
+			Value: val,
+		}
+	} else {
+		p.error(ERR_COULD_NOT_PARSE_FLOAT, p.currToken.Literal, err.Error())
+		return nil
+	}
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+const (
+	ERR_FOR_START_LPAREN       = "Expect 'for' clauses to start with '('."
+	ERR_FOR_END_RPAREN         = "Expect 'for' clauses to end with ')'."
+	ERR_FOR_NO_SEMI_AFTER_INIT = "Expect ';' after 'for' loop initializer."
+	ERR_FOR_NO_SEMI_AFTER_COND = "Expect ';' after 'for' loop condition."
+	ERR_FOR_IN_NO_IN           = "Expect 'in' after 'for' loop variable."
+	ERR_FOR_IN_BODY_LBRACE     = "Expect body of 'for' loop without parentheses to start with '{'."
+)
+
+// parseForStmt parses both C-style loops
+//
+//	for (let i = 0; i < 10; i = i + 1) body
+//
+// and loops over collections
+//
+//	for (x in iterable) body
+//	for x in iterable { ... }
+//
+// where the body of the loop without parentheses has to be a block, so it
+// can not be mistaken for continuation of the iterable.
+func (p *Parser) parseForStmt() ast.Statement {
+	tok := p.currToken
+
+	if p.peekTokenIs(token.IDENTIFIER) {
+		p.nextToken()
+		return p.parseForInStmt(tok, false)
+	}
+
+	if !p.expectPeek(token.LPAREN, ERR_FOR_START_LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	if p.currToken.Type == token.IDENTIFIER && p.peekTokenIs(token.IN) {
+		return p.parseForInStmt(tok, true)
+	}
+
+	stmt := &ast.ForStmt{Token: tok}
+
+	switch p.currToken.Type {
+	case token.SEMICOLON:
+	case token.LET:
+		init := p.parseLetStmt()
+		if init == nil {
+			return nil
+		}
+		stmt.Init = init
+	default:
+		init := &ast.ExpressionStmt{Token: p.currToken}
+		init.Expression = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.SEMICOLON, ERR_FOR_NO_SEMI_AFTER_INIT) {
+			return nil
+		}
+		stmt.Init = init
+	}
+
+	p.nextToken()
+	if p.currToken.Type != token.SEMICOLON {
+		stmt.Condition = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.SEMICOLON, ERR_FOR_NO_SEMI_AFTER_COND) {
+			return nil
+		}
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		stmt.Update = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.RPAREN, ERR_FOR_END_RPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Body = p.parseStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForInStmt(tok token.Token, parenthesized bool) ast.Statement {
+	stmt := &ast.ForInStmt{
+		Token:    tok,
+		Variable: p.parseIdentifierExpr().(*ast.IdentifierExpr),
+	}
+
+	if !p.expectPeek(token.IN, ERR_FOR_IN_NO_IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if parenthesized {
+		if !p.expectPeek(token.RPAREN, ERR_FOR_END_RPAREN) {
+			return nil
+		}
+		p.nextToken()
+	} else if !p.expectPeek(token.LBRACE, ERR_FOR_IN_BODY_LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
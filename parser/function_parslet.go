@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"fmt"
 	"monkey/ast"
 	"monkey/token"
 )
@@ -21,12 +20,17 @@ func (p *Parser) parseFunctionExpr() ast.Expression {
 		return nil
 	}
 
-	fn.Parameters = p.parseFunctionParameters()
+	fn.Parameters, fn.ParameterTypes = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.RPAREN, ERR_FN_PARAMETERS_END_RPAREN) {
 		return nil
 	}
 
+	var ok bool
+	if fn.ReturnType, ok = p.parseTypeAnnotation(); !ok {
+		return nil
+	}
+
 	if !p.expectPeek(token.LBRACE, ERR_FN_BODY_START_LBRACE) {
 		return nil
 	}
@@ -53,12 +57,17 @@ func (p *Parser) parseFunctionDefinition() *ast.LetStmt {
 		return nil
 	}
 
-	fn.Parameters = p.parseFunctionParameters()
+	fn.Parameters, fn.ParameterTypes = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.RPAREN, ERR_FN_PARAMETERS_END_RPAREN) {
 		return nil
 	}
 
+	var ok bool
+	if fn.ReturnType, ok = p.parseTypeAnnotation(); !ok {
+		return nil
+	}
+
 	if !p.expectPeek(token.LBRACE, ERR_FN_BODY_START_LBRACE) {
 		return nil
 	}
@@ -78,27 +87,43 @@ func (p *Parser) parseFunctionDefinition() *ast.LetStmt {
 		Token: token.Token{
 			Type:    token.LET,
 			Literal: "let",
+			Start:   fn.Token.Start,
+			End:     fn.Token.End,
 		},
 		Name:  name,
 		Value: fn,
 	}
 }
 
-func (p *Parser) parseFunctionParameters() (params []*ast.IdentifierExpr) {
+// parseFunctionParameters returns parameters and their annotations,
+// the annotations are nil when none of parameters is annotated.
+func (p *Parser) parseFunctionParameters() (params []*ast.IdentifierExpr, types []*ast.TypeAnnotation) {
+	annotated := false
+
 	for !p.peekTokenIs(token.EOF) && !p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
 
 		if p.currToken.Type != token.IDENTIFIER {
-			p.error(fmt.Sprintf(ERR_FN_PARAMETER_SHOULD_BE_IDENTIFIER, p.currToken.Literal))
-			return nil
+			p.error(ERR_FN_PARAMETER_SHOULD_BE_IDENTIFIER, p.currToken.Literal)
+			return nil, nil
 		} else {
 			params = append(params, p.parseIdentifierExpr().(*ast.IdentifierExpr))
 		}
 
+		typ, ok := p.parseTypeAnnotation()
+		if !ok {
+			return nil, nil
+		}
+		annotated = annotated || typ != nil
+		types = append(types, typ)
+
 		if p.peekTokenIs(token.COMMA) {
 			p.nextToken()
 		}
 	}
 
+	if !annotated {
+		types = nil
+	}
 	return
 }
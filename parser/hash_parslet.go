@@ -12,10 +12,7 @@ const (
 )
 
 func (p *Parser) parseHashLiteral() ast.Expression {
-	hash := &ast.HashLiteralExpr{
-		Token: p.currToken,
-		Pairs: make(map[ast.Expression]ast.Expression),
-	}
+	hash := &ast.HashLiteralExpr{Token: p.currToken}
 
 	for !p.peekTokenIs(token.EOF) && !p.peekTokenIs(token.RHASHBRACE) {
 		p.nextToken()
@@ -35,7 +32,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 			return nil
 		}
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RHASHBRACE) && !p.expectPeek(token.COMMA, ERR_HASH_NO_COMMA) {
 			return nil
@@ -45,6 +42,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RHASHBRACE, ERR_HASH_LITERAL_END_BRACE) {
 		return nil
 	}
+	hash.Rbrace = p.currToken.Start
 
 	return hash
 }
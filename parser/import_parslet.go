@@ -0,0 +1,35 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+const ERR_IMPORT_NO_PATH = "Expect module path string after 'import' keyword."
+const ERR_IMPORT_NO_AS = "Expect 'as' after module path."
+const ERR_IMPORT_NO_NAME = "Expect module name after 'as'."
+const ERR_IMPORT_NO_SEMI = "Expect ';' after 'import' statement."
+
+func (p *Parser) parseImportStmt() *ast.ImportStmt {
+	stmt := &ast.ImportStmt{Token: p.currToken}
+
+	if !p.expectPeek(token.STRING, ERR_IMPORT_NO_PATH) {
+		return nil
+	}
+	stmt.Path = p.parseStringLiteralExpr().(*ast.StringLiteralExpr)
+
+	if !p.expectPeek(token.AS, ERR_IMPORT_NO_AS) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENTIFIER, ERR_IMPORT_NO_NAME) {
+		return nil
+	}
+	stmt.Name = p.parseIdentifierExpr().(*ast.IdentifierExpr)
+
+	if !p.expectPeek(token.SEMICOLON, ERR_IMPORT_NO_SEMI) {
+		return nil
+	}
+
+	return stmt
+}
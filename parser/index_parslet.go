@@ -14,13 +14,45 @@ func (p *Parser) parseIndexExpr(left ast.Expression) ast.Expression {
 		Index: nil,
 	}
 
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpr(idx.Token, left, nil)
+	}
+
 	p.nextToken()
 
 	idx.Index = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpr(idx.Token, left, idx.Index)
+	}
+
 	if !p.expectPeek(token.RBRACKET, ERR_INDEX_END_BRACKET) {
 		return nil
 	}
+	idx.Rbracket = p.currToken.Start
 
 	return idx
 }
+
+// parseSliceExpr continues index expression when ':' follows optional low bound.
+func (p *Parser) parseSliceExpr(lbracket token.Token, left ast.Expression, low ast.Expression) ast.Expression {
+	slice := &ast.SliceExpr{
+		Token: lbracket,
+		Left:  left,
+		Low:   low,
+	}
+
+	p.nextToken()
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.High = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.RBRACKET, ERR_INDEX_END_BRACKET) {
+		return nil
+	}
+	slice.Rbracket = p.currToken.Start
+
+	return slice
+}
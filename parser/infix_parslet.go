@@ -1,6 +1,9 @@
 package parser
 
-import "monkey/ast"
+import (
+	"monkey/ast"
+	"monkey/token"
+)
 
 func (p *Parser) parseInfixExpr(left ast.Expression) ast.Expression {
 	expr := &ast.InfixExpr{
@@ -10,6 +13,10 @@ func (p *Parser) parseInfixExpr(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.currPrecedence()
+	if expr.Token.Type == token.POWER {
+		// right associative, 2 ** 3 ** 2 is 2 ** (3 ** 2)
+		precedence--
+	}
 	p.nextToken()
 	expr.Right = p.parseExpression(precedence)
 
@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"fmt"
 	"monkey/ast"
 	"strconv"
 )
@@ -15,8 +14,7 @@ func (p *Parser) parseIntLiteralExpr() ast.Expression {
 			Value: val,
 		}
 	} else {
-		msg := fmt.Sprintf(ERR_COULD_NOT_PARSE_INT, p.currToken.Literal, err.Error())
-		p.error(msg)
+		p.error(ERR_COULD_NOT_PARSE_INT, p.currToken.Literal, err.Error())
 		return nil
 	}
 }
@@ -18,9 +18,14 @@ func (p *Parser) parseLetStmt() *ast.LetStmt {
 
 	name := p.parseIdentifierExpr().(*ast.IdentifierExpr)
 
+	typ, ok := p.parseTypeAnnotation()
+	if !ok {
+		return nil
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
-		return &ast.LetStmt{Token: tok, Name: name, Value: nil}
+		return &ast.LetStmt{Token: tok, Name: name, Type: typ, Value: nil}
 	}
 
 	if !p.expectPeek(token.ASSIGN, ERR_LET_NO_ASSIGN_AFTER_IDENTIFIER) {
@@ -38,5 +43,5 @@ func (p *Parser) parseLetStmt() *ast.LetStmt {
 		return nil
 	}
 
-	return &ast.LetStmt{Token: tok, Name: name, Value: value}
+	return &ast.LetStmt{Token: tok, Name: name, Type: typ, Value: value}
 }
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"monkey/ast"
+	"monkey/diagnostics"
 	"monkey/lexer"
 	"monkey/token"
 )
@@ -16,29 +17,47 @@ const (
 	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > || <
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // << || >>
 	SUM         // +
-	PRODUCT     // *
-	PREFIX      // -X || !x
+	PRODUCT     // * || / || %
+	PREFIX      // -X || !x || ~x
+	POWER       // **, binds tighter than prefix operators on its left: -2 ** 2 == -4
 	CALL        // function()
 	GET         // obj.field || arr[]
 	//  isn't call/get/index expressions should have the same precedence
 )
 
 var precedences = map[token.TokenType]int{
-	token.ASSIGN:      ASSIGN,
-	token.OR:          OR,
-	token.AND:         AND,
-	token.EQUAL_EQUAL: EQUALS,
-	token.NOT_EQUAL:   EQUALS,
-	token.LESS:        LESSGREATER,
-	token.GREATER:     LESSGREATER,
-	token.PLUS:        SUM,
-	token.MINUS:       SUM,
-	token.SLASH:       PRODUCT,
-	token.STAR:        PRODUCT,
-	token.LPAREN:      CALL,
-	token.DOT:         GET,
-	token.LBRACKET:    GET,
+	token.ASSIGN:        ASSIGN,
+	token.PLUS_ASSIGN:   ASSIGN,
+	token.MINUS_ASSIGN:  ASSIGN,
+	token.STAR_ASSIGN:   ASSIGN,
+	token.SLASH_ASSIGN:  ASSIGN,
+	token.OR:            OR,
+	token.AND:           AND,
+	token.EQUAL_EQUAL:   EQUALS,
+	token.NOT_EQUAL:     EQUALS,
+	token.LESS:          LESSGREATER,
+	token.GREATER:       LESSGREATER,
+	token.LESS_EQUAL:    LESSGREATER,
+	token.GREATER_EQUAL: LESSGREATER,
+	token.BIT_OR:        BIT_OR,
+	token.BIT_XOR:       BIT_XOR,
+	token.BIT_AND:       BIT_AND,
+	token.SHIFT_LEFT:    SHIFT,
+	token.SHIFT_RIGHT:   SHIFT,
+	token.PLUS:          SUM,
+	token.MINUS:         SUM,
+	token.SLASH:         PRODUCT,
+	token.STAR:          PRODUCT,
+	token.PERCENT:       PRODUCT,
+	token.POWER:         POWER,
+	token.LPAREN:        CALL,
+	token.DOT:           GET,
+	token.LBRACKET:      GET,
 }
 
 const (
@@ -54,7 +73,7 @@ type (
 type Parser struct {
 	l *lexer.Lexer
 
-	errors   []string
+	errors   []diagnostics.Diagnostic
 	needSync bool
 
 	currToken token.Token
@@ -67,7 +86,7 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := Parser{
 		l:      l,
-		errors: []string{},
+		errors: []diagnostics.Diagnostic{},
 	}
 
 	p.nextToken()
@@ -76,12 +95,16 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParslets = make(map[token.TokenType]prefixParslet)
 	p.registerPrefix(token.IDENTIFIER, p.parseIdentifierExpr)
 	p.registerPrefix(token.INT, p.parseIntLiteralExpr)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteralExpr)
 	p.registerPrefix(token.TRUE, p.parseBoolLiteralExpr)
 	p.registerPrefix(token.FALSE, p.parseBoolLiteralExpr)
 	p.registerPrefix(token.NULL, p.parseNullExpr)
 	p.registerPrefix(token.STRING, p.parseStringLiteralExpr)
+	p.registerPrefix(token.RAW_STRING, p.parseStringLiteralExpr)
+	p.registerPrefix(token.TEMPLATE_HEAD, p.parseTemplateExpr)
 	p.registerPrefix(token.BANG, p.parsePrefixExpr)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpr)
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpr)
 	p.registerPrefix(token.LPAREN, p.parseGroupingExpr)
 	p.registerPrefix(token.IF, p.parseIfExpr)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionExpr)
@@ -97,12 +120,25 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.SLASH, p.parseInfixExpr)
 	p.registerInfix(token.GREATER, p.parseInfixExpr)
 	p.registerInfix(token.LESS, p.parseInfixExpr)
+	p.registerInfix(token.GREATER_EQUAL, p.parseInfixExpr)
+	p.registerInfix(token.LESS_EQUAL, p.parseInfixExpr)
+	p.registerInfix(token.PERCENT, p.parseInfixExpr)
+	p.registerInfix(token.POWER, p.parseInfixExpr)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpr)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpr)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpr)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpr)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpr)
 	p.registerInfix(token.EQUAL_EQUAL, p.parseInfixExpr)
 	p.registerInfix(token.NOT_EQUAL, p.parseInfixExpr)
 	p.registerInfix(token.OR, p.parseInfixExpr)
 	p.registerInfix(token.AND, p.parseInfixExpr)
 	p.registerInfix(token.LPAREN, p.parseCallExpr)
 	p.registerInfix(token.ASSIGN, p.parseAssignExpr)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpr)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpr)
+	p.registerInfix(token.STAR_ASSIGN, p.parseAssignExpr)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpr)
 	p.registerInfix(token.DOT, p.parseGetExpr)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpr)
 
@@ -128,7 +164,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return prog
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []diagnostics.Diagnostic {
 	return p.errors
 }
 
@@ -142,6 +178,20 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseBlockStmt()
 	case token.CLASS:
 		return p.parseClassStmt()
+	case token.WHILE:
+		return p.parseWhileStmt()
+	case token.FOR:
+		return p.parseForStmt()
+	case token.BREAK:
+		return p.parseBreakStmt()
+	case token.CONTINUE:
+		return p.parseContinueStmt()
+	case token.IMPORT:
+		return p.parseImportStmt()
+	case token.TRY:
+		return p.parseTryStmt()
+	case token.THROW:
+		return p.parseThrowStmt()
 	default:
 		if p.currToken.Type == token.FUNCTION && p.peekTokenIs(token.IDENTIFIER) {
 			return p.parseFunctionDefinition()
@@ -187,11 +237,25 @@ func (p *Parser) peekTokenIs(tt token.TokenType) bool {
 	return p.peekToken.Type == tt
 }
 
-func (p *Parser) error(msg string) {
-	p.errors = append(p.errors, msg)
+// error reports an error at current token. Format should be one of the
+// ERR_* constants, it is used to look up diagnostic code.
+func (p *Parser) error(format string, args ...interface{}) {
+	p.report(newError(p.currToken, format, args...))
+}
+
+func (p *Parser) report(d diagnostics.Diagnostic) {
+	p.errors = append(p.errors, d)
 	p.needSync = true
 }
 
+func newError(tok token.Token, format string, args ...interface{}) diagnostics.Diagnostic {
+	d := diagnostics.New(diagnostics.Error, errorCodes[format], tok.Span(), format, args...)
+	if hint, ok := errorHints[format]; ok {
+		d = d.WithHint(hint)
+	}
+	return d
+}
+
 func (p *Parser) synchronize() {
 	p.needSync = false
 
@@ -204,7 +268,7 @@ func (p *Parser) synchronize() {
 		case token.SEMICOLON:
 			p.nextToken()
 			return
-		case token.LET, token.FUNCTION, token.RETURN, token.IF, token.CLASS:
+		case token.LET, token.FUNCTION, token.RETURN, token.IF, token.CLASS, token.WHILE, token.FOR, token.IMPORT, token.TRY, token.THROW:
 			return
 		default:
 			p.nextToken()
@@ -213,13 +277,15 @@ func (p *Parser) synchronize() {
 }
 
 func (p *Parser) noPrefixParsletError(tt token.TokenType) {
-	var msg string
 	if tt == token.ILLEGAL {
-		msg = fmt.Sprintf(ERR_ILLEGAL_TOKEN, p.currToken.Literal)
+		p.error(ERR_ILLEGAL_TOKEN, p.currToken.Literal)
+	} else if tt == token.UNTERMINATED_STRING {
+		p.error(ERR_UNTERMINATED_STRING)
+	} else if tt == token.INVALID_NUMBER {
+		p.error(ERR_INVALID_NUMBER, p.currToken.Literal)
 	} else {
-		msg = fmt.Sprintf(ERR_NO_PREFIX_PARSLET_FOUND, tt)
+		p.error(ERR_NO_PREFIX_PARSLET_FOUND, tt)
 	}
-	p.error(msg)
 }
 
 func (p *Parser) expectPeek(tt token.TokenType, errMsg string) bool {
@@ -227,11 +293,18 @@ func (p *Parser) expectPeek(tt token.TokenType, errMsg string) bool {
 		p.nextToken()
 		return true
 	} else {
-		p.error(errMsg)
+		p.report(newError(p.peekToken, errMsg).WithNote("got " + describeToken(p.peekToken)))
 		return false
 	}
 }
 
+func describeToken(tok token.Token) string {
+	if tok.Type == token.EOF {
+		return "end of file"
+	}
+	return fmt.Sprintf("%s '%s'", tok.Type, tok.Literal)
+}
+
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -240,6 +313,16 @@ func (p *Parser) peekPrecedence() int {
 	return LOWEST
 }
 
+// Precedence returns binding power of infix operator tt,
+// it is LOWEST for tokens which are not infix operators.
+func Precedence(tt token.TokenType) int {
+	if p, ok := precedences[tt]; ok {
+		return p
+	}
+
+	return LOWEST
+}
+
 func (p *Parser) currPrecedence() int {
 	if p, ok := precedences[p.currToken.Type]; ok {
 		return p
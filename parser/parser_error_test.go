@@ -105,12 +105,12 @@ func TestParserError(t *testing.T) {
 	}
 
 	i := 0
-	for _, msg := range errors {
+	for _, err := range errors {
 		if i >= len(expect) {
-			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant nothing", i, msg)
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant nothing", i, err.Message)
 		} else {
-			if want := expect[i]; want != msg {
-				t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant %q", i, msg, want)
+			if want := expect[i]; want != err.Message {
+				t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant %q", i, err.Message, want)
 			}
 		}
 		i++
@@ -139,7 +139,245 @@ func TestIntParseError(t *testing.T) {
 		t.Fatalf("Wrong parser error count. Got %d, want %d", len(errors), wantLen)
 	}
 
-	if msg := errors[0]; !strings.HasPrefix(msg, want) {
+	if msg := errors[0].Message; !strings.HasPrefix(msg, want) {
 		t.Errorf("Wrong parser error message. %q should start with %q", msg, want)
 	}
 }
+
+func TestInvalidNumberError(t *testing.T) {
+	p := parser.New(lexer.New("1e;\n1.e3;"))
+	p.ParseProgram()
+
+	expect := []string{
+		fmt.Sprintf(parser.ERR_INVALID_NUMBER, "1e"),
+		fmt.Sprintf(parser.ERR_INVALID_NUMBER, "1.e3"),
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expect) {
+		t.Fatalf("Wrong parser error count. Got %d, want %d", len(errors), len(expect))
+	}
+
+	for i, err := range errors {
+		if err.Message != expect[i] {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant %q", i, err.Message, expect[i])
+		}
+	}
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	source := "let x = 10;\nlet y 5;"
+
+	p := parser.New(lexer.NewFile("script.mk", source))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("Wrong parser error count. Got %d, want %d", len(errors), 1)
+	}
+
+	want := "script.mk:2:7: error[P011]: " + parser.ERR_LET_NO_ASSIGN_AFTER_IDENTIFIER
+	if got := errors[0].Error(); got != want {
+		t.Errorf("Wrong parser error. Got %q, want %q", got, want)
+	}
+
+	if len(errors[0].Notes) != 1 || errors[0].Notes[0] != "got INT '5'" {
+		t.Errorf("Wrong parser error notes. Got %q", errors[0].Notes)
+	}
+}
+
+func TestLoopParserError(t *testing.T) {
+	source := `
+		while x < 10 x;
+		while (x < 10 x;
+		for x in xs x;
+		for x xs {}
+		for 1 {}
+		for (let i = 0; i < 10 i = i + 1) x;
+		for (i = 0 i) x;
+		for (x in xs x;
+		`
+
+	p := parser.New(lexer.New(source))
+	p.ParseProgram()
+
+	expect := []string{
+		parser.ERR_WHILE_CONDITION_START_LPAREN,
+		parser.ERR_WHILE_CONDITION_END_RPAREN,
+		parser.ERR_FOR_IN_BODY_LBRACE,
+		parser.ERR_FOR_IN_NO_IN,
+		parser.ERR_FOR_START_LPAREN,
+		parser.ERR_FOR_NO_SEMI_AFTER_COND,
+		parser.ERR_FOR_NO_SEMI_AFTER_INIT,
+		parser.ERR_FOR_END_RPAREN,
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expect) {
+		t.Errorf("Wrong parser error count. Got %d, want %d", len(errors), len(expect))
+	}
+
+	for i, err := range errors {
+		if i >= len(expect) {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant nothing", i, err.Message)
+		} else if want := expect[i]; want != err.Message {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant %q", i, err.Message, want)
+		}
+	}
+}
+
+func TestImportParserError(t *testing.T) {
+	source := `
+		import lib;
+		import "lib.mk" lib;
+		import "lib.mk" as 10;
+		import "lib.mk" as lib
+		x;
+		`
+
+	p := parser.New(lexer.New(source))
+	p.ParseProgram()
+
+	expect := []string{
+		parser.ERR_IMPORT_NO_PATH,
+		parser.ERR_IMPORT_NO_AS,
+		parser.ERR_IMPORT_NO_NAME,
+		parser.ERR_IMPORT_NO_SEMI,
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expect) {
+		t.Errorf("Wrong parser error count. Got %d, want %d", len(errors), len(expect))
+	}
+
+	for i, err := range errors {
+		if i >= len(expect) {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant nothing", i, err.Message)
+		} else if want := expect[i]; want != err.Message {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant %q", i, err.Message, want)
+		}
+	}
+}
+
+func TestTryParserError(t *testing.T) {
+	source := `
+		try x;
+		try { x; } x;
+		try { } catch e { }
+		try { } catch (10) { }
+		try { } catch (e { }
+		try { } catch (e) x;
+		try { } finally x;
+		throw;
+		`
+
+	p := parser.New(lexer.New(source))
+	p.ParseProgram()
+
+	expect := []string{
+		parser.ERR_TRY_BODY_START_LBRACE,
+		parser.ERR_TRY_NO_CATCH_OR_FINALLY,
+		parser.ERR_CATCH_PARAM_START_LPAREN,
+		parser.ERR_CATCH_NO_PARAM,
+		parser.ERR_CATCH_PARAM_END_RPAREN,
+		parser.ERR_CATCH_BODY_START_LBRACE,
+		parser.ERR_FINALLY_BODY_START_LBRACE,
+		parser.ERR_THROW_NO_VALUE,
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expect) {
+		t.Errorf("Wrong parser error count. Got %d, want %d", len(errors), len(expect))
+	}
+
+	for i, err := range errors {
+		if i >= len(expect) {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant nothing", i, err.Message)
+		} else if want := expect[i]; want != err.Message {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant %q", i, err.Message, want)
+		}
+	}
+}
+
+func TestStringParserError(t *testing.T) {
+	source := `
+		"bad \q escape";
+		"\u{110000}";
+		"\u41";
+		let s = "unterminated;
+		let t = 1;
+		`
+
+	p := parser.New(lexer.New(source))
+	p.ParseProgram()
+
+	expect := []string{
+		`Invalid escape sequence '\q' in string.`,
+		`Invalid escape sequence '\u{110000}' in string.`,
+		`Invalid escape sequence '\u' in string.`,
+		parser.ERR_UNTERMINATED_STRING,
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expect) {
+		t.Errorf("Wrong parser error count. Got %d, want %d", len(errors), len(expect))
+	}
+
+	for i, err := range errors {
+		if i >= len(expect) {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant nothing", i, err.Message)
+		} else if want := expect[i]; want != err.Message {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant %q", i, err.Message, want)
+		}
+	}
+}
+
+func TestTemplateParserError(t *testing.T) {
+	source := `
+		"a ${} b";
+		"a ${x y} b";
+		"a ${x} b
+		let s = 1;
+		`
+
+	p := parser.New(lexer.New(source))
+	p.ParseProgram()
+
+	expect := []string{
+		parser.ERR_TEMPLATE_EMPTY_EXPR,
+		parser.ERR_TEMPLATE_NO_RBRACE,
+		parser.ERR_UNTERMINATED_STRING,
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expect) {
+		t.Errorf("Wrong parser error count. Got %d, want %d", len(errors), len(expect))
+	}
+
+	for i, err := range errors {
+		if i >= len(expect) {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant nothing", i, err.Message)
+		} else if want := expect[i]; want != err.Message {
+			t.Errorf("%d: Wrong parser error message. \nGot %q, \nwant %q", i, err.Message, want)
+		}
+	}
+}
+
+func TestTypeParserError(t *testing.T) {
+	tt := []string{
+		"let x: = 1;",
+		"let x: 1 = 1;",
+		"fn f(a: ) {}",
+		"fn f(): {}",
+	}
+
+	for _, source := range tt {
+		p := parser.New(lexer.New(source))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0].Message != parser.ERR_TYPE_NO_NAME {
+			t.Errorf("Wrong errors of %q, got %v, want %q first.", source, errors, parser.ERR_TYPE_NO_NAME)
+		}
+	}
+}
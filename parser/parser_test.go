@@ -532,6 +532,39 @@ func TestIntLiteral(t *testing.T) {
 	testIdentifierOrLiteralExpr(t, expressionStmt.Expression, wantInt)
 }
 
+func TestFloatLiteral(t *testing.T) {
+	tt := []struct {
+		source string
+		want   float64
+	}{
+		{source: "1.5;", want: 1.5},
+		{source: "2e3;", want: 2000},
+		{source: "2.5e-1;", want: 0.25},
+	}
+
+	for _, tc := range tt {
+		program := parse(t, tc.source)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements len is %d, want 1.", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStmt)
+		if !ok {
+			t.Fatalf("stmt is not *ast.ExpressionStmt. Got %T.", program.Statements[0])
+		}
+
+		literal, ok := stmt.Expression.(*ast.FloatLiteralExpr)
+		if !ok {
+			t.Fatalf("Expression is not FloatLiteralExpr, got %T.", stmt.Expression)
+		}
+
+		if literal.Value != tc.want {
+			t.Errorf("Wrong FloatLiteralExpr Value. Got %g, want %g.", literal.Value, tc.want)
+		}
+	}
+}
+
 func TestBoolLiteral(t *testing.T) {
 	source := `
 		true;
@@ -687,7 +720,8 @@ func TestHashLiteral(t *testing.T) {
 	if len(hashExpr.Pairs) != len(want) {
 		t.Errorf("Wrong Pairs length, got %d, want %d.", len(hashExpr.Pairs), len(want))
 	}
-	for key, val := range hashExpr.Pairs {
+	for _, pair := range hashExpr.Pairs {
+		key, val := pair.Key, pair.Value
 		switch k := key.(type) {
 		case *ast.StringLiteralExpr:
 			wantVal, ok := want[k.Value]
@@ -924,6 +958,17 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a.b.c = 10;", "((a.b).c = 10)"},
 		{"a.b().c = 10;", "((a.b)().c = 10)"},
 		{"a || b && c", "(a || (b && c))"},
+		{"a <= b == c >= d", "((a <= b) == (c >= d))"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a ** -b", "(a ** (-b))"},
+		{"~a & b", "((~a) & b)"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a & b << c + d", "(a & (b << (c + d)))"},
+		{"a >> 1 < b | c", "((a >> 1) < (b | c))"},
+		{"a == b | c", "(a == (b | c))"},
 		{
 			"x - y || a * b + c || d && e * !f;",
 			"(((x - y) || ((a * b) + c)) || (d && (e * (!f))))",
@@ -1132,3 +1177,319 @@ func parse(t testing.TB, source string) *ast.Program {
 
 	return program
 }
+
+func TestNodePositions(t *testing.T) {
+	source := "let x = [1, 2];\nx[0] + add(1, 2);\nclass A {\n}\n"
+
+	program := parse(t, source)
+
+	tt := []struct {
+		node  ast.Node
+		start string
+		end   string
+	}{
+		{program.Statements[0], "1:1", "1:15"},
+		{program.Statements[0].(*ast.LetStmt).Value, "1:9", "1:15"},
+		{program.Statements[1], "2:1", "2:17"},
+		{program.Statements[1].(*ast.ExpressionStmt).Expression.(*ast.InfixExpr).Left, "2:1", "2:5"},
+		{program.Statements[1].(*ast.ExpressionStmt).Expression.(*ast.InfixExpr).Right, "2:8", "2:17"},
+		{program.Statements[2], "3:1", "4:2"},
+	}
+
+	for i, tc := range tt {
+		if got := tc.node.Pos().String(); got != tc.start {
+			t.Errorf("%d: wrong start of %q, got %s, want %s", i, tc.node, got, tc.start)
+		}
+		if got := tc.node.End().String(); got != tc.end {
+			t.Errorf("%d: wrong end of %q, got %s, want %s", i, tc.node, got, tc.end)
+		}
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	tt := []struct {
+		source string
+		want   string
+	}{
+		{source: "while (x < 10) { x = x + 1; }", want: "while ((x < 10)) { x = (x + 1); }\n"},
+		{source: "while (true) x = x + 1;", want: "while (true) x = (x + 1);\n"},
+		{source: "while (true) { break; continue; };", want: "while (true) { break;continue; }\n"},
+		{
+			source: "for (let i = 0; i < 10; i = i + 1) { i; }",
+			want:   "for (let i = 0; (i < 10); i = (i + 1)) { i; }\n",
+		},
+		{source: "for (i = 0; i < 10;) i;", want: "for (i = 0; (i < 10); ) i;\n"},
+		{source: "for (;;) { break; }", want: "for (; ; ) { break; }\n"},
+		{source: "for (x in [1, 2]) { x; }", want: "for (x in [1, 2]) { x; }\n"},
+		{source: "for (x in xs) print(x);", want: "for (x in xs) print(x);\n"},
+		{source: "for x in [1, 2] { x; }", want: "for (x in [1, 2]) { x; }\n"},
+		{source: "for x in xs {}", want: "for (x in xs) {  }\n"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			program := parse(t, tc.source)
+
+			if len(program.Statements) != 1 {
+				t.Fatalf("program.Statements len is %d, want 1.", len(program.Statements))
+			}
+
+			if got := program.String(); got != tc.want {
+				t.Errorf("Wrong program, got %q, want %q.", got, tc.want)
+			}
+		})
+	}
+
+	program := parse(t, "for (x in xs) x;")
+	forIn, ok := program.Statements[0].(*ast.ForInStmt)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ForInStmt. Got %T.", program.Statements[0])
+	}
+	testIdentifierExpression(t, forIn.Variable, "x")
+	testIdentifierExpression(t, forIn.Iterable, "xs")
+}
+
+func TestImportStatement(t *testing.T) {
+	program := parse(t, `import "lib/math.mk" as math;`)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements len is %d, want 1.", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ImportStmt)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ImportStmt. Got %T.", program.Statements[0])
+	}
+
+	if stmt.Path.Value != "lib/math.mk" {
+		t.Errorf("Wrong import path, got %q, want %q.", stmt.Path.Value, "lib/math.mk")
+	}
+	testIdentifierExpression(t, stmt.Name, "math")
+
+	if got := program.String(); got != "import \"lib/math.mk\" as math;\n" {
+		t.Errorf("Wrong program, got %q.", got)
+	}
+}
+
+func TestTryStatement(t *testing.T) {
+	tt := []struct {
+		source     string
+		want       string
+		hasCatch   bool
+		hasFinally bool
+	}{
+		{
+			source:   "try { x; } catch (e) { e; }",
+			want:     "try { x; } catch (e) { e; }\n",
+			hasCatch: true,
+		},
+		{
+			source:     "try { x; } finally { y; }",
+			want:       "try { x; } finally { y; }\n",
+			hasFinally: true,
+		},
+		{
+			source:     "try { x; } catch (e) { e; } finally { y; }",
+			want:       "try { x; } catch (e) { e; } finally { y; }\n",
+			hasCatch:   true,
+			hasFinally: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			program := parse(t, tc.source)
+
+			if len(program.Statements) != 1 {
+				t.Fatalf("program.Statements len is %d, want 1.", len(program.Statements))
+			}
+
+			stmt, ok := program.Statements[0].(*ast.TryStmt)
+			if !ok {
+				t.Fatalf("stmt is not *ast.TryStmt. Got %T.", program.Statements[0])
+			}
+
+			if (stmt.Catch != nil) != tc.hasCatch {
+				t.Errorf("Wrong catch clause, got %v.", stmt.Catch)
+			}
+			if tc.hasCatch {
+				testIdentifierExpression(t, stmt.Param, "e")
+			}
+			if (stmt.Finally != nil) != tc.hasFinally {
+				t.Errorf("Wrong finally clause, got %v.", stmt.Finally)
+			}
+
+			if got := program.String(); got != tc.want {
+				t.Errorf("Wrong program, got %q, want %q.", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	program := parse(t, `throw "boom" + x;`)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements len is %d, want 1.", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStmt)
+	if !ok {
+		t.Fatalf("stmt is not *ast.ThrowStmt. Got %T.", program.Statements[0])
+	}
+
+	if got := stmt.Value.String(); got != `("boom" + x)` {
+		t.Errorf("Wrong thrown value, got %q.", got)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tt := []struct {
+		source string
+		want   string
+		str    string
+	}{
+		{source: `"a\tb\n";`, want: "a\tb\n", str: `"a\tb\n"`},
+		{source: `"say \"hi\" \\ \0";`, want: "say \"hi\" \\ \x00", str: `"say \"hi\" \\ \0"`},
+		{source: `"\u{48}\u{1F600}";`, want: "H\U0001F600", str: `"\u{48}\u{1F600}"`},
+		{source: "`no \\n escapes`;", want: `no \n escapes`, str: "`no \\n escapes`"},
+		{source: "`two\nlines`;", want: "two\nlines", str: "`two\nlines`"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			program := parse(t, tc.source)
+
+			stmt := program.Statements[0].(*ast.ExpressionStmt)
+			str, ok := stmt.Expression.(*ast.StringLiteralExpr)
+			if !ok {
+				t.Fatalf("Expression is not *ast.StringLiteralExpr. Got %T.", stmt.Expression)
+			}
+
+			if str.Value != tc.want {
+				t.Errorf("Wrong string value, got %q, want %q.", str.Value, tc.want)
+			}
+			if str.String() != tc.str {
+				t.Errorf("Wrong string, got %q, want %q.", str.String(), tc.str)
+			}
+		})
+	}
+}
+
+func TestTemplateExpr(t *testing.T) {
+	program := parse(t, `"a\t${x + 1}b${f("c")}";`)
+
+	stmt := program.Statements[0].(*ast.ExpressionStmt)
+	tmpl, ok := stmt.Expression.(*ast.TemplateExpr)
+	if !ok {
+		t.Fatalf("Expression is not *ast.TemplateExpr. Got %T.", stmt.Expression)
+	}
+
+	texts := []string{"a\t", "b", ""}
+	if len(tmpl.Texts) != len(texts) {
+		t.Fatalf("Wrong texts count, got %d, want %d.", len(tmpl.Texts), len(texts))
+	}
+	for i, text := range texts {
+		if tmpl.Texts[i].Value != text {
+			t.Errorf("Wrong text %d, got %q, want %q.", i, tmpl.Texts[i].Value, text)
+		}
+	}
+
+	exprs := []string{"(x + 1)", `f("c")`}
+	if len(tmpl.Exprs) != len(exprs) {
+		t.Fatalf("Wrong expressions count, got %d, want %d.", len(tmpl.Exprs), len(exprs))
+	}
+	for i, expr := range exprs {
+		if tmpl.Exprs[i].String() != expr {
+			t.Errorf("Wrong expression %d, got %q, want %q.", i, tmpl.Exprs[i].String(), expr)
+		}
+	}
+
+	if got, want := tmpl.String(), `"a\t${(x + 1)}b${f("c")}"`; got != want {
+		t.Errorf("Wrong template, got %q, want %q.", got, want)
+	}
+}
+
+func TestSliceExpr(t *testing.T) {
+	tt := []struct {
+		source string
+		want   string
+	}{
+		{source: "s[1:2];", want: "(s[1:2])"},
+		{source: "s[:n - 1];", want: "(s[:(n - 1)])"},
+		{source: "s[i + 1:];", want: "(s[(i + 1):])"},
+		{source: "s[:];", want: "(s[:])"},
+		{source: "f()[1:][0];", want: "((f()[1:])[0])"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			program := parse(t, tc.source)
+
+			stmt := program.Statements[0].(*ast.ExpressionStmt)
+			if got := stmt.Expression.String(); got != tc.want {
+				t.Errorf("Wrong expression, got %q, want %q.", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIndexSetExpr(t *testing.T) {
+	tt := []struct {
+		source string
+		want   string
+	}{
+		{source: "xs[0] = 1;", want: "(xs[0] = 1)"},
+		{source: "h[k + 1] = v * 2;", want: "(h[(k + 1)] = (v * 2))"},
+		{source: "m[i][j] = 0;", want: "((m[i])[j] = 0)"},
+		{source: "a[0] = b[1] = c;", want: "(a[0] = (b[1] = c))"},
+		{source: "f()[0] -= 1;", want: "(f()[0] -= 1)"},
+		{source: "x += 1;", want: "x += 1"},
+		{source: "x *= y /= 2;", want: "x *= y /= 2"},
+		{source: "a.b -= 1 + 2;", want: "(a.b -= (1 + 2))"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			program := parse(t, tc.source)
+
+			stmt := program.Statements[0].(*ast.ExpressionStmt)
+			if got := stmt.Expression.String(); got != tc.want {
+				t.Errorf("Wrong expression, got %q, want %q.", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tt := []struct {
+		source string
+		want   string
+	}{
+		{source: "let x: int = 1;", want: "let x: int = 1;"},
+		{source: "let p: Point;", want: "let p: Point;"},
+		{source: "let f = fn(a: int, b): float { a };", want: "let f = fn(a: int, b): float { a; };"},
+		{source: "fn add(a: int, b: int): int { a + b }", want: "let add = fn(a: int, b: int): int { (a + b); };"},
+		{source: "fn g(h: fn, n: null): any {}", want: "let g = fn(h: fn, n: null): any {  };"},
+		{source: "fn(x) { x };", want: "fn(x) { x; };"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			program := parse(t, tc.source)
+
+			if got := program.Statements[0].String(); got != tc.want {
+				t.Errorf("Wrong statement, got %q, want %q.", got, tc.want)
+			}
+		})
+	}
+
+	program := parse(t, "fn f(a, b: int) {}")
+	fn := program.Statements[0].(*ast.LetStmt).Value.(*ast.FunctionExpr)
+	if fn.ParameterType(0) != nil || fn.ParameterType(1).Name != "int" || fn.ReturnType != nil {
+		t.Errorf("Wrong annotations of %s.", fn)
+	}
+	if fn := parse(t, "fn f(a, b) {}").Statements[0].(*ast.LetStmt).Value.(*ast.FunctionExpr); fn.ParameterTypes != nil {
+		t.Errorf("Expect nil ParameterTypes without annotations, got %v.", fn.ParameterTypes)
+	}
+}
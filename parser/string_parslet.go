@@ -1,10 +1,77 @@
 package parser
 
-import "monkey/ast"
+import (
+	"monkey/ast"
+	"monkey/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const ERR_INVALID_ESCAPE = "Invalid escape sequence '%s' in string."
+const ERR_UNTERMINATED_STRING = "Unterminated string literal."
 
 func (p *Parser) parseStringLiteralExpr() ast.Expression {
-	return &ast.StringLiteralExpr{
+	str := &ast.StringLiteralExpr{
 		Token: p.currToken,
 		Value: p.currToken.Literal,
 	}
+
+	if p.currToken.Type != token.RAW_STRING {
+		value, invalid := unescape(p.currToken.Literal)
+		if invalid != "" {
+			p.error(ERR_INVALID_ESCAPE, invalid)
+		}
+		str.Value = value
+	}
+
+	return str
+}
+
+// unescape decodes escape sequences of string literal, on failure
+// it returns the first invalid sequence as the second result.
+func unescape(literal string) (string, string) {
+	if !strings.Contains(literal, `\`) {
+		return literal, ""
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '\\' {
+			out.WriteByte(literal[i])
+			continue
+		}
+
+		// lexer never ends string literal with a single backslash
+		i++
+		switch literal[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '0':
+			out.WriteByte(0)
+		case '\\', '"', '$':
+			out.WriteByte(literal[i])
+		case 'u':
+			end := strings.IndexByte(literal[i:], '}')
+			if !strings.HasPrefix(literal[i:], "u{") || end < 0 {
+				return "", `\u`
+			}
+			digits := literal[i+2 : i+end]
+			code, err := strconv.ParseUint(digits, 16, 32)
+			if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(code)) {
+				return "", `\` + literal[i:i+end+1]
+			}
+			out.WriteRune(rune(code))
+			i += end
+		default:
+			ch, _ := utf8.DecodeRuneInString(literal[i:])
+			return "", `\` + string(ch)
+		}
+	}
+
+	return out.String(), ""
 }
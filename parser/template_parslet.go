@@ -0,0 +1,38 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+const ERR_TEMPLATE_EMPTY_EXPR = "Expect expression inside '${}' of string template."
+const ERR_TEMPLATE_NO_RBRACE = "Expect '}' after expression embedded in string template."
+
+func (p *Parser) parseTemplateExpr() ast.Expression {
+	tmpl := &ast.TemplateExpr{Token: p.currToken}
+	tmpl.Texts = append(tmpl.Texts, p.parseStringLiteralExpr().(*ast.StringLiteralExpr))
+
+	for p.currToken.Type != token.TEMPLATE_TAIL {
+		if p.peekTokenIs(token.TEMPLATE_MIDDLE) || p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.report(newError(p.peekToken, ERR_TEMPLATE_EMPTY_EXPR))
+			return nil
+		}
+
+		p.nextToken()
+		tmpl.Exprs = append(tmpl.Exprs, p.parseExpression(LOWEST))
+
+		if p.peekTokenIs(token.UNTERMINATED_STRING) {
+			p.report(newError(p.peekToken, ERR_UNTERMINATED_STRING))
+			return nil
+		}
+		if !p.peekTokenIs(token.TEMPLATE_MIDDLE) && !p.peekTokenIs(token.TEMPLATE_TAIL) {
+			p.report(newError(p.peekToken, ERR_TEMPLATE_NO_RBRACE).WithNote("got " + describeToken(p.peekToken)))
+			return nil
+		}
+
+		p.nextToken()
+		tmpl.Texts = append(tmpl.Texts, p.parseStringLiteralExpr().(*ast.StringLiteralExpr))
+	}
+
+	return tmpl
+}
@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+const ERR_THROW_NO_VALUE = "Expect value after 'throw'."
+
+func (p *Parser) parseThrowStmt() *ast.ThrowStmt {
+	stmt := &ast.ThrowStmt{Token: p.currToken}
+
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.EOF) {
+		p.report(newError(p.peekToken, ERR_THROW_NO_VALUE).WithNote("got " + describeToken(p.peekToken)))
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+const ERR_TRY_BODY_START_LBRACE = "Expect '{' after 'try'."
+const ERR_TRY_NO_CATCH_OR_FINALLY = "Expect 'catch' or 'finally' after 'try' block."
+const ERR_CATCH_PARAM_START_LPAREN = "Expect '(' after 'catch'."
+const ERR_CATCH_NO_PARAM = "Expect exception variable name in 'catch'."
+const ERR_CATCH_PARAM_END_RPAREN = "Expect ')' after exception variable name."
+const ERR_CATCH_BODY_START_LBRACE = "Expect '{' after 'catch' clause."
+const ERR_FINALLY_BODY_START_LBRACE = "Expect '{' after 'finally'."
+
+func (p *Parser) parseTryStmt() *ast.TryStmt {
+	stmt := &ast.TryStmt{Token: p.currToken}
+
+	if !p.expectPeek(token.LBRACE, ERR_TRY_BODY_START_LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStmt()
+
+	if !p.peekTokenIs(token.CATCH) && !p.peekTokenIs(token.FINALLY) {
+		p.report(newError(p.peekToken, ERR_TRY_NO_CATCH_OR_FINALLY).WithNote("got " + describeToken(p.peekToken)))
+		return nil
+	}
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN, ERR_CATCH_PARAM_START_LPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.IDENTIFIER, ERR_CATCH_NO_PARAM) {
+			return nil
+		}
+		stmt.Param = p.parseIdentifierExpr().(*ast.IdentifierExpr)
+		if !p.expectPeek(token.RPAREN, ERR_CATCH_PARAM_END_RPAREN) {
+			return nil
+		}
+
+		if !p.expectPeek(token.LBRACE, ERR_CATCH_BODY_START_LBRACE) {
+			return nil
+		}
+		stmt.Catch = p.parseBlockStmt()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE, ERR_FINALLY_BODY_START_LBRACE) {
+			return nil
+		}
+		stmt.Finally = p.parseBlockStmt()
+	}
+
+	return stmt
+}
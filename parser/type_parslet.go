@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+const ERR_TYPE_NO_NAME = "Expect type name after ':'."
+
+// parseTypeAnnotation parses optional ': type' following current token,
+// ok is false when the annotation is wrong.
+func (p *Parser) parseTypeAnnotation() (annotation *ast.TypeAnnotation, ok bool) {
+	if !p.peekTokenIs(token.COLON) {
+		return nil, true
+	}
+	p.nextToken()
+
+	switch p.peekToken.Type {
+	case token.IDENTIFIER, token.NULL, token.FUNCTION:
+		p.nextToken()
+		return &ast.TypeAnnotation{Token: p.currToken, Name: p.currToken.Literal}, true
+	default:
+		p.report(newError(p.peekToken, ERR_TYPE_NO_NAME).WithNote("got " + describeToken(p.peekToken)))
+		return nil, false
+	}
+}
@@ -0,0 +1,35 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+const ERR_WHILE_CONDITION_START_LPAREN = "Expect while condition to start with '('."
+const ERR_WHILE_CONDITION_END_RPAREN = "Expect while condition to end with ')'."
+
+func (p *Parser) parseWhileStmt() *ast.WhileStmt {
+	stmt := &ast.WhileStmt{
+		Token: p.currToken,
+	}
+
+	if !p.expectPeek(token.LPAREN, ERR_WHILE_CONDITION_START_LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN, ERR_WHILE_CONDITION_END_RPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Body = p.parseStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
@@ -4,11 +4,16 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/checker"
+	"monkey/compiler"
+	"monkey/diagnostics"
 	"monkey/eval"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/resolver"
+	"monkey/vm"
 )
 
 const ReplWelcomeMessage = `
@@ -22,20 +27,40 @@ monkey           to run REPL
 `
 
 type REPL struct {
-	in  io.Reader
-	out io.Writer
+	in   io.Reader
+	out  io.Writer
+	opts Options
+}
+
+// Options configure how the REPL runs lines.
+type Options struct {
+	// VM compiles lines to bytecode run by the virtual machine instead of
+	// evaluating them with the tree-walking evaluator.
+	VM bool
+	// MaxCallDepth limits nested calls, zero means default of the engine.
+	MaxCallDepth int
+	// Capabilities granted to lines, nil means eval.DefaultCapabilities.
+	Capabilities []eval.Capability
 }
 
 func New(in io.Reader, out io.Writer) *REPL {
 	return &REPL{in: in, out: out}
 }
 
+// SetOptions configures lines run by the following Start.
+func (r *REPL) SetOptions(opts Options) {
+	r.opts = opts
+}
+
 func (r *REPL) Start() {
 	r.printWelcomeMessage()
 
 	scanner := bufio.NewScanner(r.in)
-	env := object.NewEnvironment()
 	res := resolver.New()
+	run := r.evaluate()
+	if r.opts.VM {
+		run = r.runCompiled()
+	}
 
 	lineNumber := 0
 	for {
@@ -53,35 +78,96 @@ func (r *REPL) Start() {
 		program := p.ParseProgram()
 
 		if len(p.Errors()) != 0 {
-			printParseErrors(r.out, p.Errors())
+			printParseErrors(r.out, line, p.Errors())
 		}
 
 		res.Resolve(program)
 
 		if len(res.Errors()) != 0 {
-			printParseErrors(r.out, res.Errors())
+			printParseErrors(r.out, line, res.Errors())
 		}
 
-		eval.Locals = res.Locals()
+		c := checker.New(res.Definitions())
+		c.Check(program)
+
+		if diagnostics.HasErrors(c.Errors()) {
+			printDiagnostics(r.out, "Errors found while checking types:", line, c.Errors())
+			continue
+		}
+		if len(c.Errors()) != 0 {
+			printDiagnostics(r.out, "Warnings found while checking types:", line, c.Errors())
+		}
 
-		evalResult := eval.Eval(program, env)
+		evalResult := run(program, res.Locals())
 
-		if evalResult != nil {
+		if err, ok := evalResult.(*object.Error); ok {
+			io.WriteString(r.out, err.StackTrace())
+			io.WriteString(r.out, "\n")
+		} else if evalResult != nil {
 			io.WriteString(r.out, evalResult.Inspect())
 			io.WriteString(r.out, "\n")
 		}
 	}
 }
 
+// evaluate returns function evaluating lines with the tree-walking
+// evaluator, globals are kept in environment shared by the lines.
+func (r *REPL) evaluate() func(*ast.Program, map[ast.Expression]int) object.Object {
+	env := object.NewEnvironment()
+	evaluator := eval.New()
+	evaluator.SetStdout(r.out)
+	if r.opts.MaxCallDepth > 0 {
+		evaluator.SetMaxCallDepth(r.opts.MaxCallDepth)
+	}
+	if r.opts.Capabilities != nil {
+		evaluator.SetCapabilities(r.opts.Capabilities...)
+	}
+
+	return func(program *ast.Program, locals map[ast.Expression]int) object.Object {
+		evaluator.SetLocals(locals)
+		return evaluator.Eval(program, env)
+	}
+}
+
+// runCompiled returns function running lines with the virtual machine,
+// every line is compiled by the same compiler, so it knows globals of
+// the previous ones.
+func (r *REPL) runCompiled() func(*ast.Program, map[ast.Expression]int) object.Object {
+	c := compiler.New()
+	var machine *vm.VM
+
+	return func(program *ast.Program, _ map[ast.Expression]int) object.Object {
+		if err := c.Compile(program); err != nil {
+			io.WriteString(r.out, err.Error()+"\n")
+			return nil
+		}
+		if machine != nil {
+			return machine.Continue(c.Bytecode())
+		}
+
+		machine = vm.New(c.Bytecode())
+		machine.SetStdout(r.out)
+		if r.opts.MaxCallDepth > 0 {
+			machine.SetMaxCallDepth(r.opts.MaxCallDepth)
+		}
+		if r.opts.Capabilities != nil {
+			machine.SetCapabilities(r.opts.Capabilities...)
+		}
+		return machine.Run()
+	}
+}
+
 func (r *REPL) printWelcomeMessage() {
 	io.WriteString(r.out, ReplWelcomeMessage)
 }
 
-func printParseErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "Errors found while parsing:\n")
-	for _, e := range errors {
-		io.WriteString(out, "\t"+e+"\n")
-	}
+func printParseErrors(out io.Writer, source string, errors []diagnostics.Diagnostic) {
+	printDiagnostics(out, "Errors found while parsing:", source, errors)
+}
+
+func printDiagnostics(out io.Writer, header string, source string, diags []diagnostics.Diagnostic) {
+	io.WriteString(out, header+"\n")
+	diagnostics.RenderText(out, source, diags)
 }
 
 func prompt(lineNumber int) string {
@@ -2,6 +2,7 @@ package repl_test
 
 import (
 	"bufio"
+	"monkey/eval"
 	"monkey/repl"
 	"strings"
 	"testing"
@@ -15,6 +16,21 @@ func TestReplIntegration(t *testing.T) {
 		{"12345;", "12345"},
 		{"true; false;", "false"},
 		{"false; 12345; true;", "true"},
+		{
+			"let x: int = 1; x + true;",
+			"Errors found while checking types:\n" +
+				"1:17: error[T002]: Type mismatch: int + bool.\n" +
+				" 1 | let x: int = 1; x + true;\n" +
+				"   |                 ^~~~~~~~",
+		},
+		{
+			"1 + true;",
+			"Warnings found while checking types:\n" +
+				"1:1: warning[T002]: Type mismatch: int + bool.\n" +
+				" 1 | 1 + true;\n" +
+				"   | ^~~~~~~~\n" +
+				"Runtime error at 1:1: type mismatch: INTEGER + BOOLEAN",
+		},
 	}
 
 	for _, tc := range tt {
@@ -39,3 +55,23 @@ func TestReplIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestReplOptions(t *testing.T) {
+	input := "let x = 2;\nfn f() { x * 3 }\nprintln(f());\nreadFile(\"a\");"
+
+	for _, opts := range []repl.Options{
+		{Capabilities: []eval.Capability{eval.STDOUT}},
+		{VM: true, Capabilities: []eval.Capability{eval.STDOUT}},
+	} {
+		r, out := createReplWithReader(input)
+		r.SetOptions(opts)
+		r.Start()
+
+		if !strings.Contains(out.String(), ">> 6\nnull\n") {
+			t.Errorf("Printed value missing from output of %+v: %q.", opts, out.String())
+		}
+		if !strings.Contains(out.String(), "permission denied: 'readFile' needs filesystem capability") {
+			t.Errorf("Denied builtin was called with %+v: %q.", opts, out.String())
+		}
+	}
+}
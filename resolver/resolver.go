@@ -1,8 +1,8 @@
 package resolver
 
 import (
-	"fmt"
 	"monkey/ast"
+	"monkey/diagnostics"
 	"monkey/token"
 	"monkey/utils"
 )
@@ -29,24 +29,55 @@ const (
 	ERR_THIS_OUTSIDE_OF_CLASS    = "Can not use 'this' outside of class."
 	ERR_SUPER_OUTSIDE_OF_CLASS   = "Can not use 'super' outside of class."
 	ERR_SUPER_WITHOUT_SUPERCLASS = "Can not use 'super' in a class with no superclass."
+	ERR_BREAK_OUTSIDE_OF_LOOP    = "Can not use 'break' outside of loop."
+	ERR_CONTINUE_OUTSIDE_OF_LOOP = "Can not use 'continue' outside of loop."
 )
 
+// errorCodes maps error message formats to stable diagnostic codes.
+var errorCodes = map[string]string{
+	ERR_READ_ON_OWN_INIT:         "R001",
+	ERR_ALREADY_DECLARED:         "R002",
+	ERR_CLASS_INHERIT_SELF:       "R003",
+	ERR_INITIALIZER_VAL_RETURN:   "R004",
+	ERR_THIS_OUTSIDE_OF_CLASS:    "R005",
+	ERR_SUPER_OUTSIDE_OF_CLASS:   "R006",
+	ERR_SUPER_WITHOUT_SUPERCLASS: "R007",
+	ERR_BREAK_OUTSIDE_OF_LOOP:    "R008",
+	ERR_CONTINUE_OUTSIDE_OF_LOOP: "R009",
+}
+
+var errorHints = map[string]string{
+	ERR_READ_ON_OWN_INIT:       "declare the variable first or use another name",
+	ERR_INITIALIZER_VAL_RETURN: "initializer always returns 'this', use bare 'return;'",
+}
+
+// variable is a name declared in scope.
+type variable struct {
+	defined bool
+	decl    *ast.IdentifierExpr // nil for implicit names like 'this'
+}
+
 type resolver struct {
-	scopes utils.Stack[map[string]bool]
-	locals map[ast.Expression]int
-	errors []string
+	scopes      utils.Stack[map[string]*variable]
+	locals      map[ast.Expression]int
+	definitions map[ast.Expression]*ast.IdentifierExpr
+	shadows     map[*ast.IdentifierExpr]*ast.IdentifierExpr
+	errors      []diagnostics.Diagnostic
 
 	currFn    FnType
 	currClass ClassType
+	loopDepth int
 }
 
 func New() *resolver {
 	r := &resolver{
-		scopes:    utils.NewStack[map[string]bool](),
-		locals:    make(map[ast.Expression]int),
-		errors:    []string{},
-		currFn:    NONE,
-		currClass: NONE,
+		scopes:      utils.NewStack[map[string]*variable](),
+		locals:      make(map[ast.Expression]int),
+		definitions: make(map[ast.Expression]*ast.IdentifierExpr),
+		shadows:     make(map[*ast.IdentifierExpr]*ast.IdentifierExpr),
+		errors:      []diagnostics.Diagnostic{},
+		currFn:      NONE,
+		currClass:   NONE,
 	}
 
 	r.beginScope()
@@ -58,7 +89,18 @@ func (r *resolver) Locals() map[ast.Expression]int {
 	return r.locals
 }
 
-func (r *resolver) Errors() []string {
+// Definitions maps resolved variables to identifiers declaring them.
+func (r *resolver) Definitions() map[ast.Expression]*ast.IdentifierExpr {
+	return r.definitions
+}
+
+// Shadows maps declarations to declarations of the same name
+// in enclosing scopes which they hide.
+func (r *resolver) Shadows() map[*ast.IdentifierExpr]*ast.IdentifierExpr {
+	return r.shadows
+}
+
+func (r *resolver) Errors() []diagnostics.Diagnostic {
 	return r.errors
 }
 
@@ -78,7 +120,7 @@ func (r *resolver) Resolve(node ast.Node) {
 		// }
 		if node.Value != nil {
 			if r.currFn == INITIALIZER {
-				r.error(ERR_INITIALIZER_VAL_RETURN)
+				r.error(node, ERR_INITIALIZER_VAL_RETURN)
 			}
 			r.Resolve(node.Value)
 		}
@@ -93,6 +135,48 @@ func (r *resolver) Resolve(node ast.Node) {
 			r.Resolve(node.Value)
 			r.define(node.Name)
 		}
+	case *ast.WhileStmt:
+		r.Resolve(node.Condition)
+		r.resolveLoopBody(node.Body)
+	case *ast.ForStmt:
+		r.beginScope()
+		r.Resolve(node.Init)
+		r.Resolve(node.Condition)
+		r.Resolve(node.Update)
+		r.resolveLoopBody(node.Body)
+		r.endScope()
+	case *ast.ForInStmt:
+		r.Resolve(node.Iterable)
+		r.beginScope()
+		r.declare(node.Variable)
+		r.define(node.Variable)
+		r.resolveLoopBody(node.Body)
+		r.endScope()
+	case *ast.ImportStmt:
+		r.declare(node.Name)
+		r.define(node.Name)
+	case *ast.ThrowStmt:
+		r.Resolve(node.Value)
+	case *ast.TryStmt:
+		r.Resolve(node.Body)
+		if node.Catch != nil {
+			r.beginScope()
+			r.declare(node.Param)
+			r.define(node.Param)
+			r.Resolve(node.Catch)
+			r.endScope()
+		}
+		if node.Finally != nil {
+			r.Resolve(node.Finally)
+		}
+	case *ast.BreakStmt:
+		if r.loopDepth == 0 {
+			r.error(node, ERR_BREAK_OUTSIDE_OF_LOOP)
+		}
+	case *ast.ContinueStmt:
+		if r.loopDepth == 0 {
+			r.error(node, ERR_CONTINUE_OUTSIDE_OF_LOOP)
+		}
 	case *ast.ClassStmt:
 		enclosingClass := r.currClass
 		r.currClass = CLASS
@@ -103,7 +187,7 @@ func (r *resolver) Resolve(node ast.Node) {
 			r.currClass = SUBCLASS
 
 			if node.Superclass.Value == node.Name.Value {
-				r.error(fmt.Sprintf(ERR_CLASS_INHERIT_SELF, node.Name.Value))
+				r.error(node.Superclass, ERR_CLASS_INHERIT_SELF, node.Name.Value)
 			} else {
 				r.Resolve(node.Superclass)
 			}
@@ -137,14 +221,14 @@ func (r *resolver) Resolve(node ast.Node) {
 
 	case *ast.ThisExpr:
 		if r.currClass == NONE {
-			r.error(ERR_THIS_OUTSIDE_OF_CLASS)
+			r.error(node, ERR_THIS_OUTSIDE_OF_CLASS)
 		}
 		r.resolveLocal(node, token.THIS_KEYWORD)
 	case *ast.SuperExpr:
 		if r.currClass == NONE {
-			r.error(ERR_SUPER_OUTSIDE_OF_CLASS)
+			r.error(node, ERR_SUPER_OUTSIDE_OF_CLASS)
 		} else if r.currClass == CLASS {
-			r.error(ERR_SUPER_WITHOUT_SUPERCLASS)
+			r.error(node, ERR_SUPER_WITHOUT_SUPERCLASS)
 		}
 		r.resolveLocal(node, token.SUPER_KEYWORD)
 	case *ast.GetExpr:
@@ -152,6 +236,10 @@ func (r *resolver) Resolve(node ast.Node) {
 	case *ast.SetExpr:
 		r.Resolve(node.Value)
 		r.Resolve(node.Expression)
+	case *ast.IndexSetExpr:
+		r.Resolve(node.Left)
+		r.Resolve(node.Index)
+		r.Resolve(node.Value)
 	case *ast.PrefixExpr:
 		r.Resolve(node.Right)
 	case *ast.InfixExpr:
@@ -176,26 +264,45 @@ func (r *resolver) Resolve(node ast.Node) {
 	case *ast.IndexExpr:
 		r.Resolve(node.Left)
 		r.Resolve(node.Index)
+	case *ast.SliceExpr:
+		r.Resolve(node.Left)
+		r.Resolve(node.Low)
+		r.Resolve(node.High)
 	case *ast.ArrayLiteralExpr:
 		for _, el := range node.Elements {
 			r.Resolve(el)
 		}
+	case *ast.TemplateExpr:
+		for _, expr := range node.Exprs {
+			r.Resolve(expr)
+		}
 	case *ast.HashLiteralExpr:
-		for k, v := range node.Pairs {
-			r.Resolve(k)
-			r.Resolve(v)
+		for _, pair := range node.Pairs {
+			r.Resolve(pair.Key)
+			r.Resolve(pair.Value)
 		}
 	case *ast.IntLiteralExpr:
+	case *ast.FloatLiteralExpr:
 	case *ast.BoolLiteralExpr:
 	case *ast.StringLiteralExpr:
 	case *ast.NullExpr:
 	}
 }
 
+func (r *resolver) resolveLoopBody(body ast.Statement) {
+	r.loopDepth++
+	r.Resolve(body)
+	r.loopDepth--
+}
+
 func (r *resolver) resolveFn(fn *ast.FunctionExpr, t FnType) {
 	enclosingFn := r.currFn
 	r.currFn = t
 
+	// loops do not cross function boundaries
+	enclosingLoopDepth := r.loopDepth
+	r.loopDepth = 0
+
 	r.beginScope()
 
 	for _, p := range fn.Parameters {
@@ -206,6 +313,7 @@ func (r *resolver) resolveFn(fn *ast.FunctionExpr, t FnType) {
 
 	r.endScope()
 	r.currFn = enclosingFn
+	r.loopDepth = enclosingLoopDepth
 }
 
 func (r *resolver) resolveVariable(name *ast.IdentifierExpr) {
@@ -217,9 +325,12 @@ func (r *resolver) resolveLocal(expr ast.Expression, name string) {
 
 	foundUndefined := false
 	for i := len(scopes) - 1; i >= 0; i-- {
-		if defined, ok := scopes[i][name]; ok {
-			if defined {
+		if v, ok := scopes[i][name]; ok {
+			if v.defined {
 				r.locals[expr] = len(scopes) - 1 - i
+				if v.decl != nil {
+					r.definitions[expr] = v.decl
+				}
 				return
 			} else {
 				// variable 'x' appears on the right side of initializer of variable 'x'
@@ -230,7 +341,7 @@ func (r *resolver) resolveLocal(expr ast.Expression, name string) {
 
 	// no other 'x' variables found in outer scopes
 	if foundUndefined {
-		r.error(fmt.Sprintf(ERR_READ_ON_OWN_INIT, name))
+		r.error(expr, ERR_READ_ON_OWN_INIT, name)
 	}
 	// assume variable is global/builtin and do nothing ( and crash at runtime :D )
 }
@@ -248,9 +359,17 @@ func (r *resolver) declare(name *ast.IdentifierExpr) {
 	}
 
 	if _, alreadyDeclared := currScope[name.Value]; alreadyDeclared {
-		r.error(fmt.Sprintf(ERR_ALREADY_DECLARED, name.Value))
-	} else {
-		currScope[name.Value] = false
+		r.error(name, ERR_ALREADY_DECLARED, name.Value)
+		return
+	}
+	currScope[name.Value] = &variable{decl: name}
+
+	scopes := r.scopes.List()
+	for i := len(scopes) - 2; i >= 0; i-- {
+		if v, ok := scopes[i][name.Value]; ok && v.decl != nil {
+			r.shadows[name] = v.decl
+			return
+		}
 	}
 }
 
@@ -264,17 +383,26 @@ func (r *resolver) defineName(name string) {
 		return
 	}
 
-	currScope[name] = true
+	if v, ok := currScope[name]; ok {
+		v.defined = true
+	} else {
+		currScope[name] = &variable{defined: true}
+	}
 }
 
 func (r *resolver) beginScope() {
-	r.scopes.Push(make(map[string]bool))
+	r.scopes.Push(make(map[string]*variable))
 }
 
 func (r *resolver) endScope() {
 	r.scopes.Pop()
 }
 
-func (r *resolver) error(err string) {
-	r.errors = append(r.errors, err)
+func (r *resolver) error(node ast.Node, format string, args ...interface{}) {
+	d := diagnostics.New(diagnostics.Error, errorCodes[format], ast.Span(node), format, args...)
+	if hint, ok := errorHints[format]; ok {
+		d = d.WithHint(hint)
+	}
+
+	r.errors = append(r.errors, d)
 }
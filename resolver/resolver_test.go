@@ -1,7 +1,9 @@
 package resolver_test
 
 import (
+	"fmt"
 	"monkey/ast"
+	"monkey/diagnostics"
 	"monkey/lexer"
 	"monkey/parser"
 	"monkey/resolver"
@@ -12,34 +14,34 @@ import (
 func TestResolver(t *testing.T) {
 	tt := []struct {
 		source string
-		want   map[string]int
+		want   map[string]int // reference position -> depth
 	}{
 		{source: "x;", want: map[string]int{}},
-		{source: "let x; x;", want: map[string]int{"x": 0}},
-		{source: "let x = 10; x;", want: map[string]int{"x": 0}},
-		{source: "let x = 10; { let f = x; }", want: map[string]int{"x": 1}},
-		{source: "let x = 10; { let x = x; }", want: map[string]int{"x": 1}},
-		{source: "let x = 10; x = x;", want: map[string]int{"x": 0}},
-		{source: "let x = 10; x = x + 1;", want: map[string]int{"x": 0}},
-		{source: "let x = 10; { x; }", want: map[string]int{"x": 1}},
-		{source: "let x = 10; { [x, 20, 30]; }", want: map[string]int{"x": 1}},
-		{source: "let x = 1; { [10, 20, 30][x]; }", want: map[string]int{"x": 1}},
-		{source: "let x = 1; { {| 10: x, 20: 30 |}; }", want: map[string]int{"x": 1}},
-		{source: "let x = 10; { {| 10: 20, 30: 40 |}[x]; }", want: map[string]int{"x": 1}},
-		{source: "let x = 10; x = 20;", want: map[string]int{"x": 0}},
-		{source: "{ let x = true; return !x; }", want: map[string]int{"x": 0}},
-		{source: "let a = 1; let b = 2; return a + b;", want: map[string]int{"a": 0, "b": 0}},
+		{source: "let x; x;", want: map[string]int{"1:8": 0}},
+		{source: "let x = 10; x;", want: map[string]int{"1:13": 0}},
+		{source: "let x = 10; { let f = x; }", want: map[string]int{"1:23": 1}},
+		{source: "let x = 10; { let x = x; }", want: map[string]int{"1:23": 1}},
+		{source: "let x = 10; x = x;", want: map[string]int{"1:13": 0, "1:17": 0}},
+		{source: "let x = 10; x = x + 1;", want: map[string]int{"1:13": 0, "1:17": 0}},
+		{source: "let x = 10; { x; }", want: map[string]int{"1:15": 1}},
+		{source: "let x = 10; { [x, 20, 30]; }", want: map[string]int{"1:16": 1}},
+		{source: "let x = 1; { [10, 20, 30][x]; }", want: map[string]int{"1:27": 1}},
+		{source: "let x = 1; { {| 10: x, 20: 30 |}; }", want: map[string]int{"1:21": 1}},
+		{source: "let x = 10; { {| 10: 20, 30: 40 |}[x]; }", want: map[string]int{"1:36": 1}},
+		{source: "let x = 10; x = 20;", want: map[string]int{"1:13": 0}},
+		{source: "{ let x = true; return !x; }", want: map[string]int{"1:25": 0}},
+		{source: "let a = 1; let b = 2; return a + b;", want: map[string]int{"1:30": 0, "1:34": 0}},
 		{
 			source: "let x = true; let a = 1; let b = 2; if (x) a else b;",
-			want:   map[string]int{"x": 0, "a": 0, "b": 0},
+			want:   map[string]int{"1:41": 0, "1:44": 0, "1:51": 0},
 		},
 		{
 			source: "let f = fn(x) { x }; f(10);",
-			want:   map[string]int{"x": 0, "f": 0},
+			want:   map[string]int{"1:17": 0, "1:22": 0},
 		},
 		{
 			source: "let x = 10; let f = fn() { x }; { f() }",
-			want:   map[string]int{"f": 1, "x": 1},
+			want:   map[string]int{"1:28": 1, "1:35": 1},
 		},
 		{
 			source: "class A < B {}",
@@ -47,7 +49,7 @@ func TestResolver(t *testing.T) {
 		},
 		{
 			source: "class B {} class A < B {}",
-			want:   map[string]int{"B": 0},
+			want:   map[string]int{"1:22": 0},
 		},
 		{
 			source: `class A {
@@ -57,11 +59,31 @@ func TestResolver(t *testing.T) {
 						B();
 					}
 				  }`,
-			want: map[string]int{"A": 2, "this": 1, "B": 0, "n": 0, "super": 2},
+			want: map[string]int{"2:19": 1, "2:28": 0, "4:17": 2, "4:30": 2, "5:7": 0},
 		},
 		{
 			source: "let x = 1; class B {} { class A < B { fn f() { x = 20; }} }",
-			want:   map[string]int{"B": 1, "x": 4},
+			want:   map[string]int{"1:35": 1, "1:48": 4},
+		},
+		{
+			source: "let n = 0; while (n < 10) { n = n + 1; }",
+			want:   map[string]int{"1:19": 0, "1:29": 1, "1:33": 1},
+		},
+		{
+			source: "for (let i = 0; i < 10; i = i + 1) { i; }",
+			want:   map[string]int{"1:17": 0, "1:25": 0, "1:29": 0, "1:38": 1},
+		},
+		{
+			source: "let xs = [1]; for (x in xs) x;",
+			want:   map[string]int{"1:25": 0, "1:29": 0},
+		},
+		{
+			source: `let x = 1; { "x is ${x}"; }`,
+			want:   map[string]int{"1:22": 1},
+		},
+		{
+			source: "let f; try { f(); } catch (e) { e; } finally { f; }",
+			want:   map[string]int{"1:14": 1, "1:33": 1, "1:48": 1},
 		},
 	}
 
@@ -77,16 +99,12 @@ func TestResolver(t *testing.T) {
 			}
 
 			got := make(map[string]int)
-			for name, depth := range locals {
-				switch local := name.(type) {
-				case *ast.IdentifierExpr:
-					got[local.Value] = depth
-				case *ast.ThisExpr:
-					got[local.TokenLiteral()] = depth
-				case *ast.SuperExpr:
-					got[local.TokenLiteral()] = depth
+			for expr, depth := range locals {
+				switch expr.(type) {
+				case *ast.IdentifierExpr, *ast.ThisExpr, *ast.SuperExpr:
+					got[position(expr)] = depth
 				default:
-					t.Fatalf("Expect local to be an Identifier, This or Super, got %T.", name)
+					t.Fatalf("Expect local to be an Identifier, This or Super, got %T.", expr)
 				}
 			}
 
@@ -143,6 +161,21 @@ func TestResolverErrorHandling(t *testing.T) {
 			source: "class A { fn f() { super.method; } }",
 			want:   []string{resolver.ERR_SUPER_WITHOUT_SUPERCLASS},
 		},
+		{source: "while (true) { break; continue; }", want: []string{}},
+		{source: "for (x in xs) { if (x) break; }", want: []string{}},
+		{source: "break;", want: []string{resolver.ERR_BREAK_OUTSIDE_OF_LOOP}},
+		{source: "{ continue; }", want: []string{resolver.ERR_CONTINUE_OUTSIDE_OF_LOOP}},
+		{
+			source: "while (true) { fn f() { break; } }",
+			want:   []string{resolver.ERR_BREAK_OUTSIDE_OF_LOOP},
+		},
+		{
+			source: "for (;;) { class A { fn m() { continue; } } break; }",
+			want:   []string{resolver.ERR_CONTINUE_OUTSIDE_OF_LOOP},
+		}, {
+			source: `{ import "lib.mk" as lib; let lib = 1; }`,
+			want:   []string{"Variable 'lib' is already declared in current scope."},
+		},
 	}
 
 	for _, tc := range tt {
@@ -155,19 +188,88 @@ func TestResolverErrorHandling(t *testing.T) {
 					t.Errorf("Want error %q, got none.", tc.want[i])
 				}
 
-				if tc.want[i] != errors[i] {
-					t.Errorf("Wrong error message. Want %q, got %q.", tc.want[i], errors[i])
+				if tc.want[i] != errors[i].Message {
+					t.Errorf("Wrong error message. Want %q, got %q.", tc.want[i], errors[i].Message)
 				}
 			}
 
 			for ; i < len(errors); i++ {
-				t.Errorf("Got error %q, expected none.", errors[i])
+				t.Errorf("Got error %q, expected none.", errors[i].Message)
+			}
+		})
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	tt := []struct {
+		source string
+		want   map[string]string // reference position -> declaration position
+	}{
+		{source: "let x = 1; x;", want: map[string]string{"1:12": "1:5"}},
+		{source: "let x = 1; { let x = 2; x; } x;", want: map[string]string{"1:25": "1:18", "1:30": "1:5"}},
+		{source: "let f = fn(a) { a + f(a) };", want: map[string]string{"1:17": "1:12", "1:21": "1:5", "1:23": "1:12"}},
+		{source: "let x = 1; x = 2;", want: map[string]string{"1:12": "1:5"}},
+		{source: "for (x in [1]) { x; }", want: map[string]string{"1:18": "1:6"}},
+		{source: "try {} catch (e) { e; }", want: map[string]string{"1:20": "1:15"}},
+		{source: "class A { fn m() { this; } }", want: map[string]string{}},
+		{source: "y;", want: map[string]string{}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			p := parser.New(lexer.New(tc.source))
+			program := p.ParseProgram()
+			r := resolver.New()
+			r.Resolve(program)
+
+			got := map[string]string{}
+			for expr, decl := range r.Definitions() {
+				got[position(expr)] = position(decl)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Got %v, want %v", got, tc.want)
 			}
 		})
 	}
 }
 
-func resolve(t testing.TB, source string) (map[ast.Expression]int, []string) {
+func TestShadows(t *testing.T) {
+	tt := []struct {
+		source string
+		want   map[string]string // declaration position -> shadowed declaration position
+	}{
+		{source: "let x = 1; { let x = 2; }", want: map[string]string{"1:18": "1:5"}},
+		{source: "let x = 1; fn f(x) { let y = 1; { let x = 2; } }", want: map[string]string{"1:17": "1:5", "1:39": "1:17"}},
+		{source: "let e = 1; try {} catch (e) {} for (e in []) {}", want: map[string]string{"1:26": "1:5", "1:37": "1:5"}},
+		{source: "fn f() { let g = 1; } let g = 2;", want: map[string]string{}},
+		{source: "for (let i = 0; i < 1; i += 1) { let i = 2; }", want: map[string]string{"1:38": "1:10"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			p := parser.New(lexer.New(tc.source))
+			program := p.ParseProgram()
+			r := resolver.New()
+			r.Resolve(program)
+
+			got := map[string]string{}
+			for decl, shadowed := range r.Shadows() {
+				got[position(decl)] = position(shadowed)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func position(node ast.Node) string {
+	return fmt.Sprintf("%d:%d", node.Pos().Line, node.Pos().Column)
+}
+
+func resolve(t testing.TB, source string) (map[ast.Expression]int, []diagnostics.Diagnostic) {
 	t.Helper()
 
 	p := parser.New(lexer.New(source))
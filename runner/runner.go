@@ -3,32 +3,192 @@ package runner
 import (
 	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/checker"
+	"monkey/compiler"
+	"monkey/dap"
+	"monkey/debugger"
+	"monkey/diagnostics"
 	"monkey/eval"
+	"monkey/format"
 	"monkey/lexer"
+	"monkey/lint"
+	"monkey/lsp"
 	"monkey/object"
 	"monkey/parser"
 	"monkey/resolver"
+	"monkey/vm"
 	"os"
 )
 
-func RunFile(name string) {
+const RUNTIME_ERROR_CODE = "E001"
+
+// Engine selects backend executing programs.
+type Engine string
+
+const (
+	EVAL Engine = "eval" // tree-walking evaluator
+	VM   Engine = "vm"   // bytecode compiler and virtual machine
+)
+
+func ParseEngine(name string) (Engine, error) {
+	switch Engine(name) {
+	case EVAL, VM:
+		return Engine(name), nil
+	default:
+		return "", fmt.Errorf("unknown engine %q, expect one of: eval, vm", name)
+	}
+}
+
+type Options struct {
+	Diagnostics diagnostics.Format
+	Engine      Engine
+	// MaxCallDepth limits nested calls, zero means default of the engine.
+	MaxCallDepth int
+	// Capabilities granted to programs, nil means eval.DefaultCapabilities.
+	Capabilities []eval.Capability
+}
+
+func RunFile(name string, opts Options) {
+	runProgram(name, readFile(name), opts)
+}
+
+// DebugFile runs script with the terminal debugger reading commands from stdin.
+func DebugFile(name string, opts Options) {
+	requireEvaluator(opts)
+	source := readFile(name)
+	program, locals := parseProgram(name, source, opts.Diagnostics)
+
+	t := debugger.NewTerminal(name, source, os.Stdin, os.Stdout)
+	if opts.MaxCallDepth > 0 {
+		t.Debugger.SetMaxCallDepth(opts.MaxCallDepth)
+	}
+	if opts.Capabilities != nil {
+		t.Debugger.SetCapabilities(opts.Capabilities...)
+	}
+	result := t.Debugger.Run(program, locals)
+	if result == nil {
+		io.WriteString(os.Stdout, "Program aborted.\n")
+		return
+	}
+	reportRuntimeError(result, opts.Diagnostics)
+}
+
+// ServeDAP runs Debug Adapter Protocol server on stdin and stdout, output
+// printed by the program is forwarded to the client.
+func ServeDAP(opts Options) {
+	requireEvaluator(opts)
+
+	s := dap.NewServer(os.Stdin, os.Stdout)
+	if opts.MaxCallDepth > 0 {
+		s.Debugger().SetMaxCallDepth(opts.MaxCallDepth)
+	}
+	if opts.Capabilities != nil {
+		s.Debugger().SetCapabilities(opts.Capabilities...)
+	}
+
+	if err := s.Serve(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(70)
+	}
+}
+
+// ServeLSP runs Language Server Protocol server on stdin and stdout.
+func ServeLSP() {
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		os.Stderr.WriteString(err.Error() + "\n")
+		os.Exit(70)
+	}
+}
+
+// FormatFiles rewrites files in canonical format. With check files are
+// not changed, names of those which are not formatted are printed instead
+// and the exit code is 1.
+func FormatFiles(names []string, check bool, opts Options) {
+	code := 0
+	for _, name := range names {
+		source := readFile(name)
+
+		formatted, errors := format.Source(name, source)
+		if len(errors) != 0 {
+			printParseErrors(os.Stderr, opts.Diagnostics, source, errors)
+			code = 65
+			continue
+		}
+		if formatted == source {
+			continue
+		}
+
+		if check {
+			io.WriteString(os.Stdout, name+"\n")
+			if code == 0 {
+				code = 1
+			}
+			continue
+		}
+		if err := os.WriteFile(name, []byte(formatted), 0o644); err != nil {
+			os.Stderr.WriteString(fmt.Sprintf("Can not write file %q : %s\n", name, err.Error()))
+			code = 74
+		}
+	}
+
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// LintFiles reports warnings found in files, the exit code is 1 when
+// there are any and 65 when some file has errors.
+func LintFiles(names []string, config lint.Config, opts Options) {
+	code := 0
+	for _, name := range names {
+		source := readFile(name)
+
+		diags := lint.Source(name, source, config)
+		if len(diags) == 0 {
+			continue
+		}
+
+		diagnostics.Render(os.Stderr, opts.Diagnostics, source, diags)
+		if diagnostics.HasErrors(diags) {
+			code = 65
+		} else if code == 0 {
+			code = 1
+		}
+	}
+
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// requireEvaluator exits unless programs run with the tree-walking
+// evaluator, debuggers pause it between statements.
+func requireEvaluator(opts Options) {
+	if opts.Engine == VM {
+		os.Stderr.WriteString("Debugging is supported only with eval engine.\n")
+		os.Exit(64)
+	}
+}
+
+func readFile(name string) string {
 	data, err := os.ReadFile(name)
 	if err != nil {
 		os.Stderr.WriteString(fmt.Sprintf("Can not read file %q : %s", name, err.Error()))
 		os.Exit(64)
 	}
-	runProgram(string(data))
+	return string(data)
 }
 
-func runProgram(source string) {
-	env := object.NewEnvironment()
-	l := lexer.New(string(source))
+// parseProgram parses and resolves source, it exits when source has errors.
+func parseProgram(file string, source string, format diagnostics.Format) (*ast.Program, map[ast.Expression]int) {
+	l := lexer.NewFile(file, source)
 	p := parser.New(l)
 
 	program := p.ParseProgram()
 
 	if len(p.Errors()) != 0 {
-		printParseErrors(os.Stderr, p.Errors())
+		printParseErrors(os.Stderr, format, source, p.Errors())
 		os.Exit(65)
 	}
 
@@ -36,23 +196,91 @@ func runProgram(source string) {
 	r.Resolve(program)
 
 	if len(r.Errors()) != 0 {
-		printParseErrors(os.Stderr, r.Errors())
+		printParseErrors(os.Stderr, format, source, r.Errors())
+		os.Exit(65)
+	}
+
+	c := checker.New(r.Definitions())
+	c.Check(program)
+
+	if diagnostics.HasErrors(c.Errors()) {
+		printDiagnostics(os.Stderr, format, "Errors found while checking types:", source, c.Errors())
 		os.Exit(65)
 	}
+	if len(c.Errors()) != 0 {
+		printDiagnostics(os.Stderr, format, "Warnings found while checking types:", source, c.Errors())
+	}
 
-	eval.Locals = r.Locals()
+	return program, r.Locals()
+}
+
+func runProgram(file string, source string, opts Options) {
+	program, locals := parseProgram(file, source, opts.Diagnostics)
 
-	evalResult := eval.Eval(program, env)
+	var result object.Object
+	if opts.Engine == VM {
+		result = runCompiled(program, opts)
+	} else {
+		e := eval.New()
+		e.SetLocals(locals)
+		if opts.MaxCallDepth > 0 {
+			e.SetMaxCallDepth(opts.MaxCallDepth)
+		}
+		if opts.Capabilities != nil {
+			e.SetCapabilities(opts.Capabilities...)
+		}
+		result = e.Eval(program, object.NewEnvironment())
+	}
+
+	reportRuntimeError(result, opts.Diagnostics)
+}
+
+// reportRuntimeError exits when result is an error.
+func reportRuntimeError(result object.Object, format diagnostics.Format) {
+	if err, ok := result.(*object.Error); ok {
+		if format == diagnostics.JSON {
+			diagnostics.RenderJSON(os.Stderr, []diagnostics.Diagnostic{runtimeDiagnostic(err)})
+		} else {
+			io.WriteString(os.Stderr, err.StackTrace()+"\n")
+		}
+		os.Exit(70)
+	}
+}
 
-	if evalResult != nil && evalResult.Type() == object.ERROR_OBJ {
-		io.WriteString(os.Stderr, evalResult.Inspect()+"\n")
+func runCompiled(program *ast.Program, opts Options) object.Object {
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		io.WriteString(os.Stderr, err.Error()+"\n")
 		os.Exit(70)
 	}
+
+	machine := vm.New(c.Bytecode())
+	if opts.MaxCallDepth > 0 {
+		machine.SetMaxCallDepth(opts.MaxCallDepth)
+	}
+	if opts.Capabilities != nil {
+		machine.SetCapabilities(opts.Capabilities...)
+	}
+	return machine.Run()
+}
+
+func runtimeDiagnostic(err *object.Error) diagnostics.Diagnostic {
+	d := diagnostics.New(diagnostics.Error, RUNTIME_ERROR_CODE, err.Span, err.Message)
+	for i := len(err.Stack) - 1; i >= 0; i-- {
+		frame := err.Stack[i]
+		d = d.WithNote(frame.Name() + " called at " + frame.CallSite.String())
+	}
+	return d
+}
+
+func printParseErrors(out io.Writer, format diagnostics.Format, source string, errors []diagnostics.Diagnostic) {
+	printDiagnostics(out, format, "Errors found while parsing:", source, errors)
 }
 
-func printParseErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "Errors found while parsing:\n")
-	for _, e := range errors {
-		io.WriteString(out, "\t"+e+"\n")
+// printDiagnostics renders diagnostics, text ones under the header.
+func printDiagnostics(out io.Writer, format diagnostics.Format, header string, source string, diags []diagnostics.Diagnostic) {
+	if format == diagnostics.Text {
+		io.WriteString(out, header+"\n")
 	}
+	diagnostics.Render(out, format, source, diags)
 }
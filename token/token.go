@@ -1,23 +1,74 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
+// Position is a location in source code. Line and Column are 1-based,
+// Column counts bytes. The zero Position is not valid.
+type Position struct {
+	File   string
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.File != "" {
+			return p.File
+		}
+		return "-"
+	}
+
+	s := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.File != "" {
+		s = p.File + ":" + s
+	}
+	return s
+}
+
+// Span is a half-open source range [Start, End).
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) IsValid() bool  { return s.Start.IsValid() }
+func (s Span) String() string { return s.Start.String() }
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Start   Position
+	End     Position
 }
 
+func (t Token) Span() Span { return Span{Start: t.Start, End: t.End} }
+
 func (t Token) String() string {
 	return "Token{Type: " + string(t.Type) + ", Literal: " + t.Literal + "}"
 }
 
 const (
-	ILLEGAL = "ILLEGAL"
-	EOF     = "EOF"
+	ILLEGAL             = "ILLEGAL"
+	UNTERMINATED_STRING = "UNTERMINATED_STRING"
+	INVALID_NUMBER      = "INVALID_NUMBER"
+	EOF                 = "EOF"
 
 	IDENTIFIER = "IDENTIFIER"
 	INT        = "INT"
-	STRING     = "STRING"
+	FLOAT      = "FLOAT"
+	STRING     = "STRING"     // "..." with escape sequences, Literal keeps them undecoded
+	RAW_STRING = "RAW_STRING" // `...` taken verbatim, may span multiple lines
+	COMMENT    = "COMMENT"    // "// ..." up to the end of line, never returned by NextToken
+
+	// "head ${a} middle ${b} tail" is lexed into template parts around tokens of embedded expressions
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"
 
 	ASSIGN = "="
 	PLUS   = "+"
@@ -26,6 +77,20 @@ const (
 	SLASH  = "/"
 	BANG   = "!"
 
+	PERCENT     = "%"
+	POWER       = "**"
+	BIT_AND     = "&"
+	BIT_OR      = "|"
+	BIT_XOR     = "^"
+	BIT_NOT     = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	PLUS_ASSIGN  = "+="
+	MINUS_ASSIGN = "-="
+	STAR_ASSIGN  = "*="
+	SLASH_ASSIGN = "/="
+
 	OR  = "||"
 	AND = "&&"
 
@@ -41,12 +106,12 @@ const (
 	SEMICOLON = ";"
 	COLON     = ":"
 
-	LPAREN   = "("
-	RPAREN   = ")"
-	LBRACE   = "{"
-	RBRACE   = "}"
-	LBRACKET = "["
-	RBRACKET = "]"
+	LPAREN     = "("
+	RPAREN     = ")"
+	LBRACE     = "{"
+	RBRACE     = "}"
+	LBRACKET   = "["
+	RBRACKET   = "]"
 	LHASHBRACE = "{|"
 	RHASHBRACE = "|}"
 
@@ -59,6 +124,17 @@ const (
 	RETURN   = "RETURN"
 	IF       = "IF"
 	ELSE     = "ELSE"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	IMPORT   = "IMPORT"
+	AS       = "AS"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	NULL     = "NULL"
@@ -79,11 +155,38 @@ var keywords = map[string]TokenType{
 	"return":      RETURN,
 	"if":          IF,
 	"else":        ELSE,
+	"while":       WHILE,
+	"for":         FOR,
+	"in":          IN,
+	"break":       BREAK,
+	"continue":    CONTINUE,
+	"import":      IMPORT,
+	"as":          AS,
+	"try":         TRY,
+	"catch":       CATCH,
+	"finally":     FINALLY,
+	"throw":       THROW,
 	"true":        TRUE,
 	"false":       FALSE,
 	"null":        NULL,
 }
 
+// compoundAssignments maps compound assignment operators
+// to the operator they apply.
+var compoundAssignments = map[TokenType]string{
+	PLUS_ASSIGN:  PLUS,
+	MINUS_ASSIGN: MINUS,
+	STAR_ASSIGN:  STAR,
+	SLASH_ASSIGN: SLASH,
+}
+
+// CompoundOperator returns operator applied by compound assignment,
+// e.g. "+" for "+=", it reports false for plain assignment.
+func CompoundOperator(assignment TokenType) (string, bool) {
+	operator, ok := compoundAssignments[assignment]
+	return operator, ok
+}
+
 func LookupKeyword(identifier string) TokenType {
 	if token, ok := keywords[identifier]; ok {
 		return token
@@ -0,0 +1,59 @@
+package transport_test
+
+import (
+	"bufio"
+	"bytes"
+	"monkey/transport"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, msg := range []string{"first", "second"} {
+		if err := transport.WriteMessage(&buf, map[string]string{"text": msg}); err != nil {
+			t.Fatalf("Error while writing message: %s.", err)
+		}
+	}
+
+	r := bufio.NewReader(&buf)
+	for _, want := range []string{`{"text":"first"}`, `{"text":"second"}`} {
+		content, err := transport.ReadMessage(r)
+		if err != nil {
+			t.Fatalf("Error while reading message: %s.", err)
+		}
+		if string(content) != want {
+			t.Errorf("Wrong content, got %q, want %q.", content, want)
+		}
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tt := []struct {
+		input string
+		want  string
+		err   string
+	}{
+		{input: "content-length: 2\r\nContent-Type: json\r\n\r\n{}", want: "{}"},
+		{input: "Content-Type: json\r\n\r\n{}", err: "missing Content-Length header"},
+		{input: "Content-Length: x\r\n\r\n{}", err: `invalid Content-Length " x"`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.input, func(t *testing.T) {
+			content, err := transport.ReadMessage(bufio.NewReader(strings.NewReader(tc.input)))
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("Wrong error, got %v, want %q.", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error while reading message: %s.", err)
+			}
+			if string(content) != tc.want {
+				t.Errorf("Wrong content, got %q, want %q.", content, tc.want)
+			}
+		})
+	}
+}
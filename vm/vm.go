@@ -0,0 +1,775 @@
+package vm
+
+import (
+	"context"
+	"io"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/object"
+	"monkey/token"
+	"os"
+	"strings"
+)
+
+const (
+	StackSize = 1 << 16
+	// DefaultMaxCallDepth is the limit of nested calls of new machines,
+	// see SetMaxCallDepth.
+	DefaultMaxCallDepth = 1 << 10
+)
+
+const ERR_STACK_OVERFLOW = eval.ERR_STACK_OVERFLOW
+
+// frame is an activation of compiled function, its locals start at base.
+type frame struct {
+	fn   *object.Function
+	ip   int
+	base int
+	info object.Frame
+}
+
+type upvalueSlot struct {
+	slot    int
+	upvalue *object.Upvalue
+}
+
+// handler catches errors raised while it is installed by OpTry, it
+// unwinds frames and stack to the state they had at that time.
+type handler struct {
+	fp     int
+	sp     int
+	target int
+}
+
+// iterator walks over snapshot of items in for-in loop.
+type iterator struct {
+	items []object.Object
+	next  int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "<iterator>" }
+
+// stackOverflow is raised by push when the stack is exhausted.
+type stackOverflow struct{}
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+	builtins    map[string]*object.Builtin
+	granted     []eval.Capability
+	stdout      io.Writer
+
+	// limits bound every run, budget tracks resources used by the last one.
+	limits eval.Limits
+	budget *eval.Budget
+
+	stack []object.Object
+	sp    int // points to the next free slot
+
+	frames []frame
+	fp     int // number of active frames
+
+	openUpvalues []upvalueSlot
+	handlers     []handler
+	last         object.Object // value of the last executed statement
+
+	// modules caches imported modules by absolute path, importing is
+	// a chain of modules being run, used to detect cycles.
+	modules   map[string]*object.Module
+	importing []string
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	vm := &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, len(bytecode.Globals)),
+		globalNames: bytecode.Globals,
+		builtins:    eval.Builtins(eval.DefaultCapabilities...),
+		granted:     eval.DefaultCapabilities,
+		stdout:      os.Stdout,
+		stack:       make([]object.Object, StackSize),
+		frames:      make([]frame, DefaultMaxCallDepth+1),
+		last:        eval.NULL,
+		modules:     map[string]*object.Module{},
+	}
+
+	main := &object.Function{Compiled: bytecode.Main}
+	vm.push(main)
+	vm.frames[0] = frame{fn: main}
+	vm.fp = 1
+
+	return vm
+}
+
+// SetMaxCallDepth limits number of nested calls, deeper calls fail with
+// stack overflow error. Calls returned by functions replace them, so they
+// do not count. It must be called before Run.
+func (vm *VM) SetMaxCallDepth(depth int) {
+	// the first frame runs the program itself
+	frames := make([]frame, depth+1)
+	copy(frames, vm.frames[:vm.fp])
+	vm.frames = frames
+}
+
+// SetCapabilities grants program builtins of given capabilities only, calls
+// of other builtins fail with PermissionError. New machines are granted
+// eval.DefaultCapabilities. Imports read module files, so they need
+// eval.FILESYSTEM.
+func (vm *VM) SetCapabilities(granted ...eval.Capability) {
+	vm.builtins = eval.Builtins(granted...)
+	vm.granted = granted
+}
+
+// SetStdout redirects output printed by the program, new machines print
+// to os.Stdout.
+func (vm *VM) SetStdout(out io.Writer) {
+	vm.stdout = out
+}
+
+// SetLimits bounds resources used by following runs, steps are executed
+// instructions. Limits must not be changed while the program runs.
+func (vm *VM) SetLimits(limits eval.Limits) {
+	vm.limits = limits
+}
+
+// Usage returns resources used by the last run, see SetLimits.
+func (vm *VM) Usage() eval.Usage {
+	if vm.budget == nil {
+		return eval.Usage{}
+	}
+	return vm.budget.Usage()
+}
+
+// Run executes the program and returns its value, runtime errors are
+// returned as *object.Error with position and call stack attached.
+func (vm *VM) Run() object.Object {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext executes the program like Run, the run fails with
+// eval.CANCELLED_ERROR or eval.TIMEOUT_ERROR when ctx is done.
+func (vm *VM) RunContext(ctx context.Context) (result object.Object) {
+	if vm.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, vm.limits.Timeout)
+		defer cancel()
+	}
+	vm.budget = eval.NewBudget(ctx, vm.limits)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(stackOverflow); !ok {
+				panic(r)
+			}
+			result = vm.fail(eval.StackOverflowError())
+		}
+	}()
+
+	return vm.run(0)
+}
+
+// Continue runs bytecode compiled by the compiler of the program run
+// before, e.g. next line of REPL, globals defined before stay visible.
+func (vm *VM) Continue(bytecode *compiler.Bytecode) object.Object {
+	// the previous program may have failed in the middle
+	vm.closeUpvalues(0)
+	vm.sp, vm.handlers, vm.last = 0, nil, eval.NULL
+
+	vm.constants, vm.globalNames = bytecode.Constants, bytecode.Globals
+	vm.globals = append(vm.globals, make([]object.Object, len(vm.globalNames)-len(vm.globals))...)
+
+	main := &object.Function{Compiled: bytecode.Main}
+	vm.push(main)
+	vm.frames[0] = frame{fn: main}
+	vm.fp = 1
+
+	return vm.Run()
+}
+
+// run executes instructions until the function running in frame
+// stopAt+1 returns, builtins calling back into functions of the program
+// run them in nested loops.
+func (vm *VM) run(stopAt int) object.Object {
+	for {
+		fr := &vm.frames[vm.fp-1]
+		ins := fr.fn.Compiled.Instructions
+		op := compiler.Opcode(ins[fr.ip])
+		fr.ip++
+
+		if err := vm.budget.Step(); err != nil {
+			if err := vm.fail(err); !vm.catch(err, stopAt) {
+				return err
+			}
+			continue
+		}
+
+		var err *object.Error
+
+		switch op {
+		case compiler.OpConstant:
+			vm.push(vm.constants[vm.readOperand(fr)])
+		case compiler.OpNull:
+			vm.push(eval.NULL)
+		case compiler.OpTrue:
+			vm.push(eval.TRUE)
+		case compiler.OpFalse:
+			vm.push(eval.FALSE)
+		case compiler.OpPop:
+			vm.pop()
+		case compiler.OpResult:
+			vm.last = vm.pop()
+		case compiler.OpLastResult:
+			vm.push(vm.last)
+		case compiler.OpDup:
+			n := int(ins[fr.ip])
+			fr.ip++
+			for _, obj := range vm.stack[vm.sp-n : vm.sp] {
+				vm.push(obj)
+			}
+
+		case compiler.OpDefineGlobal:
+			vm.globals[vm.readOperand(fr)] = vm.peek()
+		case compiler.OpGetGlobal:
+			idx := vm.readOperand(fr)
+			if val := vm.globals[idx]; val != nil {
+				vm.push(val)
+			} else if builtin, ok := vm.builtins[vm.globalNames[idx]]; ok {
+				vm.push(builtin)
+			} else {
+				err = eval.IdentifierNotFoundError(vm.globalNames[idx])
+			}
+		case compiler.OpSetGlobal:
+			idx := vm.readOperand(fr)
+			if vm.globals[idx] == nil {
+				err = eval.IdentifierNotFoundError(vm.globalNames[idx])
+			} else {
+				vm.globals[idx] = vm.peek()
+			}
+		case compiler.OpGetLocal:
+			vm.push(vm.stack[fr.base+vm.readOperand(fr)])
+		case compiler.OpSetLocal:
+			vm.stack[fr.base+vm.readOperand(fr)] = vm.peek()
+		case compiler.OpGetUpvalue:
+			vm.push(*fr.fn.Upvalues[vm.readOperand(fr)].Location)
+		case compiler.OpSetUpvalue:
+			*fr.fn.Upvalues[vm.readOperand(fr)].Location = vm.peek()
+		case compiler.OpCloseUpvalue:
+			vm.closeUpvalues(vm.sp - 1)
+			vm.pop()
+
+		case compiler.OpBang, compiler.OpNegate, compiler.OpBitNot:
+			err = vm.push(eval.Prefix(compiler.Operators[op], vm.pop()))
+		case compiler.OpAdd, compiler.OpSub, compiler.OpMul, compiler.OpDiv, compiler.OpMod, compiler.OpPow,
+			compiler.OpBitAnd, compiler.OpBitOr, compiler.OpBitXor, compiler.OpShiftLeft, compiler.OpShiftRight,
+			compiler.OpEqual, compiler.OpNotEqual,
+			compiler.OpGreater, compiler.OpGreaterEqual, compiler.OpLess, compiler.OpLessEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushNew(eval.Infix(left, compiler.Operators[op], right))
+
+		case compiler.OpJump:
+			fr.ip = vm.readOperand(fr)
+		case compiler.OpJumpIfFalse:
+			target := vm.readOperand(fr)
+			if !eval.IsTruthy(vm.pop()) {
+				fr.ip = target
+			}
+		case compiler.OpJumpIfFalseKeep:
+			target := vm.readOperand(fr)
+			if !eval.IsTruthy(vm.peek()) {
+				fr.ip = target
+			}
+		case compiler.OpJumpIfTrueKeep:
+			target := vm.readOperand(fr)
+			if eval.IsTruthy(vm.peek()) {
+				fr.ip = target
+			}
+
+		case compiler.OpArray:
+			n := vm.readOperand(fr)
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			err = vm.pushNew(&object.Array{Elements: elements})
+		case compiler.OpTemplate:
+			n := vm.readOperand(fr)
+			var out strings.Builder
+			for _, part := range vm.stack[vm.sp-n : vm.sp] {
+				out.WriteString(part.Inspect())
+			}
+			vm.sp -= n
+			err = vm.pushNew(&object.String{Value: out.String()})
+		case compiler.OpHash:
+			n := vm.readOperand(fr)
+			err = vm.buildHash(n)
+		case compiler.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.push(eval.Index(left, index))
+		case compiler.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			size := vm.budget.Size(left)
+			if err = vm.push(eval.SetIndex(left, index, val)); err == nil {
+				err = vm.budget.Grow(left, size)
+			}
+		case compiler.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+			err = vm.pushNew(eval.Slice(left, low, high))
+
+		case compiler.OpCall:
+			argc := int(ins[fr.ip])
+			fr.ip++
+			err = vm.call(argc, fr.fn.Compiled.Spans[fr.ip-2])
+		case compiler.OpTailCall:
+			argc := int(ins[fr.ip])
+			fr.ip++
+			err = vm.tailCall(fr, argc, fr.fn.Compiled.Spans[fr.ip-2])
+		case compiler.OpReturn:
+			// handlers of returning function are left with it
+			for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].fp == vm.fp {
+				vm.handlers = vm.handlers[:len(vm.handlers)-1]
+			}
+			result := vm.pop()
+			vm.closeUpvalues(fr.base)
+			vm.sp = fr.base
+			vm.fp--
+			if vm.fp == stopAt {
+				return result
+			}
+			vm.push(result)
+		case compiler.OpClosure:
+			err = vm.pushNew(vm.closure(fr, vm.constants[vm.readOperand(fr)].(*object.CompiledFunction)))
+
+		case compiler.OpClass:
+			template := vm.constants[vm.readOperand(fr)].(*object.Class)
+			vm.push(&object.Class{Name: template.Name, Methods: make(map[string]*object.Function)})
+		case compiler.OpInherit:
+			class := vm.pop().(*object.Class)
+			if super, ok := vm.peek().(*object.Class); ok {
+				class.Super = super
+			} else {
+				err = eval.SuperclassMustBeClassError(class.Name.Value, vm.peek().Type())
+			}
+		case compiler.OpMethod:
+			name := vm.readName(fr)
+			method := vm.pop().(*object.Function)
+			class := vm.peek().(*object.Class)
+			method.Class = class
+			class.Methods[name] = method
+		case compiler.OpGetProperty:
+			err = vm.push(eval.GetProperty(vm.pop(), vm.readName(fr)))
+		case compiler.OpSetProperty:
+			name := vm.readName(fr)
+			val := vm.pop()
+			obj := vm.pop()
+			if inst, ok := obj.(*object.Instance); ok {
+				size := vm.budget.Size(inst)
+				inst.Fields[name] = val
+				vm.push(val)
+				err = vm.budget.Grow(inst, size)
+			} else {
+				err = eval.WrongSetTargetError(obj.Type(), name)
+			}
+		case compiler.OpGetSuper:
+			name := vm.readName(fr)
+			super := vm.pop().(*object.Class)
+			this := vm.pop().(*object.Instance)
+			if method := super.FindMethod(name); method != nil {
+				vm.push(method.Bind(this))
+			} else {
+				err = eval.UndefinedPropertyError(name)
+			}
+
+		case compiler.OpIter:
+			items, iterErr := eval.IterationItems(vm.pop())
+			if iterErr != nil {
+				err = iterErr
+			} else {
+				vm.push(&iterator{items: items})
+			}
+		case compiler.OpIterNext:
+			target := vm.readOperand(fr)
+			it := vm.peek().(*iterator)
+			if it.next < len(it.items) {
+				vm.push(it.items[it.next])
+				it.next++
+			} else {
+				fr.ip = target
+			}
+
+		case compiler.OpTry:
+			vm.handlers = append(vm.handlers, handler{fp: vm.fp, sp: vm.sp, target: vm.readOperand(fr)})
+		case compiler.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case compiler.OpThrow:
+			err = eval.Throw(vm.pop())
+		case compiler.OpImport:
+			path := vm.readName(fr)
+			err = vm.importModule(path, fr.fn.Compiled.Spans[fr.ip-1])
+		}
+
+		if err != nil {
+			err = vm.fail(err)
+			if !vm.catch(err, stopAt) {
+				return err
+			}
+		}
+	}
+}
+
+// catch passes err to the innermost handler installed by frames of run
+// which stops at frame stopAt, it reports whether there is such handler.
+func (vm *VM) catch(err *object.Error, stopAt int) bool {
+	if len(vm.handlers) == 0 {
+		return false
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	if h.fp <= stopAt {
+		return false
+	}
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.closeUpvalues(h.sp)
+	vm.sp, vm.fp = h.sp, h.fp
+	vm.frames[vm.fp-1].ip = h.target
+	vm.push(&object.Exception{Error: err})
+	return true
+}
+
+func (vm *VM) invokeBuiltin(fn *object.Builtin, args []object.Object, callSite token.Span) object.Object {
+	if fn.Cancellable != nil {
+		result := fn.Cancellable(vm.budget.Context(), args...)
+		// interrupted builtin fails the run, not only the call
+		if err := vm.budget.Interrupted(); err != nil {
+			return err
+		}
+		return result
+	}
+	if fn.Output != nil {
+		return fn.Output(vm.stdout, args...)
+	}
+	if fn.HigherOrder != nil {
+		return fn.HigherOrder(vm.callback(callSite), args...)
+	}
+	return fn.Fn(args...)
+}
+
+// tailCall replaces function running in fr with the called one, so calls
+// returned by functions do not nest. Other callees are called as usual.
+func (vm *VM) tailCall(fr *frame, argc int, callSite token.Span) *object.Error {
+	slot := vm.sp - 1 - argc
+	callee, ok := vm.stack[slot].(*object.Function)
+	if !ok || callee.Compiled.NumParams != argc {
+		return vm.call(argc, callSite)
+	}
+
+	if callee.Receiver != nil {
+		vm.stack[slot] = callee.Receiver
+	}
+	vm.closeUpvalues(fr.base)
+	copy(vm.stack[fr.base:], vm.stack[slot:vm.sp])
+	vm.sp = fr.base + 1 + argc
+	*fr = frame{fn: callee, base: fr.base, info: newFrame(callee, callSite)}
+	return nil
+}
+
+func (vm *VM) call(argc int, callSite token.Span) *object.Error {
+	slot := vm.sp - 1 - argc
+
+	switch callee := vm.stack[slot].(type) {
+	case *object.Function:
+		if callee.Compiled.NumParams != argc {
+			return eval.WrongArgumentsCountError(callee.Compiled.NumParams, argc)
+		}
+		if callee.Receiver != nil {
+			vm.stack[slot] = callee.Receiver
+		}
+		return vm.pushFrame(callee, slot, newFrame(callee, callSite))
+
+	case *object.Builtin:
+		args := make([]object.Object, argc)
+		copy(args, vm.stack[slot+1:vm.sp])
+		vm.sp = slot
+		return vm.push(vm.budget.CallBuiltin(callee, args, func() object.Object {
+			return vm.invokeBuiltin(callee, args, callSite)
+		}))
+
+	case *object.Class:
+		init := callee.FindMethod(token.INITIALIZER_KEYWORD)
+
+		expectArgs := 0
+		if init != nil {
+			expectArgs = init.Compiled.NumParams
+		}
+
+		if argc != expectArgs {
+			return eval.WrongArgumentsCountError(expectArgs, argc)
+		}
+
+		inst := &object.Instance{
+			Class:  callee,
+			Fields: make(map[string]object.Object),
+		}
+		vm.stack[slot] = inst
+		if err := vm.budget.Allocate(inst); err != nil {
+			return err
+		}
+
+		if init != nil {
+			info := object.Frame{
+				Class:    callee.Name.Value,
+				Function: token.INITIALIZER_KEYWORD,
+				CallSite: callSite,
+			}
+			return vm.pushFrame(init, slot, info)
+		}
+
+		vm.sp = slot + 1
+		return nil
+
+	default:
+		return eval.NotAFunctionError(callee)
+	}
+}
+
+// callback lets builtins call functions of the program, the calls
+// appear on the call stack as made from callSite.
+func (vm *VM) callback(callSite token.Span) object.CallFunction {
+	return func(fn object.Object, args ...object.Object) object.Object {
+		base, depth := vm.sp, vm.fp
+
+		vm.push(fn)
+		for _, arg := range args {
+			vm.push(arg)
+		}
+
+		if err := vm.call(len(args), callSite); err != nil {
+			vm.sp = base
+			return err
+		}
+
+		// builtins and classes without initializer are already done
+		if vm.fp == depth {
+			return vm.pop()
+		}
+
+		result := vm.run(depth)
+		if _, ok := result.(*object.Error); ok {
+			vm.sp, vm.fp = base, depth
+		}
+		return result
+	}
+}
+
+// importModule pushes module imported at importSite, modules run once
+// and they are cached by absolute path.
+func (vm *VM) importModule(path string, importSite token.Span) *object.Error {
+	if err := eval.ImportPermission(vm.granted); err != nil {
+		return err
+	}
+
+	path, key, err := eval.ModulePath(path, importSite.Start.File)
+	if err != nil {
+		return err
+	}
+
+	if module, ok := vm.modules[key]; ok {
+		return vm.push(module)
+	}
+	if err := eval.ImportCycle(vm.importing, key); err != nil {
+		return err
+	}
+
+	program, err := eval.ParseModule(path)
+	if err != nil {
+		return err
+	}
+
+	c := compiler.NewModule(&compiler.Bytecode{Constants: vm.constants, Globals: vm.globalNames})
+	if err := c.Compile(program); err != nil {
+		return eval.ImportError(path, err.Error())
+	}
+	bytecode := c.Bytecode()
+	vm.constants, vm.globalNames = bytecode.Constants, bytecode.Globals
+	vm.globals = append(vm.globals, make([]object.Object, len(vm.globalNames)-len(vm.globals))...)
+
+	main := &object.Function{Name: eval.MODULE_FRAME_NAME, Compiled: bytecode.Main}
+	last := vm.last
+
+	vm.importing = append(vm.importing, key)
+	result := vm.callback(importSite)(main)
+	vm.importing = vm.importing[:len(vm.importing)-1]
+
+	vm.last = last
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+
+	module := &object.Module{Path: path, Lookup: func(name string) (object.Object, bool) {
+		idx, ok := c.Global(name)
+		if !ok || vm.globals[idx] == nil {
+			return nil, false
+		}
+		return vm.globals[idx], true
+	}}
+	vm.modules[key] = module
+	return vm.push(module)
+}
+
+func newFrame(fn *object.Function, callSite token.Span) object.Frame {
+	frame := object.Frame{Function: "<anonymous>", CallSite: callSite}
+	if fn.Name != "" {
+		frame.Function = fn.Name
+	}
+	if fn.Class != nil {
+		frame.Class = fn.Class.Name.Value
+	}
+	return frame
+}
+
+func (vm *VM) pushFrame(fn *object.Function, base int, info object.Frame) *object.Error {
+	if vm.fp == len(vm.frames) {
+		return eval.StackOverflowError()
+	}
+
+	vm.frames[vm.fp] = frame{fn: fn, base: base, info: info}
+	vm.fp++
+	return nil
+}
+
+func (vm *VM) closure(fr *frame, compiled *object.CompiledFunction) *object.Function {
+	fn := &object.Function{
+		Name:       compiled.Name,
+		Parameters: compiled.Parameters,
+		Body:       compiled.Body,
+		IsInit:     compiled.IsInit,
+		Compiled:   compiled,
+		Upvalues:   make([]*object.Upvalue, len(compiled.Captures)),
+	}
+
+	for i, capture := range compiled.Captures {
+		if capture.Local {
+			fn.Upvalues[i] = vm.captureUpvalue(fr.base + capture.Index)
+		} else {
+			fn.Upvalues[i] = fr.fn.Upvalues[capture.Index]
+		}
+	}
+
+	return fn
+}
+
+// captureUpvalue returns upvalue for stack slot, closures capturing
+// the same variable share a single upvalue.
+func (vm *VM) captureUpvalue(slot int) *object.Upvalue {
+	for _, open := range vm.openUpvalues {
+		if open.slot == slot {
+			return open.upvalue
+		}
+	}
+
+	upvalue := &object.Upvalue{Location: &vm.stack[slot]}
+	vm.openUpvalues = append(vm.openUpvalues, upvalueSlot{slot: slot, upvalue: upvalue})
+	return upvalue
+}
+
+// closeUpvalues moves variables living in slots from given one and above
+// off the stack.
+func (vm *VM) closeUpvalues(from int) {
+	open := vm.openUpvalues[:0]
+	for _, u := range vm.openUpvalues {
+		if u.slot >= from {
+			u.upvalue.Close()
+		} else {
+			open = append(open, u)
+		}
+	}
+	vm.openUpvalues = open
+}
+
+func (vm *VM) buildHash(n int) *object.Error {
+	hash := &object.Hash{}
+
+	for i := vm.sp - 2*n; i < vm.sp; i += 2 {
+		key, val := vm.stack[i], vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return eval.NotHashableKeyError(key.Type())
+		}
+
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: val})
+	}
+
+	vm.sp -= 2 * n
+	return vm.pushNew(hash)
+}
+
+// fail attaches position of current instruction and active calls to err.
+func (vm *VM) fail(err *object.Error) *object.Error {
+	// errors from functions called back by builtins are already located
+	if err.Span.IsValid() {
+		return err
+	}
+
+	fr := vm.frames[vm.fp-1]
+
+	// ip already points past the operands of the failed instruction,
+	// all its bytes share the same span
+	err.Span = fr.fn.Compiled.Spans[fr.ip-1]
+
+	err.Stack = make([]object.Frame, 0, vm.fp-1)
+	for _, f := range vm.frames[1:vm.fp] {
+		err.Stack = append(err.Stack, f.info)
+	}
+
+	return err
+}
+
+func (vm *VM) readOperand(fr *frame) int {
+	operand := int(compiler.ReadUint16(fr.fn.Compiled.Instructions[fr.ip:]))
+	fr.ip += 2
+	return operand
+}
+
+func (vm *VM) readName(fr *frame) string {
+	return vm.constants[vm.readOperand(fr)].(*object.String).Value
+}
+
+// push puts obj on the stack, runtime errors produced by operations
+// are returned instead.
+func (vm *VM) push(obj object.Object) *object.Error {
+	if err, ok := obj.(*object.Error); ok {
+		return err
+	}
+
+	if vm.sp >= StackSize {
+		panic(stackOverflow{})
+	}
+
+	vm.stack[vm.sp] = obj
+	vm.sp++
+	return nil
+}
+
+// pushNew pushes obj created by the program, it accounts it in budget.
+func (vm *VM) pushNew(obj object.Object) *object.Error {
+	if err := vm.push(obj); err != nil {
+		return err
+	}
+	return vm.budget.Allocate(obj)
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) peek() object.Object {
+	return vm.stack[vm.sp-1]
+}
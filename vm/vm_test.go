@@ -0,0 +1,348 @@
+package vm_test
+
+import (
+	"context"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/eval/evaltest"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/vm"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	for _, tc := range evaltest.Cases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := runSource(t, tc.Source)
+
+			if got == nil {
+				t.Errorf("Error while running %q, got nil, want '%v'.", tc.Source, tc.Want)
+			}
+
+			evaltest.CheckObject(t, got, tc.Want)
+		})
+	}
+}
+
+func TestRuntimeErrorHandling(t *testing.T) {
+	for _, tc := range evaltest.ErrorCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := runSource(t, tc.Source)
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+			if err.Message != tc.Want {
+				t.Errorf("Wrong error message, got %q, want %q.", err.Message, tc.Want)
+			}
+		})
+	}
+}
+
+func TestRuntimeErrorPosition(t *testing.T) {
+	for _, tc := range evaltest.PositionCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := runSource(t, tc.Source)
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+			if err.Span.String() != tc.Want {
+				t.Errorf("Wrong error position, got %s, want %s.", err.Span, tc.Want)
+			}
+		})
+	}
+}
+
+func TestUncaughtException(t *testing.T) {
+	for _, tc := range evaltest.ExceptionCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := runSource(t, tc.Source)
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+			if err.Kind != tc.Kind {
+				t.Errorf("Wrong error kind, got %q, want %q.", err.Kind, tc.Kind)
+			}
+			if err.Message != tc.Message {
+				t.Errorf("Wrong error message, got %q, want %q.", err.Message, tc.Message)
+			}
+			if err.Span.String() != tc.Span {
+				t.Errorf("Wrong error position, got %s, want %s.", err.Span, tc.Span)
+			}
+		})
+	}
+}
+
+func TestRuntimeErrorStack(t *testing.T) {
+	for _, tc := range evaltest.StackCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := runSource(t, tc.Source)
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+
+			names := []string{}
+			for _, frame := range err.Stack {
+				names = append(names, frame.Name())
+			}
+
+			if strings.Join(names, " ") != strings.Join(tc.Want, " ") {
+				t.Errorf("Wrong error stack, got %v, want %v.", names, tc.Want)
+			}
+		})
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	for _, tc := range evaltest.TailCallCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			evaltest.CheckObject(t, runSource(t, tc.Source), tc.Want)
+		})
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	got := runSource(t, "fn f(n) { f(n + 1) } f(0);")
+
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("No error object returned, got %T (%+v).", got, got)
+	}
+	if err.Message != vm.ERR_STACK_OVERFLOW {
+		t.Errorf("Wrong error message, got %q, want %q.", err.Message, vm.ERR_STACK_OVERFLOW)
+	}
+	if err.Kind != eval.RECURSION_ERROR {
+		t.Errorf("Wrong error kind, got %q, want %q.", err.Kind, eval.RECURSION_ERROR)
+	}
+}
+
+func TestMaxCallDepth(t *testing.T) {
+	source := "fn f(n) { if (n == 0) { return 0; } 1 + f(n - 1) }" +
+		" let a = f(99); let b = null; try { f(100); } catch (e) { b = e.kind; } b;"
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("Error while compiling %q: %s.", source, err)
+	}
+
+	machine := vm.New(c.Bytecode())
+	machine.SetMaxCallDepth(100)
+
+	evaltest.CheckObject(t, machine.Run(), eval.RECURSION_ERROR)
+}
+
+func TestLimits(t *testing.T) {
+	for _, tc := range evaltest.LimitCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			machine := newMachine(t, tc.Source)
+			machine.SetLimits(tc.Limits)
+
+			got := machine.Run()
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+			if err.Kind != tc.Kind {
+				t.Errorf("Wrong error kind, got %q, want %q.", err.Kind, tc.Kind)
+			}
+			if err.Message != tc.Message {
+				t.Errorf("Wrong error message, got %q, want %q.", err.Message, tc.Message)
+			}
+		})
+	}
+}
+
+func TestBuiltinMemoryCheckedBeforeAllocation(t *testing.T) {
+	// string literals are constants, they are not allocated by the run
+	tt := []struct {
+		source string
+		memory int64
+	}{
+		{`repeat("ab", 268435456)`, 0},
+		{`split(repeat(",", 1048576), ",")`, 16 + 1048576},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.source, func(t *testing.T) {
+			machine := newMachine(t, tc.source)
+			machine.SetLimits(eval.Limits{MaxMemory: 1 << 22})
+
+			got := machine.Run()
+
+			err, ok := got.(*object.Error)
+			if !ok || err.Kind != eval.MEMORY_ERROR {
+				t.Fatalf("No memory error returned, got %T (%+v).", got, got)
+			}
+			if memory := machine.Usage().Memory; memory != tc.memory {
+				t.Errorf("Result of builtin accounted, got %d bytes, want %d.", memory, tc.memory)
+			}
+		})
+	}
+}
+
+func TestRunContextCancel(t *testing.T) {
+	machine := newMachine(t, "fn f() { while (true) {} } f();")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	got := machine.RunContext(ctx)
+
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("No error object returned, got %T (%+v).", got, got)
+	}
+	if err.Kind != eval.CANCELLED_ERROR {
+		t.Errorf("Wrong error kind, got %q, want %q.", err.Kind, eval.CANCELLED_ERROR)
+	}
+	if len(err.Stack) != 1 || err.Stack[0].Name() != "f" {
+		t.Errorf("Wrong error stack, got %v.", err.Stack)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	source := `try { getEnv("HOME"); } catch (e) { e.kind; }`
+	evaltest.CheckObject(t, runSource(t, source), eval.PERMISSION_ERROR)
+
+	t.Setenv("MONKEY_TEST_VAR", "banana")
+	p := parser.New(lexer.New(`getEnv("MONKEY_TEST_VAR")`))
+	program := p.ParseProgram()
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("Error while compiling: %s.", err)
+	}
+
+	machine := vm.New(c.Bytecode())
+	machine.SetCapabilities(eval.ENV)
+
+	evaltest.CheckObject(t, machine.Run(), "banana")
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	evaltest.WriteModules(t, dir)
+
+	for _, tc := range evaltest.ImportCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := runFile(t, filepath.Join(dir, "main.mk"), tc.Source)
+			evaltest.CheckObject(t, got, tc.Want)
+		})
+	}
+
+	for _, tc := range evaltest.ImportErrorCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			got := runFile(t, filepath.Join(dir, "main.mk"), tc.Source)
+
+			err, ok := got.(*object.Error)
+			if !ok {
+				t.Fatalf("No error object returned, got %T (%+v).", got, got)
+			}
+			if !strings.Contains(err.Message, tc.Want) {
+				t.Errorf("Wrong error message, got %q, want it to contain %q.", err.Message, tc.Want)
+			}
+		})
+	}
+}
+
+func TestImportPermission(t *testing.T) {
+	dir := t.TempDir()
+	evaltest.WriteModules(t, dir)
+
+	for _, tc := range evaltest.DeniedImportCases {
+		t.Run(tc.Source, func(t *testing.T) {
+			p := parser.New(lexer.NewFile(filepath.Join(dir, "main.mk"), tc.Source))
+			c := compiler.New()
+			if err := c.Compile(p.ParseProgram()); err != nil {
+				t.Fatalf("Error while compiling %q: %s.", tc.Source, err)
+			}
+
+			machine := vm.New(c.Bytecode())
+			machine.SetCapabilities(eval.PURE, eval.STDOUT)
+
+			evaltest.CheckObject(t, machine.Run(), tc.Want)
+		})
+	}
+}
+
+func TestImportErrorStack(t *testing.T) {
+	dir := t.TempDir()
+	evaltest.WriteModules(t, dir)
+
+	got := runFile(t, filepath.Join(dir, "main.mk"), `import "throwing.mk" as lib;`)
+
+	err, ok := got.(*object.Error)
+	if !ok {
+		t.Fatalf("No error object returned, got %T (%+v).", got, got)
+	}
+
+	if err.Span.Start.File != filepath.Join(dir, "throwing.mk") || err.Span.Start.Line != 2 {
+		t.Errorf("Wrong error position, got %s.", err.Span)
+	}
+
+	if len(err.Stack) != 1 || err.Stack[0].Name() != eval.MODULE_FRAME_NAME {
+		t.Errorf("Wrong error stack, got %v.", err.Stack)
+	}
+}
+
+func runSource(t testing.TB, source string) object.Object {
+	t.Helper()
+	return runFile(t, "", source)
+}
+
+func runFile(t testing.TB, file string, source string) object.Object {
+	t.Helper()
+
+	p := parser.New(lexer.NewFile(file, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Error while parsing %q.", source)
+	}
+
+	r := resolver.New()
+	r.Resolve(program)
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("Error while compiling %q: %s.", source, err)
+	}
+
+	machine := vm.New(c.Bytecode())
+	machine.SetCapabilities(append([]eval.Capability{eval.FILESYSTEM}, eval.DefaultCapabilities...)...)
+	return machine.Run()
+}
+
+func newMachine(t testing.TB, source string) *vm.VM {
+	t.Helper()
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Error while parsing %q.", source)
+	}
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("Error while compiling %q: %s.", source, err)
+	}
+
+	return vm.New(c.Bytecode())
+}